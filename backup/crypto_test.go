@@ -0,0 +1,67 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestParseKey(t *testing.T) {
+	raw := bytes.Repeat([]byte{0xab}, 32)
+	tests := []struct {
+		name, in string
+		want     []byte
+		ok       bool
+	}{
+		{"empty", "  ", nil, true},
+		{"hex", hex.EncodeToString(raw), raw, true},
+		{"base64", base64.StdEncoding.EncodeToString(raw), raw, true},
+		{"raw url base64", base64.RawURLEncoding.EncodeToString(raw), raw, true},
+		{"short", hex.EncodeToString(raw[:16]), nil, false},
+		{"garbage", "not a key", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseKey(tt.in)
+		if (err == nil) != tt.ok || !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: ParseKey = %x, %v", tt.name, got, err)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	plain := []byte("SQLite format 3\x00 snapshot")
+	sealed, err := Encrypt(key, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(sealed) || bytes.Contains(sealed, plain) {
+		t.Fatal("output is not an encrypted backup")
+	}
+	got, err := Decrypt(key, sealed)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("Decrypt = %q, %v", got, err)
+	}
+
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 1
+	tests := []struct {
+		name string
+		key  []byte
+		data []byte
+		err  string
+	}{
+		{"wrong key", bytes.Repeat([]byte{2}, 32), sealed, "wrong key"},
+		{"tampered", key, tampered, "wrong key"},
+		{"truncated", key, sealed[:len(encryptedMagic)+4], "truncated"},
+		{"plain", key, plain, "not encrypted"},
+		{"no key", nil, sealed, "not configured"},
+	}
+	for _, tt := range tests {
+		if _, err := Decrypt(tt.key, tt.data); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
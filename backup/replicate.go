@@ -0,0 +1,129 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func (m *Manager) SetTargets(targets []Target, key []byte) {
+	m.targets = targets
+	m.key = key
+}
+
+func (m *Manager) Targets() []Target {
+	return m.targets
+}
+
+func (m *Manager) target(name string) Target {
+	for _, t := range m.targets {
+		if t.Name() == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func (m *Manager) objectName(name string) string {
+	if m.key != nil {
+		return name + ".enc"
+	}
+	return name
+}
+
+func (m *Manager) CheckTargets(ctx context.Context) map[string]error {
+	results := make(map[string]error, len(m.targets))
+	for _, t := range m.targets {
+		results[t.Name()] = t.Check(ctx)
+	}
+	return results
+}
+
+func (m *Manager) replicate(snap *Snapshot, removed []string) {
+	data, err := os.ReadFile(filepath.Join(m.dir, snap.Name))
+	if err != nil {
+		log.Printf("failed to read snapshot %s for upload: %v", snap.Name, err)
+		return
+	}
+	if m.key != nil {
+		data, err = Encrypt(m.key, data)
+		if err != nil {
+			log.Printf("failed to encrypt snapshot %s: %v", snap.Name, err)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	var uploaded []string
+	for _, t := range m.targets {
+		if err := t.Put(ctx, m.objectName(snap.Name), data); err != nil {
+			log.Printf("backup upload of %s to %s failed: %v", snap.Name, t.Name(), err)
+			continue
+		}
+		uploaded = append(uploaded, t.Name())
+		for _, name := range removed {
+			if err := t.Delete(ctx, m.objectName(name)); err != nil {
+				log.Printf("failed to remove expired backup %s from %s: %v", name, t.Name(), err)
+			}
+		}
+	}
+	snap.Targets = uploaded
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	manifest, err := m.loadManifest()
+	if err != nil {
+		log.Printf("failed to record uploads for %s: %v", snap.Name, err)
+		return
+	}
+	for i := range manifest.Snapshots {
+		if manifest.Snapshots[i].Name == snap.Name {
+			manifest.Snapshots[i].Targets = uploaded
+		}
+	}
+	if err := m.saveManifest(manifest); err != nil {
+		log.Printf("failed to record uploads for %s: %v", snap.Name, err)
+	}
+}
+
+func (m *Manager) ListRemote(ctx context.Context) ([]Snapshot, map[string]string) {
+	var list []Snapshot
+	errs := map[string]string{}
+	for _, t := range m.targets {
+		objects, err := t.List(ctx)
+		if err != nil {
+			errs[t.Name()] = err.Error()
+			continue
+		}
+		for _, o := range objects {
+			o.Source = t.Name()
+			list = append(list, o)
+		}
+	}
+	return list, errs
+}
+
+func (m *Manager) Fetch(ctx context.Context, source, name, dest string) error {
+	t := m.target(source)
+	if t == nil {
+		return fmt.Errorf("unknown backup target %q", source)
+	}
+	data, err := t.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	if IsEncrypted(data) {
+		if m.key == nil {
+			return fmt.Errorf("backup %s is encrypted but no BACKUP_ENCRYPTION_KEY is configured", name)
+		}
+		data, err = Decrypt(m.key, data)
+		if err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(dest, data, 0600)
+}
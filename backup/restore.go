@@ -0,0 +1,172 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"exunreg25/db"
+)
+
+var requiredTables = []string{"users", "events", "registrations"}
+
+type RestoreResult struct {
+	Restored       string           `json:"restored"`
+	SafetySnapshot string           `json:"safety_snapshot"`
+	Tables         map[string]int64 `json:"tables"`
+}
+
+func (m *Manager) Available() ([]Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	manifest, err := m.loadManifest()
+	if err != nil {
+		return nil, err
+	}
+	tracked := map[string]bool{}
+	var list []Snapshot
+	for _, s := range manifest.Snapshots {
+		s.Source = "local"
+		tracked[s.Name] = true
+		list = append(list, s)
+	}
+	entries, err := os.ReadDir(m.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".db") || tracked[e.Name()] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		list = append(list, Snapshot{
+			Name:      e.Name(),
+			Source:    "local",
+			CreatedAt: info.ModTime().UTC(),
+			Trigger:   "untracked",
+			Size:      info.Size(),
+		})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+func (m *Manager) Validate(name string) (*Snapshot, error) {
+	path, err := m.Path(name)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := validateFile(path)
+	if err != nil {
+		return nil, err
+	}
+	snap.Name = name
+	snap.Source = "local"
+
+	manifest, err := m.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range manifest.Snapshots {
+		if s.Name == name && s.SHA256 != "" && s.SHA256 != snap.SHA256 {
+			return nil, fmt.Errorf("snapshot %s does not match its manifest checksum", name)
+		}
+	}
+	return snap, nil
+}
+
+func validateFile(path string) (*Snapshot, error) {
+	tables, err := db.VerifySnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range requiredTables {
+		if _, ok := tables[t]; !ok {
+			return nil, fmt.Errorf("snapshot is missing required table %s", t)
+		}
+	}
+	sum, size, err := fileChecksum(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{
+		Name:      filepath.Base(path),
+		CreatedAt: info.ModTime().UTC(),
+		Size:      size,
+		SHA256:    sum,
+		Integrity: "ok",
+		Tables:    tables,
+	}, nil
+}
+
+func (m *Manager) Restore(name string) (*RestoreResult, error) {
+	if _, err := m.Validate(name); err != nil {
+		return nil, err
+	}
+	path, _ := m.Path(name)
+	return m.RestoreFile(path)
+}
+
+func (m *Manager) RestoreFile(path string) (*RestoreResult, error) {
+	snap, err := validateFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m.database.Pause()
+	defer m.database.Resume()
+	safety, err := m.TakeSnapshot("pre-restore")
+	if err != nil {
+		return nil, fmt.Errorf("failed to take pre-restore snapshot: %v", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	trail, err := m.database.AuditEventsAfter(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit trail: %v", err)
+	}
+	if err := m.database.RestoreFrom(path); err != nil {
+		return nil, err
+	}
+	if err := m.database.InitTables(); err != nil {
+		return nil, fmt.Errorf("restore succeeded but migrations failed: %v", err)
+	}
+	if _, err := m.database.CarryAuditEvents(trail); err != nil {
+		return nil, fmt.Errorf("restore succeeded but carrying the audit trail forward failed: %v", err)
+	}
+	if err := m.database.ResetSyncCursors(); err != nil {
+		return nil, fmt.Errorf("restore succeeded but resetting sync cursors failed: %v", err)
+	}
+	return &RestoreResult{
+		Restored:       snap.Name,
+		SafetySnapshot: safety.Name,
+		Tables:         snap.Tables,
+	}, nil
+}
+
+func (m *Manager) DiffFile(path string, opts db.DiffOptions) ([]db.TableDiff, error) {
+	return m.database.DiffSnapshot(path, opts)
+}
+
+func (m *Manager) RestoreRowsFile(path, table string, keys []string) (map[string]int64, string, error) {
+	if _, err := validateFile(path); err != nil {
+		return nil, "", err
+	}
+	m.database.Pause()
+	defer m.database.Resume()
+	safety, err := m.TakeSnapshot("pre-restore")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to take pre-restore snapshot: %v", err)
+	}
+	n, err := m.database.RestoreRowsFromSnapshot(path, table, keys)
+	return n, safety.Name, err
+}
@@ -0,0 +1,287 @@
+package backup
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"exunreg25/db"
+)
+
+const manifestFile = "manifest.json"
+
+type Retention struct {
+	Hourly int `json:"hourly"`
+	Daily  int `json:"daily"`
+	Weekly int `json:"weekly"`
+}
+
+type Snapshot struct {
+	Name      string           `json:"name"`
+	Source    string           `json:"source,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+	Trigger   string           `json:"trigger"`
+	Size      int64            `json:"size"`
+	SHA256    string           `json:"sha256"`
+	Integrity string           `json:"integrity"`
+	Tables    map[string]int64 `json:"tables"`
+	Keep      []string         `json:"keep,omitempty"`
+	Targets   []string         `json:"targets,omitempty"`
+}
+
+type Manifest struct {
+	Database  string     `json:"database"`
+	Retention Retention  `json:"retention"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+type Manager struct {
+	database  *db.Database
+	dbPath    string
+	dir       string
+	retention Retention
+	targets   []Target
+	key       []byte
+	mu        sync.Mutex
+}
+
+func NewManager(database *db.Database, dbPath, dir string, retention Retention) *Manager {
+	return &Manager{
+		database:  database,
+		dbPath:    dbPath,
+		dir:       dir,
+		retention: retention,
+	}
+}
+
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+func (m *Manager) Path(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || !strings.HasSuffix(name, ".db") {
+		return "", fmt.Errorf("invalid snapshot name %q", name)
+	}
+	return filepath.Join(m.dir, name), nil
+}
+
+func (m *Manager) TakeSnapshot(trigger string) (*Snapshot, error) {
+	snap, removed, err := m.takeLocal(trigger)
+	if err != nil {
+		return nil, err
+	}
+	if trigger != "pre-restore" && len(m.targets) > 0 {
+		m.replicate(snap, removed)
+	}
+	return snap, nil
+}
+
+func (m *Manager) takeLocal(trigger string) (*Snapshot, []string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	now := time.Now().UTC()
+	base := strings.TrimSuffix(filepath.Base(m.dbPath), filepath.Ext(m.dbPath))
+	stamp := now.Format("20060102-150405")
+	name := fmt.Sprintf("%s-%s.db", base, stamp)
+	path := filepath.Join(m.dir, name)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("%s-%s-%d.db", base, stamp, i)
+		path = filepath.Join(m.dir, name)
+	}
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+
+	if err := m.database.SnapshotTo(tmp); err != nil {
+		return nil, nil, err
+	}
+	tables, err := db.VerifySnapshot(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return nil, nil, err
+	}
+	sum, size, err := fileChecksum(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return nil, nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, nil, err
+	}
+
+	snap := Snapshot{
+		Name:      name,
+		CreatedAt: now,
+		Trigger:   trigger,
+		Size:      size,
+		SHA256:    sum,
+		Integrity: "ok",
+		Tables:    tables,
+	}
+
+	manifest, err := m.loadManifest()
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest.Snapshots = append(manifest.Snapshots, snap)
+	var removed []string
+	if trigger != "pre-restore" {
+		removed = m.prune(manifest)
+	}
+	if err := m.saveManifest(manifest); err != nil {
+		return nil, nil, err
+	}
+	for _, name := range removed {
+		if err := os.Remove(filepath.Join(m.dir, name)); err != nil && !os.IsNotExist(err) {
+			log.Printf("failed to remove expired snapshot %s: %v", name, err)
+		}
+	}
+
+	for _, s := range manifest.Snapshots {
+		if s.Name == name {
+			snap = s
+		}
+	}
+	return &snap, removed, nil
+}
+
+func (m *Manager) List() ([]Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	manifest, err := m.loadManifest()
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Snapshots, nil
+}
+
+func (m *Manager) Manifest() (*Manifest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.loadManifest()
+}
+
+func (m *Manager) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if m.database.Paused() {
+			continue
+		}
+		snap, err := m.TakeSnapshot("scheduled")
+		if err != nil {
+			log.Printf("scheduled snapshot error: %v", err)
+			continue
+		}
+		log.Printf("scheduled snapshot %s (%d bytes)", snap.Name, snap.Size)
+	}
+}
+
+func (m *Manager) prune(manifest *Manifest) []string {
+	snaps := manifest.Snapshots
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].CreatedAt.After(snaps[j].CreatedAt) })
+
+	keep := make(map[string][]string)
+	hours := map[string]bool{}
+	days := map[string]bool{}
+	weeks := map[string]bool{}
+	for _, s := range snaps {
+		t := s.CreatedAt
+		hour := t.Format("2006010215")
+		if !hours[hour] && len(hours) < m.retention.Hourly {
+			hours[hour] = true
+			keep[s.Name] = append(keep[s.Name], "hourly")
+		}
+		day := t.Format("20060102")
+		if !days[day] && len(days) < m.retention.Daily {
+			days[day] = true
+			keep[s.Name] = append(keep[s.Name], "daily")
+		}
+		y, w := t.ISOWeek()
+		week := fmt.Sprintf("%d-%02d", y, w)
+		if !weeks[week] && len(weeks) < m.retention.Weekly {
+			weeks[week] = true
+			keep[s.Name] = append(keep[s.Name], "weekly")
+		}
+	}
+
+	if len(snaps) > 0 && len(keep[snaps[0].Name]) == 0 {
+		keep[snaps[0].Name] = []string{"latest"}
+	}
+
+	var kept []Snapshot
+	var removed []string
+	for _, s := range snaps {
+		if len(keep[s.Name]) == 0 {
+			removed = append(removed, s.Name)
+			continue
+		}
+		s.Keep = keep[s.Name]
+		kept = append(kept, s)
+	}
+	manifest.Snapshots = kept
+	return removed
+}
+
+func (m *Manager) loadManifest() (*Manifest, error) {
+	manifest := &Manifest{}
+	b, err := os.ReadFile(filepath.Join(m.dir, manifestFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			manifest.Database = filepath.Base(m.dbPath)
+			manifest.Retention = m.retention
+			return manifest, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, manifest); err != nil {
+		return nil, fmt.Errorf("invalid backup manifest: %v", err)
+	}
+	return manifest, nil
+}
+
+func (m *Manager) saveManifest(manifest *Manifest) error {
+	manifest.Database = filepath.Base(m.dbPath)
+	manifest.Retention = m.retention
+	manifest.UpdatedAt = time.Now().UTC()
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(m.dir, manifestFile)
+	if err := os.WriteFile(path+".tmp", b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func fileChecksum(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), n, nil
+}
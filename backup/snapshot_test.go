@@ -0,0 +1,106 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"exunreg25/db"
+)
+
+func TestPrune(t *testing.T) {
+	base := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) Snapshot {
+		return Snapshot{Name: base.Add(d).Format("0102-1504"), CreatedAt: base.Add(d)}
+	}
+	tests := []struct {
+		name      string
+		retention Retention
+		snaps     []Snapshot
+		kept      []string
+	}{
+		{
+			name:      "one per hour",
+			retention: Retention{Hourly: 2},
+			snaps:     []Snapshot{at(0), at(10 * time.Minute), at(time.Hour), at(2 * time.Hour)},
+			kept:      []string{"1014-1100", "1014-1000"},
+		},
+		{
+			name:      "daily keeps the newest of each day",
+			retention: Retention{Daily: 2},
+			snaps:     []Snapshot{at(0), at(time.Hour), at(24 * time.Hour), at(48 * time.Hour)},
+			kept:      []string{"1016-0900", "1015-0900"},
+		},
+		{
+			name:      "latest is always kept",
+			retention: Retention{},
+			snaps:     []Snapshot{at(0), at(time.Hour)},
+			kept:      []string{"1014-1000"},
+		},
+		{
+			name:      "weekly reaches back past the daily window",
+			retention: Retention{Daily: 1, Weekly: 2},
+			snaps:     []Snapshot{at(0), at(24 * time.Hour), at(8 * 24 * time.Hour)},
+			kept:      []string{"1022-0900", "1015-0900"},
+		},
+	}
+	for _, tt := range tests {
+		m := &Manager{retention: tt.retention}
+		manifest := &Manifest{Snapshots: tt.snaps}
+		removed := m.prune(manifest)
+		var kept []string
+		for _, s := range manifest.Snapshots {
+			kept = append(kept, s.Name)
+		}
+		if !reflect.DeepEqual(kept, tt.kept) {
+			t.Errorf("%s: kept %v, want %v", tt.name, kept, tt.kept)
+		}
+		if len(kept)+len(removed) != len(tt.snaps) {
+			t.Errorf("%s: kept %d and removed %d of %d", tt.name, len(kept), len(removed), len(tt.snaps))
+		}
+	}
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "exun.db")
+	database, err := db.NewConnection(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if err := database.InitTables(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec(`INSERT INTO events (id, name) VALUES ('quiz', 'Quiz')`); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewManager(database, dbPath, filepath.Join(dir, "backups"), Retention{Hourly: 24})
+	snap, err := m.TakeSnapshot("manual")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Integrity != "ok" || snap.Tables["events"] != 1 || snap.SHA256 == "" {
+		t.Errorf("snapshot = %+v", snap)
+	}
+	path, err := m.Path(snap.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != snap.Size {
+		t.Errorf("snapshot file: %v", err)
+	}
+	list, err := m.List()
+	if err != nil || len(list) != 1 || list[0].Name != snap.Name {
+		t.Errorf("manifest lists %+v, %v", list, err)
+	}
+
+	for _, name := range []string{"", "../exun.db", "manifest.json", "sub/exun.db"} {
+		if _, err := m.Path(name); err == nil {
+			t.Errorf("Path(%q) was accepted", name)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type Target interface {
+	Name() string
+	Check(ctx context.Context) error
+	Put(ctx context.Context, name string, data []byte) error
+	Get(ctx context.Context, name string) ([]byte, error)
+	List(ctx context.Context) ([]Snapshot, error)
+	Delete(ctx context.Context, name string) error
+}
+
+type LocalTarget struct {
+	dir string
+}
+
+func NewLocalTarget(dir string) *LocalTarget {
+	return &LocalTarget{dir: dir}
+}
+
+func (t *LocalTarget) Name() string {
+	return "dir"
+}
+
+func (t *LocalTarget) path(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) {
+		return "", fmt.Errorf("invalid object name %q", name)
+	}
+	return filepath.Join(t.dir, name), nil
+}
+
+func (t *LocalTarget) Check(ctx context.Context) error {
+	if err := os.MkdirAll(t.dir, 0755); err != nil {
+		return err
+	}
+	probe := filepath.Join(t.dir, ".write-check")
+	if err := os.WriteFile(probe, []byte("ok"), 0644); err != nil {
+		return err
+	}
+	return os.Remove(probe)
+}
+
+func (t *LocalTarget) Put(ctx context.Context, name string, data []byte) error {
+	path, err := t.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(t.dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (t *LocalTarget) Get(ctx context.Context, name string) ([]byte, error) {
+	path, err := t.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+func (t *LocalTarget) List(ctx context.Context) ([]Snapshot, error) {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var list []Snapshot
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") || strings.HasSuffix(e.Name(), ".tmp") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		list = append(list, Snapshot{Name: e.Name(), CreatedAt: info.ModTime().UTC(), Size: info.Size()})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list, nil
+}
+
+func (t *LocalTarget) Delete(ctx context.Context, name string) error {
+	path, err := t.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
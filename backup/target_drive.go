@@ -0,0 +1,112 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+type DriveTarget struct {
+	name     string
+	srv      *drive.Service
+	folderID string
+}
+
+func NewDriveTarget(name string, srv *drive.Service, folderID string) (*DriveTarget, error) {
+	if folderID == "" {
+		return nil, fmt.Errorf("drive target requires a folder id")
+	}
+	return &DriveTarget{name: name, srv: srv, folderID: folderID}, nil
+}
+
+func NewServiceAccountDriveTarget(ctx context.Context, credentialsFile, folderID string) (*DriveTarget, error) {
+	if credentialsFile == "" {
+		return nil, fmt.Errorf("drive target requires GOOGLE_SERVICE_ACCOUNT_JSON")
+	}
+	srv, err := drive.NewService(ctx, option.WithCredentialsFile(credentialsFile), option.WithScopes(drive.DriveScope))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create drive service: %v", err)
+	}
+	return NewDriveTarget("drive", srv, folderID)
+}
+
+func (t *DriveTarget) Name() string {
+	return t.name
+}
+
+func (t *DriveTarget) Check(ctx context.Context) error {
+	f, err := t.srv.Files.Get(t.folderID).Context(ctx).SupportsAllDrives(true).Fields("id, mimeType, capabilities/canAddChildren").Do()
+	if err != nil {
+		return err
+	}
+	if f.MimeType != "application/vnd.google-apps.folder" {
+		return fmt.Errorf("drive backup folder %s is not a folder", t.folderID)
+	}
+	if f.Capabilities != nil && !f.Capabilities.CanAddChildren {
+		return fmt.Errorf("no write access to drive backup folder %s", t.folderID)
+	}
+	return nil
+}
+
+func (t *DriveTarget) findFile(ctx context.Context, name string) (*drive.File, error) {
+	q := fmt.Sprintf("'%s' in parents and name = '%s' and trashed = false", t.folderID, strings.ReplaceAll(name, "'", `\'`))
+	res, err := t.srv.Files.List().Context(ctx).Q(q).Fields("files(id, name)").
+		SupportsAllDrives(true).IncludeItemsFromAllDrives(true).PageSize(1).Do()
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Files) == 0 {
+		return nil, fmt.Errorf("%s not found in drive backup folder", name)
+	}
+	return res.Files[0], nil
+}
+
+func (t *DriveTarget) Put(ctx context.Context, name string, data []byte) error {
+	file := &drive.File{Name: name, Parents: []string{t.folderID}}
+	_, err := t.srv.Files.Create(file).Context(ctx).SupportsAllDrives(true).Media(bytes.NewReader(data)).Do()
+	return err
+}
+
+func (t *DriveTarget) Get(ctx context.Context, name string) ([]byte, error) {
+	f, err := t.findFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.srv.Files.Get(f.Id).Context(ctx).SupportsAllDrives(true).Download()
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (t *DriveTarget) List(ctx context.Context) ([]Snapshot, error) {
+	var list []Snapshot
+	call := t.srv.Files.List().Context(ctx).
+		Q(fmt.Sprintf("'%s' in parents and trashed = false", t.folderID)).
+		Fields("nextPageToken, files(id, name, size, createdTime)").
+		SupportsAllDrives(true).IncludeItemsFromAllDrives(true).
+		OrderBy("createdTime desc").PageSize(100)
+	err := call.Pages(ctx, func(res *drive.FileList) error {
+		for _, f := range res.Files {
+			created, _ := time.Parse(time.RFC3339, f.CreatedTime)
+			list = append(list, Snapshot{Name: f.Name, CreatedAt: created, Size: f.Size})
+		}
+		return nil
+	})
+	return list, err
+}
+
+func (t *DriveTarget) Delete(ctx context.Context, name string) error {
+	f, err := t.findFile(ctx, name)
+	if err != nil {
+		return err
+	}
+	return t.srv.Files.Delete(f.Id).Context(ctx).SupportsAllDrives(true).Do()
+}
@@ -0,0 +1,281 @@
+package backup
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "ap-south-1"
+	testBucket    = "exun-backups"
+)
+
+type fakeS3 struct {
+	t        *testing.T
+	mu       sync.Mutex
+	objects  map[string][]byte
+	modified map[string]time.Time
+	pageSize int
+}
+
+func newFakeS3(t *testing.T) *fakeS3 {
+	return &fakeS3{t: t, objects: map[string][]byte{}, modified: map[string]time.Time{}, pageSize: 2}
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if err := verifySignature(r, body); err != nil {
+		s.t.Errorf("%s %s: %v", r.Method, r.URL, err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, key, _ := strings.Cut(path, "/")
+	if bucket != testBucket {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		s.list(w, r.URL.Query())
+	case r.Method == http.MethodPut:
+		s.objects[key] = body
+		s.modified[key] = time.Now().Add(time.Duration(len(s.objects)) * time.Second)
+	case r.Method == http.MethodGet:
+		data, ok := s.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unsupported", http.StatusMethodNotAllowed)
+	}
+}
+
+type fakeListResult struct {
+	XMLName  xml.Name `xml:"ListBucketResult"`
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken,omitempty"`
+}
+
+func (s *fakeS3) list(w http.ResponseWriter, q url.Values) {
+	if q.Get("list-type") != "2" {
+		http.Error(w, "list-type=2 expected", http.StatusBadRequest)
+		return
+	}
+	var keys []string
+	for k := range s.objects {
+		if strings.HasPrefix(k, q.Get("prefix")) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	start := 0
+	if token := q.Get("continuation-token"); token != "" {
+		fmt.Sscanf(token, "%d", &start)
+	}
+	var res fakeListResult
+	end := min(start+s.pageSize, len(keys))
+	for _, k := range keys[start:end] {
+		res.Contents = append(res.Contents, struct {
+			Key          string    `xml:"Key"`
+			LastModified time.Time `xml:"LastModified"`
+			Size         int64     `xml:"Size"`
+		}{k, s.modified[k], int64(len(s.objects[k]))})
+	}
+	if end < len(keys) {
+		res.IsTruncated = true
+		res.NextContinuationToken = fmt.Sprint(end)
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(res)
+}
+
+// verifySignature checks a request's SigV4 signature the way the server
+// sees it: from the escaped path, the received query and headers.
+func verifySignature(r *http.Request, body []byte) error {
+	auth := r.Header.Get("Authorization")
+	const algo = "AWS4-HMAC-SHA256 "
+	if !strings.HasPrefix(auth, algo) {
+		return fmt.Errorf("unexpected Authorization %q", auth)
+	}
+	fields := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(auth, algo), ", ") {
+		k, v, _ := strings.Cut(part, "=")
+		fields[k] = v
+	}
+	cred := strings.Split(fields["Credential"], "/")
+	if len(cred) != 5 || cred[0] != testAccessKey || cred[2] != testRegion || cred[3] != "s3" || cred[4] != "aws4_request" {
+		return fmt.Errorf("bad credential %q", fields["Credential"])
+	}
+	amzDate := r.Header.Get("x-amz-date")
+	if !strings.HasPrefix(amzDate, cred[1]) {
+		return fmt.Errorf("credential date %s does not match x-amz-date %s", cred[1], amzDate)
+	}
+	if got := r.Header.Get("x-amz-content-sha256"); got != sha256Hex(body) {
+		return fmt.Errorf("payload hash %s does not match body", got)
+	}
+
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	if !sort.StringsAreSorted(signed) || !slices.Contains(signed, "host") {
+		return fmt.Errorf("host must be signed, got %v", signed)
+	}
+	var headers strings.Builder
+	for _, h := range signed {
+		v := r.Header.Get(h)
+		if h == "host" {
+			v = r.Host
+		}
+		headers.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	q := r.URL.Query()
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var query []string
+	for _, k := range keys {
+		for _, v := range q[k] {
+			query = append(query, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{r.Method, path, strings.Join(query, "&"), headers.String(), fields["SignedHeaders"], sha256Hex(body)}, "\n")
+	scope := strings.Join(cred[1:], "/")
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := []byte("AWS4" + testSecretKey)
+	for _, part := range cred[1:] {
+		key = hmacSHA256(key, part)
+	}
+	want := hex.EncodeToString(hmacSHA256(key, toSign))
+	if !hmac.Equal([]byte(want), []byte(fields["Signature"])) {
+		return fmt.Errorf("signature mismatch\ncanonical request:\n%s", canonical)
+	}
+	return nil
+}
+
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func TestS3TargetAgainstStandIn(t *testing.T) {
+	fake := newFakeS3(t)
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	target, err := NewS3Target(S3Config{
+		Endpoint:  srv.URL,
+		Region:    testRegion,
+		Bucket:    testBucket,
+		Prefix:    "backups",
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := target.Check(ctx); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+
+	names := []string{"exun-20261018-0100.db.enc", "exun-20261018-0200.db.enc", "exun-20261018-0300.db.enc"}
+	for _, name := range names {
+		if err := target.Put(ctx, name, []byte("snapshot "+name)); err != nil {
+			t.Fatalf("Put %s: %v", name, err)
+		}
+	}
+	fake.mu.Lock()
+	fake.objects["other/exun-old.db.enc"] = []byte("outside the prefix")
+	fake.objects["backups/nested/exun.db.enc"] = []byte("nested")
+	fake.mu.Unlock()
+
+	list, err := target.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != len(names) {
+		t.Fatalf("List returned %d snapshots, want %d: %+v", len(list), len(names), list)
+	}
+	if list[0].Name != names[2] || list[2].Name != names[0] {
+		t.Errorf("List is not newest first: %+v", list)
+	}
+	if list[0].Size != int64(len("snapshot "+names[2])) {
+		t.Errorf("size = %d", list[0].Size)
+	}
+
+	data, err := target.Get(ctx, names[1])
+	if err != nil || string(data) != "snapshot "+names[1] {
+		t.Fatalf("Get = %q, %v", data, err)
+	}
+
+	if err := target.Delete(ctx, names[0]); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	list, err = target.List(ctx)
+	if err != nil {
+		t.Fatalf("List after delete: %v", err)
+	}
+	for _, s := range list {
+		if s.Name == names[0] {
+			t.Errorf("%s still listed after delete", names[0])
+		}
+	}
+	if _, err := target.Get(ctx, names[0]); err == nil {
+		t.Error("Get of a deleted object succeeded")
+	}
+}
+
+func TestS3TargetWrongSecretIsRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := verifySignature(r, body); err != nil {
+			http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	target, err := NewS3Target(S3Config{Endpoint: srv.URL, Region: testRegion, Bucket: testBucket, AccessKey: testAccessKey, SecretKey: "wrong", PathStyle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = target.Put(context.Background(), "exun.db.enc", []byte("data"))
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Fatalf("Put with the wrong secret: %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"exunreg25/backup"
+	"exunreg25/db"
+	"exunreg25/handlers"
+)
+
+func runCommand(args []string, snapshots *backup.Manager, dbPath string) error {
+	switch args[0] {
+	case "snapshot":
+		snap, err := snapshots.TakeSnapshot("cli")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("snapshot %s written to %s (%d bytes, sha256 %s)\n", snap.Name, snapshots.Dir(), snap.Size, snap.SHA256)
+		return nil
+	case "snapshots":
+		list, err := snapshots.List()
+		if err != nil {
+			return err
+		}
+		return printSnapshots(list)
+	case "restore":
+		return runRestore(args[1:], dbPath)
+	default:
+		return fmt.Errorf("unknown command %q (available: snapshot, snapshots, restore)", args[0])
+	}
+}
+
+func printSnapshots(list []backup.Snapshot) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tNAME\tCREATED\tSIZE\tTRIGGER\tKEEP")
+	for _, s := range list {
+		source := s.Source
+		if source == "" {
+			source = "local"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n", source, s.Name, s.CreatedAt.Format("2006-01-02 15:04:05"), s.Size, s.Trigger, strings.Join(s.Keep, ","))
+	}
+	return tw.Flush()
+}
+
+func splitSnapshotRef(ref string) (string, string) {
+	if strings.HasPrefix(ref, "drive:") {
+		return "drive", strings.TrimPrefix(ref, "drive:")
+	}
+	return "local", ref
+}
+
+// lockForRestore refuses to restore under a running server.
+func lockForRestore(dbPath string) (func(), error) {
+	lock, err := db.Lock(dbPath)
+	if errors.Is(err, db.ErrLocked) {
+		return nil, fmt.Errorf("the server is running on %s; stop it or restore from the admin panel (POST /api/admin/restore)", dbPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return func() { lock.Close() }, nil
+}
+
+func runRestore(args []string, dbPath string) error {
+	if len(args) == 0 {
+		list, remoteErrors, err := handlers.AvailableSnapshots()
+		if err != nil {
+			return err
+		}
+		if err := printSnapshots(list); err != nil {
+			return err
+		}
+		for source, msg := range remoteErrors {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", source, msg)
+		}
+		return nil
+	}
+
+	switch args[0] {
+	case "diff":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: restore diff <snapshot> [table...] [column=value]")
+		}
+		source, name := splitSnapshotRef(args[1])
+		opts := db.DiffOptions{Limit: 50}
+		for _, a := range args[2:] {
+			if col, val, ok := strings.Cut(a, "="); ok {
+				opts.Column, opts.Value = col, val
+				continue
+			}
+			opts.Tables = append(opts.Tables, a)
+		}
+		diffs, err := handlers.DiffSnapshot(source, name, opts)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "TABLE\tLIVE\tSNAPSHOT\tADDED\tREMOVED\tCHANGED\tNOTE")
+		for _, d := range diffs {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%s\n", d.Table, d.LiveRows, d.SnapRows, d.Added, d.Removed, d.Changed, d.Note)
+		}
+		tw.Flush()
+		for _, d := range diffs {
+			if len(d.RemovedKeys) > 0 {
+				fmt.Printf("%s removed since snapshot (%s): %s\n", d.Table, d.Key, strings.Join(d.RemovedKeys, ", "))
+			}
+			if len(d.ChangedKeys) > 0 {
+				fmt.Printf("%s changed since snapshot (%s): %s\n", d.Table, d.Key, strings.Join(d.ChangedKeys, ", "))
+			}
+		}
+		return nil
+	case "rows":
+		if len(args) < 4 {
+			return fmt.Errorf("usage: restore rows <snapshot> <table> <key>...")
+		}
+		unlock, err := lockForRestore(dbPath)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		source, name := splitSnapshotRef(args[1])
+		n, safety, err := handlers.RestoreSnapshotRows(source, name, args[2], args[3:])
+		if err != nil {
+			return err
+		}
+		tables := make([]string, 0, len(n))
+		for t := range n {
+			tables = append(tables, t)
+		}
+		sort.Strings(tables)
+		for _, t := range tables {
+			fmt.Printf("restored %d %s rows\n", n[t], t)
+		}
+		fmt.Printf("pre-restore snapshot %s\n", safety)
+		return nil
+	}
+
+	unlock, err := lockForRestore(dbPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	source, name := splitSnapshotRef(args[0])
+	if len(args) < 2 || args[1] != "--yes" {
+		fmt.Printf("This replaces the entire database with %s. Type the snapshot name to continue: ", args[0])
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(line) != args[0] {
+			return fmt.Errorf("restore aborted")
+		}
+	}
+	result, err := handlers.RestoreSnapshot(source, name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("restored %s (pre-restore snapshot %s)\n", result.Restored, result.SafetySnapshot)
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -18,6 +19,63 @@ type Config struct {
 	SMTPPassword string
 	FromEmail    string
 	FromName     string
+	BaseURL      string
+	TokenSecret  string
+
+	TrustedProxies string
+
+	RegistrationDigest bool
+	DigestMinChanges   int
+
+	BackupDir          string
+	BackupInterval     int
+	BackupKeepHourly   int
+	BackupKeepDaily    int
+	BackupKeepWeekly   int
+	ScheduledSnapshots bool
+
+	BackupTargets       string
+	BackupEncryptionKey string
+	BackupMirrorDir     string
+	BackupDriveFolderID string
+	ServiceAccountJSON  string
+	S3Endpoint          string
+	S3Region            string
+	S3Bucket            string
+	S3Prefix            string
+	S3AccessKey         string
+	S3SecretKey         string
+	S3PathStyle         bool
+
+	OAuthTokenKey  string
+	OAuthTokenFile string
+
+	SyncTarget    string
+	SyncCSVDir    string
+	SpreadsheetID string
+
+	ChangeFeedToken string
+
+	WebhookMaxAttempts int
+	WebhookTimeout     int
+
+	LLMProviders  string
+	LLMTimeout    int
+	GeminiAPIKey  string
+	GeminiModel   string
+	OpenAIBaseURL string
+	OpenAIAPIKey  string
+	OpenAIModel   string
+	LLMStubScript string
+
+	QueryRatePerMinute   int
+	QueryBurst           int
+	QueryIPRatePerMinute int
+	QueryIPBurst         int
+	LLMDailyCallLimit    int
+	LLMDailyTokenLimit   int
+
+	GuardrailsPath string
 }
 
 func Load() (*Config, error) {
@@ -39,6 +97,63 @@ func Load() (*Config, error) {
 		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
 		FromEmail:    getEnv("FROM_EMAIL", ""),
 		FromName:     getEnv("FROM_NAME", ""),
+		BaseURL:      getEnv("BASE_URL", "https://reg.exunclan.com"),
+		TokenSecret:  getEnv("EMAIL_TOKEN_SECRET", authSalt),
+
+		TrustedProxies: getEnv("TRUSTED_PROXIES", "127.0.0.1,::1"),
+
+		RegistrationDigest: getEnvBool("REGISTRATION_DIGEST", false),
+		DigestMinChanges:   getEnvInt("REGISTRATION_DIGEST_MIN_CHANGES", 3),
+
+		BackupDir:          getEnv("BACKUP_DIR", "./data/backups"),
+		BackupInterval:     getEnvInt("BACKUP_INTERVAL", 60),
+		BackupKeepHourly:   getEnvInt("BACKUP_KEEP_HOURLY", 24),
+		BackupKeepDaily:    getEnvInt("BACKUP_KEEP_DAILY", 7),
+		BackupKeepWeekly:   getEnvInt("BACKUP_KEEP_WEEKLY", 4),
+		ScheduledSnapshots: getEnvBool("SCHEDULED_SNAPSHOTS", true),
+
+		BackupTargets:       getEnv("BACKUP_TARGETS", ""),
+		BackupEncryptionKey: getEnv("BACKUP_ENCRYPTION_KEY", ""),
+		BackupMirrorDir:     getEnv("BACKUP_MIRROR_DIR", ""),
+		BackupDriveFolderID: getEnv("BACKUP_DRIVE_FOLDER_ID", getEnv("FOLDER_ID", "")),
+		ServiceAccountJSON:  getEnv("GOOGLE_SERVICE_ACCOUNT_JSON", ""),
+		S3Endpoint:          getEnv("S3_ENDPOINT", ""),
+		S3Region:            getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:            getEnv("S3_BUCKET", ""),
+		S3Prefix:            getEnv("S3_PREFIX", "backups/"),
+		S3AccessKey:         getEnv("S3_ACCESS_KEY_ID", ""),
+		S3SecretKey:         getEnv("S3_SECRET_ACCESS_KEY", ""),
+		S3PathStyle:         getEnvBool("S3_PATH_STYLE", true),
+
+		OAuthTokenKey:  getEnv("OAUTH_TOKEN_KEY", ""),
+		OAuthTokenFile: getEnv("OAUTH_TOKEN_FILE", "./drive_token.json"),
+
+		SyncTarget:    getEnv("SYNC_TARGET", "sheets"),
+		SyncCSVDir:    getEnv("SYNC_CSV_DIR", "./data/sync"),
+		SpreadsheetID: getEnv("SPREADSHEET_ID", ""),
+
+		ChangeFeedToken: getEnv("CHANGE_FEED_TOKEN", ""),
+
+		WebhookMaxAttempts: getEnvInt("WEBHOOK_MAX_ATTEMPTS", 6),
+		WebhookTimeout:     getEnvInt("WEBHOOK_TIMEOUT", 10),
+
+		LLMProviders:  getEnv("LLM_PROVIDERS", "gemini"),
+		LLMTimeout:    getEnvInt("LLM_TIMEOUT", 30),
+		GeminiAPIKey:  getEnv("GEMINI_API_KEY", ""),
+		GeminiModel:   getEnv("GEMINI_MODEL", "gemini-2.5-flash"),
+		OpenAIBaseURL: getEnv("OPENAI_BASE_URL", "https://api.openai.com/v1"),
+		OpenAIAPIKey:  getEnv("OPENAI_API_KEY", ""),
+		OpenAIModel:   getEnv("OPENAI_MODEL", ""),
+		LLMStubScript: getEnv("LLM_STUB_SCRIPT", ""),
+
+		QueryRatePerMinute:   getEnvInt("QUERY_RATE_PER_MINUTE", 6),
+		QueryBurst:           getEnvInt("QUERY_BURST", 10),
+		QueryIPRatePerMinute: getEnvInt("QUERY_IP_RATE_PER_MINUTE", 60),
+		QueryIPBurst:         getEnvInt("QUERY_IP_BURST", 120),
+		LLMDailyCallLimit:    getEnvInt("LLM_DAILY_CALL_LIMIT", 2000),
+		LLMDailyTokenLimit:   getEnvInt("LLM_DAILY_TOKEN_LIMIT", 0),
+
+		GuardrailsPath: getEnv("GUARDRAILS_PATH", "handlers/guardrails.json"),
 	}
 
 	return config, nil
@@ -80,3 +195,12 @@ func getEnvBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if v, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			return v
+		}
+	}
+	return defaultValue
+}
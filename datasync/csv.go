@@ -0,0 +1,174 @@
+package datasync
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+type CSVTarget struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewCSVTarget(dir string) (*CSVTarget, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("SYNC_CSV_DIR not set")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &CSVTarget{dir: dir}, nil
+}
+
+func (t *CSVTarget) Name() string {
+	return "csv"
+}
+
+func (t *CSVTarget) path(table string) string {
+	return filepath.Join(t.dir, filepath.Base(table)+".csv")
+}
+
+func (t *CSVTarget) load(table string) ([][]string, error) {
+	f, err := os.Open(t.path(table))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	return r.ReadAll()
+}
+
+func (t *CSVTarget) save(table string, records [][]string) error {
+	path := t.path(table)
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(records); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func toRecord(row []interface{}) []string {
+	rec := make([]string, len(row))
+	for i, v := range row {
+		if v != nil {
+			rec[i] = fmt.Sprintf("%v", v)
+		}
+	}
+	return rec
+}
+
+func (t *CSVTarget) EnsureTable(ctx context.Context, table string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := os.Stat(t.path(table)); os.IsNotExist(err) {
+		return t.save(table, nil)
+	}
+	return nil
+}
+
+func (t *CSVTarget) ReadTable(ctx context.Context, table string) ([]string, [][]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	records, err := t.load(table)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("empty sheet")
+	}
+	headers := records[0]
+	rows := make([][]string, 0, len(records)-1)
+	for _, r := range records[1:] {
+		row := make([]string, len(headers))
+		copy(row, r)
+		rows = append(rows, row)
+	}
+	return headers, rows, nil
+}
+
+func (t *CSVTarget) WriteTable(ctx context.Context, table string, rows [][]interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	records := make([][]string, 0, len(rows))
+	for _, r := range rows {
+		records = append(records, toRecord(r))
+	}
+	return t.save(table, records)
+}
+
+func (t *CSVTarget) UpdateCells(ctx context.Context, table string, cells []CellUpdate) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	records, err := t.load(table)
+	if err != nil {
+		return err
+	}
+	for _, c := range cells {
+		ri := c.Row + 1
+		for len(records) <= ri {
+			records = append(records, []string{})
+		}
+		for len(records[ri]) <= c.Col {
+			records[ri] = append(records[ri], "")
+		}
+		records[ri][c.Col] = c.Value
+	}
+	return t.save(table, records)
+}
+
+func (t *CSVTarget) AppendRows(ctx context.Context, table string, rows [][]interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	records, err := t.load(table)
+	if err != nil {
+		return err
+	}
+	for _, r := range rows {
+		records = append(records, toRecord(r))
+	}
+	return t.save(table, records)
+}
+
+func (t *CSVTarget) DeleteRows(ctx context.Context, table string, rows []int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	records, err := t.load(table)
+	if err != nil {
+		return err
+	}
+	idxs := append([]int{}, rows...)
+	sort.Sort(sort.Reverse(sort.IntSlice(idxs)))
+	for _, r := range idxs {
+		ri := r + 1
+		if ri <= 0 || ri >= len(records) {
+			continue
+		}
+		records = append(records[:ri], records[ri+1:]...)
+	}
+	return t.save(table, records)
+}
+
+func (t *CSVTarget) FormatHeader(ctx context.Context, table string, cols int) error {
+	return nil
+}
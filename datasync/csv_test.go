@@ -0,0 +1,266 @@
+package datasync
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"exunreg25/db"
+)
+
+func newTestDB(t *testing.T) *db.Database {
+	t.Helper()
+	database, err := db.NewConnection(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := database.InitTables(); err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+func readCSV(t *testing.T, path string) [][]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func column(t *testing.T, header []string, name string) int {
+	t.Helper()
+	for i, h := range header {
+		if h == name {
+			return i
+		}
+	}
+	t.Fatalf("no %s column in %v", name, header)
+	return -1
+}
+
+func eventName(t *testing.T, database *db.Database, id string) (string, bool) {
+	t.Helper()
+	var name string
+	err := database.QueryRow("SELECT name FROM events WHERE id = ?", id).Scan(&name)
+	if err != nil {
+		return "", false
+	}
+	return name, true
+}
+
+func TestCSVTargetEdits(t *testing.T) {
+	ctx := context.Background()
+	target, err := NewCSVTarget(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := target.EnsureTable(ctx, "events"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := target.ReadTable(ctx, "events"); err == nil {
+		t.Error("reading a new, empty table succeeded")
+	}
+	if err := target.WriteTable(ctx, "events", [][]interface{}{{"id", "name"}, {"quiz", "Quiz"}, {"crossword", nil}}); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name string
+		edit func() error
+		want [][]string
+	}{
+		{"write", func() error { return nil }, [][]string{{"quiz", "Quiz"}, {"crossword", ""}}},
+		{"update", func() error {
+			return target.UpdateCells(ctx, "events", []CellUpdate{{Row: 1, Col: 1, Value: "Crossword"}})
+		}, [][]string{{"quiz", "Quiz"}, {"crossword", "Crossword"}}},
+		{"append", func() error {
+			return target.AppendRows(ctx, "events", [][]interface{}{{"cubing", "Cubing"}, {"sudocrypt", 5}})
+		}, [][]string{{"quiz", "Quiz"}, {"crossword", "Crossword"}, {"cubing", "Cubing"}, {"sudocrypt", "5"}}},
+		{"delete", func() error {
+			return target.DeleteRows(ctx, "events", []int{0, 2, 9})
+		}, [][]string{{"crossword", "Crossword"}, {"sudocrypt", "5"}}},
+	}
+	for _, s := range steps {
+		if err := s.edit(); err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		header, rows, err := target.ReadTable(ctx, "events")
+		if err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		if !reflect.DeepEqual(header, []string{"id", "name"}) || !reflect.DeepEqual(rows, s.want) {
+			t.Errorf("%s: got %v %v, want %v", s.name, header, rows, s.want)
+		}
+	}
+
+	if target.path("../users") != filepath.Join(target.dir, "users.csv") {
+		t.Errorf("table names are not confined to the directory: %s", target.path("../users"))
+	}
+}
+
+func TestRunWritesTablesToCSV(t *testing.T) {
+	database := newTestDB(t)
+	if _, err := database.Exec(`INSERT INTO events (id, name) VALUES ('quiz', 'Quiz'), ('crossword', 'Crossword')`); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	target, err := NewCSVTarget(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Run(context.Background(), database, target); err != nil {
+		t.Fatal(err)
+	}
+
+	records := readCSV(t, filepath.Join(dir, "events.csv"))
+	if len(records) != 3 {
+		t.Fatalf("events.csv has %d records, want a header and 2 rows", len(records))
+	}
+	idCol := column(t, records[0], "id")
+	ids := map[string]bool{}
+	for _, r := range records[1:] {
+		ids[r[idCol]] = true
+	}
+	if !ids["quiz"] || !ids["crossword"] {
+		t.Errorf("events.csv ids = %v", ids)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "users.csv")); err != nil {
+		t.Errorf("users table was not written: %v", err)
+	}
+}
+
+func TestRunSkipsInternalTables(t *testing.T) {
+	database := newTestDB(t)
+	if err := database.SetTeamFields(1, "quiz", map[string]string{"repo": "https://x.org"}); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	target, err := NewCSVTarget(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := syncOnce(t, database, target)
+
+	tables := []string{
+		"audit_events", "change_log", "sync_cursors", "oauth_tokens", "sync_base", "sync_conflicts",
+		"sync_runs", "webhooks", "webhook_deliveries", "llm_usage", "query_strikes", "query_reviews",
+		"email_suppressions", "email_templates", "registration_changes", "team_fields",
+	}
+	for _, table := range tables {
+		t.Run(table, func(t *testing.T) {
+			if _, err := os.Stat(filepath.Join(dir, table+".csv")); !os.IsNotExist(err) {
+				t.Errorf("%s was written to the target", table)
+			}
+			for _, ts := range report.Tables {
+				if ts.Table == table {
+					t.Errorf("%s is in the sync report", table)
+				}
+			}
+		})
+	}
+}
+
+func TestRunAgainstCSVTarget(t *testing.T) {
+	database := newTestDB(t)
+	for _, ev := range []*db.Event{
+		{ID: "quiz", Name: "Quiz", Mode: "Offline", Participants: 2, TeamsPerSchool: 1, DescriptionShort: "Quiz"},
+		{ID: "crossword", Name: "Crossword", Mode: "Online", Participants: 1, TeamsPerSchool: 1, DescriptionShort: "Crossword"},
+		{ID: "sudocrypt", Name: "Sudocrypt", Mode: "Online", Participants: 1, TeamsPerSchool: 1, DescriptionShort: "Sudocrypt"},
+	} {
+		if err := database.Create("events", ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	target, err := NewCSVTarget(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "events.csv")
+
+	// The first run writes the table out; the second records the merge base.
+	syncOnce(t, database, target)
+	syncOnce(t, database, target)
+	records := readCSV(t, path)
+	if len(records) != 4 {
+		t.Fatalf("events.csv has %d records, want a header and 3 rows", len(records))
+	}
+	header := records[0]
+	idCol, nameCol, descCol := column(t, header, "id"), column(t, header, "name"), column(t, header, "description_short")
+
+	// Edit the sheet: rename quiz, add a new event and remove crossword.
+	var edited [][]string
+	for _, r := range records {
+		switch r[idCol] {
+		case "quiz":
+			r[nameCol] = "Quiz Bowl"
+		case "crossword":
+			continue
+		}
+		edited = append(edited, r)
+	}
+	added := make([]string, len(header))
+	added[idCol], added[nameCol], added[descCol] = "cubing", "Cubing", "Cubing"
+	edited = append(edited, added)
+	writeCSV(t, path, edited)
+
+	syncOnce(t, database, target)
+	if name, _ := eventName(t, database, "quiz"); name != "Quiz Bowl" {
+		t.Errorf("quiz name = %q, want the sheet's %q", name, "Quiz Bowl")
+	}
+	if name, ok := eventName(t, database, "cubing"); !ok || name != "Cubing" {
+		t.Errorf("cubing was not inserted from the sheet (name %q)", name)
+	}
+	if _, ok := eventName(t, database, "crossword"); ok {
+		t.Error("crossword is still in the database after being removed from the sheet")
+	}
+
+	// Change the same cell differently on both sides.
+	if _, err := database.Exec("UPDATE events SET description_short = ? WHERE id = ?", "Sudocrypt (db)", "sudocrypt"); err != nil {
+		t.Fatal(err)
+	}
+	records = readCSV(t, path)
+	for _, r := range records[1:] {
+		if r[idCol] == "sudocrypt" {
+			r[descCol] = "Sudocrypt (sheet)"
+		}
+	}
+	writeCSV(t, path, records)
+
+	report := syncOnce(t, database, target)
+	conflicts, err := database.ListSyncConflicts("open", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 {
+		t.Fatalf("got %d open conflicts, want 1: %+v", len(conflicts), conflicts)
+	}
+	c := conflicts[0]
+	if c.PK != "sudocrypt" || c.Column != "description_short" || c.SheetValue != "Sudocrypt (sheet)" || c.DBValue != "Sudocrypt (db)" {
+		t.Errorf("unexpected conflict %+v", c)
+	}
+	if c.BaseValue == nil || *c.BaseValue != "Sudocrypt" {
+		t.Errorf("conflict base = %v, want %q", c.BaseValue, "Sudocrypt")
+	}
+	conflicted := 0
+	for _, ts := range report.Tables {
+		conflicted += ts.Conflicted
+	}
+	if conflicted != 1 {
+		t.Errorf("report counts %d conflicts, want 1", conflicted)
+	}
+}
@@ -0,0 +1,815 @@
+package datasync
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"exunreg25/db"
+	"exunreg25/regform"
+)
+
+func parseFlexibleTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	tryParse := func(str string) (time.Time, error) {
+		layouts := []string{
+			"2006-01-02 15:04:05.999999999 -0700 MST",
+			"2006-01-02 15:04:05.999999 -0700 MST",
+			"2006-01-02 15:04:05.999 -0700 MST",
+			"2006-01-02 15:04:05 -0700 MST",
+			"2006-01-02 15:04:05.999999999 -0700",
+			"2006-01-02 15:04:05.999999 -0700",
+			"2006-01-02 15:04:05.999 -0700",
+			"2006-01-02 15:04:05 -0700",
+			"2006-01-02T15:04:05.999999999-0700",
+			"2006-01-02T15:04:05.999999-0700",
+			"2006-01-02T15:04:05-0700",
+			time.RFC3339Nano,
+			time.RFC3339,
+			"2006-01-02 15:04:05",
+		}
+		for _, l := range layouts {
+			if t, err := time.ParseInLocation(l, str, time.UTC); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unrecognized time format: %s", str)
+	}
+
+	if t, err := tryParse(s); err == nil {
+		return t, nil
+	}
+
+	parts := strings.Fields(s)
+	if len(parts) >= 2 {
+		last := parts[len(parts)-1]
+		secondLast := parts[len(parts)-2]
+		if last == secondLast {
+			ns := strings.Join(parts[:len(parts)-1], " ")
+			if t, err := tryParse(ns); err == nil {
+				return t, nil
+			}
+		}
+		if len(parts) >= 3 {
+			p2 := parts[len(parts)-2]
+			p1 := parts[len(parts)-3]
+			if (strings.HasPrefix(p2, "+") || strings.HasPrefix(p2, "-")) && p1 == p2 {
+				ns := strings.Join(parts[:len(parts)-2], " ") + " " + p2
+				if t, err := tryParse(ns); err == nil {
+					return t, nil
+				}
+			}
+		}
+	}
+
+	if idx := strings.Index(s, " "); idx != -1 {
+		s2 := s[:idx] + "T" + s[idx+1:]
+		if t, err := tryParse(s2); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized time format: %s", s)
+}
+
+var primaryKeys = map[string]string{
+	"users":                    "email",
+	"events":                   "id",
+	"individual_registrations": "id",
+	"usr_regs":                 "id",
+}
+
+const (
+	fullSyncInterval   = 24 * time.Hour
+	changeLogRetention = 30 * 24 * time.Hour
+)
+
+var internalTables = map[string]bool{
+	"audit_events":         true,
+	"change_log":           true,
+	"sync_cursors":         true,
+	"oauth_tokens":         true,
+	"sync_base":            true,
+	"sync_conflicts":       true,
+	"sync_runs":            true,
+	"webhooks":             true,
+	"webhook_deliveries":   true,
+	"llm_usage":            true,
+	"query_strikes":        true,
+	"query_reviews":        true,
+	"email_suppressions":   true,
+	"email_templates":      true,
+	"registration_changes": true,
+	"team_fields":          true, // synced as the registrations' team_* columns
+}
+
+func Run(ctx context.Context, database *db.Database, target SyncTarget) (*Report, error) {
+	tables, err := listTables(database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %v", err)
+	}
+	report := &Report{Tables: []TableStats{}}
+
+	minChange, maxChange, err := database.ChangeLogBounds()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read change log: %v", err)
+	}
+	var changed map[string]map[string]bool
+	if cursor, err := database.GetSyncCursor(target.Name()); err == nil &&
+		time.Since(cursor.FullAt) < fullSyncInterval &&
+		cursor.LastChangeID <= maxChange &&
+		(minChange == 0 || cursor.LastChangeID >= minChange-1) {
+		changed, err = database.ChangedKeys(cursor.LastChangeID, maxChange)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read change log: %v", err)
+		}
+		report.Incremental = true
+	}
+
+	eventsCap := map[string]int{}
+	evRows, err := queryTableRows(database, "events")
+	if err == nil {
+		for _, er := range evRows[1:] {
+			id := fmt.Sprintf("%v", er[0])
+			capStr := fmt.Sprintf("%v", er[6])
+			if capStr == "" {
+				continue
+			}
+			if v, err := strconv.Atoi(capStr); err == nil {
+				eventsCap[id] = v
+			}
+		}
+	}
+
+	evFields, err := loadEventFields(database)
+	if err != nil {
+		log.Printf("failed to load event form fields: %v", err)
+	}
+	teamFields, err := database.AllTeamFields()
+	if err != nil {
+		log.Printf("failed to load team fields: %v", err)
+	}
+
+	usersRows, err := queryTableRows(database, "users")
+	if err == nil {
+		if len(usersRows) > 0 {
+			userHeader := usersRows[0]
+			userIndexLower := map[string]int{}
+			for i, c := range userHeader {
+				key := strings.ToLower(fmt.Sprintf("%v", c))
+				userIndexLower[key] = i
+			}
+			unameIdx := -1
+			regsIdx := -1
+			instIdx := -1
+			updatedIdx := -1
+			idIdx := -1
+			if v, ok := userIndexLower["id"]; ok {
+				idIdx = v
+			}
+			if v, ok := userIndexLower["username"]; ok {
+				unameIdx = v
+			}
+			if v, ok := userIndexLower["registrations"]; ok {
+				regsIdx = v
+			}
+			if v, ok := userIndexLower["institution_name"]; ok {
+				instIdx = v
+			} else if v, ok := userIndexLower["institution"]; ok {
+				instIdx = v
+			}
+			if v, ok := userIndexLower["updated_at"]; ok {
+				updatedIdx = v
+			}
+
+			upsertQ := `INSERT INTO usr_regs (username, institution, event_id, p1_name, p1_email, p1_class, p1_phone, p2_name, p2_email, p2_class, p2_phone, p3_name, p3_email, p3_class, p3_phone, p4_name, p4_email, p4_class, p4_phone, p5_name, p5_email, p5_class, p5_phone, p6_name, p6_email, p6_class, p6_phone, p7_name, p7_email, p7_class, p7_phone, p8_name, p8_email, p8_class, p8_phone, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(username, event_id) DO UPDATE SET p1_name = excluded.p1_name, p1_email = excluded.p1_email, p1_class = excluded.p1_class, p1_phone = excluded.p1_phone, p2_name = excluded.p2_name, p2_email = excluded.p2_email, p2_class = excluded.p2_class, p2_phone = excluded.p2_phone, p3_name = excluded.p3_name, p3_email = excluded.p3_email, p3_class = excluded.p3_class, p3_phone = excluded.p3_phone, p4_name = excluded.p4_name, p4_email = excluded.p4_email, p4_class = excluded.p4_class, p4_phone = excluded.p4_phone, p5_name = excluded.p5_name, p5_email = excluded.p5_email, p5_class = excluded.p5_class, p5_phone = excluded.p5_phone, p6_name = excluded.p6_name, p6_email = excluded.p6_email, p6_class = excluded.p6_class, p6_phone = excluded.p6_phone, p7_name = excluded.p7_name, p7_email = excluded.p7_email, p7_class = excluded.p7_class, p7_phone = excluded.p7_phone, p8_name = excluded.p8_name, p8_email = excluded.p8_email, p8_class = excluded.p8_class, p8_phone = excluded.p8_phone, updated_at = excluded.updated_at`
+
+			const maxParts = 8
+			for _, ur := range usersRows[1:] {
+				username := ""
+				if unameIdx >= 0 && unameIdx < len(ur) {
+					username = fmt.Sprintf("%v", ur[unameIdx])
+				}
+				institution := ""
+				if instIdx >= 0 && instIdx < len(ur) {
+					institution = fmt.Sprintf("%v", ur[instIdx])
+				}
+				regsRaw := ""
+				if regsIdx >= 0 && regsIdx < len(ur) {
+					regsRaw = fmt.Sprintf("%v", ur[regsIdx])
+				}
+				if regsRaw == "" || regsRaw == "{}" {
+					continue
+				}
+				var regs map[string][]db.Participant
+				if err := json.Unmarshal([]byte(regsRaw), &regs); err != nil {
+					continue
+				}
+				userUpdated := time.Now()
+				if updatedIdx >= 0 && updatedIdx < len(ur) {
+					s := fmt.Sprintf("%v", ur[updatedIdx])
+					if t, err := parseFlexibleTime(s); err == nil && !t.IsZero() {
+						userUpdated = t
+					}
+				}
+				userID := 0
+				if idIdx >= 0 && idIdx < len(ur) {
+					userID, _ = strconv.Atoi(fmt.Sprintf("%v", ur[idIdx]))
+				}
+				now := time.Now()
+				for evID, parts := range regs {
+					var existingUpdated sql.NullString
+					err := database.QueryRow("SELECT updated_at FROM usr_regs WHERE username = ? AND event_id = ?", username, evID).Scan(&existingUpdated)
+					if err != nil && err != sql.ErrNoRows {
+						continue
+					}
+					if err == nil {
+						existingT, _ := parseFlexibleTime(fmt.Sprintf("%v", existingUpdated.String))
+						if !userUpdated.After(existingT) {
+							continue
+						}
+					}
+					args := []interface{}{username, institution, evID}
+					for i := 0; i < maxParts; i++ {
+						if i < len(parts) {
+							args = append(args, parts[i].Name)
+							args = append(args, parts[i].Email)
+							args = append(args, fmt.Sprintf("%v", parts[i].Class))
+							args = append(args, parts[i].Phone)
+						} else {
+							args = append(args, "", "", "", "")
+						}
+					}
+					args = append(args, now, userUpdated)
+					_, _ = database.Exec(upsertQ, args...)
+					if ef, ok := fieldsFor(evFields, evID); ok {
+						if err := writeUsrRegsFields(database, username, evID, ef, teamFields[userID][evID], parts); err != nil {
+							log.Printf("failed to write form fields of %s for %s: %v", username, evID, err)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	for _, t := range tables {
+		if internalTables[t] {
+			continue
+		}
+		pk, hasPK := primaryKeys[t]
+		var changedKeys map[string]bool
+		if report.Incremental {
+			changedKeys = changed[t]
+			if changedKeys == nil {
+				changedKeys = map[string]bool{}
+			}
+			if !hasPK && len(changedKeys) == 0 {
+				continue
+			}
+		}
+		report.Tables = append(report.Tables, TableStats{Table: t})
+		stats := &report.Tables[len(report.Tables)-1]
+		var rows [][]interface{}
+		if !report.Incremental || !hasPK {
+			rows, err = queryTableRows(database, t)
+			if err != nil {
+				log.Printf("failed to query table %s: %v", t, err)
+				stats.fail(err)
+				continue
+			}
+		}
+
+		sheetName := t
+		if err := target.EnsureTable(ctx, sheetName); err != nil {
+			log.Printf("failed to ensure %s table %s exists: %v", target.Name(), sheetName, err)
+			stats.fail(err)
+			continue
+		}
+
+		hdr, sheetRows, err := target.ReadTable(ctx, sheetName)
+		if err != nil {
+			log.Printf("failed to read %s table %s: %v", target.Name(), sheetName, err)
+			if rows == nil {
+				if rows, err = queryTableRows(database, t); err != nil {
+					stats.fail(err)
+					continue
+				}
+			}
+			if err := target.WriteTable(ctx, sheetName, convertToValues(rows)); err != nil {
+				log.Printf("failed to write %s table %s: %v", target.Name(), sheetName, err)
+				stats.fail(err)
+			} else if len(rows) > 1 {
+				stats.Inserted += len(rows) - 1
+				stats.record(Change{Side: target.Name(), Action: "write", New: fmt.Sprintf("%d rows", len(rows)-1)})
+			}
+			continue
+		}
+
+		hdr = extendHeader(ctx, database, target, sheetName, hdr)
+
+		if hasPK {
+			pkIndex := -1
+			idIndex := -1
+			for i, h := range hdr {
+				if strings.EqualFold(h, pk) {
+					pkIndex = i
+				}
+				if strings.EqualFold(h, "id") {
+					idIndex = i
+				}
+			}
+			if pkIndex >= 0 || idIndex >= 0 {
+				seenPK := map[string]int{}
+				seenID := map[string]int{}
+				toDeleteSet := map[int]bool{}
+				dbDeleteIDs := []string{}
+				for i, r := range sheetRows {
+					var pkVal string
+					if pkIndex >= 0 && pkIndex < len(r) {
+						pkVal = strings.TrimSpace(r[pkIndex])
+						if pkVal == "''" {
+							pkVal = ""
+						}
+					}
+					var idVal string
+					if idIndex >= 0 && idIndex < len(r) {
+						idVal = strings.TrimSpace(r[idIndex])
+						if idVal == "''" {
+							idVal = ""
+						}
+					}
+					if pkVal != "" {
+						if first, ok := seenPK[pkVal]; ok {
+							toDeleteSet[i] = true
+							_ = first
+							continue
+						}
+						seenPK[pkVal] = i
+					}
+					if idVal != "" {
+						if first, ok := seenID[idVal]; ok {
+							toDeleteSet[i] = true
+							_ = first
+							continue
+						}
+						seenID[idVal] = i
+					}
+					if idVal != "" && pkVal == "" {
+						allEmpty := true
+						for j, cell := range r {
+							if j == idIndex {
+								continue
+							}
+							c := strings.TrimSpace(cell)
+							if c == "''" {
+								c = ""
+							}
+							if c != "" {
+								allEmpty = false
+								break
+							}
+						}
+						if allEmpty {
+							toDeleteSet[i] = true
+							dbDeleteIDs = append(dbDeleteIDs, idVal)
+						}
+					}
+				}
+				if len(toDeleteSet) > 0 {
+					idxs := make([]int, 0, len(toDeleteSet))
+					for k := range toDeleteSet {
+						idxs = append(idxs, k)
+					}
+					sort.Slice(idxs, func(a, b int) bool { return idxs[a] > idxs[b] })
+					if err := target.DeleteRows(ctx, sheetName, idxs); err != nil {
+						log.Printf("failed to delete duplicate rows from %s table %s: %v", target.Name(), sheetName, err)
+					}
+					for _, sr := range idxs {
+						r := sheetRows[sr]
+						key := ""
+						if pkIndex >= 0 && pkIndex < len(r) {
+							key = normalizeCell(r[pkIndex])
+						}
+						if key == "" && idIndex >= 0 && idIndex < len(r) {
+							key = normalizeCell(r[idIndex])
+						}
+						stats.Deleted++
+						stats.record(Change{Side: target.Name(), Action: "delete", PK: key})
+					}
+					if len(dbDeleteIDs) > 0 {
+						for _, did := range dbDeleteIDs {
+							dq := fmt.Sprintf("DELETE FROM %s WHERE id = ?", t)
+							_, _ = database.Exec(dq, did)
+							auditSheetDelete(database, target, t, did, map[string]string{"id": did})
+							stats.Deleted++
+							stats.record(Change{Side: "db", Action: "delete", PK: did})
+						}
+					}
+					newRows := make([][]string, 0, len(sheetRows)-len(toDeleteSet))
+					for i, r := range sheetRows {
+						if _, del := toDeleteSet[i]; del {
+							continue
+						}
+						newRows = append(newRows, r)
+					}
+					sheetRows = newRows
+				}
+			}
+			if err := mergeTable(ctx, database, target, t, pk, hdr, sheetRows, changedKeys, stats); err != nil {
+				log.Printf("failed to merge %s table %s: %v", target.Name(), sheetName, err)
+				stats.fail(err)
+			}
+		} else if err := updateOnlyMissingCells(ctx, target, sheetName, "", hdr, sheetRows, rows, stats); err != nil {
+			log.Printf("failed to partially update %s table %s: %v", target.Name(), sheetName, err)
+			stats.fail(err)
+			continue
+		}
+
+		if len(rows) > 0 {
+			_ = target.FormatHeader(ctx, sheetName, len(rows[0]))
+		}
+	}
+
+	if err := mergeUsrRegsToUsers(database, evFields); err != nil {
+		log.Printf("merge usr_regs->users error: %v", err)
+	}
+
+	for _, t := range report.Tables {
+		if t.Error != "" {
+			return report, nil
+		}
+	}
+	if err := database.SaveSyncCursor(target.Name(), maxChange, !report.Incremental); err != nil {
+		log.Printf("failed to save %s sync cursor: %v", target.Name(), err)
+	}
+	if !report.Incremental {
+		if n, err := database.PruneChangeLog(time.Now().Add(-changeLogRetention)); err != nil {
+			log.Printf("failed to prune change log: %v", err)
+		} else if n > 0 {
+			log.Printf("pruned %d change log entries", n)
+		}
+	}
+	return report, nil
+}
+
+func mergeUsrRegsToUsers(database *db.Database, evFields map[string]eventFields) error {
+	fieldValues, err := readUsrRegsFields(database, evFields)
+	if err != nil {
+		log.Printf("failed to read usr_regs form fields: %v", err)
+	}
+
+	q := `SELECT username, event_id, p1_name, p1_email, p1_class, p1_phone, p2_name, p2_email, p2_class, p2_phone, p3_name, p3_email, p3_class, p3_phone, p4_name, p4_email, p4_class, p4_phone, p5_name, p5_email, p5_class, p5_phone, p6_name, p6_email, p6_class, p6_phone, p7_name, p7_email, p7_class, p7_phone, p8_name, p8_email, p8_class, p8_phone, updated_at FROM usr_regs`
+	rows, err := database.Query(q)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	type userRegsData struct {
+		regs    map[string][]db.Participant
+		team    map[string]map[string]string
+		updated time.Time
+	}
+
+	usersMap := map[string]*userRegsData{}
+
+	for rows.Next() {
+		var username, eventID string
+		var p [8]struct{ name, email, class, phone sql.NullString }
+		var updated sql.NullString
+		scanArgs := []interface{}{&username, &eventID}
+		for i := 0; i < 8; i++ {
+			scanArgs = append(scanArgs, &p[i].name, &p[i].email, &p[i].class, &p[i].phone)
+		}
+		scanArgs = append(scanArgs, &updated)
+		if err := rows.Scan(scanArgs...); err != nil {
+			continue
+		}
+		if username == "" {
+			continue
+		}
+		parts := []db.Participant{}
+		for i := 0; i < 8; i++ {
+			if (p[i].name.Valid && strings.TrimSpace(p[i].name.String) != "") || (p[i].email.Valid && strings.TrimSpace(p[i].email.String) != "") {
+				classInt := 0
+				if p[i].class.Valid {
+					if v, err := strconv.Atoi(strings.TrimSpace(p[i].class.String)); err == nil {
+						classInt = v
+					}
+				}
+				name := ""
+				if p[i].name.Valid {
+					name = p[i].name.String
+				}
+				email := ""
+				if p[i].email.Valid {
+					email = p[i].email.String
+				}
+				phone := ""
+				if p[i].phone.Valid {
+					phone = p[i].phone.String
+				}
+				parts = append(parts, db.Participant{Name: name, Email: email, Class: classInt, Phone: phone})
+			}
+		}
+		ud := time.Time{}
+		if updated.Valid {
+			if t, err := parseFlexibleTime(strings.TrimSpace(updated.String)); err == nil {
+				ud = t
+			}
+		}
+		ur := usersMap[username]
+		if ur == nil {
+			ur = &userRegsData{regs: map[string][]db.Participant{}, team: map[string]map[string]string{}, updated: ud}
+			usersMap[username] = ur
+		}
+		if ef, ok := fieldsFor(evFields, eventID); ok {
+			vals := fieldValues[[2]string{username, eventID}]
+			for i := range parts {
+				for _, f := range ef.schema.Participant {
+					if v := vals[regform.ParticipantColumn(i+1, f.Key)]; v != "" {
+						if parts[i].Fields == nil {
+							parts[i].Fields = map[string]string{}
+						}
+						parts[i].Fields[f.Key] = v
+					}
+				}
+			}
+			team := map[string]string{}
+			for _, f := range ef.schema.Team {
+				if v := vals[regform.TeamColumn(f.Key)]; v != "" {
+					team[f.Key] = v
+				}
+			}
+			ur.team[eventID] = team
+		}
+		ur.regs[eventID] = parts
+		if ud.After(ur.updated) {
+			ur.updated = ud
+		}
+	}
+
+	for username, ur := range usersMap {
+		var userID int
+		var regsStr sql.NullString
+		var userUpdated sql.NullString
+		err := database.QueryRow("SELECT id, registrations, updated_at FROM users WHERE username = ? LIMIT 1", username).Scan(&userID, &regsStr, &userUpdated)
+		if err != nil {
+			continue
+		}
+		var existing map[string][]db.Participant
+		if regsStr.Valid && regsStr.String != "" && regsStr.String != "{}" {
+			_ = json.Unmarshal([]byte(regsStr.String), &existing)
+		} else {
+			existing = map[string][]db.Participant{}
+		}
+		// The sheet has no gender column, so keep the value already on
+		// record for the participant in the same position.
+		for eventID, parts := range ur.regs {
+			for i := range parts {
+				if old := existing[eventID]; i < len(old) && strings.EqualFold(old[i].Email, parts[i].Email) {
+					parts[i].Gender = old[i].Gender
+				}
+			}
+		}
+		for eventID, team := range ur.team {
+			current, err := database.TeamFields(userID, eventID)
+			if err == nil && !reflect.DeepEqual(current, team) && !(len(current) == 0 && len(team) == 0) {
+				_ = database.SetTeamFields(userID, eventID, team)
+			}
+		}
+		if reflect.DeepEqual(existing, ur.regs) {
+			continue
+		}
+		userUpdT := time.Time{}
+		if userUpdated.Valid {
+			if t, err := parseFlexibleTime(strings.TrimSpace(userUpdated.String)); err == nil {
+				userUpdT = t
+			}
+		}
+		if ur.updated.Before(userUpdT) {
+			continue
+		}
+		b, err := json.Marshal(ur.regs)
+		if err != nil {
+			continue
+		}
+		upd := time.Now()
+		_, _ = database.Exec("UPDATE users SET registrations = ?, updated_at = ? WHERE username = ?", string(b), upd, username)
+	}
+	return nil
+}
+
+func listTables(database *db.Database) ([]string, error) {
+	rows, err := database.Query("SELECT name FROM sqlite_master WHERE type='table' AND name NOT LIKE 'sqlite_%' ORDER BY name;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, nil
+}
+
+func queryTableRows(database *db.Database, table string) ([][]interface{}, error) {
+	return queryRows(database, fmt.Sprintf("SELECT * FROM %s", table))
+}
+
+func queryTableRowsByKeys(database *db.Database, table, pk string, keys []string) ([][]interface{}, error) {
+	const chunk = 500
+	result, err := queryRows(database, fmt.Sprintf("SELECT * FROM %s WHERE 0", table))
+	if err != nil {
+		return nil, err
+	}
+	for start := 0; start < len(keys); start += chunk {
+		end := start + chunk
+		if end > len(keys) {
+			end = len(keys)
+		}
+		args := make([]interface{}, 0, end-start)
+		for _, k := range keys[start:end] {
+			args = append(args, k)
+		}
+		q := fmt.Sprintf("SELECT * FROM %s WHERE %s IN (?%s)", table, pk, strings.Repeat(", ?", len(args)-1))
+		rows, err := queryRows(database, q, args...)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, rows[1:]...)
+	}
+	return result, nil
+}
+
+func queryRows(database *db.Database, q string, args ...interface{}) ([][]interface{}, error) {
+	rows, err := database.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := [][]interface{}{}
+	header := make([]interface{}, len(cols))
+	for i, c := range cols {
+		header[i] = c
+	}
+	result = append(result, header)
+
+	for rows.Next() {
+		vals := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		rec := make([]interface{}, len(cols))
+		for i, v := range vals {
+			switch val := v.(type) {
+			case nil:
+				rec[i] = ""
+			case []byte:
+				rec[i] = string(val)
+			default:
+				rec[i] = fmt.Sprintf("%v", val)
+			}
+		}
+		result = append(result, rec)
+	}
+	return result, nil
+}
+
+func convertToValues(rows [][]interface{}) [][]interface{} {
+	vals := make([][]interface{}, len(rows))
+	for i, r := range rows {
+		row := make([]interface{}, len(r))
+		copy(row, r)
+		vals[i] = row
+	}
+	return vals
+}
+
+func updateOnlyMissingCells(ctx context.Context, target SyncTarget, sheetName string, pk string, headers []string, sheetRows [][]string, dbRows [][]interface{}, stats *TableStats) error {
+	headerCount := len(headers)
+	sheetMap := map[string]int{}
+	pkIndex := -1
+	if headerCount == 0 {
+		return fmt.Errorf("no headers")
+	}
+	if pk == "" {
+		pk = headers[0]
+	}
+	for i, h := range headers {
+		if strings.EqualFold(h, pk) {
+			pkIndex = i
+			break
+		}
+	}
+	if pkIndex == -1 {
+		pkIndex = 0
+	}
+	for i, r := range sheetRows {
+		if pkIndex < len(r) {
+			v := strings.TrimSpace(r[pkIndex])
+			if v == "''" {
+				v = ""
+			}
+			sheetMap[v] = i
+		}
+	}
+
+	cells := []CellUpdate{}
+	appendRows := [][]interface{}{}
+
+	for ri, dbRow := range dbRows[1:] {
+		pkVal := fmt.Sprintf("%v", dbRow[pkIndex])
+		if pkVal == "" {
+			continue
+		}
+		if sr, exists := sheetMap[pkVal]; exists {
+			filled := false
+			for ci := 0; ci < headerCount; ci++ {
+				var sheetVal string
+				if ci < len(sheetRows[sr]) {
+					sheetVal = sheetRows[sr][ci]
+				}
+				dbVal := ""
+				if ci < len(dbRow) {
+					dbVal = fmt.Sprintf("%v", dbRow[ci])
+				}
+				writeVal := dbVal
+				if dbVal == "" {
+					writeVal = ""
+				}
+				if sheetVal == "" {
+					cells = append(cells, CellUpdate{Row: sr, Col: ci, Value: writeVal})
+					if writeVal != "" {
+						filled = true
+						stats.record(Change{Side: target.Name(), Action: "update", PK: pkVal, Column: headers[ci], New: writeVal})
+					}
+				}
+			}
+			if filled {
+				stats.Updated++
+			}
+		} else {
+			newRow := make([]interface{}, headerCount)
+			for ci := 0; ci < headerCount; ci++ {
+				if ci < len(dbRow) {
+					dv := fmt.Sprintf("%v", dbRow[ci])
+					if dv == "" {
+						newRow[ci] = ""
+					} else {
+						newRow[ci] = dv
+					}
+				} else {
+					newRow[ci] = ""
+				}
+			}
+			appendRows = append(appendRows, newRow)
+			stats.Inserted++
+			stats.record(Change{Side: target.Name(), Action: "insert", PK: pkVal})
+		}
+		_ = ri
+	}
+
+	if len(cells) > 0 {
+		_ = target.UpdateCells(ctx, sheetName, cells)
+	}
+	if len(appendRows) > 0 {
+		_ = target.AppendRows(ctx, sheetName, appendRows)
+	}
+
+	dbCount := len(dbRows) - 1
+	sheetCount := len(sheetRows)
+	if sheetCount > dbCount {
+		extra := []int{}
+		for i := dbCount + 1; i < sheetCount; i++ {
+			extra = append(extra, i)
+			stats.Deleted++
+		}
+		_ = target.DeleteRows(ctx, sheetName, extra)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package datasync
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"exunreg25/db"
+	"exunreg25/regform"
+)
+
+type eventFields struct {
+	schema regform.Schema
+	size   int
+}
+
+func (f eventFields) columns() []string {
+	cols := []string{}
+	for _, field := range f.schema.Team {
+		cols = append(cols, regform.TeamColumn(field.Key))
+	}
+	for n := 1; n <= f.size; n++ {
+		for _, field := range f.schema.Participant {
+			cols = append(cols, regform.ParticipantColumn(n, field.Key))
+		}
+	}
+	return cols
+}
+
+func loadEventFields(database *db.Database) (map[string]eventFields, error) {
+	rows, err := database.Query(`SELECT id, participants, COALESCE(form_fields, '') FROM events`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	fields := map[string]eventFields{}
+	all := []string{}
+	for rows.Next() {
+		var id, raw string
+		var size int
+		if err := rows.Scan(&id, &size, &raw); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		var schema regform.Schema
+		if err := json.Unmarshal([]byte(raw), &schema); err != nil || schema.IsEmpty() {
+			continue
+		}
+		if size > 8 {
+			size = 8
+		}
+		ef := eventFields{schema: schema, size: size}
+		fields[id] = ef
+		all = append(all, ef.columns()...)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	return fields, database.EnsureColumns("usr_regs", all)
+}
+
+// A school's second and later teams use "<event id>#<n>" as the event_id.
+func fieldsFor(fields map[string]eventFields, key string) (eventFields, bool) {
+	eventID, _ := db.SplitTeamKey(key)
+	ef, ok := fields[eventID]
+	return ef, ok
+}
+
+func writeUsrRegsFields(database *db.Database, username, eventID string, ef eventFields, team map[string]string, parts []db.Participant) error {
+	cols := []string{}
+	args := []interface{}{}
+	for _, f := range ef.schema.Team {
+		cols = append(cols, regform.TeamColumn(f.Key)+" = ?")
+		args = append(args, team[f.Key])
+	}
+	for n := 1; n <= ef.size; n++ {
+		for _, f := range ef.schema.Participant {
+			v := ""
+			if n <= len(parts) {
+				v = parts[n-1].Fields[f.Key]
+			}
+			cols = append(cols, regform.ParticipantColumn(n, f.Key)+" = ?")
+			args = append(args, v)
+		}
+	}
+	if len(cols) == 0 {
+		return nil
+	}
+	args = append(args, username, eventID)
+	_, err := database.Exec(fmt.Sprintf("UPDATE usr_regs SET %s WHERE username = ? AND event_id = ?", strings.Join(cols, ", ")), args...)
+	return err
+}
+
+func readUsrRegsFields(database *db.Database, fields map[string]eventFields) (map[[2]string]map[string]string, error) {
+	rows, err := database.Query(`SELECT * FROM usr_regs`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	out := map[[2]string]map[string]string{}
+	for rows.Next() {
+		values := make([]sql.NullString, len(names))
+		ptrs := make([]interface{}, len(names))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := map[string]string{}
+		for i, n := range names {
+			row[n] = values[i].String
+		}
+		ef, ok := fieldsFor(fields, row["event_id"])
+		if !ok {
+			continue
+		}
+		vals := map[string]string{}
+		for _, c := range ef.columns() {
+			vals[c] = row[c]
+		}
+		out[[2]string{row["username"], row["event_id"]}] = vals
+	}
+	return out, rows.Err()
+}
+
+// Columns added after the sheet was first written are appended to its header.
+func extendHeader(ctx context.Context, database *db.Database, target SyncTarget, table string, hdr []string) []string {
+	rows, err := database.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return hdr
+	}
+	defer rows.Close()
+	have := map[string]bool{}
+	for _, h := range hdr {
+		have[strings.ToLower(h)] = true
+	}
+	cells := []CellUpdate{}
+	extended := append([]string{}, hdr...)
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var dflt interface{}
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return hdr
+		}
+		if have[strings.ToLower(name)] {
+			continue
+		}
+		cells = append(cells, CellUpdate{Row: -1, Col: len(extended), Value: name})
+		extended = append(extended, name)
+	}
+	if len(cells) == 0 || len(hdr) == 0 {
+		return hdr
+	}
+	if err := target.UpdateCells(ctx, table, cells); err != nil {
+		return hdr
+	}
+	return extended
+}
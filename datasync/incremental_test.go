@@ -0,0 +1,27 @@
+package datasync
+
+import "testing"
+
+func TestRunIsIncrementalAfterFullSync(t *testing.T) {
+	f := newMergeFixture(t)
+	f.setDB("crossword", "Crossword DB")
+	f.setSheet("quiz", "Quiz Bowl")
+
+	report := syncOnce(t, f.database, f.target)
+	if !report.Incremental {
+		t.Fatal("run after a full sync was not incremental")
+	}
+	if got := f.sheetName("crossword"); got != "Crossword DB" {
+		t.Errorf("database change did not reach the sheet: %q", got)
+	}
+	if got := f.dbName("quiz"); got != "Quiz Bowl" {
+		t.Errorf("sheet change did not reach the database: %q", got)
+	}
+
+	if err := f.database.ResetSyncCursors(); err != nil {
+		t.Fatal(err)
+	}
+	if report := syncOnce(t, f.database, f.target); report.Incremental {
+		t.Error("run without a cursor was incremental")
+	}
+}
@@ -0,0 +1,442 @@
+package datasync
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"exunreg25/db"
+)
+
+func normalizeCell(v string) string {
+	v = strings.TrimSpace(v)
+	if v == "''" {
+		return ""
+	}
+	return v
+}
+
+func columnTypes(database *db.Database, table string) (map[string]string, error) {
+	rows, err := database.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	types := map[string]string{}
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var dflt interface{}
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		types[name] = strings.ToUpper(typ)
+	}
+	return types, rows.Err()
+}
+
+func dbArg(typ, v string) interface{} {
+	switch {
+	case strings.Contains(typ, "DATE") || strings.Contains(typ, "TIME"):
+		if v == "" {
+			return nil
+		}
+		if t, err := parseFlexibleTime(v); err == nil {
+			return t
+		}
+	case strings.Contains(typ, "BOOL"):
+		switch strings.ToLower(v) {
+		case "true", "1", "t", "yes":
+			return true
+		case "false", "0", "f", "no", "":
+			return false
+		}
+	case strings.Contains(typ, "INT") || strings.Contains(typ, "REAL"):
+		if v == "" {
+			return nil
+		}
+	}
+	return v
+}
+
+func updateDBRow(database *db.Database, table, pk, pkVal string, types map[string]string, values map[string]string) error {
+	sets := []string{}
+	args := []interface{}{}
+	for col, v := range values {
+		sets = append(sets, fmt.Sprintf("%s = ?", col))
+		args = append(args, dbArg(types[col], v))
+	}
+	if _, ok := types["updated_at"]; ok {
+		sets = append(sets, "updated_at = ?")
+		args = append(args, time.Now())
+	}
+	args = append(args, pkVal)
+	_, err := database.Exec(fmt.Sprintf("UPDATE %s SET %s WHERE %s = ?", table, strings.Join(sets, ", "), pk), args...)
+	return err
+}
+
+func insertDBRow(database *db.Database, table string, types map[string]string, values map[string]string) error {
+	cols := []string{}
+	placeholders := []string{}
+	args := []interface{}{}
+	for col, v := range values {
+		cols = append(cols, col)
+		placeholders = append(placeholders, "?")
+		args = append(args, dbArg(types[col], v))
+	}
+	if _, ok := types["updated_at"]; ok {
+		cols = append(cols, "updated_at")
+		placeholders = append(placeholders, "?")
+		args = append(args, time.Now())
+	}
+	_, err := database.Exec(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(cols, ", "), strings.Join(placeholders, ", ")), args...)
+	return err
+}
+
+// auditSheetDelete records a row deleted because it left the sync target.
+func auditSheetDelete(database *db.Database, target SyncTarget, table, pkVal string, before map[string]string) {
+	err := database.RecordAudit(db.AuditEntry{
+		Actor:    "sync:" + target.Name(),
+		Action:   "sheet.delete",
+		Entity:   table,
+		EntityID: pkVal,
+		Before:   before,
+	})
+	if err != nil {
+		log.Printf("sync: failed to audit delete of %s %s: %v", table, pkVal, err)
+	}
+}
+
+func sameValues(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesBase(values, base map[string]string) bool {
+	for col, b := range base {
+		if v, ok := values[col]; ok && v != b {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeTable merges one table field by field against sync_base; a field
+// changed differently on both sides becomes a sync_conflicts row.
+// A non-nil changed limits the database reads to those keys, open conflicts
+// and sheet rows the base has not seen.
+func mergeTable(ctx context.Context, database *db.Database, target SyncTarget, table, pk string, hdr []string, sheetRows [][]string, changed map[string]bool, stats *TableStats) error {
+	base, err := database.GetSyncBase(table)
+	if err != nil {
+		return err
+	}
+	open, err := database.OpenSyncConflicts(table)
+	if err != nil {
+		return err
+	}
+	if changed != nil {
+		read := map[string]bool{}
+		for k := range changed {
+			read[k] = true
+		}
+		for k := range open {
+			read[k] = true
+		}
+		changed = read
+	}
+	var dbRows [][]interface{}
+	if changed == nil {
+		dbRows, err = queryTableRows(database, table)
+	} else {
+		keys := []string{}
+		for k := range changed {
+			keys = append(keys, k)
+		}
+		for _, r := range sheetRows {
+			for i, h := range hdr {
+				if strings.EqualFold(h, pk) && i < len(r) {
+					if k := normalizeCell(r[i]); k != "" && !changed[k] && base[k] == nil {
+						keys = append(keys, k)
+					}
+				}
+			}
+		}
+		dbRows, err = queryTableRowsByKeys(database, table, pk, keys)
+	}
+	if err != nil {
+		return err
+	}
+	if len(dbRows) == 0 {
+		return nil
+	}
+	types, err := columnTypes(database, table)
+	if err != nil {
+		return err
+	}
+	dbIndex := map[string]int{}
+	for i, c := range dbRows[0] {
+		dbIndex[fmt.Sprintf("%v", c)] = i
+	}
+	dbPkIdx, ok := dbIndex[pk]
+	if !ok {
+		return fmt.Errorf("table has no %s column", pk)
+	}
+	pkIndex := -1
+	updatedIndex := -1
+	fields := map[int]string{}
+	for i, h := range hdr {
+		switch {
+		case strings.EqualFold(h, pk):
+			pkIndex = i
+		case strings.EqualFold(h, "updated_at"):
+			updatedIndex = i
+		default:
+			if _, ok := dbIndex[h]; ok {
+				fields[i] = h
+			}
+		}
+	}
+	if pkIndex < 0 {
+		return fmt.Errorf("sheet has no %s column", pk)
+	}
+
+	sheetValues := func(r []string) map[string]string {
+		values := map[string]string{}
+		for ci, col := range fields {
+			if ci < len(r) {
+				values[col] = normalizeCell(r[ci])
+			} else {
+				values[col] = ""
+			}
+		}
+		return values
+	}
+	dbValues := func(r []interface{}) map[string]string {
+		values := map[string]string{}
+		for _, col := range fields {
+			values[col] = normalizeCell(fmt.Sprintf("%v", r[dbIndex[col]]))
+		}
+		return values
+	}
+	sheetRow := func(r []interface{}) []interface{} {
+		row := make([]interface{}, len(hdr))
+		for ci, h := range hdr {
+			if di, ok := dbIndex[h]; ok {
+				row[ci] = fmt.Sprintf("%v", r[di])
+			} else {
+				row[ci] = ""
+			}
+		}
+		return row
+	}
+
+	dbByPK := map[string][]interface{}{}
+	for _, r := range dbRows[1:] {
+		if v := fmt.Sprintf("%v", r[dbPkIdx]); v != "" {
+			dbByPK[v] = r
+		}
+	}
+	unchanged := map[string]bool{}
+	if changed != nil {
+		for k, values := range base {
+			if changed[k] || dbByPK[k] != nil {
+				continue
+			}
+			r := make([]interface{}, len(dbRows[0]))
+			for col, i := range dbIndex {
+				r[i] = values[col]
+			}
+			r[dbPkIdx] = k
+			dbByPK[k] = r
+			unchanged[k] = true
+		}
+	}
+
+	cells := []CellUpdate{}
+	deleteRows := []int{}
+	appendRows := [][]interface{}{}
+	seen := map[string]bool{}
+	changedPKs := map[string]int{}
+
+	for sr, r := range sheetRows {
+		if pkIndex >= len(r) {
+			stats.Skipped++
+			continue
+		}
+		pkVal := normalizeCell(r[pkIndex])
+		if pkVal == "" || seen[pkVal] {
+			stats.Skipped++
+			continue
+		}
+		seen[pkVal] = true
+		sv := sheetValues(r)
+		b, hasBase := base[pkVal]
+		dr, inDB := dbByPK[pkVal]
+
+		if !inDB {
+			if hasBase && matchesBase(sv, b) && len(dbByPK) > 0 {
+				deleteRows = append(deleteRows, sr)
+				_ = database.DeleteSyncBase(table, pkVal)
+				stats.Deleted++
+				stats.record(Change{Side: target.Name(), Action: "delete", PK: pkVal})
+				continue
+			}
+			values := map[string]string{pk: pkVal}
+			for col, v := range sv {
+				values[col] = v
+			}
+			if err := insertDBRow(database, table, types, values); err != nil {
+				log.Printf("sync merge: failed to insert %s %s=%s: %v", table, pk, pkVal, err)
+				stats.Skipped++
+				continue
+			}
+			_ = database.SaveSyncBase(table, pkVal, sv)
+			changedPKs[pkVal] = sr
+			stats.Inserted++
+			stats.record(Change{Side: "db", Action: "insert", PK: pkVal})
+			continue
+		}
+
+		dv := dbValues(dr)
+		newBase := map[string]string{}
+		dbChanges := map[string]string{}
+		sheetChanged := false
+		for ci, col := range fields {
+			s, d := sv[col], dv[col]
+			bv, hb := b[col]
+			switch {
+			case s == d:
+				newBase[col] = s
+				if id, ok := open[pkVal][col]; ok {
+					_ = database.ResolveSyncConflict(id, "converged", &s, "sync")
+				}
+			case (hb && s == bv) || (!hb && s == ""):
+				cells = append(cells, CellUpdate{Row: sr, Col: ci, Value: d})
+				newBase[col] = d
+				sheetChanged = true
+				stats.record(Change{Side: target.Name(), Action: "update", PK: pkVal, Column: col, Old: s, New: d})
+			case (hb && d == bv) || (!hb && d == ""):
+				dbChanges[col] = s
+				newBase[col] = s
+				stats.record(Change{Side: "db", Action: "update", PK: pkVal, Column: col, Old: d, New: s})
+			default:
+				var basePtr *string
+				if hb {
+					v := bv
+					basePtr = &v
+					newBase[col] = bv
+				}
+				created, err := database.RecordSyncConflict(table, pkVal, col, basePtr, s, d)
+				if err != nil {
+					log.Printf("sync merge: failed to record conflict %s %s=%s %s: %v", table, pk, pkVal, col, err)
+				} else if created {
+					log.Printf("sync merge: conflict on %s %s=%s column %s (sheet=%q db=%q)", table, pk, pkVal, col, s, d)
+				}
+				stats.Conflicted++
+				stats.record(Change{Side: "both", Action: "conflict", PK: pkVal, Column: col, Old: d, New: s})
+			}
+		}
+		if len(dbChanges) > 0 {
+			if err := updateDBRow(database, table, pk, pkVal, types, dbChanges); err != nil {
+				log.Printf("sync merge: failed to update %s %s=%s: %v", table, pk, pkVal, err)
+				stats.Skipped++
+				continue
+			}
+			changedPKs[pkVal] = sr
+		}
+		if len(dbChanges) > 0 || sheetChanged {
+			stats.Updated++
+		}
+		if len(dbChanges) == 0 && updatedIndex >= 0 && !unchanged[pkVal] {
+			if di, ok := dbIndex["updated_at"]; ok {
+				d := fmt.Sprintf("%v", dr[di])
+				if updatedIndex >= len(r) || normalizeCell(r[updatedIndex]) != d {
+					cells = append(cells, CellUpdate{Row: sr, Col: updatedIndex, Value: d})
+				}
+			}
+		}
+		if !hasBase || !sameValues(newBase, b) {
+			_ = database.SaveSyncBase(table, pkVal, newBase)
+		}
+	}
+
+	for pkVal, dr := range dbByPK {
+		if seen[pkVal] {
+			continue
+		}
+		dv := dbValues(dr)
+		if b, hasBase := base[pkVal]; hasBase && matchesBase(dv, b) && len(seen) > 0 {
+			if _, err := database.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s = ?", table, pk), pkVal); err != nil {
+				log.Printf("sync merge: failed to delete %s %s=%s: %v", table, pk, pkVal, err)
+				stats.Skipped++
+				continue
+			}
+			_ = database.DeleteSyncBase(table, pkVal)
+			auditSheetDelete(database, target, table, pkVal, dv)
+			stats.Deleted++
+			stats.record(Change{Side: "db", Action: "delete", PK: pkVal})
+			continue
+		}
+		appendRows = append(appendRows, sheetRow(dr))
+		_ = database.SaveSyncBase(table, pkVal, dv)
+		stats.Inserted++
+		stats.record(Change{Side: target.Name(), Action: "insert", PK: pkVal})
+	}
+
+	for pkVal := range base {
+		if _, ok := dbByPK[pkVal]; !ok && !seen[pkVal] {
+			_ = database.DeleteSyncBase(table, pkVal)
+		}
+	}
+
+	if len(changedPKs) > 0 && updatedIndex >= 0 {
+		if fresh, err := queryTableRows(database, table); err == nil {
+			for _, r := range fresh[1:] {
+				if sr, ok := changedPKs[fmt.Sprintf("%v", r[dbPkIdx])]; ok {
+					if di, ok := dbIndex["updated_at"]; ok {
+						cells = append(cells, CellUpdate{Row: sr, Col: updatedIndex, Value: fmt.Sprintf("%v", r[di])})
+					}
+				}
+			}
+		}
+	}
+
+	if err := target.UpdateCells(ctx, table, cells); err != nil {
+		return fmt.Errorf("failed to update cells: %v", err)
+	}
+	if err := target.DeleteRows(ctx, table, deleteRows); err != nil {
+		return fmt.Errorf("failed to delete rows: %v", err)
+	}
+	if err := target.AppendRows(ctx, table, appendRows); err != nil {
+		return fmt.Errorf("failed to append rows: %v", err)
+	}
+	log.Printf("sync merge: table=%s inserted=%d updated=%d deleted=%d conflicted=%d", table, stats.Inserted, stats.Updated, stats.Deleted, stats.Conflicted)
+	return nil
+}
+
+func ResolveConflict(database *db.Database, c *db.SyncConflict, value string) error {
+	types, err := columnTypes(database, c.Table)
+	if err != nil {
+		return err
+	}
+	if _, ok := types[c.Column]; !ok {
+		return fmt.Errorf("table %s has no column %s", c.Table, c.Column)
+	}
+	pk, ok := primaryKeys[c.Table]
+	if !ok {
+		return fmt.Errorf("table %s is not synced by primary key", c.Table)
+	}
+	if err := updateDBRow(database, c.Table, pk, c.PK, types, map[string]string{c.Column: value}); err != nil {
+		return err
+	}
+	return database.SetSyncBaseValue(c.Table, c.PK, c.Column, c.SheetValue)
+}
@@ -0,0 +1,223 @@
+package datasync
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"exunreg25/db"
+)
+
+func writeCSV(t *testing.T, path string, records [][]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(records); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func syncOnce(t *testing.T, database *db.Database, target SyncTarget) *Report {
+	t.Helper()
+	report, err := Run(context.Background(), database, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ts := range report.Tables {
+		if ts.Error != "" {
+			t.Fatalf("table %s: %s", ts.Table, ts.Error)
+		}
+	}
+	return report
+}
+
+type mergeFixture struct {
+	t        *testing.T
+	database *db.Database
+	target   *CSVTarget
+	path     string
+}
+
+func newMergeFixture(t *testing.T) *mergeFixture {
+	database := newTestDB(t)
+	if _, err := database.Exec(`INSERT INTO events (id, name) VALUES ('quiz', 'Quiz'), ('crossword', 'Crossword')`); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	target, err := NewCSVTarget(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &mergeFixture{t: t, database: database, target: target, path: filepath.Join(dir, "events.csv")}
+	syncOnce(t, database, target)
+	syncOnce(t, database, target)
+	return f
+}
+
+func (f *mergeFixture) setDB(id, name string) {
+	if _, err := f.database.Exec(`UPDATE events SET name = ? WHERE id = ?`, name, id); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *mergeFixture) setSheet(id, name string) {
+	records := readCSV(f.t, f.path)
+	idCol, nameCol := column(f.t, records[0], "id"), column(f.t, records[0], "name")
+	for _, r := range records[1:] {
+		if r[idCol] == id {
+			r[nameCol] = name
+		}
+	}
+	writeCSV(f.t, f.path, records)
+}
+
+func (f *mergeFixture) dbName(id string) string {
+	var name string
+	if err := f.database.QueryRow(`SELECT name FROM events WHERE id = ?`, id).Scan(&name); err != nil {
+		return ""
+	}
+	return name
+}
+
+func (f *mergeFixture) sheetName(id string) string {
+	records := readCSV(f.t, f.path)
+	idCol, nameCol := column(f.t, records[0], "id"), column(f.t, records[0], "name")
+	for _, r := range records[1:] {
+		if r[idCol] == id {
+			return r[nameCol]
+		}
+	}
+	return ""
+}
+
+func (f *mergeFixture) conflicts() []db.SyncConflict {
+	conflicts, err := f.database.ListSyncConflicts("open", "events")
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	return conflicts
+}
+
+func TestMergeFieldByField(t *testing.T) {
+	tests := []struct {
+		name              string
+		sheet, db         string
+		wantSheet, wantDB string
+		conflict          bool
+	}{
+		{"unchanged", "", "", "Quiz", "Quiz", false},
+		{"sheet edit wins", "Quiz Bowl", "", "Quiz Bowl", "Quiz Bowl", false},
+		{"db edit wins", "", "Quiz Bowl", "Quiz Bowl", "Quiz Bowl", false},
+		{"same edit on both sides", "Quiz Bowl", "Quiz Bowl", "Quiz Bowl", "Quiz Bowl", false},
+		{"different edits conflict", "Quiz Bowl", "Quiz Night", "Quiz Bowl", "Quiz Night", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newMergeFixture(t)
+			if tt.sheet != "" {
+				f.setSheet("quiz", tt.sheet)
+			}
+			if tt.db != "" {
+				f.setDB("quiz", tt.db)
+			}
+			syncOnce(t, f.database, f.target)
+
+			if got := f.sheetName("quiz"); got != tt.wantSheet {
+				t.Errorf("sheet name = %q, want %q", got, tt.wantSheet)
+			}
+			if got := f.dbName("quiz"); got != tt.wantDB {
+				t.Errorf("db name = %q, want %q", got, tt.wantDB)
+			}
+			if got := f.dbName("crossword"); got != "Crossword" {
+				t.Errorf("untouched row changed to %q", got)
+			}
+			conflicts := f.conflicts()
+			if (len(conflicts) == 1) != tt.conflict || len(conflicts) > 1 {
+				t.Fatalf("conflicts = %+v", conflicts)
+			}
+			if tt.conflict {
+				c := conflicts[0]
+				if c.PK != "quiz" || c.Column != "name" || c.SheetValue != tt.sheet || c.DBValue != tt.db || c.BaseValue == nil || *c.BaseValue != "Quiz" {
+					t.Errorf("conflict = %+v", c)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeConflictLifecycle(t *testing.T) {
+	f := newMergeFixture(t)
+	f.setSheet("quiz", "Quiz Bowl")
+	f.setDB("quiz", "Quiz Night")
+	syncOnce(t, f.database, f.target)
+	syncOnce(t, f.database, f.target)
+	if n := len(f.conflicts()); n != 1 {
+		t.Fatalf("a repeated run recorded %d open conflicts, want 1", n)
+	}
+
+	f.setSheet("quiz", "Quiz Night")
+	syncOnce(t, f.database, f.target)
+	if n := len(f.conflicts()); n != 0 {
+		t.Errorf("conflict still open after both sides converged")
+	}
+
+	f.setSheet("quiz", "Quiz Bowl")
+	f.setDB("quiz", "Quiz Live")
+	syncOnce(t, f.database, f.target)
+	conflicts := f.conflicts()
+	if len(conflicts) != 1 {
+		t.Fatalf("conflicts = %+v", conflicts)
+	}
+	if err := ResolveConflict(f.database, &conflicts[0], conflicts[0].SheetValue); err != nil {
+		t.Fatal(err)
+	}
+	syncOnce(t, f.database, f.target)
+	if f.dbName("quiz") != "Quiz Bowl" || f.sheetName("quiz") != "Quiz Bowl" {
+		t.Errorf("after resolving: db %q, sheet %q", f.dbName("quiz"), f.sheetName("quiz"))
+	}
+}
+
+func TestMergeRowsAddedAndRemoved(t *testing.T) {
+	f := newMergeFixture(t)
+	records := readCSV(t, f.path)
+	idCol, nameCol := column(t, records[0], "id"), column(t, records[0], "name")
+	var kept [][]string
+	for _, r := range records {
+		if r[idCol] != "crossword" {
+			kept = append(kept, r)
+		}
+	}
+	added := make([]string, len(records[0]))
+	added[idCol], added[nameCol] = "cubing", "Cubing"
+	writeCSV(t, f.path, append(kept, added))
+	if _, err := f.database.Exec(`INSERT INTO events (id, name) VALUES ('sudocrypt', 'Sudocrypt')`); err != nil {
+		t.Fatal(err)
+	}
+	syncOnce(t, f.database, f.target)
+
+	tests := []struct {
+		id            string
+		inDB, inSheet bool
+	}{
+		{"quiz", true, true},
+		{"crossword", false, false},
+		{"cubing", true, true},
+		{"sudocrypt", true, true},
+	}
+	for _, tt := range tests {
+		if got := f.dbName(tt.id) != ""; got != tt.inDB {
+			t.Errorf("%s in db = %v, want %v", tt.id, got, tt.inDB)
+		}
+		if got := f.sheetName(tt.id) != ""; got != tt.inSheet {
+			t.Errorf("%s in sheet = %v, want %v", tt.id, got, tt.inSheet)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package datasync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"exunreg25/db"
+)
+
+const maxChangesPerTable = 500
+
+type Change struct {
+	Side   string `json:"side"`
+	Action string `json:"action"`
+	PK     string `json:"pk,omitempty"`
+	Column string `json:"column,omitempty"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+type TableStats struct {
+	Table      string   `json:"table"`
+	Inserted   int      `json:"inserted"`
+	Updated    int      `json:"updated"`
+	Deleted    int      `json:"deleted"`
+	Skipped    int      `json:"skipped"`
+	Conflicted int      `json:"conflicted"`
+	Error      string   `json:"error,omitempty"`
+	Changes    []Change `json:"changes,omitempty"`
+	Truncated  bool     `json:"truncated,omitempty"`
+}
+
+func (s *TableStats) record(c Change) {
+	if len(s.Changes) >= maxChangesPerTable {
+		s.Truncated = true
+		return
+	}
+	s.Changes = append(s.Changes, c)
+}
+
+func (s *TableStats) fail(err error) {
+	s.Error = err.Error()
+}
+
+type Report struct {
+	DryRun      bool         `json:"dry_run"`
+	Incremental bool         `json:"incremental"`
+	Tables      []TableStats `json:"tables"`
+}
+
+type dryRunTarget struct {
+	SyncTarget
+}
+
+func (t dryRunTarget) EnsureTable(ctx context.Context, table string) error {
+	return nil
+}
+
+func (t dryRunTarget) WriteTable(ctx context.Context, table string, rows [][]interface{}) error {
+	return nil
+}
+
+func (t dryRunTarget) UpdateCells(ctx context.Context, table string, cells []CellUpdate) error {
+	return nil
+}
+
+func (t dryRunTarget) AppendRows(ctx context.Context, table string, rows [][]interface{}) error {
+	return nil
+}
+
+func (t dryRunTarget) DeleteRows(ctx context.Context, table string, rows []int) error {
+	return nil
+}
+
+func (t dryRunTarget) FormatHeader(ctx context.Context, table string, cols int) error {
+	return nil
+}
+
+// DryRun syncs a private copy of the database in dir against a target that
+// discards writes; the copy keeps the live database unlocked meanwhile.
+func DryRun(ctx context.Context, database *db.Database, target SyncTarget, dir string) (*Report, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("no directory for the dry-run copy")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	scratchDir, err := os.MkdirTemp(dir, "sync-dryrun-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(scratchDir)
+	path := filepath.Join(scratchDir, "dryrun.db")
+	if err := database.SnapshotTo(path); err != nil {
+		return nil, fmt.Errorf("failed to copy database: %v", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		return nil, err
+	}
+	scratch, err := db.NewConnection(path)
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Close()
+	report, err := Run(ctx, scratch, dryRunTarget{target})
+	if report != nil {
+		report.DryRun = true
+	}
+	return report, err
+}
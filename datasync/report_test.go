@@ -0,0 +1,98 @@
+package datasync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func tableStats(report *Report, table string) TableStats {
+	for _, ts := range report.Tables {
+		if ts.Table == table {
+			return ts
+		}
+	}
+	return TableStats{}
+}
+
+func TestRunReportCounts(t *testing.T) {
+	f := newMergeFixture(t)
+	f.setSheet("quiz", "Quiz Bowl")
+	f.setSheet("crossword", "Crossword Sheet")
+	f.setDB("crossword", "Crossword DB")
+	if _, err := f.database.Exec(`INSERT INTO events (id, name) VALUES ('cubing', 'Cubing')`); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := tableStats(syncOnce(t, f.database, f.target), "events")
+	got := [4]int{ts.Inserted, ts.Updated, ts.Deleted, ts.Conflicted}
+	if want := [4]int{1, 1, 0, 1}; got != want {
+		t.Errorf("inserted, updated, deleted, conflicted = %v, want %v", got, want)
+	}
+	wantChanges := map[Change]bool{
+		{Side: "db", Action: "update", PK: "quiz", Column: "name", Old: "Quiz", New: "Quiz Bowl"}:                        true,
+		{Side: "csv", Action: "insert", PK: "cubing"}:                                                                    true,
+		{Side: "both", Action: "conflict", PK: "crossword", Column: "name", Old: "Crossword DB", New: "Crossword Sheet"}: true,
+	}
+	gotChanges := map[Change]bool{}
+	for _, c := range ts.Changes {
+		gotChanges[c] = true
+	}
+	if !reflect.DeepEqual(gotChanges, wantChanges) {
+		t.Errorf("changes = %+v", ts.Changes)
+	}
+}
+
+// copyModeTarget records the mode of the dry-run copy while the sync runs.
+type copyModeTarget struct {
+	SyncTarget
+	dir   string
+	modes []os.FileMode
+}
+
+func (t *copyModeTarget) ReadTable(ctx context.Context, table string) ([]string, [][]string, error) {
+	copies, _ := filepath.Glob(filepath.Join(t.dir, "*", "dryrun.db"))
+	for _, c := range copies {
+		if fi, err := os.Stat(c); err == nil {
+			t.modes = append(t.modes, fi.Mode().Perm())
+		}
+	}
+	return t.SyncTarget.ReadTable(ctx, table)
+}
+
+func TestDryRunChangesNothing(t *testing.T) {
+	f := newMergeFixture(t)
+	f.setSheet("quiz", "Quiz Bowl")
+	f.setDB("crossword", "Crossword DB")
+	before := readCSV(t, f.path)
+
+	dir := t.TempDir()
+	target := &copyModeTarget{SyncTarget: f.target, dir: dir}
+	report, err := DryRun(context.Background(), f.database, target, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(target.modes) == 0 || target.modes[0] != 0600 {
+		t.Errorf("dry-run copy modes = %v, want 0600", target.modes)
+	}
+	if left, _ := os.ReadDir(dir); len(left) != 0 {
+		t.Errorf("dry run left %d files in %s", len(left), dir)
+	}
+	if _, err := DryRun(context.Background(), f.database, f.target, ""); err == nil {
+		t.Error("dry run ran without a directory for its copy")
+	}
+	if !report.DryRun {
+		t.Error("report is not marked as a dry run")
+	}
+	if ts := tableStats(report, "events"); ts.Updated != 2 {
+		t.Errorf("dry run reported %d updates, want 2: %+v", ts.Updated, ts.Changes)
+	}
+	if got := f.dbName("quiz"); got != "Quiz" {
+		t.Errorf("dry run wrote %q to the database", got)
+	}
+	if got := readCSV(t, f.path); !reflect.DeepEqual(got, before) {
+		t.Error("dry run wrote to the sheet")
+	}
+}
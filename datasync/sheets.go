@@ -0,0 +1,231 @@
+package datasync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"google.golang.org/api/option"
+	sheets "google.golang.org/api/sheets/v4"
+)
+
+type SheetsTarget struct {
+	srv           *sheets.Service
+	spreadsheetID string
+	mu            sync.Mutex
+	sheetIDs      map[string]int64
+}
+
+func NewSheetsTarget(ctx context.Context, credentials, spreadsheetID string) (*SheetsTarget, error) {
+	if credentials == "" {
+		return nil, fmt.Errorf("GOOGLE_SERVICE_ACCOUNT_JSON not set")
+	}
+	if _, err := os.Stat(credentials); err == nil {
+		b, err := os.ReadFile(credentials)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service account file: %v", err)
+		}
+		credentials = string(b)
+	}
+	if spreadsheetID == "" {
+		return nil, fmt.Errorf("SPREADSHEET_ID not set")
+	}
+	srv, err := sheets.NewService(ctx, option.WithCredentialsJSON([]byte(credentials)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sheets service: %v", err)
+	}
+	return &SheetsTarget{srv: srv, spreadsheetID: spreadsheetID, sheetIDs: map[string]int64{}}, nil
+}
+
+func (t *SheetsTarget) Name() string {
+	return "sheets"
+}
+
+func (t *SheetsTarget) sheetID(ctx context.Context, table string) (int64, error) {
+	t.mu.Lock()
+	id, ok := t.sheetIDs[table]
+	t.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+	if err := t.EnsureTable(ctx, table); err != nil {
+		return 0, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sheetIDs[table], nil
+}
+
+func (t *SheetsTarget) EnsureTable(ctx context.Context, table string) error {
+	id, err := ensureSheetExists(ctx, t.srv, t.spreadsheetID, table)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.sheetIDs[table] = id
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *SheetsTarget) ReadTable(ctx context.Context, table string) ([]string, [][]string, error) {
+	return readSheetRows(ctx, t.srv, t.spreadsheetID, table)
+}
+
+func (t *SheetsTarget) WriteTable(ctx context.Context, table string, rows [][]interface{}) error {
+	vr := &sheets.ValueRange{Values: rows}
+	_, err := t.srv.Spreadsheets.Values.Update(t.spreadsheetID, table+"!A1", vr).ValueInputOption("RAW").Context(ctx).Do()
+	return err
+}
+
+func (t *SheetsTarget) UpdateCells(ctx context.Context, table string, cells []CellUpdate) error {
+	if len(cells) == 0 {
+		return nil
+	}
+	data := make([]*sheets.ValueRange, 0, len(cells))
+	for _, c := range cells {
+		rng := fmt.Sprintf("%s!%s%d", table, a1ColumnName(c.Col), c.Row+2)
+		data = append(data, &sheets.ValueRange{Range: rng, Values: [][]interface{}{{c.Value}}})
+	}
+	req := &sheets.BatchUpdateValuesRequest{ValueInputOption: "RAW", Data: data}
+	_, err := t.srv.Spreadsheets.Values.BatchUpdate(t.spreadsheetID, req).Context(ctx).Do()
+	return err
+}
+
+func (t *SheetsTarget) AppendRows(ctx context.Context, table string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	vr := &sheets.ValueRange{Values: rows}
+	_, err := t.srv.Spreadsheets.Values.Append(t.spreadsheetID, table+"!A1", vr).ValueInputOption("RAW").InsertDataOption("INSERT_ROWS").Context(ctx).Do()
+	return err
+}
+
+func (t *SheetsTarget) DeleteRows(ctx context.Context, table string, rows []int) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	sheetID, err := t.sheetID(ctx, table)
+	if err != nil {
+		return err
+	}
+	idxs := append([]int{}, rows...)
+	sort.Sort(sort.Reverse(sort.IntSlice(idxs)))
+	requests := []*sheets.Request{}
+	for _, r := range idxs {
+		requests = append(requests, &sheets.Request{DeleteDimension: &sheets.DeleteDimensionRequest{
+			Range: &sheets.DimensionRange{
+				SheetId:    sheetID,
+				Dimension:  "ROWS",
+				StartIndex: int64(r + 1),
+				EndIndex:   int64(r + 2),
+			},
+		}})
+	}
+	batch := &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}
+	_, err = t.srv.Spreadsheets.BatchUpdate(t.spreadsheetID, batch).Context(ctx).Do()
+	return err
+}
+
+func (t *SheetsTarget) FormatHeader(ctx context.Context, table string, cols int) error {
+	sheetID, err := t.sheetID(ctx, table)
+	if err != nil {
+		return err
+	}
+	requests := []*sheets.Request{
+		{UpdateSheetProperties: &sheets.UpdateSheetPropertiesRequest{
+			Properties: &sheets.SheetProperties{
+				SheetId: sheetID,
+				GridProperties: &sheets.GridProperties{
+					FrozenRowCount: 1,
+				},
+			},
+			Fields: "gridProperties.frozenRowCount",
+		}},
+		{RepeatCell: &sheets.RepeatCellRequest{
+			Range: &sheets.GridRange{
+				SheetId:          sheetID,
+				StartRowIndex:    0,
+				EndRowIndex:      1,
+				StartColumnIndex: 0,
+				EndColumnIndex:   int64(cols),
+			},
+			Cell:   &sheets.CellData{UserEnteredFormat: &sheets.CellFormat{TextFormat: &sheets.TextFormat{Bold: true}}},
+			Fields: "userEnteredFormat.textFormat.bold",
+		}},
+	}
+	batch := &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}
+	_, err = t.srv.Spreadsheets.BatchUpdate(t.spreadsheetID, batch).Context(ctx).Do()
+	return err
+}
+
+func ensureSheetExists(ctx context.Context, srv *sheets.Service, spreadsheetID, sheetName string) (int64, error) {
+	ss, err := srv.Spreadsheets.Get(spreadsheetID).Fields("sheets.properties").Do()
+	if err != nil {
+		return 0, err
+	}
+	for _, s := range ss.Sheets {
+		if s.Properties.Title == sheetName {
+			return s.Properties.SheetId, nil
+		}
+	}
+	addReq := &sheets.Request{AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{Title: sheetName}}}
+	batch := &sheets.BatchUpdateSpreadsheetRequest{Requests: []*sheets.Request{addReq}}
+	resp, err := srv.Spreadsheets.BatchUpdate(spreadsheetID, batch).Context(ctx).Do()
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Replies) > 0 && resp.Replies[0].AddSheet != nil && resp.Replies[0].AddSheet.Properties != nil {
+		return resp.Replies[0].AddSheet.Properties.SheetId, nil
+	}
+	ss2, err := srv.Spreadsheets.Get(spreadsheetID).Fields("sheets.properties").Do()
+	if err != nil {
+		return 0, err
+	}
+	for _, s := range ss2.Sheets {
+		if s.Properties.Title == sheetName {
+			return s.Properties.SheetId, nil
+		}
+	}
+	return 0, fmt.Errorf("failed to get sheet id for %s", sheetName)
+}
+
+func readSheetRows(ctx context.Context, srv *sheets.Service, spreadsheetID, sheetName string) ([]string, [][]string, error) {
+	rng := sheetName + "!A1:Z"
+	resp, err := srv.Spreadsheets.Values.Get(spreadsheetID, rng).Context(ctx).Do()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(resp.Values) == 0 {
+		return nil, nil, fmt.Errorf("empty sheet")
+	}
+	headerIface := resp.Values[0]
+	headers := make([]string, len(headerIface))
+	for i, h := range headerIface {
+		headers[i] = fmt.Sprintf("%v", h)
+	}
+	rows := [][]string{}
+	for _, r := range resp.Values[1:] {
+		row := make([]string, len(headers))
+		for i := range headers {
+			if i < len(r) {
+				row[i] = fmt.Sprintf("%v", r[i])
+			} else {
+				row[i] = ""
+			}
+		}
+		rows = append(rows, row)
+	}
+	return headers, rows, nil
+}
+
+func a1ColumnName(n int) string {
+	name := ""
+	for n >= 0 {
+		ch := rune('A' + (n % 26))
+		name = string([]rune{ch}) + name
+		n = n/26 - 1
+	}
+	return name
+}
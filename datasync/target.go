@@ -0,0 +1,20 @@
+package datasync
+
+import "context"
+
+type CellUpdate struct {
+	Row   int
+	Col   int
+	Value string
+}
+
+type SyncTarget interface {
+	Name() string
+	EnsureTable(ctx context.Context, table string) error
+	ReadTable(ctx context.Context, table string) ([]string, [][]string, error)
+	WriteTable(ctx context.Context, table string, rows [][]interface{}) error
+	UpdateCells(ctx context.Context, table string, cells []CellUpdate) error
+	AppendRows(ctx context.Context, table string, rows [][]interface{}) error
+	DeleteRows(ctx context.Context, table string, rows []int) error
+	FormatHeader(ctx context.Context, table string, cols int) error
+}
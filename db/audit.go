@@ -0,0 +1,346 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+type AuditEvent struct {
+	ID        int64           `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Entity    string          `json:"entity"`
+	EntityID  string          `json:"entity_id"`
+	Diff      json.RawMessage `json:"diff,omitempty"`
+	IP        string          `json:"ip,omitempty"`
+	RequestID string          `json:"request_id,omitempty"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+}
+
+type AuditEntry struct {
+	Actor     string
+	Action    string
+	Entity    string
+	EntityID  string
+	Before    interface{}
+	After     interface{}
+	IP        string
+	RequestID string
+}
+
+type AuditFilter struct {
+	Actor    string
+	Action   string
+	Entity   string
+	EntityID string
+	Since    time.Time
+	Until    time.Time
+	Offset   int
+	Limit    int
+}
+
+type AuditVerification struct {
+	Checked  int    `json:"checked"`
+	Valid    bool   `json:"valid"`
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	HeadID   int64  `json:"head_id"`
+	HeadHash string `json:"head_hash"`
+}
+
+const auditTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+var auditMu sync.Mutex
+
+var auditRedacted = map[string]bool{
+	"password_hash": true,
+	"secret":        true,
+	"token":         true,
+}
+
+func auditFields(v interface{}) (map[string]interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		return m, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return map[string]interface{}{"value": json.RawMessage(b)}, nil
+	}
+	return m, nil
+}
+
+// AuditDiff keeps the fields that differ as {"field": {"before": x, "after": y}}.
+func AuditDiff(before, after interface{}) (json.RawMessage, error) {
+	b, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil && a == nil {
+		return nil, nil
+	}
+	diff := map[string]map[string]interface{}{}
+	for k, bv := range b {
+		av, ok := a[k]
+		if ok && reflect.DeepEqual(av, bv) {
+			continue
+		}
+		change := map[string]interface{}{"before": bv}
+		if ok {
+			change["after"] = av
+		}
+		diff[k] = change
+	}
+	for k, av := range a {
+		if _, ok := b[k]; !ok {
+			diff[k] = map[string]interface{}{"after": av}
+		}
+	}
+	for k := range diff {
+		if auditRedacted[k] {
+			diff[k] = map[string]interface{}{"redacted": true}
+		}
+	}
+	if len(diff) == 0 {
+		return nil, nil
+	}
+	out, err := json.Marshal(diff)
+	return json.RawMessage(out), err
+}
+
+func auditHash(e *AuditEvent) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s\n%s\n%s\n", e.PrevHash, e.CreatedAt.UTC().Format(auditTimeFormat), e.Actor, e.Action, e.Entity, e.EntityID)
+	h.Write(e.Diff)
+	fmt.Fprintf(h, "\n%s\n%s", e.IP, e.RequestID)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// RecordAudit appends an entry chained to the previous row's hash.
+func (db *Database) RecordAudit(entry AuditEntry) error {
+	diff, err := AuditDiff(entry.Before, entry.After)
+	if err != nil {
+		return fmt.Errorf("failed to diff audit entry: %v", err)
+	}
+	e := &AuditEvent{
+		CreatedAt: time.Now().UTC(),
+		Actor:     entry.Actor,
+		Action:    entry.Action,
+		Entity:    entry.Entity,
+		EntityID:  entry.EntityID,
+		Diff:      diff,
+		IP:        entry.IP,
+		RequestID: entry.RequestID,
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1`).Scan(&e.PrevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	e.Hash = auditHash(e)
+	var diffArg interface{}
+	if len(e.Diff) > 0 {
+		diffArg = string(e.Diff)
+	}
+	if _, err := tx.Exec(`INSERT INTO audit_events (created_at, actor, action, entity, entity_id, diff, ip, request_id, prev_hash, hash) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.CreatedAt.Format(auditTimeFormat), e.Actor, e.Action, e.Entity, e.EntityID, diffArg, e.IP, e.RequestID, e.PrevHash, e.Hash); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const auditEventColumns = `id, created_at, actor, action, entity, entity_id, diff, ip, request_id, prev_hash, hash`
+
+func scanAuditEvent(scan func(...interface{}) error) (*AuditEvent, error) {
+	e := &AuditEvent{}
+	var createdAt string
+	var diff, ip, requestID sql.NullString
+	if err := scan(&e.ID, &createdAt, &e.Actor, &e.Action, &e.Entity, &e.EntityID, &diff, &ip, &requestID, &e.PrevHash, &e.Hash); err != nil {
+		return nil, err
+	}
+	t, err := time.Parse(auditTimeFormat, createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("audit event %d has invalid timestamp %q", e.ID, createdAt)
+	}
+	e.CreatedAt = t
+	if diff.Valid && diff.String != "" {
+		e.Diff = json.RawMessage(diff.String)
+	}
+	e.IP = ip.String
+	e.RequestID = requestID.String
+	return e, nil
+}
+
+func auditWhere(f AuditFilter) (string, []interface{}) {
+	clauses := []string{}
+	args := []interface{}{}
+	if f.Actor != "" {
+		clauses = append(clauses, `actor = ?`)
+		args = append(args, f.Actor)
+	}
+	if f.Action != "" {
+		if strings.HasSuffix(f.Action, "*") {
+			clauses = append(clauses, `action LIKE ?`)
+			args = append(args, strings.TrimSuffix(f.Action, "*")+"%")
+		} else {
+			clauses = append(clauses, `action = ?`)
+			args = append(args, f.Action)
+		}
+	}
+	if f.Entity != "" {
+		clauses = append(clauses, `entity = ?`)
+		args = append(args, f.Entity)
+	}
+	if f.EntityID != "" {
+		clauses = append(clauses, `entity_id = ?`)
+		args = append(args, f.EntityID)
+	}
+	if !f.Since.IsZero() {
+		clauses = append(clauses, `created_at >= ?`)
+		args = append(args, f.Since.UTC().Format(auditTimeFormat))
+	}
+	if !f.Until.IsZero() {
+		clauses = append(clauses, `created_at < ?`)
+		args = append(args, f.Until.UTC().Format(auditTimeFormat))
+	}
+	if len(clauses) == 0 {
+		return "", args
+	}
+	return ` WHERE ` + strings.Join(clauses, ` AND `), args
+}
+
+func (db *Database) ListAuditEvents(f AuditFilter) ([]AuditEvent, int, error) {
+	where, args := auditWhere(f)
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM audit_events`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	query := `SELECT ` + auditEventColumns + ` FROM audit_events` + where + ` ORDER BY id DESC`
+	if f.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, f.Limit, f.Offset)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []AuditEvent{}
+	for rows.Next() {
+		e, err := scanAuditEvent(rows.Scan)
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, *e)
+	}
+	return events, total, rows.Err()
+}
+
+func (db *Database) VerifyAuditChain() (*AuditVerification, error) {
+	rows, err := db.Query(`SELECT ` + auditEventColumns + ` FROM audit_events ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	v := &AuditVerification{Valid: true}
+	prev := ""
+	for rows.Next() {
+		e, err := scanAuditEvent(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		v.Checked++
+		if v.Valid {
+			if e.PrevHash != prev {
+				v.Valid, v.BrokenAt, v.Reason = false, e.ID, "previous hash does not match the preceding entry"
+			} else if auditHash(e) != e.Hash {
+				v.Valid, v.BrokenAt, v.Reason = false, e.ID, "entry contents do not match its hash"
+			}
+		}
+		prev = e.Hash
+		v.HeadID = e.ID
+		v.HeadHash = e.Hash
+	}
+	return v, rows.Err()
+}
+
+func (db *Database) AuditEventsAfter(id int64) ([]AuditEvent, error) {
+	rows, err := db.Query(`SELECT `+auditEventColumns+` FROM audit_events WHERE id > ? ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []AuditEvent{}
+	for rows.Next() {
+		e, err := scanAuditEvent(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *e)
+	}
+	return events, rows.Err()
+}
+
+// CarryAuditEvents keeps the audit trail across a full restore.
+func (db *Database) CarryAuditEvents(events []AuditEvent) (int, error) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	var maxID sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(id) FROM audit_events`).Scan(&maxID); err != nil {
+		return 0, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	carried := 0
+	for _, e := range events {
+		if e.ID <= maxID.Int64 {
+			continue
+		}
+		var diffArg interface{}
+		if len(e.Diff) > 0 {
+			diffArg = string(e.Diff)
+		}
+		if _, err := tx.Exec(`INSERT INTO audit_events (id, created_at, actor, action, entity, entity_id, diff, ip, request_id, prev_hash, hash) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			e.ID, e.CreatedAt.UTC().Format(auditTimeFormat), e.Actor, e.Action, e.Entity, e.EntityID, diffArg, e.IP, e.RequestID, e.PrevHash, e.Hash); err != nil {
+			return 0, err
+		}
+		carried++
+	}
+	return carried, tx.Commit()
+}
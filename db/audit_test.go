@@ -0,0 +1,161 @@
+package db
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAuditDiff(t *testing.T) {
+	type event struct {
+		Name   string `json:"name"`
+		Points int    `json:"points"`
+	}
+	tests := []struct {
+		name          string
+		before, after interface{}
+		want          string
+	}{
+		{"nothing", nil, nil, ""},
+		{"unchanged", event{"Quiz", 10}, event{"Quiz", 10}, ""},
+		{"changed field", event{"Quiz", 10}, event{"Quiz Bowl", 10}, `{"name":{"after":"Quiz Bowl","before":"Quiz"}}`},
+		{"create", nil, event{"Quiz", 10}, `{"name":{"after":"Quiz"},"points":{"after":10}}`},
+		{"delete", map[string]interface{}{"name": "Quiz"}, nil, `{"name":{"before":"Quiz"}}`},
+		{"redacted", map[string]interface{}{"password_hash": "a"}, map[string]interface{}{"password_hash": "b"}, `{"password_hash":{"redacted":true}}`},
+	}
+	for _, tt := range tests {
+		got, err := AuditDiff(tt.before, tt.after)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if tt.want == "" {
+			if got != nil {
+				t.Errorf("%s: diff = %s, want none", tt.name, got)
+			}
+			continue
+		}
+		var g, w interface{}
+		json.Unmarshal(got, &g)
+		json.Unmarshal([]byte(tt.want), &w)
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("%s: diff = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func recordAuditTrail(t *testing.T, database *Database) {
+	t.Helper()
+	entries := []AuditEntry{
+		{Actor: "admin@exun.co", Action: "event.update", Entity: "event", EntityID: "quiz", Before: map[string]interface{}{"name": "Quiz"}, After: map[string]interface{}{"name": "Quiz Bowl"}, IP: "203.0.113.7", RequestID: "r1"},
+		{Actor: "dps@school.edu", Action: "registration.submit", Entity: "registration", EntityID: "quiz"},
+		{Actor: "admin@exun.co", Action: "user.delete", Entity: "user", EntityID: "dps@school.edu"},
+	}
+	for _, e := range entries {
+		if err := database.RecordAudit(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	tests := []struct {
+		name     string
+		tamper   string
+		brokenAt int64
+	}{
+		{"intact", "", 0},
+		{"edited actor", `UPDATE audit_events SET actor = 'someone@else' WHERE id = 2`, 2},
+		{"edited diff", `UPDATE audit_events SET diff = '{}' WHERE id = 1`, 1},
+		{"deleted row", `DELETE FROM audit_events WHERE id = 2`, 3},
+		{"rehashed row", `UPDATE audit_events SET hash = 'x' WHERE id = 1`, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newTestDB(t)
+			recordAuditTrail(t, database)
+			if tt.tamper != "" {
+				if _, err := database.Exec(tt.tamper); err == nil {
+					t.Fatal("audit_events accepted an edit")
+				}
+				if _, err := database.Exec(`DROP TRIGGER audit_events_no_update; DROP TRIGGER audit_events_no_delete`); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := database.Exec(tt.tamper); err != nil {
+					t.Fatal(err)
+				}
+			}
+			v, err := database.VerifyAuditChain()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.Valid != (tt.brokenAt == 0) || v.BrokenAt != tt.brokenAt {
+				t.Errorf("verification = %+v, want broken at %d", v, tt.brokenAt)
+			}
+		})
+	}
+}
+
+func TestListAuditEvents(t *testing.T) {
+	database := newTestDB(t)
+	recordAuditTrail(t, database)
+	tests := []struct {
+		filter AuditFilter
+		want   []int64
+	}{
+		{AuditFilter{}, []int64{3, 2, 1}},
+		{AuditFilter{Actor: "admin@exun.co"}, []int64{3, 1}},
+		{AuditFilter{Action: "registration.*"}, []int64{2}},
+		{AuditFilter{Entity: "event", EntityID: "quiz"}, []int64{1}},
+		{AuditFilter{Limit: 1, Offset: 1}, []int64{2}},
+	}
+	for _, tt := range tests {
+		events, total, err := database.ListAuditEvents(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int64
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("%+v: ids = %v, want %v (total %d)", tt.filter, ids, tt.want, total)
+		}
+	}
+	events, _, _ := database.ListAuditEvents(AuditFilter{EntityID: "quiz", Entity: "event"})
+	if len(events) == 1 && (events[0].IP != "203.0.113.7" || events[0].RequestID != "r1" || events[0].PrevHash != "") {
+		t.Errorf("first event = %+v", events[0])
+	}
+}
+
+func TestAuditEventsAppendOnly(t *testing.T) {
+	database := newTestDB(t)
+	recordAuditTrail(t, database)
+	if _, err := database.Exec(`DROP TRIGGER audit_events_no_update; DROP TRIGGER audit_events_no_delete`); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.InitTables(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		stmt string
+	}{
+		{"update", `UPDATE audit_events SET actor = 'someone@else' WHERE id = 1`},
+		{"update every row", `UPDATE audit_events SET ip = NULL`},
+		{"delete", `DELETE FROM audit_events WHERE id = 3`},
+		{"delete every row", `DELETE FROM audit_events`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := database.Exec(tt.stmt)
+			if err == nil || !strings.Contains(err.Error(), "append-only") {
+				t.Errorf("%s = %v, want the append-only abort", tt.stmt, err)
+			}
+		})
+	}
+	if v, err := database.VerifyAuditChain(); err != nil || !v.Valid || v.Checked != 3 {
+		t.Errorf("chain after refused edits = %+v, %v", v, err)
+	}
+}
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ChangeLogKeys maps each table captured in change_log to its key column.
+var ChangeLogKeys = map[string]string{
+	"users":                    "email",
+	"events":                   "id",
+	"registrations":            "id",
+	"individual_registrations": "id",
+	"usr_regs":                 "id",
+	"logs":                     "id",
+	"email_suppressions":       "id",
+	"email_templates":          "id",
+	"registration_changes":     "id",
+	"team_fields":              "id",
+}
+
+type Change struct {
+	ID        int64                  `json:"id"`
+	Table     string                 `json:"table"`
+	PK        string                 `json:"pk"`
+	Op        string                 `json:"op"`
+	ChangedAt time.Time              `json:"changed_at"`
+	Row       map[string]interface{} `json:"row,omitempty"`
+}
+
+type SyncCursor struct {
+	Target       string    `json:"target"`
+	LastChangeID int64     `json:"last_change_id"`
+	FullAt       time.Time `json:"full_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (db *Database) createChangeTriggers() error {
+	for table, key := range ChangeLogKeys {
+		stmts := []string{
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS change_log_%[1]s_insert AFTER INSERT ON %[1]s BEGIN
+				INSERT INTO change_log (table_name, pk, op, changed_at) VALUES ('%[1]s', NEW.%[2]s, 'insert', CURRENT_TIMESTAMP);
+			END;`, table, key),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS change_log_%[1]s_update AFTER UPDATE ON %[1]s BEGIN
+				INSERT INTO change_log (table_name, pk, op, changed_at) SELECT '%[1]s', OLD.%[2]s, 'delete', CURRENT_TIMESTAMP WHERE OLD.%[2]s IS NOT NEW.%[2]s;
+				INSERT INTO change_log (table_name, pk, op, changed_at) VALUES ('%[1]s', NEW.%[2]s, 'update', CURRENT_TIMESTAMP);
+			END;`, table, key),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS change_log_%[1]s_delete AFTER DELETE ON %[1]s BEGIN
+				INSERT INTO change_log (table_name, pk, op, changed_at) VALUES ('%[1]s', OLD.%[2]s, 'delete', CURRENT_TIMESTAMP);
+			END;`, table, key),
+		}
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				return fmt.Errorf("error creating change_log trigger for %s: %v", table, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (db *Database) ChangeLogBounds() (int64, int64, error) {
+	var minID, maxID sql.NullInt64
+	if err := db.QueryRow(`SELECT MIN(id), MAX(id) FROM change_log`).Scan(&minID, &maxID); err != nil {
+		return 0, 0, err
+	}
+	return minID.Int64, maxID.Int64, nil
+}
+
+func (db *Database) ListChanges(since int64, limit int, tables []string) ([]Change, error) {
+	query := `SELECT id, table_name, pk, op, changed_at FROM change_log WHERE id > ?`
+	args := []interface{}{since}
+	if len(tables) > 0 {
+		query += ` AND table_name IN (?` + strings.Repeat(", ?", len(tables)-1) + `)`
+		for _, t := range tables {
+			args = append(args, t)
+		}
+	}
+	query += ` ORDER BY id LIMIT ?`
+	args = append(args, limit)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []Change{}
+	for rows.Next() {
+		var c Change
+		var pk sql.NullString
+		if err := rows.Scan(&c.ID, &c.Table, &pk, &c.Op, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		c.PK = pk.String
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+func (db *Database) ChangedKeys(after, upTo int64) (map[string]map[string]bool, error) {
+	rows, err := db.Query(`SELECT DISTINCT table_name, pk FROM change_log WHERE id > ? AND id <= ? AND pk IS NOT NULL`, after, upTo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changed := make(map[string]map[string]bool)
+	for rows.Next() {
+		var table, pk string
+		if err := rows.Scan(&table, &pk); err != nil {
+			return nil, err
+		}
+		if changed[table] == nil {
+			changed[table] = map[string]bool{}
+		}
+		changed[table][pk] = true
+	}
+	return changed, rows.Err()
+}
+
+func (db *Database) PruneChangeLog(before time.Time) (int64, error) {
+	res, err := db.Exec(`DELETE FROM change_log WHERE changed_at < ?`, before.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (db *Database) GetSyncCursor(target string) (*SyncCursor, error) {
+	c := &SyncCursor{}
+	err := db.QueryRow(`SELECT target, last_change_id, full_at, updated_at FROM sync_cursors WHERE target = ?`, target).Scan(&c.Target, &c.LastChangeID, &c.FullAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (db *Database) SaveSyncCursor(target string, lastChangeID int64, full bool) error {
+	now := time.Now()
+	if full {
+		_, err := db.Exec(`INSERT INTO sync_cursors (target, last_change_id, full_at, updated_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT(target) DO UPDATE SET last_change_id = excluded.last_change_id, full_at = excluded.full_at, updated_at = excluded.updated_at`,
+			target, lastChangeID, now, now)
+		return err
+	}
+	_, err := db.Exec(`UPDATE sync_cursors SET last_change_id = ?, updated_at = ? WHERE target = ?`, lastChangeID, now, target)
+	return err
+}
+
+func (db *Database) ResetSyncCursors() error {
+	_, err := db.Exec(`DELETE FROM sync_cursors`)
+	return err
+}
+
+// changeFeedColumns leaves out contact details, participants and payloads.
+var changeFeedColumns = map[string][]string{
+	"users":                    {"id", "username", "school_code", "individual", "institution_name", "created_at", "updated_at"},
+	"events":                   {"id", "name", "open_to_all", "participants", "mode", "independent_registration", "points", "dates", "archived_at", "category", "display_order", "teams_per_school", "created_at", "updated_at"},
+	"registrations":            {"id", "event_id", "user_id", "team", "team_name", "status", "created_at", "updated_at"},
+	"individual_registrations": {"id", "user_id", "created_at", "updated_at"},
+	"usr_regs":                 {"id", "username", "institution", "event_id", "created_at", "updated_at"},
+	"logs":                     {"id", "reason", "created_at"},
+	"email_suppressions":       {"id", "category", "created_at"},
+	"email_templates":          {"id", "name", "version", "active", "created_at"},
+	"registration_changes":     {"id", "event_id", "action", "created_at", "digested_at"},
+	"team_fields":              {"id", "user_id", "event_id", "updated_at"},
+}
+
+func (db *Database) ChangeRow(table, pk string) (map[string]interface{}, error) {
+	key, ok := ChangeLogKeys[table]
+	if !ok {
+		return nil, fmt.Errorf("table %s is not captured", table)
+	}
+	rows, err := db.Query(fmt.Sprintf("SELECT * FROM %s WHERE %s = ? LIMIT 1", table, key), pk)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	vals := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return nil, err
+	}
+	row := map[string]interface{}{}
+	for i, c := range cols {
+		if !slices.Contains(changeFeedColumns[table], c) {
+			continue
+		}
+		if b, ok := vals[i].([]byte); ok {
+			row[c] = string(b)
+		} else {
+			row[c] = vals[i]
+		}
+	}
+	return row, nil
+}
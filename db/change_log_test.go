@@ -0,0 +1,117 @@
+package db
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestChangeLogTriggers(t *testing.T) {
+	database := newTestDB(t)
+	_, start, err := database.ChangeLogBounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		query string
+		want  []Change
+	}{
+		{`INSERT INTO events (id, name) VALUES ('quiz', 'Quiz')`, []Change{{Table: "events", PK: "quiz", Op: "insert"}}},
+		{`UPDATE events SET name = 'Quiz Bowl' WHERE id = 'quiz'`, []Change{{Table: "events", PK: "quiz", Op: "update"}}},
+		{`UPDATE events SET id = 'quiz-bowl' WHERE id = 'quiz'`, []Change{{Table: "events", PK: "quiz", Op: "delete"}, {Table: "events", PK: "quiz-bowl", Op: "update"}}},
+		{`DELETE FROM events WHERE id = 'quiz-bowl'`, []Change{{Table: "events", PK: "quiz-bowl", Op: "delete"}}},
+		{`INSERT INTO users (username, email, password_hash) VALUES ('dps', 'dps@school.edu', 'hash')`, []Change{{Table: "users", PK: "dps@school.edu", Op: "insert"}}},
+	}
+	since := start
+	for _, s := range steps {
+		if _, err := database.Exec(s.query); err != nil {
+			t.Fatal(err)
+		}
+		changes, err := database.ListChanges(since, 10, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []Change
+		for _, c := range changes {
+			got = append(got, Change{Table: c.Table, PK: c.PK, Op: c.Op})
+			since = c.ID
+		}
+		if !reflect.DeepEqual(got, s.want) {
+			t.Errorf("%s: changes = %+v, want %+v", s.query, got, s.want)
+		}
+	}
+
+	_, end, _ := database.ChangeLogBounds()
+	changed, err := database.ChangedKeys(start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]bool{
+		"events": {"quiz": true, "quiz-bowl": true},
+		"users":  {"dps@school.edu": true},
+	}
+	if !reflect.DeepEqual(changed, want) {
+		t.Errorf("ChangedKeys = %v", changed)
+	}
+	if only, _ := database.ListChanges(start, 10, []string{"users"}); len(only) != 1 {
+		t.Errorf("table filter returned %+v", only)
+	}
+
+	if _, err := database.ChangeRow("sync_base", "x"); err == nil {
+		t.Error("ChangeRow read a table that is not captured")
+	}
+}
+
+func TestChangeRowColumns(t *testing.T) {
+	database := newTestDB(t)
+	seed := []string{
+		`INSERT INTO users (id, username, email, password_hash, phone_number, registrations) VALUES (1, 'dps', 'dps@school.edu', 'hash', '9876543210', '{"quiz":[{"email":"a@b.c"}]}')`,
+		`INSERT INTO logs (id, reason, content) VALUES (1, 'login', '{"email":"dps@school.edu"}')`,
+		`INSERT INTO usr_regs (id, username, event_id, p1_name, p1_email) VALUES (1, 'dps', 'quiz', 'Asha', 'a@b.c')`,
+		`INSERT INTO team_fields (id, user_id, event_id, fields) VALUES (1, 1, 'quiz', '{"github":"asha"}')`,
+	}
+	for _, q := range seed {
+		if _, err := database.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		table, pk string
+		want      map[string]interface{}
+	}{
+		{"users", "dps@school.edu", map[string]interface{}{"id": int64(1), "username": "dps"}},
+		{"logs", "1", map[string]interface{}{"id": int64(1), "reason": "login"}},
+		{"usr_regs", "1", map[string]interface{}{"id": int64(1), "username": "dps", "event_id": "quiz"}},
+		{"team_fields", "1", map[string]interface{}{"id": int64(1), "user_id": int64(1), "event_id": "quiz"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.table, func(t *testing.T) {
+			row, err := database.ChangeRow(tt.table, tt.pk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for c := range row {
+				if !slices.Contains(changeFeedColumns[tt.table], c) {
+					t.Errorf("column %s is not allowed", c)
+				}
+			}
+			for c, v := range tt.want {
+				if row[c] != v {
+					t.Errorf("%s = %v, want %v", c, row[c], v)
+				}
+			}
+		})
+	}
+
+	for table, key := range ChangeLogKeys {
+		cols, ok := changeFeedColumns[table]
+		if !ok {
+			t.Errorf("captured table %s has no feed columns", table)
+		}
+		if table != "users" && !slices.Contains(cols, key) {
+			t.Errorf("feed columns of %s leave out its key %s", table, key)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -13,13 +14,17 @@ import (
 
 type Database struct {
 	*sql.DB
+	paused atomic.Int32
 }
 
 type Participant struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
-	Class int    `json:"class"`
-	Phone string `json:"phone"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Class  int    `json:"class"`
+	Phone  string `json:"phone"`
+	Gender string `json:"gender,omitempty"`
+
+	Fields map[string]string `json:"fields,omitempty"`
 }
 
 type User struct {
@@ -85,27 +90,35 @@ func (u *User) unmarshalRegistrations(data string) error {
 }
 
 type Event struct {
-	ID                      string    `json:"id"`
-	Name                    string    `json:"name"`
-	Image                   string    `json:"image"`
-	OpenToAll               bool      `json:"open_to_all"`
-	Eligibility             string    `json:"eligibility"`
-	Participants            int       `json:"participants"`
-	Mode                    string    `json:"mode"`
-	IndependentRegistration bool      `json:"independent_registration"`
-	Points                  int       `json:"points"`
-	Dates                   string    `json:"dates"`
-	DescriptionLong         string    `json:"description_long"`
-	DescriptionShort        string    `json:"description_short"`
-	CreatedAt               time.Time `json:"created_at"`
-	UpdatedAt               time.Time `json:"updated_at"`
+	ID                      string     `json:"id"`
+	Name                    string     `json:"name"`
+	Image                   string     `json:"image"`
+	OpenToAll               bool       `json:"open_to_all"`
+	Eligibility             string     `json:"eligibility"`
+	EligibilityRules        string     `json:"eligibility_rules,omitempty"`
+	FormFields              string     `json:"form_fields,omitempty"`
+	Participants            int        `json:"participants"`
+	TeamsPerSchool          int        `json:"teams_per_school"`
+	Mode                    string     `json:"mode"`
+	IndependentRegistration bool       `json:"independent_registration"`
+	Points                  int        `json:"points"`
+	Dates                   string     `json:"dates"`
+	DescriptionLong         string     `json:"description_long"`
+	DescriptionShort        string     `json:"description_short"`
+	Category                string     `json:"category"`
+	DisplayOrder            int        `json:"display_order"`
+	ArchivedAt              *time.Time `json:"archived_at,omitempty"`
+	CreatedAt               time.Time  `json:"created_at"`
+	UpdatedAt               time.Time  `json:"updated_at"`
 }
 
 type Registration struct {
 	ID        int       `json:"id"`
 	EventID   string    `json:"event_id"`
 	UserID    int       `json:"user_id"`
+	Team      int       `json:"team"`
 	TeamName  string    `json:"team_name"`
+	Captain   string    `json:"captain,omitempty"`
 	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -138,13 +151,51 @@ func NewConnection(dbPath string) (*Database, error) {
 	}
 
 	log.Println("Successfully connected to SQLite database")
-	return &Database{db}, nil
+	return &Database{DB: db}, nil
 }
 
 func (db *Database) Close() error {
 	return db.DB.Close()
 }
 
+// Pause makes background jobs skip their runs until Resume. Pauses nest.
+func (db *Database) Pause() {
+	db.paused.Add(1)
+}
+
+func (db *Database) Resume() {
+	db.paused.Add(-1)
+}
+
+func (db *Database) Paused() bool {
+	return db.paused.Load() > 0
+}
+
+func (db *Database) addColumnIfMissing(table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var dflt interface{}
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 func (db *Database) InitTables() error {
 	createUsersTable := `
 	CREATE TABLE IF NOT EXISTS users (
@@ -200,6 +251,12 @@ func (db *Database) InitTables() error {
 	CREATE INDEX IF NOT EXISTS idx_events_name ON events(name);
 	CREATE INDEX IF NOT EXISTS idx_registrations_event_user ON registrations(event_id, user_id);
 	CREATE INDEX IF NOT EXISTS idx_registrations_status ON registrations(status);
+	CREATE INDEX IF NOT EXISTS idx_sync_conflicts_open ON sync_conflicts(table_name, pk, column_name, status);
+	CREATE INDEX IF NOT EXISTS idx_change_log_table ON change_log(table_name, id);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_entity ON audit_events(entity, entity_id);
+	CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events(actor);
 	`
 
 	if _, err := db.Exec(createUsersTable); err != nil {
@@ -210,10 +267,52 @@ func (db *Database) InitTables() error {
 		return fmt.Errorf("error creating events table: %v", err)
 	}
 
+	if err := db.addColumnIfMissing("events", "archived_at", "DATETIME"); err != nil {
+		return fmt.Errorf("error migrating events table: %v", err)
+	}
+
+	if err := db.addColumnIfMissing("events", "category", "TEXT DEFAULT ''"); err != nil {
+		return fmt.Errorf("error migrating events table: %v", err)
+	}
+
+	if err := db.addColumnIfMissing("events", "display_order", "INTEGER DEFAULT 0"); err != nil {
+		return fmt.Errorf("error migrating events table: %v", err)
+	}
+
+	if err := db.addColumnIfMissing("events", "eligibility_rules", "TEXT DEFAULT ''"); err != nil {
+		return fmt.Errorf("error migrating events table: %v", err)
+	}
+
+	if err := db.addColumnIfMissing("events", "form_fields", "TEXT DEFAULT ''"); err != nil {
+		return fmt.Errorf("error migrating events table: %v", err)
+	}
+
+	if err := db.addColumnIfMissing("events", "teams_per_school", "INTEGER DEFAULT 1"); err != nil {
+		return fmt.Errorf("error migrating events table: %v", err)
+	}
+
 	if _, err := db.Exec(createRegistrationsTable); err != nil {
 		return fmt.Errorf("error creating registrations table: %v", err)
 	}
 
+	if err := db.addColumnIfMissing("registrations", "team", "INTEGER DEFAULT 1"); err != nil {
+		return fmt.Errorf("error migrating registrations table: %v", err)
+	}
+
+	if err := db.addColumnIfMissing("registrations", "captain", "TEXT DEFAULT ''"); err != nil {
+		return fmt.Errorf("error migrating registrations table: %v", err)
+	}
+
+	// Keep one registration row per team, the latest.
+	if _, err := db.Exec(`DELETE FROM registrations WHERE id NOT IN (
+		SELECT MAX(id) FROM registrations GROUP BY user_id, event_id, COALESCE(team, 1))`); err != nil {
+		return fmt.Errorf("error migrating registrations table: %v", err)
+	}
+
+	if _, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_registrations_team ON registrations(user_id, event_id, team)`); err != nil {
+		return fmt.Errorf("error migrating registrations table: %v", err)
+	}
+
 	createIndividualRegistrationsTable := `
 	CREATE TABLE IF NOT EXISTS individual_registrations (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -264,6 +363,271 @@ func (db *Database) InitTables() error {
 		return fmt.Errorf("error creating usr_regs table: %v", err)
 	}
 
+	createEmailSuppressionsTable := `
+	CREATE TABLE IF NOT EXISTS email_suppressions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		email TEXT NOT NULL,
+		category TEXT NOT NULL,
+		reason TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(email, category)
+	);`
+
+	if _, err := db.Exec(createEmailSuppressionsTable); err != nil {
+		return fmt.Errorf("error creating email_suppressions table: %v", err)
+	}
+
+	createEmailTemplatesTable := `
+	CREATE TABLE IF NOT EXISTS email_templates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		version INTEGER NOT NULL,
+		body TEXT NOT NULL,
+		active BOOLEAN DEFAULT FALSE,
+		created_by TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(name, version)
+	);`
+
+	if _, err := db.Exec(createEmailTemplatesTable); err != nil {
+		return fmt.Errorf("error creating email_templates table: %v", err)
+	}
+
+	createRegistrationChangesTable := `
+	CREATE TABLE IF NOT EXISTS registration_changes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_email TEXT NOT NULL,
+		event_id TEXT NOT NULL,
+		action TEXT NOT NULL,
+		payload TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		digested_at DATETIME
+	);`
+
+	if _, err := db.Exec(createRegistrationChangesTable); err != nil {
+		return fmt.Errorf("error creating registration_changes table: %v", err)
+	}
+
+	createTeamFieldsTable := `
+	CREATE TABLE IF NOT EXISTS team_fields (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		event_id TEXT NOT NULL,
+		fields TEXT NOT NULL DEFAULT '{}',
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(user_id, event_id),
+		FOREIGN KEY (user_id) REFERENCES users (id)
+	);`
+
+	if _, err := db.Exec(createTeamFieldsTable); err != nil {
+		return fmt.Errorf("error creating team_fields table: %v", err)
+	}
+
+	createOAuthTokensTable := `
+	CREATE TABLE IF NOT EXISTS oauth_tokens (
+		provider TEXT PRIMARY KEY,
+		token TEXT NOT NULL,
+		connected_by TEXT,
+		connected_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		refreshed_at DATETIME,
+		expiry DATETIME,
+		last_error TEXT,
+		last_error_at DATETIME
+	);`
+
+	if _, err := db.Exec(createOAuthTokensTable); err != nil {
+		return fmt.Errorf("error creating oauth_tokens table: %v", err)
+	}
+
+	createSyncBaseTable := `
+	CREATE TABLE IF NOT EXISTS sync_base (
+		table_name TEXT NOT NULL,
+		pk TEXT NOT NULL,
+		data TEXT NOT NULL,
+		synced_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (table_name, pk)
+	);`
+
+	if _, err := db.Exec(createSyncBaseTable); err != nil {
+		return fmt.Errorf("error creating sync_base table: %v", err)
+	}
+
+	createSyncConflictsTable := `
+	CREATE TABLE IF NOT EXISTS sync_conflicts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		table_name TEXT NOT NULL,
+		pk TEXT NOT NULL,
+		column_name TEXT NOT NULL,
+		base_value TEXT,
+		sheet_value TEXT,
+		db_value TEXT,
+		status TEXT NOT NULL DEFAULT 'open',
+		resolution TEXT,
+		resolved_value TEXT,
+		resolved_by TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		resolved_at DATETIME
+	);`
+
+	if _, err := db.Exec(createSyncConflictsTable); err != nil {
+		return fmt.Errorf("error creating sync_conflicts table: %v", err)
+	}
+
+	createSyncRunsTable := `
+	CREATE TABLE IF NOT EXISTS sync_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		target TEXT NOT NULL,
+		triggered_by TEXT NOT NULL,
+		dry_run BOOLEAN DEFAULT FALSE,
+		incremental BOOLEAN DEFAULT FALSE,
+		status TEXT NOT NULL DEFAULT 'running',
+		tables TEXT,
+		error TEXT,
+		started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		finished_at DATETIME
+	);`
+
+	if _, err := db.Exec(createSyncRunsTable); err != nil {
+		return fmt.Errorf("error creating sync_runs table: %v", err)
+	}
+
+	if err := db.addColumnIfMissing("sync_runs", "incremental", "BOOLEAN DEFAULT FALSE"); err != nil {
+		return fmt.Errorf("error migrating sync_runs table: %v", err)
+	}
+
+	createChangeLogTable := `
+	CREATE TABLE IF NOT EXISTS change_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		table_name TEXT NOT NULL,
+		pk TEXT,
+		op TEXT NOT NULL,
+		changed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := db.Exec(createChangeLogTable); err != nil {
+		return fmt.Errorf("error creating change_log table: %v", err)
+	}
+
+	createSyncCursorsTable := `
+	CREATE TABLE IF NOT EXISTS sync_cursors (
+		target TEXT PRIMARY KEY,
+		last_change_id INTEGER NOT NULL DEFAULT 0,
+		full_at DATETIME,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := db.Exec(createSyncCursorsTable); err != nil {
+		return fmt.Errorf("error creating sync_cursors table: %v", err)
+	}
+
+	createWebhooksTable := `
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL DEFAULT '[]',
+		description TEXT,
+		active BOOLEAN DEFAULT TRUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := db.Exec(createWebhooksTable); err != nil {
+		return fmt.Errorf("error creating webhooks table: %v", err)
+	}
+
+	createWebhookDeliveriesTable := `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER NOT NULL,
+		event_id TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		response_status INTEGER,
+		response_body TEXT,
+		error TEXT,
+		next_attempt_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		delivered_at DATETIME
+	);`
+
+	if _, err := db.Exec(createWebhookDeliveriesTable); err != nil {
+		return fmt.Errorf("error creating webhook_deliveries table: %v", err)
+	}
+
+	createAuditEventsTable := `
+	CREATE TABLE IF NOT EXISTS audit_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at TEXT NOT NULL,
+		actor TEXT NOT NULL,
+		action TEXT NOT NULL,
+		entity TEXT NOT NULL,
+		entity_id TEXT NOT NULL DEFAULT '',
+		diff TEXT,
+		ip TEXT,
+		request_id TEXT,
+		prev_hash TEXT NOT NULL,
+		hash TEXT NOT NULL
+	);
+	CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events BEGIN
+		SELECT RAISE(ABORT, 'audit_events is append-only');
+	END;
+	CREATE TRIGGER IF NOT EXISTS audit_events_no_delete BEFORE DELETE ON audit_events BEGIN
+		SELECT RAISE(ABORT, 'audit_events is append-only');
+	END;`
+
+	if _, err := db.Exec(createAuditEventsTable); err != nil {
+		return fmt.Errorf("error creating audit_events table: %v", err)
+	}
+
+	createQueryLimitsTables := `
+	CREATE TABLE IF NOT EXISTS llm_usage (
+		day TEXT PRIMARY KEY,
+		calls INTEGER NOT NULL DEFAULT 0,
+		tokens INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE IF NOT EXISTS query_strikes (
+		client TEXT PRIMARY KEY,
+		strikes INTEGER NOT NULL DEFAULT 0,
+		last_strike_at DATETIME,
+		cooldown_until DATETIME
+	);`
+
+	if _, err := db.Exec(createQueryLimitsTables); err != nil {
+		return fmt.Errorf("error creating query limit tables: %v", err)
+	}
+	for _, col := range []struct{ name, def string }{
+		{"blocked", "BOOLEAN DEFAULT FALSE"},
+		{"blocked_by", "TEXT"},
+		{"blocked_at", "DATETIME"},
+		{"block_reason", "TEXT"},
+	} {
+		if err := db.addColumnIfMissing("query_strikes", col.name, col.def); err != nil {
+			return fmt.Errorf("error migrating query_strikes table: %v", err)
+		}
+	}
+
+	createQueryReviewsTable := `
+	CREATE TABLE IF NOT EXISTS query_reviews (
+		log_id INTEGER PRIMARY KEY,
+		state TEXT NOT NULL,
+		note TEXT,
+		reviewed_by TEXT NOT NULL,
+		reviewed_at DATETIME NOT NULL
+	);`
+
+	if _, err := db.Exec(createQueryReviewsTable); err != nil {
+		return fmt.Errorf("error creating query_reviews table: %v", err)
+	}
+
+	if err := db.createChangeTriggers(); err != nil {
+		return err
+	}
+
 	if _, err := db.Exec(createIndexes); err != nil {
 		return fmt.Errorf("error creating indexes: %v", err)
 	}
@@ -309,23 +673,27 @@ func (db *Database) Get(entity string, key string) (interface{}, error) {
 
 	case "events":
 		query := `SELECT id, name, image, open_to_all, eligibility, participants, mode, 
-			independent_registration, points, dates, description_long, description_short, created_at, updated_at 
+			independent_registration, points, dates, description_long, description_short, COALESCE(category, ''), COALESCE(display_order, 0), COALESCE(eligibility_rules, ''), COALESCE(form_fields, ''), COALESCE(teams_per_school, 1), archived_at, created_at, updated_at 
 			FROM events WHERE id = ?`
 		event := &Event{}
+		var archivedAt sql.NullTime
 		err := db.QueryRow(query, key).Scan(
 			&event.ID, &event.Name, &event.Image, &event.OpenToAll, &event.Eligibility,
 			&event.Participants, &event.Mode, &event.IndependentRegistration, &event.Points, &event.Dates,
-			&event.DescriptionLong, &event.DescriptionShort, &event.CreatedAt, &event.UpdatedAt)
+			&event.DescriptionLong, &event.DescriptionShort, &event.Category, &event.DisplayOrder, &event.EligibilityRules, &event.FormFields, &event.TeamsPerSchool, &archivedAt, &event.CreatedAt, &event.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
+		if archivedAt.Valid {
+			event.ArchivedAt = &archivedAt.Time
+		}
 		return event, nil
 
 	case "registrations":
-		query := `SELECT id, event_id, user_id, team_name, status, created_at, updated_at FROM registrations WHERE id = ?`
+		query := `SELECT id, event_id, user_id, COALESCE(team, 1), team_name, COALESCE(captain, ''), status, created_at, updated_at FROM registrations WHERE id = ?`
 		reg := &Registration{}
 		err := db.QueryRow(query, key).Scan(
-			&reg.ID, &reg.EventID, &reg.UserID, &reg.TeamName, &reg.Status, &reg.CreatedAt, &reg.UpdatedAt)
+			&reg.ID, &reg.EventID, &reg.UserID, &reg.Team, &reg.TeamName, &reg.Captain, &reg.Status, &reg.CreatedAt, &reg.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -384,11 +752,11 @@ func (db *Database) Create(entity string, data interface{}) error {
 			return fmt.Errorf("invalid event data")
 		}
 		query := `INSERT INTO events (id, name, image, open_to_all, eligibility, participants, mode, 
-			independent_registration, points, dates, description_long, description_short, created_at, updated_at)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+			independent_registration, points, dates, description_long, description_short, category, display_order, eligibility_rules, form_fields, teams_per_school, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 		_, err := db.Exec(query, event.ID, event.Name, event.Image, event.OpenToAll, event.Eligibility,
 			event.Participants, event.Mode, event.IndependentRegistration, event.Points, event.Dates,
-			event.DescriptionLong, event.DescriptionShort, now, now)
+			event.DescriptionLong, event.DescriptionShort, event.Category, event.DisplayOrder, event.EligibilityRules, event.FormFields, event.TeamsPerSchool, now, now)
 		if err != nil {
 			log.Printf("db.Create(events) error: %v", err)
 		}
@@ -399,8 +767,8 @@ func (db *Database) Create(entity string, data interface{}) error {
 		if !ok {
 			return fmt.Errorf("invalid registration data")
 		}
-		query := `INSERT INTO registrations (event_id, user_id, team_name, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`
-		_, err := db.Exec(query, reg.EventID, reg.UserID, reg.TeamName, reg.Status, now, now)
+		query := `INSERT INTO registrations (event_id, user_id, team, team_name, captain, status, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+		_, err := db.Exec(query, reg.EventID, reg.UserID, teamNumber(reg.Team), reg.TeamName, reg.Captain, reg.Status, now, now)
 		if err != nil {
 			log.Printf("db.Create(registrations) error: %v", err)
 		}
@@ -462,10 +830,10 @@ func (db *Database) Update(entity string, key string, data interface{}) error {
 		}
 		query := `UPDATE events SET name = ?, image = ?, open_to_all = ?, eligibility = ?, participants = ?, 
 			mode = ?, independent_registration = ?, points = ?, dates = ?, description_long = ?, 
-			description_short = ?, updated_at = ? WHERE id = ?`
+			description_short = ?, category = ?, display_order = ?, eligibility_rules = ?, form_fields = ?, teams_per_school = ?, updated_at = ? WHERE id = ?`
 		_, err := db.Exec(query, event.Name, event.Image, event.OpenToAll, event.Eligibility,
 			event.Participants, event.Mode, event.IndependentRegistration, event.Points, event.Dates,
-			event.DescriptionLong, event.DescriptionShort, now, key)
+			event.DescriptionLong, event.DescriptionShort, event.Category, event.DisplayOrder, event.EligibilityRules, event.FormFields, event.TeamsPerSchool, now, key)
 		if err != nil {
 			log.Printf("db.Update(events) error: %v", err)
 		}
@@ -476,8 +844,8 @@ func (db *Database) Update(entity string, key string, data interface{}) error {
 		if !ok {
 			return fmt.Errorf("invalid registration data")
 		}
-		query := `UPDATE registrations SET event_id = ?, user_id = ?, team_name = ?, status = ?, updated_at = ? WHERE id = ?`
-		_, err := db.Exec(query, reg.EventID, reg.UserID, reg.TeamName, reg.Status, now, key)
+		query := `UPDATE registrations SET event_id = ?, user_id = ?, team = ?, team_name = ?, captain = ?, status = ?, updated_at = ? WHERE id = ?`
+		_, err := db.Exec(query, reg.EventID, reg.UserID, teamNumber(reg.Team), reg.TeamName, reg.Captain, reg.Status, now, key)
 		if err != nil {
 			log.Printf("db.Update(registrations) error: %v", err)
 		}
@@ -567,7 +935,8 @@ func (db *Database) GetAll(entity string) ([]interface{}, error) {
 
 	case "events":
 		query := `SELECT id, name, image, open_to_all, eligibility, participants, mode, 
-			independent_registration, points, dates, description_long, description_short, created_at, updated_at FROM events`
+			independent_registration, points, dates, description_long, description_short, COALESCE(category, ''), COALESCE(display_order, 0), COALESCE(eligibility_rules, ''), COALESCE(form_fields, ''), COALESCE(teams_per_school, 1), archived_at, created_at, updated_at 
+			FROM events ORDER BY display_order, name`
 		rows, err := db.Query(query)
 		if err != nil {
 			return nil, err
@@ -577,18 +946,22 @@ func (db *Database) GetAll(entity string) ([]interface{}, error) {
 		var events []interface{}
 		for rows.Next() {
 			event := &Event{}
+			var archivedAt sql.NullTime
 			err := rows.Scan(&event.ID, &event.Name, &event.Image, &event.OpenToAll, &event.Eligibility,
 				&event.Participants, &event.Mode, &event.IndependentRegistration, &event.Points, &event.Dates,
-				&event.DescriptionLong, &event.DescriptionShort, &event.CreatedAt, &event.UpdatedAt)
+				&event.DescriptionLong, &event.DescriptionShort, &event.Category, &event.DisplayOrder, &event.EligibilityRules, &event.FormFields, &event.TeamsPerSchool, &archivedAt, &event.CreatedAt, &event.UpdatedAt)
 			if err != nil {
 				return nil, err
 			}
+			if archivedAt.Valid {
+				event.ArchivedAt = &archivedAt.Time
+			}
 			events = append(events, event)
 		}
 		return events, nil
 
 	case "registrations":
-		query := `SELECT id, event_id, user_id, team_name, status, created_at, updated_at FROM registrations`
+		query := `SELECT id, event_id, user_id, COALESCE(team, 1), team_name, COALESCE(captain, ''), status, created_at, updated_at FROM registrations`
 		rows, err := db.Query(query)
 		if err != nil {
 			return nil, err
@@ -598,7 +971,7 @@ func (db *Database) GetAll(entity string) ([]interface{}, error) {
 		var registrations []interface{}
 		for rows.Next() {
 			reg := &Registration{}
-			err := rows.Scan(&reg.ID, &reg.EventID, &reg.UserID, &reg.TeamName, &reg.Status, &reg.CreatedAt, &reg.UpdatedAt)
+			err := rows.Scan(&reg.ID, &reg.EventID, &reg.UserID, &reg.Team, &reg.TeamName, &reg.Captain, &reg.Status, &reg.CreatedAt, &reg.UpdatedAt)
 			if err != nil {
 				return nil, err
 			}
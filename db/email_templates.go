@@ -0,0 +1,117 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type EmailTemplate struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Version   int       `json:"version"`
+	Body      string    `json:"body"`
+	Active    bool      `json:"active"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (db *Database) ActiveEmailTemplate(name string) (string, error) {
+	var body string
+	err := db.QueryRow(`SELECT body FROM email_templates WHERE name = ? AND active = 1 ORDER BY version DESC LIMIT 1`, name).Scan(&body)
+	if err != nil {
+		return "", err
+	}
+	return body, nil
+}
+
+func (db *Database) GetEmailTemplateVersion(name string, version int) (*EmailTemplate, error) {
+	t := &EmailTemplate{}
+	var createdBy sql.NullString
+	err := db.QueryRow(`SELECT id, name, version, body, active, created_by, created_at FROM email_templates WHERE name = ? AND version = ?`, name, version).Scan(
+		&t.ID, &t.Name, &t.Version, &t.Body, &t.Active, &createdBy, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	t.CreatedBy = createdBy.String
+	return t, nil
+}
+
+func (db *Database) ListEmailTemplateVersions(name string) ([]EmailTemplate, error) {
+	rows, err := db.Query(`SELECT id, name, version, body, active, created_by, created_at FROM email_templates WHERE name = ? ORDER BY version DESC`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []EmailTemplate{}
+	for rows.Next() {
+		var t EmailTemplate
+		var createdBy sql.NullString
+		if err := rows.Scan(&t.ID, &t.Name, &t.Version, &t.Body, &t.Active, &createdBy, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		t.CreatedBy = createdBy.String
+		versions = append(versions, t)
+	}
+	return versions, rows.Err()
+}
+
+func (db *Database) CreateEmailTemplateVersion(name, body, createdBy string) (*EmailTemplate, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var maxVersion sql.NullInt64
+	if err := tx.QueryRow(`SELECT MAX(version) FROM email_templates WHERE name = ?`, name).Scan(&maxVersion); err != nil {
+		return nil, err
+	}
+	version := int(maxVersion.Int64) + 1
+	now := time.Now()
+	if _, err := tx.Exec(`UPDATE email_templates SET active = 0 WHERE name = ?`, name); err != nil {
+		return nil, err
+	}
+	res, err := tx.Exec(`INSERT INTO email_templates (name, version, body, active, created_by, created_at) VALUES (?, ?, ?, 1, ?, ?)`, name, version, body, createdBy, now)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &EmailTemplate{ID: int(id), Name: name, Version: version, Body: body, Active: true, CreatedBy: createdBy, CreatedAt: now}, nil
+}
+
+func (db *Database) ActivateEmailTemplateVersion(name string, version int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM email_templates WHERE name = ? AND version = ?`, name, version).Scan(&exists); err != nil {
+		return err
+	}
+	if exists == 0 {
+		return fmt.Errorf("template %s has no version %d", name, version)
+	}
+	if _, err := tx.Exec(`UPDATE email_templates SET active = (version = ?) WHERE name = ?`, version, name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (db *Database) SeedEmailTemplate(name, body string) error {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM email_templates WHERE name = ?`, name).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := db.CreateEmailTemplateVersion(name, body, "seed")
+	return err
+}
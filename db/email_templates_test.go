@@ -0,0 +1,59 @@
+package db
+
+import "testing"
+
+func TestEmailTemplateVersions(t *testing.T) {
+	database := newTestDB(t)
+	if err := database.SeedEmailTemplate("invite", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SeedEmailTemplate("invite", "seeded again"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.CreateEmailTemplateVersion("invite", "v2", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		activate int
+		want     string
+	}{
+		{0, "v2"},
+		{1, "v1"},
+		{2, "v2"},
+	}
+	for _, s := range steps {
+		if s.activate > 0 {
+			if err := database.ActivateEmailTemplateVersion("invite", s.activate); err != nil {
+				t.Fatal(err)
+			}
+		}
+		body, err := database.ActiveEmailTemplate("invite")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != s.want {
+			t.Errorf("after activating %d: active body = %q, want %q", s.activate, body, s.want)
+		}
+	}
+
+	versions, err := database.ListEmailTemplateVersions("invite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 {
+		t.Fatalf("versions = %+v", versions)
+	}
+	active := 0
+	for _, v := range versions {
+		if v.Active {
+			active++
+		}
+	}
+	if active != 1 {
+		t.Errorf("%d active versions, want 1", active)
+	}
+	if err := database.ActivateEmailTemplateVersion("invite", 9); err == nil {
+		t.Error("activating a missing version succeeded")
+	}
+}
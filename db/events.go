@@ -0,0 +1,275 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type EventImpactTeam struct {
+	UserID       int    `json:"user_id"`
+	Email        string `json:"email"`
+	Institution  string `json:"institution"`
+	Team         int    `json:"team"`
+	Participants int    `json:"participants"`
+}
+
+// EventImpact describes everything that refers to an event and would be
+// removed along with it.
+type EventImpact struct {
+	EventID       string            `json:"event_id"`
+	Teams         int               `json:"teams"`
+	Participants  int               `json:"participants"`
+	Registrations int               `json:"registrations"`
+	UsrRegs       int               `json:"usr_regs"`
+	Changes       int               `json:"registration_changes"`
+	TeamList      []EventImpactTeam `json:"team_list"`
+}
+
+func (db *Database) EventImpact(eventID string) (*EventImpact, error) {
+	return eventImpact(db, eventID)
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func eventImpact(q queryer, eventID string) (*EventImpact, error) {
+	impact := &EventImpact{EventID: eventID, TeamList: []EventImpactTeam{}}
+
+	rows, err := q.Query(`SELECT id, email, COALESCE(institution_name, ''), COALESCE(registrations, '{}') FROM users WHERE registrations LIKE ?`, "%"+eventID+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var team EventImpactTeam
+		var regsStr string
+		if err := rows.Scan(&team.UserID, &team.Email, &team.Institution, &regsStr); err != nil {
+			return nil, err
+		}
+		var regs map[string][]Participant
+		if err := json.Unmarshal([]byte(regsStr), &regs); err != nil {
+			continue
+		}
+		for key, participants := range regs {
+			if !IsTeamOf(key, eventID) {
+				continue
+			}
+			_, team.Team = SplitTeamKey(key)
+			team.Participants = len(participants)
+			impact.Teams++
+			impact.Participants += team.Participants
+			impact.TeamList = append(impact.TeamList, team)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := q.QueryRow(`SELECT COUNT(*) FROM registrations WHERE event_id = ?`, eventID).Scan(&impact.Registrations); err != nil {
+		return nil, err
+	}
+	if err := q.QueryRow(`SELECT COUNT(*) FROM usr_regs WHERE event_id = ? OR event_id LIKE ?`, eventID, eventID+teamKeySeparator+"%").Scan(&impact.UsrRegs); err != nil {
+		return nil, err
+	}
+	if err := q.QueryRow(`SELECT COUNT(*) FROM registration_changes WHERE event_id = ? OR event_id LIKE ?`, eventID, eventID+teamKeySeparator+"%").Scan(&impact.Changes); err != nil {
+		return nil, err
+	}
+	return impact, nil
+}
+
+func (db *Database) SetEventArchived(eventID string, archived bool) error {
+	var archivedAt interface{}
+	if archived {
+		archivedAt = time.Now()
+	}
+	res, err := db.Exec(`UPDATE events SET archived_at = ?, updated_at = ? WHERE id = ?`, archivedAt, time.Now(), eventID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteEventCascade removes an event and everything that refers to it.
+func (db *Database) DeleteEventCascade(eventID string) (*EventImpact, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	impact, err := eventImpact(tx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, team := range impact.TeamList {
+		var regsStr string
+		if err := tx.QueryRow(`SELECT COALESCE(registrations, '{}') FROM users WHERE id = ?`, team.UserID).Scan(&regsStr); err != nil {
+			return nil, err
+		}
+		u := &User{}
+		if err := u.unmarshalRegistrations(regsStr); err != nil {
+			return nil, fmt.Errorf("error reading registrations of %s: %v", team.Email, err)
+		}
+		for key := range u.Registrations {
+			if IsTeamOf(key, eventID) {
+				delete(u.Registrations, key)
+			}
+		}
+		if _, err := tx.Exec(`UPDATE users SET registrations = ?, updated_at = ? WHERE id = ?`, u.marshalRegistrations(), now, team.UserID); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec(`DELETE FROM registrations WHERE event_id = ?`, eventID); err != nil {
+		return nil, err
+	}
+	for _, table := range []string{"usr_regs", "team_fields", "registration_changes"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE event_id = ? OR event_id LIKE ?`, eventID, eventID+teamKeySeparator+"%"); err != nil {
+			return nil, err
+		}
+	}
+	res, err := tx.Exec(`DELETE FROM events WHERE id = ?`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return impact, tx.Commit()
+}
+
+func (db *Database) RenameEvent(oldID, newID string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM events WHERE id = ?`, newID).Scan(&exists); err != nil {
+		return err
+	}
+	if exists > 0 {
+		return fmt.Errorf("an event with id %s already exists", newID)
+	}
+
+	impact, err := eventImpact(tx, oldID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, team := range impact.TeamList {
+		var regsStr string
+		if err := tx.QueryRow(`SELECT COALESCE(registrations, '{}') FROM users WHERE id = ?`, team.UserID).Scan(&regsStr); err != nil {
+			return err
+		}
+		u := &User{}
+		if err := u.unmarshalRegistrations(regsStr); err != nil {
+			return fmt.Errorf("error reading registrations of %s: %v", team.Email, err)
+		}
+		for key, parts := range u.Registrations {
+			if id, n := SplitTeamKey(key); id == oldID {
+				delete(u.Registrations, key)
+				u.Registrations[TeamKey(newID, n)] = parts
+			}
+		}
+		if _, err := tx.Exec(`UPDATE users SET registrations = ?, updated_at = ? WHERE id = ?`, u.marshalRegistrations(), now, team.UserID); err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.Exec(`UPDATE events SET id = ?, updated_at = ? WHERE id = ?`, newID, now, oldID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	for _, table := range []string{"registrations", "usr_regs", "registration_changes", "team_fields"} {
+		if _, err := tx.Exec(`UPDATE `+table+` SET event_id = ? WHERE event_id = ?`, newID, oldID); err != nil {
+			return err
+		}
+	}
+	for _, table := range []string{"usr_regs", "team_fields"} {
+		if _, err := tx.Exec(`UPDATE `+table+` SET event_id = ? || substr(event_id, ?) WHERE event_id LIKE ?`,
+			newID, len(oldID)+1, oldID+teamKeySeparator+"%"); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Events missing from ids keep their order after the listed ones.
+func (db *Database) SetEventOrder(ids []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	n := len(ids)
+	if _, err := tx.Exec(`UPDATE events SET display_order = display_order + ?`, n); err != nil {
+		return err
+	}
+	now := time.Now()
+	for i, id := range ids {
+		res, err := tx.Exec(`UPDATE events SET display_order = ?, updated_at = ? WHERE id = ?`, i+1, now, id)
+		if err != nil {
+			return err
+		}
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			return fmt.Errorf("unknown event %s", id)
+		}
+	}
+	return tx.Commit()
+}
+
+func (db *Database) NextEventDisplayOrder() (int, error) {
+	var max sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(display_order) FROM events`).Scan(&max); err != nil {
+		return 0, err
+	}
+	return int(max.Int64) + 1, nil
+}
+
+// SaveEvents upserts every event in one transaction, keeping created_at.
+func (db *Database) SaveEvents(events []Event) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO events (id, name, image, open_to_all, eligibility, participants, mode,
+		independent_registration, points, dates, description_long, description_short, category, display_order,
+		eligibility_rules, form_fields, teams_per_school, archived_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET name = excluded.name, image = excluded.image, open_to_all = excluded.open_to_all,
+		eligibility = excluded.eligibility, participants = excluded.participants, mode = excluded.mode,
+		independent_registration = excluded.independent_registration, points = excluded.points, dates = excluded.dates,
+		description_long = excluded.description_long, description_short = excluded.description_short,
+		category = excluded.category, display_order = excluded.display_order, eligibility_rules = excluded.eligibility_rules,
+		form_fields = excluded.form_fields, teams_per_school = excluded.teams_per_school, archived_at = excluded.archived_at,
+		updated_at = excluded.updated_at`
+	now := time.Now()
+	for _, ev := range events {
+		var archivedAt interface{}
+		if ev.ArchivedAt != nil {
+			archivedAt = *ev.ArchivedAt
+		}
+		if _, err := tx.Exec(query, ev.ID, ev.Name, ev.Image, ev.OpenToAll, ev.Eligibility, ev.Participants, ev.Mode,
+			ev.IndependentRegistration, ev.Points, ev.Dates, ev.DescriptionLong, ev.DescriptionShort, ev.Category,
+			ev.DisplayOrder, ev.EligibilityRules, ev.FormFields, ev.TeamsPerSchool, archivedAt, now, now); err != nil {
+			return fmt.Errorf("error saving event %s: %v", ev.ID, err)
+		}
+	}
+	return tx.Commit()
+}
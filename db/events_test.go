@@ -0,0 +1,234 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func seedEventImpact(t *testing.T, database *Database) {
+	t.Helper()
+	seed := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`INSERT INTO events (id, name) VALUES (?, ?)`, []interface{}{"quiz", "Quiz"}},
+		{`INSERT INTO events (id, name) VALUES (?, ?)`, []interface{}{"quiz2", "Quiz 2"}},
+		{`INSERT INTO users (id, username, email, password_hash, institution_name, registrations) VALUES (?, ?, ?, ?, ?, ?)`,
+			[]interface{}{1, "s1", "s1@example.com", "x", "School One", `{"quiz":[{"name":"A"},{"name":"B"}],"quiz2":[{"name":"C"}]}`}},
+		{`INSERT INTO users (id, username, email, password_hash, institution_name, registrations) VALUES (?, ?, ?, ?, ?, ?)`,
+			[]interface{}{2, "s2", "s2@example.com", "x", "School Two", `{"quiz2":[{"name":"D"}]}`}},
+		{`INSERT INTO registrations (event_id, user_id) VALUES (?, ?)`, []interface{}{"quiz", 1}},
+		{`INSERT INTO registrations (event_id, user_id) VALUES (?, ?)`, []interface{}{"quiz2", 2}},
+		{`INSERT INTO usr_regs (username, event_id, p1_name) VALUES (?, ?, ?)`, []interface{}{"s1", "quiz", "A"}},
+		{`INSERT INTO usr_regs (username, event_id, p1_name) VALUES (?, ?, ?)`, []interface{}{"s1", "quiz2", "C"}},
+		{`INSERT INTO registration_changes (user_email, event_id, action) VALUES (?, ?, ?)`, []interface{}{"s1@example.com", "quiz", "create"}},
+		{`INSERT INTO registration_changes (user_email, event_id, action) VALUES (?, ?, ?)`, []interface{}{"s1@example.com", "quiz#2", "create"}},
+		{`INSERT INTO registration_changes (user_email, event_id, action) VALUES (?, ?, ?)`, []interface{}{"s2@example.com", "quiz2", "create"}},
+	}
+	for _, s := range seed {
+		if _, err := database.Exec(s.query, s.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func countRows(t *testing.T, database *Database, query string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	if err := database.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestEventImpact(t *testing.T) {
+	database := newTestDB(t)
+	seedEventImpact(t, database)
+
+	tests := []struct {
+		event                                             string
+		teams, participants, registrations, usrs, changes int
+	}{
+		{"quiz", 1, 2, 1, 1, 2},
+		{"quiz2", 2, 2, 1, 1, 1},
+		{"missing", 0, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.event, func(t *testing.T) {
+			impact, err := database.EventImpact(tt.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := [5]int{impact.Teams, impact.Participants, impact.Registrations, impact.UsrRegs, impact.Changes}
+			want := [5]int{tt.teams, tt.participants, tt.registrations, tt.usrs, tt.changes}
+			if got != want {
+				t.Errorf("impact = %+v, want teams/participants/registrations/usr_regs/changes %v", impact, want)
+			}
+			if len(impact.TeamList) != tt.teams {
+				t.Errorf("team list = %+v", impact.TeamList)
+			}
+		})
+	}
+}
+
+func TestDeleteEventCascade(t *testing.T) {
+	database := newTestDB(t)
+	seedEventImpact(t, database)
+
+	impact, err := database.DeleteEventCascade("quiz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if impact.Teams != 1 || impact.Participants != 2 || impact.Changes != 2 {
+		t.Errorf("impact = %+v", impact)
+	}
+
+	checks := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"event", `SELECT COUNT(*) FROM events WHERE id = 'quiz'`, 0},
+		{"other event", `SELECT COUNT(*) FROM events WHERE id = 'quiz2'`, 1},
+		{"registrations", `SELECT COUNT(*) FROM registrations WHERE event_id = 'quiz'`, 0},
+		{"other registrations", `SELECT COUNT(*) FROM registrations WHERE event_id = 'quiz2'`, 1},
+		{"usr_regs", `SELECT COUNT(*) FROM usr_regs WHERE event_id = 'quiz'`, 0},
+		{"other usr_regs", `SELECT COUNT(*) FROM usr_regs WHERE event_id = 'quiz2'`, 1},
+		{"registration changes", `SELECT COUNT(*) FROM registration_changes WHERE event_id LIKE 'quiz#%' OR event_id = 'quiz'`, 0},
+		{"other registration changes", `SELECT COUNT(*) FROM registration_changes WHERE event_id = 'quiz2'`, 1},
+		{"user registrations", `SELECT COUNT(*) FROM users WHERE registrations LIKE '%"quiz"%'`, 0},
+		{"other user registrations", `SELECT COUNT(*) FROM users WHERE registrations LIKE '%"quiz2"%'`, 2},
+	}
+	for _, c := range checks {
+		if got := countRows(t, database, c.query); got != c.want {
+			t.Errorf("%s: %d rows, want %d", c.name, got, c.want)
+		}
+	}
+
+	if _, err := database.DeleteEventCascade("quiz"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("deleting a missing event: err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestSetEventArchived(t *testing.T) {
+	database := newTestDB(t)
+	seedEventImpact(t, database)
+
+	for _, archived := range []bool{true, false} {
+		if err := database.SetEventArchived("quiz", archived); err != nil {
+			t.Fatal(err)
+		}
+		got := countRows(t, database, `SELECT COUNT(*) FROM events WHERE id = 'quiz' AND archived_at IS NOT NULL`) == 1
+		if got != archived {
+			t.Errorf("archived = %v, want %v", got, archived)
+		}
+	}
+	if err := database.SetEventArchived("missing", true); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("archiving a missing event: err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestRenameEvent(t *testing.T) {
+	database := newTestDB(t)
+	seedEventImpact(t, database)
+
+	if err := database.RenameEvent("quiz", "quiz2"); err == nil {
+		t.Error("renaming onto an existing event succeeded")
+	}
+	if err := database.RenameEvent("missing", "other"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("renaming a missing event: err = %v, want sql.ErrNoRows", err)
+	}
+	if err := database.RenameEvent("quiz", "quiz-bowl"); err != nil {
+		t.Fatal(err)
+	}
+
+	checks := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"event", `SELECT COUNT(*) FROM events WHERE id = 'quiz-bowl'`, 1},
+		{"old event", `SELECT COUNT(*) FROM events WHERE id = 'quiz'`, 0},
+		{"registrations", `SELECT COUNT(*) FROM registrations WHERE event_id = 'quiz-bowl'`, 1},
+		{"usr_regs", `SELECT COUNT(*) FROM usr_regs WHERE event_id = 'quiz-bowl'`, 1},
+		{"user registrations", `SELECT COUNT(*) FROM users WHERE registrations LIKE '%"quiz-bowl"%'`, 1},
+		{"old user registrations", `SELECT COUNT(*) FROM users WHERE registrations LIKE '%"quiz"%'`, 0},
+		{"untouched event", `SELECT COUNT(*) FROM usr_regs WHERE event_id = 'quiz2'`, 1},
+	}
+	for _, c := range checks {
+		if got := countRows(t, database, c.query); got != c.want {
+			t.Errorf("%s: %d rows, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSetEventOrder(t *testing.T) {
+	database := newTestDB(t)
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := database.Exec(`INSERT INTO events (id, name) VALUES (?, ?)`, id, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := database.SetEventOrder([]string{"c", "a"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		id    string
+		order int
+	}{
+		{"c", 1},
+		{"a", 2},
+		{"b", 2},
+	}
+	for _, tt := range tests {
+		got := countRows(t, database, `SELECT display_order FROM events WHERE id = ?`, tt.id)
+		if got != tt.order {
+			t.Errorf("display_order(%s) = %d, want %d", tt.id, got, tt.order)
+		}
+	}
+	if next, err := database.NextEventDisplayOrder(); err != nil || next != 3 {
+		t.Errorf("NextEventDisplayOrder = %d, %v, want 3", next, err)
+	}
+	if err := database.SetEventOrder([]string{"a", "missing"}); err == nil {
+		t.Error("ordering an unknown event succeeded")
+	}
+}
+
+func TestSaveEvents(t *testing.T) {
+	database := newTestDB(t)
+	if _, err := database.Exec(`INSERT INTO events (id, name, created_at) VALUES ('quiz', 'Quiz', '2020-01-01 00:00:00')`); err != nil {
+		t.Fatal(err)
+	}
+
+	events := []Event{
+		{ID: "quiz", Name: "Quiz Bowl", Participants: 2, DisplayOrder: 2},
+		{ID: "cubing", Name: "Cubing", Participants: 1, DisplayOrder: 1},
+	}
+	if err := database.SaveEvents(events); err != nil {
+		t.Fatal(err)
+	}
+	var name, created string
+	if err := database.QueryRow(`SELECT name, created_at FROM events WHERE id = 'quiz'`).Scan(&name, &created); err != nil {
+		t.Fatal(err)
+	}
+	if name != "Quiz Bowl" || !strings.HasPrefix(created, "2020-01-01") {
+		t.Errorf("quiz = %q created %q, want the new name and the original created_at", name, created)
+	}
+	if got := countRows(t, database, `SELECT COUNT(*) FROM events`); got != 2 {
+		t.Errorf("%d events, want 2", got)
+	}
+
+	// A failing row rolls back the whole catalogue.
+	bad := []Event{{ID: "sudocrypt", Name: "Sudocrypt"}, {ID: "broken"}}
+	if _, err := database.Exec(`CREATE TRIGGER reject_broken BEFORE INSERT ON events WHEN NEW.id = 'broken' BEGIN SELECT RAISE(ABORT, 'broken'); END`); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SaveEvents(bad); err == nil {
+		t.Fatal("SaveEvents succeeded with a failing row")
+	}
+	if got := countRows(t, database, `SELECT COUNT(*) FROM events WHERE id = 'sudocrypt'`); got != 0 {
+		t.Error("a failed import left a partial catalogue")
+	}
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	first, err := Lock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(path + ".lock")
+	if strings.TrimSpace(string(b)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("lock file holds %q, want the pid", b)
+	}
+	if _, err := Lock(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("second Lock = %v, want ErrLocked", err)
+	}
+	first.Close()
+	second, err := Lock(path)
+	if err != nil {
+		t.Fatalf("Lock after release = %v", err)
+	}
+	second.Close()
+	if _, err := Lock(filepath.Join(t.TempDir(), "missing", "test.db")); err == nil || errors.Is(err, ErrLocked) {
+		t.Errorf("Lock in a missing directory = %v", err)
+	}
+}
+
+func TestPause(t *testing.T) {
+	database := newTestDB(t)
+	steps := []struct {
+		op     func()
+		paused bool
+	}{
+		{func() {}, false},
+		{database.Pause, true},
+		{database.Pause, true},
+		{database.Resume, true},
+		{database.Resume, false},
+	}
+	for i, s := range steps {
+		s.op()
+		if got := database.Paused(); got != s.paused {
+			t.Errorf("step %d: Paused = %v, want %v", i, got, s.paused)
+		}
+	}
+}
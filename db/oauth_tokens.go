@@ -0,0 +1,71 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+type OAuthToken struct {
+	Provider    string     `json:"provider"`
+	Token       string     `json:"-"`
+	ConnectedBy string     `json:"connected_by"`
+	ConnectedAt time.Time  `json:"connected_at"`
+	RefreshedAt *time.Time `json:"refreshed_at,omitempty"`
+	Expiry      *time.Time `json:"expiry,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+func (db *Database) GetOAuthToken(provider string) (*OAuthToken, error) {
+	t := &OAuthToken{}
+	var connectedBy, lastError sql.NullString
+	var refreshedAt, expiry, lastErrorAt sql.NullTime
+	err := db.QueryRow(`SELECT provider, token, connected_by, connected_at, refreshed_at, expiry, last_error, last_error_at FROM oauth_tokens WHERE provider = ?`, provider).Scan(
+		&t.Provider, &t.Token, &connectedBy, &t.ConnectedAt, &refreshedAt, &expiry, &lastError, &lastErrorAt)
+	if err != nil {
+		return nil, err
+	}
+	t.ConnectedBy = connectedBy.String
+	t.LastError = lastError.String
+	if refreshedAt.Valid {
+		t.RefreshedAt = &refreshedAt.Time
+	}
+	if expiry.Valid {
+		t.Expiry = &expiry.Time
+	}
+	if lastErrorAt.Valid {
+		t.LastErrorAt = &lastErrorAt.Time
+	}
+	return t, nil
+}
+
+func (db *Database) SaveOAuthToken(provider, token, connectedBy string, expiry time.Time) error {
+	_, err := db.Exec(`INSERT INTO oauth_tokens (provider, token, connected_by, connected_at, expiry) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(provider) DO UPDATE SET token = excluded.token, connected_by = excluded.connected_by, connected_at = excluded.connected_at,
+		expiry = excluded.expiry, refreshed_at = NULL, last_error = NULL, last_error_at = NULL`,
+		provider, token, connectedBy, time.Now(), nullableTime(expiry))
+	return err
+}
+
+func (db *Database) UpdateOAuthTokenRefresh(provider, token string, expiry time.Time) error {
+	_, err := db.Exec(`UPDATE oauth_tokens SET token = ?, expiry = ?, refreshed_at = ?, last_error = NULL, last_error_at = NULL WHERE provider = ?`,
+		token, nullableTime(expiry), time.Now(), provider)
+	return err
+}
+
+func (db *Database) RecordOAuthTokenError(provider, message string) error {
+	_, err := db.Exec(`UPDATE oauth_tokens SET last_error = ?, last_error_at = ? WHERE provider = ?`, message, time.Now(), provider)
+	return err
+}
+
+func (db *Database) DeleteOAuthToken(provider string) error {
+	_, err := db.Exec(`DELETE FROM oauth_tokens WHERE provider = ?`, provider)
+	return err
+}
+
+func nullableTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// A limit of zero or less means no limit.
+func (db *Database) ReserveLLMCall(day string, callLimit, tokenLimit int) (bool, error) {
+	if _, err := db.Exec(`INSERT OR IGNORE INTO llm_usage (day) VALUES (?)`, day); err != nil {
+		return false, fmt.Errorf("error reserving llm call: %v", err)
+	}
+	res, err := db.Exec(`UPDATE llm_usage SET calls = calls + 1 WHERE day = ?
+		AND (? <= 0 OR calls < ?) AND (? <= 0 OR tokens < ?)`, day, callLimit, callLimit, tokenLimit, tokenLimit)
+	if err != nil {
+		return false, fmt.Errorf("error reserving llm call: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error reserving llm call: %v", err)
+	}
+	return n > 0, nil
+}
+
+func (db *Database) AddLLMTokens(day string, tokens int) error {
+	_, err := db.Exec(`UPDATE llm_usage SET tokens = tokens + ? WHERE day = ?`, tokens, day)
+	return err
+}
+
+func (db *Database) LLMUsage(day string) (int, int, error) {
+	var calls, tokens int
+	err := db.QueryRow(`SELECT calls, tokens FROM llm_usage WHERE day = ?`, day).Scan(&calls, &tokens)
+	if err == sql.ErrNoRows {
+		return 0, 0, nil
+	}
+	return calls, tokens, err
+}
+
+// Strikes older than decay are forgotten first.
+func (db *Database) AddQueryStrike(client string, now time.Time, decay time.Duration) (int, error) {
+	_, err := db.Exec(`INSERT INTO query_strikes (client, strikes, last_strike_at) VALUES (?, 1, ?)
+		ON CONFLICT(client) DO UPDATE SET
+			strikes = CASE WHEN query_strikes.last_strike_at < ? THEN 1 ELSE query_strikes.strikes + 1 END,
+			last_strike_at = excluded.last_strike_at`,
+		client, now, now.Add(-decay))
+	if err != nil {
+		return 0, fmt.Errorf("error recording query strike: %v", err)
+	}
+	var strikes int
+	if err := db.QueryRow(`SELECT strikes FROM query_strikes WHERE client = ?`, client).Scan(&strikes); err != nil {
+		return 0, fmt.Errorf("error recording query strike: %v", err)
+	}
+	return strikes, nil
+}
+
+func (db *Database) SetQueryCooldown(client string, until time.Time) error {
+	_, err := db.Exec(`UPDATE query_strikes SET cooldown_until = ? WHERE client = ?`, until, client)
+	return err
+}
+
+func (db *Database) QueryCooldown(clients []string, now time.Time) (time.Time, error) {
+	var latest time.Time
+	for _, c := range clients {
+		var until sql.NullTime
+		err := db.QueryRow(`SELECT cooldown_until FROM query_strikes WHERE client = ?`, c).Scan(&until)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return time.Time{}, fmt.Errorf("error reading query cooldown: %v", err)
+		}
+		if until.Valid && until.Time.After(now) && until.Time.After(latest) {
+			latest = until.Time
+		}
+	}
+	return latest, nil
+}
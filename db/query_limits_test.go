@@ -0,0 +1,101 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReserveLLMCall(t *testing.T) {
+	tests := []struct {
+		name       string
+		callLimit  int
+		tokenLimit int
+		tokens     int
+		allowed    int
+	}{
+		{"call cap", 2, 0, 0, 2},
+		{"token cap", 0, 100, 60, 2},
+		{"no limits", 0, 0, 1000, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newTestDB(t)
+			allowed := 0
+			for i := 0; i < 5; i++ {
+				ok, err := database.ReserveLLMCall("2025-11-01", tt.callLimit, tt.tokenLimit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !ok {
+					break
+				}
+				allowed++
+				if err := database.AddLLMTokens("2025-11-01", tt.tokens); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if allowed != tt.allowed {
+				t.Errorf("allowed %d calls, want %d", allowed, tt.allowed)
+			}
+			calls, tokens, err := database.LLMUsage("2025-11-01")
+			if err != nil || calls != tt.allowed || tokens != tt.allowed*tt.tokens {
+				t.Errorf("usage = %d calls, %d tokens, %v", calls, tokens, err)
+			}
+			if ok, _ := database.ReserveLLMCall("2025-11-02", tt.callLimit, tt.tokenLimit); !ok {
+				t.Error("the next day started with the previous day's usage")
+			}
+		})
+	}
+}
+
+func TestQueryStrikesAndCooldown(t *testing.T) {
+	database := newTestDB(t)
+	now := time.Date(2025, 11, 1, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		at   time.Duration
+		want int
+	}{
+		{0, 1},
+		{time.Hour, 2},
+		{2 * time.Hour, 3},
+		{30 * time.Hour, 1},
+	}
+	for _, s := range steps {
+		got, err := database.AddQueryStrike("ip:192.0.2.1", now.Add(s.at), 24*time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != s.want {
+			t.Errorf("strikes after %s = %d, want %d", s.at, got, s.want)
+		}
+	}
+
+	if _, err := database.AddQueryStrike("session:a", now, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SetQueryCooldown("session:a", now.Add(10*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SetQueryCooldown("ip:192.0.2.1", now.Add(5*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		clients []string
+		at      time.Time
+		want    time.Time
+	}{
+		{[]string{"session:a", "ip:192.0.2.1", "email:x"}, now, now.Add(10 * time.Minute)},
+		{[]string{"ip:192.0.2.1"}, now, now.Add(5 * time.Minute)},
+		{[]string{"session:a"}, now.Add(time.Hour), time.Time{}},
+		{[]string{"session:b"}, now, time.Time{}},
+	}
+	for _, tt := range tests {
+		got, err := database.QueryCooldown(tt.clients, tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("QueryCooldown(%v) = %s, want %s", tt.clients, got, tt.want)
+		}
+	}
+}
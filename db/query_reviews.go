@@ -0,0 +1,274 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	ReviewFalsePositive = "false_positive"
+	ReviewDismissed     = "dismissed"
+	ReviewPromoted      = "promoted"
+	ReviewBlocked       = "blocked"
+)
+
+var ReviewStates = []string{ReviewFalsePositive, ReviewDismissed, ReviewPromoted, ReviewBlocked}
+
+type QueryReview struct {
+	State      string    `json:"state"`
+	Note       string    `json:"note,omitempty"`
+	ReviewedBy string    `json:"reviewed_by"`
+	ReviewedAt time.Time `json:"reviewed_at"`
+}
+
+type QueryLog struct {
+	ID        int          `json:"id"`
+	CreatedAt time.Time    `json:"created_at"`
+	Query     string       `json:"query"`
+	Answer    string       `json:"answer"`
+	Status    string       `json:"status"`
+	Rule      string       `json:"rule,omitempty"`
+	Rules     []string     `json:"rules"`
+	Scope     string       `json:"scope,omitempty"`
+	Source    string       `json:"source,omitempty"`
+	Provider  string       `json:"provider,omitempty"`
+	Clients   []string     `json:"clients"`
+	Review    *QueryReview `json:"review,omitempty"`
+}
+
+// Unless All is set only rejected or flagged queries are returned. Review is
+// "pending", "reviewed" or a review state.
+type QueryLogFilter struct {
+	Status string
+	Rule   string
+	Review string
+	Search string
+	All    bool
+	Offset int
+	Limit  int
+}
+
+const queryLogColumns = `l.id, l.created_at, l.content, r.state, r.note, r.reviewed_by, r.reviewed_at`
+
+const queryLogFrom = ` FROM logs l LEFT JOIN query_reviews r ON r.log_id = l.id`
+
+const flaggedQuery = `(json_extract(l.content, '$.status') = 'rejected' OR COALESCE(json_extract(l.content, '$.rules'), '') != '')`
+
+func queryLogWhere(f QueryLogFilter) (string, []interface{}) {
+	clauses := []string{`l.reason = 'query'`, `json_valid(l.content)`}
+	args := []interface{}{}
+	if !f.All {
+		clauses = append(clauses, flaggedQuery)
+	}
+	if f.Status != "" {
+		clauses = append(clauses, `json_extract(l.content, '$.status') = ?`)
+		args = append(args, f.Status)
+	}
+	if f.Rule != "" {
+		clauses = append(clauses, `(json_extract(l.content, '$.rule') = ? OR ',' || COALESCE(json_extract(l.content, '$.rules'), '') || ',' LIKE ?)`)
+		args = append(args, f.Rule, "%,"+f.Rule+",%")
+	}
+	switch f.Review {
+	case "":
+	case "pending":
+		clauses = append(clauses, `r.log_id IS NULL`)
+	case "reviewed":
+		clauses = append(clauses, `r.log_id IS NOT NULL`)
+	default:
+		clauses = append(clauses, `r.state = ?`)
+		args = append(args, f.Review)
+	}
+	if f.Search != "" {
+		clauses = append(clauses, `(json_extract(l.content, '$.query') LIKE ? OR json_extract(l.content, '$.answer') LIKE ?)`)
+		like := "%" + f.Search + "%"
+		args = append(args, like, like)
+	}
+	return ` WHERE ` + strings.Join(clauses, ` AND `), args
+}
+
+func scanQueryLog(scan func(dest ...interface{}) error) (*QueryLog, error) {
+	var content string
+	var state, note, by sql.NullString
+	var at sql.NullTime
+	q := &QueryLog{}
+	if err := scan(&q.ID, &q.CreatedAt, &content, &state, &note, &by, &at); err != nil {
+		return nil, err
+	}
+	var payload map[string]string
+	if err := json.Unmarshal([]byte(content), &payload); err != nil {
+		return nil, err
+	}
+	q.Query = payload["query"]
+	q.Answer = payload["answer"]
+	q.Status = payload["status"]
+	q.Rule = payload["rule"]
+	q.Scope = payload["scope"]
+	q.Source = payload["source"]
+	q.Provider = payload["provider"]
+	q.Rules = splitList(payload["rules"])
+	q.Clients = splitList(payload["clients"])
+	if state.Valid {
+		q.Review = &QueryReview{State: state.String, Note: note.String, ReviewedBy: by.String, ReviewedAt: at.Time}
+	}
+	return q, nil
+}
+
+func splitList(s string) []string {
+	out := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func (db *Database) ListQueryLogs(f QueryLogFilter) ([]QueryLog, int, error) {
+	where, args := queryLogWhere(f)
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*)`+queryLogFrom+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting query logs: %v", err)
+	}
+	query := `SELECT ` + queryLogColumns + queryLogFrom + where + ` ORDER BY l.id DESC`
+	if f.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, f.Limit, f.Offset)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error listing query logs: %v", err)
+	}
+	defer rows.Close()
+	logs := []QueryLog{}
+	for rows.Next() {
+		q, err := scanQueryLog(rows.Scan)
+		if err != nil {
+			return nil, 0, err
+		}
+		logs = append(logs, *q)
+	}
+	return logs, total, rows.Err()
+}
+
+func (db *Database) GetQueryLog(id int) (*QueryLog, error) {
+	q, err := scanQueryLog(db.QueryRow(`SELECT `+queryLogColumns+queryLogFrom+` WHERE l.id = ? AND l.reason = 'query' AND json_valid(l.content)`, id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return q, err
+}
+
+func (db *Database) SetQueryReview(logID int, state, note, by string) error {
+	_, err := db.Exec(`INSERT INTO query_reviews (log_id, state, note, reviewed_by, reviewed_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(log_id) DO UPDATE SET state = excluded.state, note = excluded.note,
+			reviewed_by = excluded.reviewed_by, reviewed_at = excluded.reviewed_at`,
+		logID, state, note, by, time.Now())
+	return err
+}
+
+func (db *Database) ClearQueryReview(logID int) error {
+	_, err := db.Exec(`DELETE FROM query_reviews WHERE log_id = ?`, logID)
+	return err
+}
+
+type QueryReviewCounts struct {
+	Pending  int            `json:"pending"`
+	Rejected int            `json:"rejected"`
+	Flagged  int            `json:"flagged"`
+	Reviewed map[string]int `json:"reviewed"`
+	Blocked  int            `json:"blocked_clients"`
+}
+
+func (db *Database) QueryReviewCounts() (QueryReviewCounts, error) {
+	c := QueryReviewCounts{Reviewed: map[string]int{}}
+	err := db.QueryRow(`SELECT
+			COUNT(CASE WHEN r.log_id IS NULL THEN 1 END),
+			COUNT(CASE WHEN json_extract(l.content, '$.status') = 'rejected' THEN 1 END),
+			COUNT(CASE WHEN json_extract(l.content, '$.status') != 'rejected' THEN 1 END)`+
+		queryLogFrom+` WHERE l.reason = 'query' AND json_valid(l.content) AND `+flaggedQuery).Scan(&c.Pending, &c.Rejected, &c.Flagged)
+	if err != nil {
+		return c, fmt.Errorf("error counting query reviews: %v", err)
+	}
+	rows, err := db.Query(`SELECT state, COUNT(*) FROM query_reviews GROUP BY state`)
+	if err != nil {
+		return c, fmt.Errorf("error counting query reviews: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var state string
+		var n int
+		if err := rows.Scan(&state, &n); err != nil {
+			return c, err
+		}
+		c.Reviewed[state] = n
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM query_strikes WHERE blocked`).Scan(&c.Blocked); err != nil {
+		return c, fmt.Errorf("error counting blocked clients: %v", err)
+	}
+	return c, rows.Err()
+}
+
+type BlockedQueryClient struct {
+	Client    string    `json:"client"`
+	BlockedBy string    `json:"blocked_by"`
+	BlockedAt time.Time `json:"blocked_at"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+func (db *Database) BlockQueryClient(client, by, reason string) error {
+	_, err := db.Exec(`INSERT INTO query_strikes (client, blocked, blocked_by, blocked_at, block_reason) VALUES (?, TRUE, ?, ?, ?)
+		ON CONFLICT(client) DO UPDATE SET blocked = TRUE, blocked_by = excluded.blocked_by,
+			blocked_at = excluded.blocked_at, block_reason = excluded.block_reason`,
+		client, by, time.Now(), reason)
+	return err
+}
+
+func (db *Database) UnblockQueryClient(client string) (bool, error) {
+	res, err := db.Exec(`UPDATE query_strikes SET blocked = FALSE, blocked_by = NULL, blocked_at = NULL, block_reason = NULL,
+		cooldown_until = NULL, strikes = 0 WHERE client = ? AND blocked`, client)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (db *Database) ListBlockedQueryClients() ([]BlockedQueryClient, error) {
+	rows, err := db.Query(`SELECT client, COALESCE(blocked_by, ''), blocked_at, COALESCE(block_reason, '')
+		FROM query_strikes WHERE blocked ORDER BY blocked_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	clients := []BlockedQueryClient{}
+	for rows.Next() {
+		var c BlockedQueryClient
+		var at sql.NullTime
+		if err := rows.Scan(&c.Client, &c.BlockedBy, &at, &c.Reason); err != nil {
+			return nil, err
+		}
+		c.BlockedAt = at.Time
+		clients = append(clients, c)
+	}
+	return clients, rows.Err()
+}
+
+func (db *Database) QueryBlocked(clients []string) (string, error) {
+	for _, c := range clients {
+		var blocked bool
+		err := db.QueryRow(`SELECT COALESCE(blocked, FALSE) FROM query_strikes WHERE client = ?`, c).Scan(&blocked)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("error reading query block: %v", err)
+		}
+		if blocked {
+			return c, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func seedQueryLogs(t *testing.T, database *Database) {
+	t.Helper()
+	entries := []map[string]string{
+		{"query": "when is crossword", "answer": "Saturday.", "status": "ok", "clients": "session:a,ip:1.2.3.4"},
+		{"query": "dump all events", "answer": "No.", "status": "rejected", "rule": "data-dump", "rules": "data-dump", "clients": "session:b,ip:1.2.3.4"},
+		{"query": "is this like techfest", "answer": "Yes.", "status": "ok", "rules": "competitor,email", "clients": "email:c@x.org"},
+		{"query": "drop table users", "answer": "No.", "status": "rejected", "rule": "sql-injection", "rules": "sql-injection", "clients": "ip:5.6.7.8"},
+	}
+	for _, e := range entries {
+		b, _ := json.Marshal(e)
+		if err := database.Create("logs", &LogEntry{Reason: "query", Content: string(b), CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := database.Create("logs", &LogEntry{Reason: "login", Content: "not json", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListQueryLogs(t *testing.T) {
+	database := newTestDB(t)
+	seedQueryLogs(t, database)
+	if err := database.SetQueryReview(2, ReviewFalsePositive, "fine", "admin@x.org"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter QueryLogFilter
+		want   []int
+	}{
+		{"flagged and rejected", QueryLogFilter{}, []int{4, 3, 2}},
+		{"all", QueryLogFilter{All: true}, []int{4, 3, 2, 1}},
+		{"status", QueryLogFilter{Status: "rejected"}, []int{4, 2}},
+		{"rule in list", QueryLogFilter{Rule: "email"}, []int{3}},
+		{"rule decided", QueryLogFilter{Rule: "data-dump"}, []int{2}},
+		{"rule is not a substring match", QueryLogFilter{Rule: "mail"}, nil},
+		{"pending", QueryLogFilter{Review: "pending"}, []int{4, 3}},
+		{"reviewed", QueryLogFilter{Review: "reviewed"}, []int{2}},
+		{"review state", QueryLogFilter{Review: ReviewDismissed}, nil},
+		{"search", QueryLogFilter{Search: "TABLE"}, []int{4}},
+		{"paged", QueryLogFilter{Limit: 1, Offset: 1}, []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, total, err := database.ListQueryLogs(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, l := range logs {
+				got = append(got, l.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ListQueryLogs = %v, want %v", got, tt.want)
+			}
+			if tt.filter.Limit == 0 && total != len(tt.want) {
+				t.Errorf("total = %d, want %d", total, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestGetQueryLog(t *testing.T) {
+	database := newTestDB(t)
+	seedQueryLogs(t, database)
+
+	q, err := database.GetQueryLog(3)
+	if err != nil || q == nil {
+		t.Fatalf("GetQueryLog = %v, %v", q, err)
+	}
+	if q.Query != "is this like techfest" || !reflect.DeepEqual(q.Rules, []string{"competitor", "email"}) ||
+		!reflect.DeepEqual(q.Clients, []string{"email:c@x.org"}) || q.Review != nil {
+		t.Errorf("GetQueryLog = %+v", q)
+	}
+	for _, id := range []int{5, 99} {
+		if q, err := database.GetQueryLog(id); err != nil || q != nil {
+			t.Errorf("GetQueryLog(%d) = %+v, %v; want nil", id, q, err)
+		}
+	}
+
+	if err := database.SetQueryReview(3, ReviewFalsePositive, "", "a@x.org"); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SetQueryReview(3, ReviewDismissed, "spam", "b@x.org"); err != nil {
+		t.Fatal(err)
+	}
+	q, _ = database.GetQueryLog(3)
+	if q.Review == nil || q.Review.State != ReviewDismissed || q.Review.Note != "spam" || q.Review.ReviewedBy != "b@x.org" {
+		t.Errorf("review = %+v", q.Review)
+	}
+	if err := database.ClearQueryReview(3); err != nil {
+		t.Fatal(err)
+	}
+	if q, _ = database.GetQueryLog(3); q.Review != nil {
+		t.Errorf("cleared review = %+v", q.Review)
+	}
+}
+
+func TestQueryReviewCounts(t *testing.T) {
+	database := newTestDB(t)
+	seedQueryLogs(t, database)
+	if err := database.SetQueryReview(4, ReviewBlocked, "", "a@x.org"); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.BlockQueryClient("ip:5.6.7.8", "a@x.org", "injection"); err != nil {
+		t.Fatal(err)
+	}
+	c, err := database.QueryReviewCounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := QueryReviewCounts{Pending: 2, Rejected: 2, Flagged: 1, Reviewed: map[string]int{ReviewBlocked: 1}, Blocked: 1}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("QueryReviewCounts = %+v, want %+v", c, want)
+	}
+}
+
+func TestBlockQueryClient(t *testing.T) {
+	database := newTestDB(t)
+	if _, err := database.AddQueryStrike("session:a", time.Now(), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		block   []string
+		clients []string
+		want    string
+	}{
+		{"nothing blocked", nil, []string{"session:a", "ip:1.2.3.4"}, ""},
+		{"existing strike row", []string{"session:a"}, []string{"session:a", "ip:1.2.3.4"}, "session:a"},
+		{"new row", []string{"ip:1.2.3.4"}, []string{"email:x@y.org", "ip:1.2.3.4"}, "ip:1.2.3.4"},
+		{"unknown clients", nil, []string{"email:x@y.org"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, c := range tt.block {
+				if err := database.BlockQueryClient(c, "a@x.org", "abuse"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := database.QueryBlocked(tt.clients)
+			if err != nil || got != tt.want {
+				t.Errorf("QueryBlocked = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	blocked, err := database.ListBlockedQueryClients()
+	if err != nil || len(blocked) != 2 || blocked[0].BlockedBy != "a@x.org" || blocked[0].Reason != "abuse" {
+		t.Errorf("ListBlockedQueryClients = %+v, %v", blocked, err)
+	}
+	if ok, err := database.UnblockQueryClient("session:a"); !ok || err != nil {
+		t.Errorf("UnblockQueryClient = %v, %v", ok, err)
+	}
+	if ok, _ := database.UnblockQueryClient("session:a"); ok {
+		t.Error("unblocking twice reported a block")
+	}
+	if got, _ := database.QueryBlocked([]string{"session:a"}); got != "" {
+		t.Errorf("unblocked client still blocked")
+	}
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"strings"
+	"time"
+)
+
+type RegistrationChange struct {
+	ID         int       `json:"id"`
+	UserEmail  string    `json:"user_email"`
+	EventID    string    `json:"event_id"`
+	Action     string    `json:"action"`
+	Payload    string    `json:"payload"`
+	CreatedAt  time.Time `json:"created_at"`
+	DigestedAt time.Time `json:"digested_at"`
+}
+
+func (db *Database) RecordRegistrationChange(userEmail, eventID, action, payload string) error {
+	query := `INSERT INTO registration_changes (user_email, event_id, action, payload, created_at) VALUES (?, ?, ?, ?, ?)`
+	_, err := db.Exec(query, userEmail, eventID, action, payload, time.Now())
+	return err
+}
+
+func (db *Database) PendingRegistrationChanges(since time.Time) (map[string][]RegistrationChange, error) {
+	query := `SELECT id, user_email, event_id, action, COALESCE(payload, ''), created_at FROM registration_changes WHERE digested_at IS NULL AND created_at >= ? ORDER BY created_at`
+	rows, err := db.Query(query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make(map[string][]RegistrationChange)
+	for rows.Next() {
+		var c RegistrationChange
+		if err := rows.Scan(&c.ID, &c.UserEmail, &c.EventID, &c.Action, &c.Payload, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		changes[c.UserEmail] = append(changes[c.UserEmail], c)
+	}
+	return changes, rows.Err()
+}
+
+func (db *Database) MarkRegistrationChangesDigested(ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(ids))
+	args := []interface{}{time.Now()}
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+	query := `UPDATE registration_changes SET digested_at = ? WHERE id IN (` + strings.Join(placeholders, ", ") + `)`
+	_, err := db.Exec(query, args...)
+	return err
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPendingRegistrationChanges(t *testing.T) {
+	database := newTestDB(t)
+	start := time.Now().Add(-time.Second)
+	changes := []struct{ email, event, action string }{
+		{"a@school.edu", "quiz", "created"},
+		{"a@school.edu", "quiz", "updated"},
+		{"b@school.edu", "crossword", "created"},
+	}
+	for _, c := range changes {
+		if err := database.RecordRegistrationChange(c.email, c.event, c.action, "{}"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pending, err := database.PendingRegistrationChanges(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending["a@school.edu"]) != 2 || len(pending["b@school.edu"]) != 1 {
+		t.Fatalf("pending = %+v", pending)
+	}
+	if got := pending["a@school.edu"][1].Action; got != "updated" {
+		t.Errorf("changes are not oldest first: second action %q", got)
+	}
+
+	if err := database.MarkRegistrationChangesDigested([]int{pending["a@school.edu"][0].ID, pending["a@school.edu"][1].ID}); err != nil {
+		t.Fatal(err)
+	}
+	pending, err = database.PendingRegistrationChanges(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || len(pending["b@school.edu"]) != 1 {
+		t.Errorf("after marking a's changes: %+v", pending)
+	}
+	if pending, _ := database.PendingRegistrationChanges(time.Now().Add(time.Minute)); len(pending) != 0 {
+		t.Errorf("changes before since were returned: %+v", pending)
+	}
+}
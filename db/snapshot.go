@@ -0,0 +1,97 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func (db *Database) SnapshotTo(destPath string) error {
+	if _, err := os.Stat(destPath); err == nil {
+		return fmt.Errorf("snapshot destination %s already exists", destPath)
+	}
+	if _, err := db.Exec(`VACUUM INTO ?`, destPath); err != nil {
+		return fmt.Errorf("error creating snapshot: %v", err)
+	}
+	return nil
+}
+
+func OpenSnapshot(path string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	conn, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("error opening snapshot: %v", err)
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error opening snapshot: %v", err)
+	}
+	return conn, nil
+}
+
+func IntegrityCheck(conn *sql.DB) error {
+	rows, err := conn.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return fmt.Errorf("error running integrity check: %v", err)
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func TableCounts(conn *sql.DB) (map[string]int64, error) {
+	rows, err := conn.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+
+	counts := make(map[string]int64, len(tables))
+	for _, t := range tables {
+		var n int64
+		if err := conn.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, strings.ReplaceAll(t, `"`, `""`))).Scan(&n); err != nil {
+			return nil, err
+		}
+		counts[t] = n
+	}
+	return counts, nil
+}
+
+func VerifySnapshot(path string) (map[string]int64, error) {
+	conn, err := OpenSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := IntegrityCheck(conn); err != nil {
+		return nil, err
+	}
+	return TableCounts(conn)
+}
@@ -0,0 +1,482 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+type TableDiff struct {
+	Table       string   `json:"table"`
+	Key         string   `json:"key"`
+	LiveRows    int64    `json:"live_rows"`
+	SnapRows    int64    `json:"snapshot_rows"`
+	Added       int      `json:"added"`
+	Removed     int      `json:"removed"`
+	Changed     int      `json:"changed"`
+	AddedKeys   []string `json:"added_keys,omitempty"`
+	RemovedKeys []string `json:"removed_keys,omitempty"`
+	ChangedKeys []string `json:"changed_keys,omitempty"`
+	Note        string   `json:"note,omitempty"`
+}
+
+type DiffOptions struct {
+	Tables []string
+	Column string
+	Value  string
+	Limit  int
+}
+
+func (db *Database) RestoreFrom(path string) error {
+	src, err := OpenSnapshot(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	ctx := context.Background()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+	destConn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	return destConn.Raw(func(destRaw interface{}) error {
+		return srcConn.Raw(func(srcRaw interface{}) error {
+			dest, ok := destRaw.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected driver connection %T", destRaw)
+			}
+			source, ok := srcRaw.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected driver connection %T", srcRaw)
+			}
+			bk, err := dest.Backup("main", source, "main")
+			if err != nil {
+				return fmt.Errorf("error starting restore: %v", err)
+			}
+			deadline := time.Now().Add(30 * time.Second)
+			for {
+				done, err := bk.Step(-1)
+				if err != nil {
+					bk.Finish()
+					return fmt.Errorf("error restoring snapshot: %v", err)
+				}
+				if done {
+					break
+				}
+				if time.Now().After(deadline) {
+					bk.Finish()
+					return fmt.Errorf("timed out waiting for database lock during restore")
+				}
+				time.Sleep(50 * time.Millisecond)
+			}
+			return bk.Finish()
+		})
+	})
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func tableColumns(conn *sql.Conn, schema, table string) ([]string, string, error) {
+	rows, err := conn.QueryContext(context.Background(), fmt.Sprintf(`PRAGMA %s.table_info(%s)`, schema, quoteIdent(table)))
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+	var cols []string
+	key := ""
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, ctype string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dflt, &pk); err != nil {
+			return nil, "", err
+		}
+		cols = append(cols, name)
+		if pk == 1 {
+			key = name
+		}
+	}
+	if key == "" {
+		key = "rowid"
+	}
+	return cols, key, rows.Err()
+}
+
+func listTables(conn *sql.Conn, schema string) (map[string]bool, error) {
+	rows, err := conn.QueryContext(context.Background(), fmt.Sprintf(`SELECT name FROM %s.sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%%'`, schema))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tables := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables[name] = true
+	}
+	return tables, rows.Err()
+}
+
+func (db *Database) withSnapshotAttached(path string, fn func(conn *sql.Conn) error) error {
+	if _, err := VerifySnapshot(path); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `ATTACH DATABASE ? AS snap`, "file:"+path+"?mode=ro"); err != nil {
+		return fmt.Errorf("error attaching snapshot: %v", err)
+	}
+	defer conn.ExecContext(ctx, `DETACH DATABASE snap`)
+	return fn(conn)
+}
+
+func commonColumns(live, snap []string) []string {
+	inSnap := map[string]bool{}
+	for _, c := range snap {
+		inSnap[c] = true
+	}
+	var cols []string
+	for _, c := range live {
+		if inSnap[c] {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+func queryKeys(conn *sql.Conn, query string, args ...interface{}) ([]string, error) {
+	rows, err := conn.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []string
+	for rows.Next() {
+		var k sql.NullString
+		if err := rows.Scan(&k); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k.String)
+	}
+	return keys, rows.Err()
+}
+
+func limitKeys(keys []string, limit int) []string {
+	if limit > 0 && len(keys) > limit {
+		return keys[:limit]
+	}
+	return keys
+}
+
+func (db *Database) DiffSnapshot(path string, opts DiffOptions) ([]TableDiff, error) {
+	var diffs []TableDiff
+	err := db.withSnapshotAttached(path, func(conn *sql.Conn) error {
+		ctx := context.Background()
+		liveTables, err := listTables(conn, "main")
+		if err != nil {
+			return err
+		}
+		snapTables, err := listTables(conn, "snap")
+		if err != nil {
+			return err
+		}
+
+		names := opts.Tables
+		if len(names) == 0 {
+			seen := map[string]bool{}
+			for t := range liveTables {
+				seen[t] = true
+			}
+			for t := range snapTables {
+				seen[t] = true
+			}
+			for t := range seen {
+				names = append(names, t)
+			}
+		}
+		sort.Strings(names)
+
+		for _, table := range names {
+			d := TableDiff{Table: table}
+			if !liveTables[table] || !snapTables[table] {
+				switch {
+				case liveTables[table]:
+					d.Note = "table missing from snapshot"
+				case snapTables[table]:
+					d.Note = "table missing from live database"
+				default:
+					d.Note = "unknown table"
+				}
+				diffs = append(diffs, d)
+				continue
+			}
+			liveCols, key, err := tableColumns(conn, "main", table)
+			if err != nil {
+				return err
+			}
+			snapCols, _, err := tableColumns(conn, "snap", table)
+			if err != nil {
+				return err
+			}
+			cols := commonColumns(liveCols, snapCols)
+			d.Key = key
+
+			where := ""
+			var args []interface{}
+			if opts.Column != "" {
+				found := false
+				for _, c := range cols {
+					if c == opts.Column {
+						found = true
+					}
+				}
+				if !found {
+					d.Note = "filter column not present"
+					diffs = append(diffs, d)
+					continue
+				}
+				where = fmt.Sprintf(" WHERE %s = ?", quoteIdent(opts.Column))
+				args = []interface{}{opts.Value}
+			}
+
+			qt := quoteIdent(table)
+			qk := key
+			if key != "rowid" {
+				qk = quoteIdent(key)
+			}
+			if err := conn.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM main.%s%s`, qt, where), args...).Scan(&d.LiveRows); err != nil {
+				return err
+			}
+			if err := conn.QueryRowContext(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM snap.%s%s`, qt, where), args...).Scan(&d.SnapRows); err != nil {
+				return err
+			}
+
+			bothArgs := append(append([]interface{}{}, args...), args...)
+			removed, err := queryKeys(conn, fmt.Sprintf(`SELECT CAST(%[1]s AS TEXT) FROM snap.%[2]s%[3]s EXCEPT SELECT CAST(%[1]s AS TEXT) FROM main.%[2]s%[3]s`, qk, qt, where), bothArgs...)
+			if err != nil {
+				return err
+			}
+			added, err := queryKeys(conn, fmt.Sprintf(`SELECT CAST(%[1]s AS TEXT) FROM main.%[2]s%[3]s EXCEPT SELECT CAST(%[1]s AS TEXT) FROM snap.%[2]s%[3]s`, qk, qt, where), bothArgs...)
+			if err != nil {
+				return err
+			}
+
+			quoted := make([]string, len(cols))
+			for i, c := range cols {
+				quoted[i] = quoteIdent(c)
+			}
+			colList := strings.Join(quoted, ", ")
+			if key == "rowid" {
+				colList = "rowid, " + colList
+			}
+			differing, err := queryKeys(conn, fmt.Sprintf(`SELECT CAST(%[1]s AS TEXT) FROM (SELECT %[2]s FROM snap.%[3]s%[4]s EXCEPT SELECT %[2]s FROM main.%[3]s%[4]s)`, qk, colList, qt, where), bothArgs...)
+			if err != nil {
+				return err
+			}
+			removedSet := map[string]bool{}
+			for _, k := range removed {
+				removedSet[k] = true
+			}
+			var changed []string
+			for _, k := range differing {
+				if !removedSet[k] {
+					changed = append(changed, k)
+				}
+			}
+
+			d.Added, d.Removed, d.Changed = len(added), len(removed), len(changed)
+			d.AddedKeys = limitKeys(added, opts.Limit)
+			d.RemovedKeys = limitKeys(removed, opts.Limit)
+			d.ChangedKeys = limitKeys(changed, opts.Limit)
+			diffs = append(diffs, d)
+		}
+		return nil
+	})
+	return diffs, err
+}
+
+// RestoreRowsFromSnapshot brings registrations back with their team's usr_regs,
+// team_fields and users.registrations entries.
+func (db *Database) RestoreRowsFromSnapshot(path, table string, keys []string) (map[string]int64, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no rows selected")
+	}
+	tables := []string{table}
+	if table == "registrations" {
+		tables = append(tables, "usr_regs", "team_fields")
+	}
+	counts := map[string]int64{}
+	err := db.withSnapshotAttached(path, func(conn *sql.Conn) error {
+		ctx := context.Background()
+		snapTables, err := listTables(conn, "snap")
+		if err != nil {
+			return err
+		}
+		liveTables, err := listTables(conn, "main")
+		if err != nil {
+			return err
+		}
+		colLists := map[string]string{}
+		var key string
+		for _, t := range tables {
+			if !snapTables[t] || !liveTables[t] {
+				return fmt.Errorf("table %s not present in both databases", t)
+			}
+			liveCols, k, err := tableColumns(conn, "main", t)
+			if err != nil {
+				return err
+			}
+			snapCols, _, err := tableColumns(conn, "snap", t)
+			if err != nil {
+				return err
+			}
+			quoted := []string{}
+			for _, c := range commonColumns(liveCols, snapCols) {
+				quoted = append(quoted, quoteIdent(c))
+			}
+			colLists[t] = strings.Join(quoted, ", ")
+			if t == table {
+				key = k
+			}
+		}
+		if key == "rowid" {
+			return fmt.Errorf("table %s has no primary key", table)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")
+		args := make([]interface{}, len(keys))
+		for i, k := range keys {
+			args[i] = k
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		copyRows := func(t, where string, args ...interface{}) error {
+			res, err := tx.ExecContext(ctx, fmt.Sprintf(`INSERT OR REPLACE INTO main.%[1]s (%[2]s) SELECT %[2]s FROM snap.%[1]s WHERE %[3]s`,
+				quoteIdent(t), colLists[t], where), args...)
+			if err != nil {
+				return fmt.Errorf("error restoring %s rows: %v", t, err)
+			}
+			n, _ := res.RowsAffected()
+			counts[t] += n
+			return nil
+		}
+		if err := copyRows(table, fmt.Sprintf(`CAST(%s AS TEXT) IN (%s)`, quoteIdent(key), placeholders), args...); err != nil {
+			return err
+		}
+		if table == "registrations" {
+			if err := restoreRegistrationTeams(ctx, tx, placeholders, args, copyRows, counts); err != nil {
+				return err
+			}
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+func restoreRegistrationTeams(ctx context.Context, tx *sql.Tx, placeholders string, args []interface{},
+	copyRows func(t, where string, args ...interface{}) error, counts map[string]int64) error {
+	rows, err := tx.QueryContext(ctx, `SELECT DISTINCT r.user_id, r.event_id, COALESCE(u.username, ''), COALESCE(u.registrations, '{}')
+		FROM snap.registrations r LEFT JOIN snap.users u ON u.id = r.user_id
+		WHERE CAST(r.id AS TEXT) IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return err
+	}
+	type team struct {
+		userID   int
+		eventID  string
+		username string
+		regs     string
+	}
+	var teams []team
+	for rows.Next() {
+		var t team
+		if err := rows.Scan(&t.userID, &t.eventID, &t.username, &t.regs); err != nil {
+			rows.Close()
+			return err
+		}
+		teams = append(teams, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, t := range teams {
+		teamsOf := `(event_id = ? OR event_id LIKE ?)`
+		like := t.eventID + teamKeySeparator + "%"
+		if _, err := tx.ExecContext(ctx, `DELETE FROM main.team_fields WHERE user_id = ? AND `+teamsOf, t.userID, t.eventID, like); err != nil {
+			return err
+		}
+		if err := copyRows("team_fields", `user_id = ? AND `+teamsOf, t.userID, t.eventID, like); err != nil {
+			return err
+		}
+		if t.username != "" {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM main.usr_regs WHERE username = ? AND `+teamsOf, t.username, t.eventID, like); err != nil {
+				return err
+			}
+			if err := copyRows("usr_regs", `username = ? AND `+teamsOf, t.username, t.eventID, like); err != nil {
+				return err
+			}
+		}
+
+		var liveRegs string
+		err := tx.QueryRowContext(ctx, `SELECT COALESCE(registrations, '{}') FROM main.users WHERE id = ?`, t.userID).Scan(&liveRegs)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		live, snap := &User{}, &User{}
+		if err := live.unmarshalRegistrations(liveRegs); err != nil {
+			return fmt.Errorf("error reading registrations of user %d: %v", t.userID, err)
+		}
+		if err := snap.unmarshalRegistrations(t.regs); err != nil {
+			return fmt.Errorf("error reading snapshot registrations of user %d: %v", t.userID, err)
+		}
+		for k := range live.Registrations {
+			if IsTeamOf(k, t.eventID) {
+				delete(live.Registrations, k)
+			}
+		}
+		for k, v := range snap.Registrations {
+			if IsTeamOf(k, t.eventID) {
+				live.Registrations[k] = v
+			}
+		}
+		res, err := tx.ExecContext(ctx, `UPDATE main.users SET registrations = ?, updated_at = ? WHERE id = ?`, live.marshalRegistrations(), now, t.userID)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		counts["users"] += n
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package db
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func eventNames(t *testing.T, database *Database) map[string]string {
+	t.Helper()
+	rows, err := database.Query(`SELECT id, name FROM events`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	names := map[string]string{}
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			t.Fatal(err)
+		}
+		names[id] = name
+	}
+	return names
+}
+
+func TestSnapshotDiffAndRestore(t *testing.T) {
+	database := newTestDB(t)
+	for _, id := range []string{"quiz", "crossword", "sudocrypt"} {
+		if _, err := database.Exec(`INSERT INTO events (id, name) VALUES (?, ?)`, id, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snap := filepath.Join(t.TempDir(), "snap.db")
+	if err := database.SnapshotTo(snap); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SnapshotTo(snap); err == nil {
+		t.Error("SnapshotTo overwrote an existing file")
+	}
+
+	edits := []string{
+		`UPDATE events SET name = 'Quiz Bowl' WHERE id = 'quiz'`,
+		`DELETE FROM events WHERE id = 'crossword'`,
+		`INSERT INTO events (id, name) VALUES ('cubing', 'cubing')`,
+	}
+	for _, q := range edits {
+		if _, err := database.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	diffs, err := database.DiffSnapshot(snap, DiffOptions{Tables: []string{"events", "nonexistent"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 {
+		t.Fatalf("diffs = %+v", diffs)
+	}
+	d := diffs[0]
+	if d.Table != "events" || d.Key != "id" || d.LiveRows != 3 || d.SnapRows != 3 {
+		t.Errorf("events diff = %+v", d)
+	}
+	keys := map[string][]string{"added": d.AddedKeys, "removed": d.RemovedKeys, "changed": d.ChangedKeys}
+	want := map[string][]string{"added": {"cubing"}, "removed": {"crossword"}, "changed": {"quiz"}}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("diff keys = %v, want %v", keys, want)
+	}
+	if diffs[1].Note != "unknown table" {
+		t.Errorf("nonexistent table note = %q", diffs[1].Note)
+	}
+
+	filtered, err := database.DiffSnapshot(snap, DiffOptions{Tables: []string{"events"}, Column: "id", Value: "quiz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := filtered[0]; f.Changed != 1 || f.Added != 0 || f.Removed != 0 {
+		t.Errorf("filtered diff = %+v", f)
+	}
+
+	n, err := database.RestoreRowsFromSnapshot(snap, "events", []string{"crossword"})
+	if err != nil || !reflect.DeepEqual(n, map[string]int64{"events": 1}) {
+		t.Fatalf("RestoreRowsFromSnapshot = %v, %v", n, err)
+	}
+	got := eventNames(t, database)
+	if got["crossword"] != "crossword" || got["quiz"] != "Quiz Bowl" || got["cubing"] != "cubing" {
+		t.Errorf("after row restore: %v", got)
+	}
+
+	if err := database.RestoreFrom(snap); err != nil {
+		t.Fatal(err)
+	}
+	want2 := map[string]string{"quiz": "quiz", "crossword": "crossword", "sudocrypt": "sudocrypt"}
+	if got := eventNames(t, database); !reflect.DeepEqual(got, want2) {
+		t.Errorf("after full restore: %v", got)
+	}
+}
+
+// registrationState lists alice's usr_regs and team_fields event ids, her
+// registrations keys and her registration rows.
+func registrationState(t *testing.T, database *Database) map[string][]string {
+	t.Helper()
+	state := map[string][]string{}
+	queries := map[string]string{
+		"usr_regs":      `SELECT event_id || ':' || COALESCE(p1_name, '') FROM usr_regs WHERE username = 'alice'`,
+		"team_fields":   `SELECT event_id FROM team_fields WHERE user_id = 1`,
+		"registrations": `SELECT event_id FROM registrations WHERE user_id = 1`,
+	}
+	for name, q := range queries {
+		rows, err := database.Query(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				t.Fatal(err)
+			}
+			state[name] = append(state[name], v)
+		}
+		rows.Close()
+		sort.Strings(state[name])
+	}
+	var regs string
+	if err := database.QueryRow(`SELECT registrations FROM users WHERE id = 1`).Scan(&regs); err != nil {
+		t.Fatal(err)
+	}
+	u := &User{}
+	if err := u.unmarshalRegistrations(regs); err != nil {
+		t.Fatal(err)
+	}
+	for k := range u.Registrations {
+		state["users"] = append(state["users"], k)
+	}
+	sort.Strings(state["users"])
+	return state
+}
+
+func TestRestoreRegistrationRows(t *testing.T) {
+	seed := []string{
+		`INSERT INTO users (id, username, email, password_hash, registrations) VALUES
+			(1, 'alice', 'alice@x.org', 'x', '{"quiz":[{"name":"A"}],"quiz#2":[{"name":"B"}],"crossword":[{"name":"C"}]}')`,
+		`INSERT INTO registrations (id, event_id, user_id) VALUES (1, 'quiz', 1), (2, 'crossword', 1)`,
+		`INSERT INTO usr_regs (username, event_id, p1_name) VALUES ('alice', 'quiz', 'A'), ('alice', 'quiz#2', 'B'), ('alice', 'crossword', 'C')`,
+		`INSERT INTO team_fields (user_id, event_id) VALUES (1, 'quiz'), (1, 'quiz#2')`,
+	}
+	deleteQuiz := []string{
+		`DELETE FROM registrations WHERE id = 1`,
+		`DELETE FROM usr_regs WHERE event_id LIKE 'quiz%'`,
+		`DELETE FROM team_fields WHERE event_id LIKE 'quiz%'`,
+		`UPDATE users SET registrations = '{"crossword":[{"name":"C2"}]}' WHERE id = 1`,
+		`UPDATE usr_regs SET p1_name = 'C2' WHERE event_id = 'crossword'`,
+	}
+	restored := map[string][]string{
+		"registrations": {"crossword", "quiz"},
+		"usr_regs":      {"crossword:C2", "quiz#2:B", "quiz:A"},
+		"team_fields":   {"quiz", "quiz#2"},
+		"users":         {"crossword", "quiz", "quiz#2"},
+	}
+	tests := []struct {
+		name   string
+		edits  []string
+		counts map[string]int64
+		state  map[string][]string
+	}{
+		{"deleted team", deleteQuiz,
+			map[string]int64{"registrations": 1, "usr_regs": 2, "team_fields": 2, "users": 1}, restored},
+		{"team added since", []string{
+			`INSERT INTO usr_regs (username, event_id, p1_name) VALUES ('alice', 'quiz#3', 'D')`,
+			`UPDATE users SET registrations = json_set(registrations, '$."quiz#3"', json('[{"name":"D"}]')) WHERE id = 1`,
+			`UPDATE usr_regs SET p1_name = 'C2' WHERE event_id = 'crossword'`,
+		}, map[string]int64{"registrations": 1, "usr_regs": 2, "team_fields": 2, "users": 1}, restored},
+		{"rolled back", append(deleteQuiz,
+			`CREATE TRIGGER block_users BEFORE UPDATE ON users BEGIN SELECT RAISE(ABORT, 'blocked'); END`),
+			nil, map[string][]string{
+				"registrations": {"crossword"},
+				"usr_regs":      {"crossword:C2"},
+				"users":         {"crossword"},
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := newTestDB(t)
+			for _, q := range seed {
+				if _, err := database.Exec(q); err != nil {
+					t.Fatal(err)
+				}
+			}
+			snap := filepath.Join(t.TempDir(), "snap.db")
+			if err := database.SnapshotTo(snap); err != nil {
+				t.Fatal(err)
+			}
+			for _, q := range tt.edits {
+				if _, err := database.Exec(q); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			counts, err := database.RestoreRowsFromSnapshot(snap, "registrations", []string{"1"})
+			if (err != nil) != (tt.counts == nil) || !reflect.DeepEqual(counts, tt.counts) {
+				t.Errorf("RestoreRowsFromSnapshot = %v, %v; want %v", counts, err, tt.counts)
+			}
+			if got := registrationState(t, database); !reflect.DeepEqual(got, tt.state) {
+				t.Errorf("state = %v, want %v", got, tt.state)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type EmailSuppression struct {
+	ID        int       `json:"id"`
+	Email     string    `json:"email"`
+	Category  string    `json:"category"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (db *Database) IsSuppressed(email, category string) (bool, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM email_suppressions WHERE email = ? AND (category = ? OR category = 'all')`, normalizeEmail(email), category).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (db *Database) SuppressEmail(email, category, reason string) error {
+	if normalizeEmail(email) == "" || category == "" {
+		return fmt.Errorf("email and category are required")
+	}
+	query := `INSERT INTO email_suppressions (email, category, reason, created_at) VALUES (?, ?, ?, ?) ON CONFLICT(email, category) DO UPDATE SET reason = excluded.reason`
+	_, err := db.Exec(query, normalizeEmail(email), category, reason, time.Now())
+	return err
+}
+
+func (db *Database) UnsuppressEmail(email, category string) error {
+	query := `DELETE FROM email_suppressions WHERE email = ? AND category = ?`
+	_, err := db.Exec(query, normalizeEmail(email), category)
+	return err
+}
+
+func (db *Database) GetSuppressedCategories(email string) (map[string]bool, error) {
+	rows, err := db.Query(`SELECT category FROM email_suppressions WHERE email = ?`, normalizeEmail(email))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make(map[string]bool)
+	for rows.Next() {
+		var category string
+		if err := rows.Scan(&category); err != nil {
+			return nil, err
+		}
+		categories[category] = true
+	}
+	return categories, rows.Err()
+}
+
+func (db *Database) ListSuppressions(email string) ([]EmailSuppression, error) {
+	query := `SELECT id, email, category, COALESCE(reason, ''), created_at FROM email_suppressions`
+	args := []interface{}{}
+	if email != "" {
+		query += ` WHERE email = ?`
+		args = append(args, normalizeEmail(email))
+	}
+	query += ` ORDER BY created_at DESC`
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suppressions := []EmailSuppression{}
+	for rows.Next() {
+		var s EmailSuppression
+		if err := rows.Scan(&s.ID, &s.Email, &s.Category, &s.Reason, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		suppressions = append(suppressions, s)
+	}
+	return suppressions, rows.Err()
+}
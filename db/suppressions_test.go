@@ -0,0 +1,44 @@
+package db
+
+import "testing"
+
+func TestIsSuppressed(t *testing.T) {
+	database := newTestDB(t)
+	if err := database.SuppressEmail(" Teacher@School.edu ", "invite", "unsubscribe link"); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SuppressEmail("all@school.edu", "all", "bounced"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		email, category string
+		want            bool
+	}{
+		{"teacher@school.edu", "invite", true},
+		{"TEACHER@school.edu", "invite", true},
+		{"teacher@school.edu", "reminder", false},
+		{"all@school.edu", "invite", true},
+		{"all@school.edu", "reminder", true},
+		{"other@school.edu", "invite", false},
+	}
+	for _, tt := range tests {
+		got, err := database.IsSuppressed(tt.email, tt.category)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("IsSuppressed(%q, %q) = %v, want %v", tt.email, tt.category, got, tt.want)
+		}
+	}
+
+	if err := database.UnsuppressEmail("teacher@school.edu", "invite"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := database.IsSuppressed("teacher@school.edu", "invite"); got {
+		t.Error("still suppressed after UnsuppressEmail")
+	}
+	if err := database.SuppressEmail("", "invite", ""); err == nil {
+		t.Error("SuppressEmail accepted an empty email")
+	}
+}
@@ -0,0 +1,251 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type SyncConflict struct {
+	ID            int        `json:"id"`
+	Table         string     `json:"table"`
+	PK            string     `json:"pk"`
+	Column        string     `json:"column"`
+	BaseValue     *string    `json:"base_value"`
+	SheetValue    string     `json:"sheet_value"`
+	DBValue       string     `json:"db_value"`
+	Status        string     `json:"status"`
+	Resolution    string     `json:"resolution,omitempty"`
+	ResolvedValue *string    `json:"resolved_value,omitempty"`
+	ResolvedBy    string     `json:"resolved_by,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+}
+
+func (db *Database) GetSyncBase(table string) (map[string]map[string]string, error) {
+	rows, err := db.Query(`SELECT pk, data FROM sync_base WHERE table_name = ?`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	base := make(map[string]map[string]string)
+	for rows.Next() {
+		var pk, data string
+		if err := rows.Scan(&pk, &data); err != nil {
+			return nil, err
+		}
+		values := map[string]string{}
+		if err := json.Unmarshal([]byte(data), &values); err != nil {
+			continue
+		}
+		base[pk] = values
+	}
+	return base, rows.Err()
+}
+
+func (db *Database) SaveSyncBase(table, pk string, values map[string]string) error {
+	b, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO sync_base (table_name, pk, data, synced_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(table_name, pk) DO UPDATE SET data = excluded.data, synced_at = excluded.synced_at`,
+		table, pk, string(b), time.Now())
+	return err
+}
+
+func (db *Database) SetSyncBaseValue(table, pk, column, value string) error {
+	base, err := db.GetSyncBase(table)
+	if err != nil {
+		return err
+	}
+	values := base[pk]
+	if values == nil {
+		values = map[string]string{}
+	}
+	values[column] = value
+	return db.SaveSyncBase(table, pk, values)
+}
+
+func (db *Database) DeleteSyncBase(table, pk string) error {
+	_, err := db.Exec(`DELETE FROM sync_base WHERE table_name = ? AND pk = ?`, table, pk)
+	return err
+}
+
+func (db *Database) RecordSyncConflict(table, pk, column string, baseValue *string, sheetValue, dbValue string) (bool, error) {
+	now := time.Now()
+	res, err := db.Exec(`UPDATE sync_conflicts SET base_value = ?, sheet_value = ?, db_value = ?, updated_at = ?
+		WHERE table_name = ? AND pk = ? AND column_name = ? AND status = 'open'`,
+		baseValue, sheetValue, dbValue, now, table, pk, column)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return false, nil
+	}
+	_, err = db.Exec(`INSERT INTO sync_conflicts (table_name, pk, column_name, base_value, sheet_value, db_value, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, 'open', ?, ?)`,
+		table, pk, column, baseValue, sheetValue, dbValue, now, now)
+	return err == nil, err
+}
+
+func (db *Database) OpenSyncConflicts(table string) (map[string]map[string]int, error) {
+	rows, err := db.Query(`SELECT id, pk, column_name FROM sync_conflicts WHERE table_name = ? AND status = 'open'`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	open := make(map[string]map[string]int)
+	for rows.Next() {
+		var id int
+		var pk, column string
+		if err := rows.Scan(&id, &pk, &column); err != nil {
+			return nil, err
+		}
+		if open[pk] == nil {
+			open[pk] = map[string]int{}
+		}
+		open[pk][column] = id
+	}
+	return open, rows.Err()
+}
+
+const syncConflictColumns = `id, table_name, pk, column_name, base_value, COALESCE(sheet_value, ''), COALESCE(db_value, ''), status, COALESCE(resolution, ''), resolved_value, COALESCE(resolved_by, ''), created_at, updated_at, resolved_at`
+
+func scanSyncConflict(scan func(...interface{}) error) (*SyncConflict, error) {
+	c := &SyncConflict{}
+	var baseValue, resolvedValue sql.NullString
+	var resolvedAt sql.NullTime
+	if err := scan(&c.ID, &c.Table, &c.PK, &c.Column, &baseValue, &c.SheetValue, &c.DBValue, &c.Status, &c.Resolution, &resolvedValue, &c.ResolvedBy, &c.CreatedAt, &c.UpdatedAt, &resolvedAt); err != nil {
+		return nil, err
+	}
+	if baseValue.Valid {
+		c.BaseValue = &baseValue.String
+	}
+	if resolvedValue.Valid {
+		c.ResolvedValue = &resolvedValue.String
+	}
+	if resolvedAt.Valid {
+		c.ResolvedAt = &resolvedAt.Time
+	}
+	return c, nil
+}
+
+func (db *Database) ListSyncConflicts(status, table string) ([]SyncConflict, error) {
+	query := `SELECT ` + syncConflictColumns + ` FROM sync_conflicts WHERE 1 = 1`
+	args := []interface{}{}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	if table != "" {
+		query += ` AND table_name = ?`
+		args = append(args, table)
+	}
+	query += ` ORDER BY id DESC`
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conflicts := []SyncConflict{}
+	for rows.Next() {
+		c, err := scanSyncConflict(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, *c)
+	}
+	return conflicts, rows.Err()
+}
+
+func (db *Database) GetSyncConflict(id int) (*SyncConflict, error) {
+	return scanSyncConflict(db.QueryRow(`SELECT `+syncConflictColumns+` FROM sync_conflicts WHERE id = ?`, id).Scan)
+}
+
+func (db *Database) ResolveSyncConflict(id int, resolution string, value *string, resolvedBy string) error {
+	_, err := db.Exec(`UPDATE sync_conflicts SET status = 'resolved', resolution = ?, resolved_value = ?, resolved_by = ?, resolved_at = ?, updated_at = ? WHERE id = ?`,
+		resolution, value, resolvedBy, time.Now(), time.Now(), id)
+	return err
+}
+
+type SyncRun struct {
+	ID          int             `json:"id"`
+	Target      string          `json:"target"`
+	TriggeredBy string          `json:"triggered_by"`
+	DryRun      bool            `json:"dry_run"`
+	Incremental bool            `json:"incremental"`
+	Status      string          `json:"status"`
+	Tables      json.RawMessage `json:"tables,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	StartedAt   time.Time       `json:"started_at"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+func (db *Database) CreateSyncRun(target, triggeredBy string, dryRun bool) (int, error) {
+	res, err := db.Exec(`INSERT INTO sync_runs (target, triggered_by, dry_run, status, started_at) VALUES (?, ?, ?, 'running', ?)`,
+		target, triggeredBy, dryRun, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	return int(id), err
+}
+
+func (db *Database) FinishSyncRun(id int, status string, incremental bool, tables interface{}, runErr string) error {
+	b, err := json.Marshal(tables)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`UPDATE sync_runs SET status = ?, incremental = ?, tables = ?, error = ?, finished_at = ? WHERE id = ?`,
+		status, incremental, string(b), runErr, time.Now(), id)
+	return err
+}
+
+func scanSyncRun(scan func(...interface{}) error) (*SyncRun, error) {
+	r := &SyncRun{}
+	var tables, runErr sql.NullString
+	var finishedAt sql.NullTime
+	if err := scan(&r.ID, &r.Target, &r.TriggeredBy, &r.DryRun, &r.Incremental, &r.Status, &tables, &runErr, &r.StartedAt, &finishedAt); err != nil {
+		return nil, err
+	}
+	if tables.Valid && tables.String != "" && tables.String != "null" {
+		r.Tables = json.RawMessage(tables.String)
+	}
+	r.Error = runErr.String
+	if finishedAt.Valid {
+		r.FinishedAt = &finishedAt.Time
+	}
+	return r, nil
+}
+
+func (db *Database) ListSyncRuns(limit int) ([]SyncRun, error) {
+	rows, err := db.Query(`SELECT id, target, triggered_by, dry_run, incremental, status, tables, error, started_at, finished_at FROM sync_runs ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []SyncRun{}
+	for rows.Next() {
+		r, err := scanSyncRun(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *r)
+	}
+	return runs, rows.Err()
+}
+
+func (db *Database) GetSyncRun(id int) (*SyncRun, error) {
+	return scanSyncRun(db.QueryRow(`SELECT id, target, triggered_by, dry_run, incremental, status, tables, error, started_at, finished_at FROM sync_runs WHERE id = ?`, id).Scan)
+}
+
+func (db *Database) FailStaleSyncRuns() error {
+	_, err := db.Exec(`UPDATE sync_runs SET status = 'failed', error = 'interrupted', finished_at = ? WHERE status = 'running'`, time.Now())
+	return err
+}
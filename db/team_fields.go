@@ -0,0 +1,72 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+func (db *Database) TeamFields(userID int, eventID string) (map[string]string, error) {
+	var raw string
+	err := db.QueryRow(`SELECT fields FROM team_fields WHERE user_id = ? AND event_id = ?`, userID, eventID).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]string
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func (db *Database) SetTeamFields(userID int, eventID string, fields map[string]string) error {
+	if len(fields) == 0 {
+		_, err := db.Exec(`DELETE FROM team_fields WHERE user_id = ? AND event_id = ?`, userID, eventID)
+		return err
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO team_fields (user_id, event_id, fields, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id, event_id) DO UPDATE SET fields = excluded.fields, updated_at = excluded.updated_at`,
+		userID, eventID, string(data), time.Now())
+	return err
+}
+
+func (db *Database) AllTeamFields() (map[int]map[string]map[string]string, error) {
+	rows, err := db.Query(`SELECT user_id, event_id, fields FROM team_fields`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	all := map[int]map[string]map[string]string{}
+	for rows.Next() {
+		var userID int
+		var eventID, raw string
+		if err := rows.Scan(&userID, &eventID, &raw); err != nil {
+			return nil, err
+		}
+		var fields map[string]string
+		if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+			continue
+		}
+		if all[userID] == nil {
+			all[userID] = map[string]map[string]string{}
+		}
+		all[userID][eventID] = fields
+	}
+	return all, rows.Err()
+}
+
+func (db *Database) EnsureColumns(table string, columns []string) error {
+	for _, c := range columns {
+		if err := db.addColumnIfMissing(table, c, "TEXT DEFAULT ''"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTeamFields(t *testing.T) {
+	database := newTestDB(t)
+
+	steps := []struct {
+		name   string
+		userID int
+		event  string
+		set    map[string]string
+		want   map[string]string
+	}{
+		{"insert", 1, "quiz", map[string]string{"repo": "https://a.org"}, map[string]string{"repo": "https://a.org"}},
+		{"replace", 1, "quiz", map[string]string{"repo": "https://b.org", "notes": "x"}, map[string]string{"repo": "https://b.org", "notes": "x"}},
+		{"other event", 1, "hack", map[string]string{"pitch": "p"}, map[string]string{"pitch": "p"}},
+		{"clear", 1, "hack", nil, nil},
+	}
+	for _, s := range steps {
+		t.Run(s.name, func(t *testing.T) {
+			if err := database.SetTeamFields(s.userID, s.event, s.set); err != nil {
+				t.Fatal(err)
+			}
+			got, err := database.TeamFields(s.userID, s.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, s.want) {
+				t.Errorf("TeamFields = %v, want %v", got, s.want)
+			}
+		})
+	}
+
+	all, err := database.AllTeamFields()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]map[string]map[string]string{1: {"quiz": {"repo": "https://b.org", "notes": "x"}}}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("AllTeamFields = %v, want %v", all, want)
+	}
+}
+
+func TestEnsureColumns(t *testing.T) {
+	database := newTestDB(t)
+	for i := 0; i < 2; i++ {
+		if err := database.EnsureColumns("usr_regs", []string{"team_repo", "p1_tshirt"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := database.Exec(`INSERT INTO usr_regs (username, event_id, team_repo, p1_tshirt) VALUES ('u', 'quiz', 'r', 'M')`); err != nil {
+		t.Fatalf("added columns are not usable: %v", err)
+	}
+}
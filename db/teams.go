@@ -0,0 +1,75 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A school's first team for an event is stored under the event ID in the
+// users' registrations JSON; further teams use "<event id>#<n>". Event IDs
+// are slugs, so they never contain "#".
+const teamKeySeparator = "#"
+
+func TeamKey(eventID string, team int) string {
+	if team <= 1 {
+		return eventID
+	}
+	return eventID + teamKeySeparator + strconv.Itoa(team)
+}
+
+func SplitTeamKey(key string) (string, int) {
+	i := strings.LastIndex(key, teamKeySeparator)
+	if i < 0 {
+		return key, 1
+	}
+	n, err := strconv.Atoi(key[i+1:])
+	if err != nil || n < 1 {
+		return key, 1
+	}
+	return key[:i], n
+}
+
+func IsTeamOf(key, eventID string) bool {
+	id, _ := SplitTeamKey(key)
+	return id == eventID
+}
+
+func teamNumber(team int) int {
+	if team < 1 {
+		return 1
+	}
+	return team
+}
+
+func (db *Database) TeamRegistration(userID int, eventID string, team int) (*Registration, error) {
+	reg := &Registration{}
+	err := db.QueryRow(`SELECT id, event_id, user_id, COALESCE(team, 1), COALESCE(team_name, ''), COALESCE(captain, ''), status, created_at, updated_at
+		FROM registrations WHERE user_id = ? AND event_id = ? AND COALESCE(team, 1) = ? ORDER BY id DESC LIMIT 1`,
+		userID, eventID, teamNumber(team)).Scan(
+		&reg.ID, &reg.EventID, &reg.UserID, &reg.Team, &reg.TeamName, &reg.Captain, &reg.Status, &reg.CreatedAt, &reg.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+func (db *Database) DeleteTeamRegistrations(userID int, eventID string, team int) error {
+	_, err := db.Exec(`DELETE FROM registrations WHERE user_id = ? AND event_id = ? AND COALESCE(team, 1) = ?`, userID, eventID, teamNumber(team))
+	return err
+}
+
+// Team names are compared case-insensitively.
+func (db *Database) TeamNameTaken(eventID, name string, userID, team int) (bool, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM registrations WHERE event_id = ? AND LOWER(TRIM(team_name)) = LOWER(TRIM(?))
+		AND NOT (user_id = ? AND COALESCE(team, 1) = ?)`, eventID, name, userID, teamNumber(team)).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("error checking team name: %v", err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,166 @@
+package db
+
+import "testing"
+
+func TestTeamKey(t *testing.T) {
+	tests := []struct {
+		event string
+		team  int
+		key   string
+	}{
+		{"quiz", 0, "quiz"},
+		{"quiz", 1, "quiz"},
+		{"quiz", 2, "quiz#2"},
+		{"quiz-bowl", 12, "quiz-bowl#12"},
+	}
+	for _, tt := range tests {
+		if got := TeamKey(tt.event, tt.team); got != tt.key {
+			t.Errorf("TeamKey(%q, %d) = %q, want %q", tt.event, tt.team, got, tt.key)
+		}
+		event, team := SplitTeamKey(tt.key)
+		if want := teamNumber(tt.team); event != tt.event || team != want {
+			t.Errorf("SplitTeamKey(%q) = %q, %d", tt.key, event, team)
+		}
+	}
+
+	splits := []struct {
+		key   string
+		event string
+		team  int
+	}{
+		{"quiz#x", "quiz#x", 1},
+		{"quiz#0", "quiz#0", 1},
+		{"quiz#-2", "quiz#-2", 1},
+	}
+	for _, tt := range splits {
+		if event, team := SplitTeamKey(tt.key); event != tt.event || team != tt.team {
+			t.Errorf("SplitTeamKey(%q) = %q, %d, want %q, %d", tt.key, event, team, tt.event, tt.team)
+		}
+	}
+
+	members := []struct {
+		key, event string
+		want       bool
+	}{
+		{"quiz", "quiz", true},
+		{"quiz#3", "quiz", true},
+		{"quiz2", "quiz", false},
+		{"quiz-bowl#2", "quiz", false},
+	}
+	for _, tt := range members {
+		if got := IsTeamOf(tt.key, tt.event); got != tt.want {
+			t.Errorf("IsTeamOf(%q, %q) = %v, want %v", tt.key, tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestTeamRegistrations(t *testing.T) {
+	database := newTestDB(t)
+	rows := []struct {
+		user int
+		team interface{}
+		name string
+	}{
+		{1, nil, "Bit Flippers"},
+		{1, 2, "Null Pointers"},
+		{2, 1, "Stack Smashers"},
+	}
+	for _, r := range rows {
+		if _, err := database.Exec(`INSERT INTO registrations (event_id, user_id, team, team_name, captain) VALUES ('quiz', ?, ?, ?, 'c@example.com')`, r.user, r.team, r.name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reg, err := database.TeamRegistration(1, "quiz", 0)
+	if err != nil || reg == nil || reg.Team != 1 || reg.TeamName != "Bit Flippers" {
+		t.Fatalf("first team = %+v, %v", reg, err)
+	}
+	if reg, err := database.TeamRegistration(1, "quiz", 3); reg != nil || err != nil {
+		t.Errorf("missing team = %+v, %v", reg, err)
+	}
+
+	taken := []struct {
+		name       string
+		user, team int
+		want       bool
+	}{
+		{" null pointers ", 2, 1, true},
+		{"Null Pointers", 1, 2, false},
+		{"Null Pointers", 1, 1, true},
+		{"Fresh Name", 1, 1, false},
+	}
+	for _, tt := range taken {
+		got, err := database.TeamNameTaken("quiz", tt.name, tt.user, tt.team)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("TeamNameTaken(%q, user %d, team %d) = %v, want %v", tt.name, tt.user, tt.team, got, tt.want)
+		}
+	}
+
+	if err := database.DeleteTeamRegistrations(1, "quiz", 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := countRows(t, database, `SELECT COUNT(*) FROM registrations WHERE user_id = 1`); got != 1 {
+		t.Errorf("%d registrations left for user 1, want 1", got)
+	}
+}
+
+func TestDeleteEventCascadeRemovesEveryTeam(t *testing.T) {
+	database := newTestDB(t)
+	seedEventImpact(t, database)
+	if _, err := database.Exec(`UPDATE users SET registrations = '{"quiz":[{"name":"A"}],"quiz#2":[{"name":"B"},{"name":"C"}],"quiz2":[{"name":"D"}]}' WHERE id = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec(`INSERT INTO usr_regs (username, event_id, p1_name) VALUES ('s1', 'quiz#2', 'B')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SetTeamFields(1, "quiz#2", map[string]string{"repo": "r"}); err != nil {
+		t.Fatal(err)
+	}
+
+	impact, err := database.DeleteEventCascade("quiz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if impact.Teams != 2 || impact.Participants != 3 || impact.UsrRegs != 2 {
+		t.Errorf("impact = %+v", impact)
+	}
+	for _, q := range []string{
+		`SELECT COUNT(*) FROM usr_regs WHERE event_id LIKE 'quiz#%'`,
+		`SELECT COUNT(*) FROM team_fields`,
+		`SELECT COUNT(*) FROM users WHERE registrations LIKE '%"quiz#2"%'`,
+	} {
+		if got := countRows(t, database, q); got != 0 {
+			t.Errorf("%s = %d, want 0", q, got)
+		}
+	}
+	if got := countRows(t, database, `SELECT COUNT(*) FROM usr_regs WHERE event_id = 'quiz2'`); got != 1 {
+		t.Error("a similarly named event lost its rows")
+	}
+}
+
+func TestRenameEventMovesEveryTeam(t *testing.T) {
+	database := newTestDB(t)
+	seedEventImpact(t, database)
+	if _, err := database.Exec(`UPDATE users SET registrations = '{"quiz":[{"name":"A"}],"quiz#2":[{"name":"B"}]}' WHERE id = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec(`INSERT INTO usr_regs (username, event_id, p1_name) VALUES ('s1', 'quiz#2', 'B')`); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.RenameEvent("quiz", "trivia"); err != nil {
+		t.Fatal(err)
+	}
+	checks := map[string]int{
+		`SELECT COUNT(*) FROM usr_regs WHERE event_id IN ('trivia', 'trivia#2')`:                            2,
+		`SELECT COUNT(*) FROM users WHERE registrations LIKE '%"trivia#2"%'`:                                1,
+		`SELECT COUNT(*) FROM users WHERE registrations LIKE '%"quiz"%' OR registrations LIKE '%"quiz#2"%'`: 0,
+	}
+	for q, want := range checks {
+		if got := countRows(t, database, q); got != want {
+			t.Errorf("%s = %d, want %d", q, got, want)
+		}
+	}
+}
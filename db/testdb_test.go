@@ -0,0 +1,19 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func newTestDB(t *testing.T) *Database {
+	t.Helper()
+	database, err := NewConnection(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := database.InitTables(); err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
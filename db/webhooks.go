@@ -0,0 +1,225 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+type Webhook struct {
+	ID          int       `json:"id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (w *Webhook) Subscribes(eventType string) bool {
+	for _, e := range w.Events {
+		if e == eventType || e == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDelivery struct {
+	ID             int        `json:"id"`
+	WebhookID      int        `json:"webhook_id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Payload        string     `json:"payload,omitempty"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	ResponseBody   string     `json:"response_body,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+const webhookColumns = `id, url, secret, events, COALESCE(description, ''), active, created_at, updated_at`
+
+func scanWebhook(scan func(...interface{}) error) (*Webhook, error) {
+	w := &Webhook{}
+	var events string
+	if err := scan(&w.ID, &w.URL, &w.Secret, &events, &w.Description, &w.Active, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(events), &w.Events); err != nil || w.Events == nil {
+		w.Events = []string{}
+	}
+	return w, nil
+}
+
+func (db *Database) ListWebhooks() ([]Webhook, error) {
+	rows, err := db.Query(`SELECT ` + webhookColumns + ` FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, *w)
+	}
+	return hooks, rows.Err()
+}
+
+func (db *Database) GetWebhook(id int) (*Webhook, error) {
+	return scanWebhook(db.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id = ?`, id).Scan)
+}
+
+func (db *Database) CreateWebhook(w *Webhook) error {
+	events, err := json.Marshal(w.Events)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	res, err := db.Exec(`INSERT INTO webhooks (url, secret, events, description, active, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		w.URL, w.Secret, string(events), w.Description, w.Active, now, now)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	w.ID = int(id)
+	w.CreatedAt = now
+	w.UpdatedAt = now
+	return nil
+}
+
+func (db *Database) UpdateWebhook(w *Webhook) error {
+	events, err := json.Marshal(w.Events)
+	if err != nil {
+		return err
+	}
+	w.UpdatedAt = time.Now()
+	res, err := db.Exec(`UPDATE webhooks SET url = ?, secret = ?, events = ?, description = ?, active = ?, updated_at = ? WHERE id = ?`,
+		w.URL, w.Secret, string(events), w.Description, w.Active, w.UpdatedAt, w.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (db *Database) DeleteWebhook(id int) error {
+	if _, err := db.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id); err != nil {
+		return err
+	}
+	res, err := db.Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (db *Database) CreateWebhookDelivery(d *WebhookDelivery) error {
+	now := time.Now()
+	res, err := db.Exec(`INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, 0, ?, ?, ?)`,
+		d.WebhookID, d.EventID, d.EventType, d.Payload, d.Status, d.NextAttemptAt, now, now)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	d.ID = int(id)
+	d.CreatedAt = now
+	d.UpdatedAt = now
+	return nil
+}
+
+func (db *Database) SaveWebhookAttempt(d *WebhookDelivery) error {
+	d.UpdatedAt = time.Now()
+	_, err := db.Exec(`UPDATE webhook_deliveries SET status = ?, attempts = ?, response_status = ?, response_body = ?, error = ?, next_attempt_at = ?, updated_at = ?, delivered_at = ? WHERE id = ?`,
+		d.Status, d.Attempts, d.ResponseStatus, d.ResponseBody, d.Error, d.NextAttemptAt, d.UpdatedAt, d.DeliveredAt, d.ID)
+	return err
+}
+
+const webhookDeliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts, COALESCE(response_status, 0), COALESCE(response_body, ''), COALESCE(error, ''), next_attempt_at, created_at, updated_at, delivered_at`
+
+func scanWebhookDelivery(scan func(...interface{}) error) (*WebhookDelivery, error) {
+	d := &WebhookDelivery{}
+	var nextAttemptAt, deliveredAt sql.NullTime
+	if err := scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.ResponseStatus, &d.ResponseBody, &d.Error, &nextAttemptAt, &d.CreatedAt, &d.UpdatedAt, &deliveredAt); err != nil {
+		return nil, err
+	}
+	if nextAttemptAt.Valid {
+		d.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if deliveredAt.Valid {
+		d.DeliveredAt = &deliveredAt.Time
+	}
+	return d, nil
+}
+
+func (db *Database) queryWebhookDeliveries(query string, args ...interface{}) ([]WebhookDelivery, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (db *Database) DueWebhookDeliveries(now time.Time, limit int) ([]WebhookDelivery, error) {
+	return db.queryWebhookDeliveries(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries
+		WHERE status = 'pending' AND (next_attempt_at IS NULL OR next_attempt_at <= ?) ORDER BY id LIMIT ?`, now, limit)
+}
+
+func (db *Database) ListWebhookDeliveries(webhookID int, status string, limit int) ([]WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE 1 = 1`
+	args := []interface{}{}
+	if webhookID > 0 {
+		query += ` AND webhook_id = ?`
+		args = append(args, webhookID)
+	}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+	return db.queryWebhookDeliveries(query, args...)
+}
+
+func (db *Database) GetWebhookDelivery(id int) (*WebhookDelivery, error) {
+	return scanWebhookDelivery(db.QueryRow(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE id = ?`, id).Scan)
+}
+
+func (db *Database) PruneWebhookDeliveries(before time.Time) (int64, error) {
+	res, err := db.Exec(`DELETE FROM webhook_deliveries WHERE status != 'pending' AND created_at < ?`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
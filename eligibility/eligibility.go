@@ -0,0 +1,240 @@
+package eligibility
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	MinClass = 1
+	MaxClass = 12
+)
+
+var Genders = []string{"female", "male", "other"}
+
+type Range struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+// Empty Condition fields match everyone.
+type Condition struct {
+	Classes *Range   `json:"classes,omitempty"`
+	Genders []string `json:"genders,omitempty"`
+}
+
+// For example, at least one member from classes 6–8.
+type TeamRule struct {
+	Match   Condition `json:"match"`
+	AtLeast int       `json:"at_least,omitempty"`
+	AtMost  *int      `json:"at_most,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+type Rules struct {
+	Member      Condition  `json:"member"`
+	MinTeamSize int        `json:"min_team_size,omitempty"`
+	Team        []TeamRule `json:"team,omitempty"`
+}
+
+type Member struct {
+	Class  int
+	Gender string
+}
+
+func ClassRange(min, max int) Rules {
+	if min <= MinClass && max >= MaxClass {
+		return Rules{}
+	}
+	return Rules{Member: Condition{Classes: &Range{Min: min, Max: max}}}
+}
+
+func (c Condition) Matches(m Member) bool {
+	if c.Classes != nil && (m.Class < c.Classes.Min || m.Class > c.Classes.Max) {
+		return false
+	}
+	if len(c.Genders) > 0 && !contains(c.Genders, strings.ToLower(strings.TrimSpace(m.Gender))) {
+		return false
+	}
+	return true
+}
+
+func (c Condition) IsEmpty() bool {
+	return c.Classes == nil && len(c.Genders) == 0
+}
+
+func (c Condition) describe() string {
+	var parts []string
+	if c.Classes != nil {
+		parts = append(parts, "from "+c.Classes.String())
+	}
+	if len(c.Genders) > 0 {
+		parts = append(parts, "who are "+joinOr(c.Genders))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (r Range) String() string {
+	if r.Min == r.Max {
+		return fmt.Sprintf("class %d", r.Min)
+	}
+	return fmt.Sprintf("classes %d–%d", r.Min, r.Max)
+}
+
+func (r Rules) OpenToAll() bool {
+	return r.Member.IsEmpty() && r.MinTeamSize <= 1 && len(r.Team) == 0
+}
+
+func (r Rules) Classes() (int, int) {
+	if r.Member.Classes == nil {
+		return MinClass, MaxClass
+	}
+	return r.Member.Classes.Min, r.Member.Classes.Max
+}
+
+func (r Rules) RequiresGender() bool {
+	if len(r.Member.Genders) > 0 {
+		return true
+	}
+	for _, t := range r.Team {
+		if len(t.Match.Genders) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rules) Describe() string {
+	if r.OpenToAll() {
+		return "Open to all"
+	}
+	min, max := r.Classes()
+	parts := []string{fmt.Sprintf("Grades %d–%d", min, max)}
+	if len(r.Member.Genders) > 0 {
+		parts = append(parts, joinOr(r.Member.Genders)+" participants only")
+	}
+	if r.MinTeamSize > 1 {
+		parts = append(parts, fmt.Sprintf("teams of at least %d", r.MinTeamSize))
+	}
+	for _, t := range r.Team {
+		parts = append(parts, t.describe())
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (t TeamRule) describe() string {
+	if t.Message != "" {
+		return t.Message
+	}
+	who := t.Match.describe()
+	switch {
+	case t.AtMost == nil:
+		return fmt.Sprintf("at least %d %s %s", t.AtLeast, plural(t.AtLeast), who)
+	case t.AtLeast == 0:
+		return fmt.Sprintf("at most %d %s %s", *t.AtMost, plural(*t.AtMost), who)
+	default:
+		return fmt.Sprintf("between %d and %d members %s", t.AtLeast, *t.AtMost, who)
+	}
+}
+
+func (r Rules) Validate(maxTeam int) error {
+	if err := r.Member.validate(); err != nil {
+		return fmt.Errorf("member: %v", err)
+	}
+	if r.MinTeamSize < 0 || r.MinTeamSize > maxTeam {
+		return fmt.Errorf("min_team_size must be between 0 and the team size (%d)", maxTeam)
+	}
+	for i, t := range r.Team {
+		if t.Match.IsEmpty() {
+			return fmt.Errorf("team rule %d: match must give classes or genders", i+1)
+		}
+		if err := t.Match.validate(); err != nil {
+			return fmt.Errorf("team rule %d: %v", i+1, err)
+		}
+		if t.AtLeast < 0 || t.AtLeast > maxTeam {
+			return fmt.Errorf("team rule %d: at_least must be between 0 and the team size (%d)", i+1, maxTeam)
+		}
+		if t.AtMost != nil && (*t.AtMost < t.AtLeast || *t.AtMost < 0) {
+			return fmt.Errorf("team rule %d: at_most must not be less than at_least", i+1)
+		}
+		if t.AtLeast == 0 && t.AtMost == nil {
+			return fmt.Errorf("team rule %d: give at_least or at_most", i+1)
+		}
+	}
+	return nil
+}
+
+func (c Condition) validate() error {
+	if c.Classes != nil && (c.Classes.Min < MinClass || c.Classes.Max > MaxClass || c.Classes.Min > c.Classes.Max) {
+		return fmt.Errorf("classes must satisfy %d <= min <= max <= %d", MinClass, MaxClass)
+	}
+	for _, g := range c.Genders {
+		if !contains(Genders, g) {
+			return fmt.Errorf("unknown gender %q (use %s)", g, strings.Join(Genders, ", "))
+		}
+	}
+	return nil
+}
+
+func (r Rules) CheckMember(m Member) []string {
+	var problems []string
+	if c := r.Member.Classes; c != nil && (m.Class < c.Min || m.Class > c.Max) {
+		problems = append(problems, fmt.Sprintf("class %d is not eligible for this event (open to %s)", m.Class, c))
+	}
+	if g := r.Member.Genders; len(g) > 0 && !contains(g, strings.ToLower(strings.TrimSpace(m.Gender))) {
+		problems = append(problems, fmt.Sprintf("this event is open to %s participants only", joinOr(g)))
+	}
+	return problems
+}
+
+func (r Rules) CheckTeam(members []Member) []string {
+	var problems []string
+	for i, m := range members {
+		for _, p := range r.CheckMember(m) {
+			problems = append(problems, fmt.Sprintf("participant %d: %s", i+1, p))
+		}
+	}
+	if r.MinTeamSize > 1 && len(members) < r.MinTeamSize {
+		problems = append(problems, fmt.Sprintf("the team needs at least %d members", r.MinTeamSize))
+	}
+	for _, t := range r.Team {
+		n := 0
+		for _, m := range members {
+			if t.Match.Matches(m) {
+				n++
+			}
+		}
+		if n >= t.AtLeast && (t.AtMost == nil || n <= *t.AtMost) {
+			continue
+		}
+		if t.Message != "" {
+			problems = append(problems, t.Message)
+		} else {
+			problems = append(problems, "the team must have "+t.describe())
+		}
+	}
+	return problems
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return "member"
+	}
+	return "members"
+}
+
+func joinOr(values []string) string {
+	if len(values) <= 1 {
+		return strings.Join(values, "")
+	}
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+}
+
+func contains(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
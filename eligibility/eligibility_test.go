@@ -0,0 +1,189 @@
+package eligibility
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func intPtr(n int) *int { return &n }
+
+var juniorGirls = Rules{
+	Member:      Condition{Classes: &Range{Min: 6, Max: 12}},
+	MinTeamSize: 2,
+	Team: []TeamRule{
+		{Match: Condition{Classes: &Range{Min: 6, Max: 8}}, AtLeast: 1},
+		{Match: Condition{Genders: []string{"female"}}, AtLeast: 1, Message: "the team needs a girl"},
+	},
+}
+
+func TestClassRange(t *testing.T) {
+	tests := []struct {
+		min, max int
+		open     bool
+	}{
+		{1, 12, true},
+		{0, 13, true},
+		{6, 12, false},
+		{1, 8, false},
+	}
+	for _, tt := range tests {
+		r := ClassRange(tt.min, tt.max)
+		if r.OpenToAll() != tt.open {
+			t.Errorf("ClassRange(%d, %d).OpenToAll() = %v, want %v", tt.min, tt.max, r.OpenToAll(), tt.open)
+		}
+	}
+	if min, max := ClassRange(6, 9).Classes(); min != 6 || max != 9 {
+		t.Errorf("Classes() = %d, %d", min, max)
+	}
+	if min, max := (Rules{}).Classes(); min != MinClass || max != MaxClass {
+		t.Errorf("open Classes() = %d, %d", min, max)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules
+		want  string
+	}{
+		{"open", Rules{}, "Open to all"},
+		{"class range", ClassRange(6, 12), "Grades 6–12"},
+		{"genders", Rules{Member: Condition{Genders: []string{"female", "other"}}}, "Grades 1–12; female or other participants only"},
+		{"team rules", juniorGirls, "Grades 6–12; teams of at least 2; at least 1 member from classes 6–8; the team needs a girl"},
+		{"at most", Rules{Team: []TeamRule{{Match: Condition{Classes: &Range{Min: 12, Max: 12}}, AtMost: intPtr(2)}}},
+			"Grades 1–12; at most 2 members from class 12"},
+		{"between", Rules{Team: []TeamRule{{Match: Condition{Genders: []string{"male"}}, AtLeast: 1, AtMost: intPtr(2)}}},
+			"Grades 1–12; between 1 and 2 members who are male"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.Describe(); got != tt.want {
+				t.Errorf("Describe() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   Rules
+		maxTeam int
+		errHas  string
+	}{
+		{"open", Rules{}, 1, ""},
+		{"team rules", juniorGirls, 4, ""},
+		{"inverted classes", ClassRange(9, 6), 4, "member: classes"},
+		{"class out of range", Rules{Member: Condition{Classes: &Range{Min: 0, Max: 12}}}, 4, "member: classes"},
+		{"unknown gender", Rules{Member: Condition{Genders: []string{"robot"}}}, 4, "unknown gender"},
+		{"min team size above team", Rules{MinTeamSize: 5}, 4, "min_team_size"},
+		{"empty match", Rules{Team: []TeamRule{{AtLeast: 1}}}, 4, "team rule 1: match"},
+		{"at least above team", Rules{Team: []TeamRule{{Match: Condition{Genders: []string{"male"}}, AtLeast: 5}}}, 4, "at_least"},
+		{"at most below at least", Rules{Team: []TeamRule{{Match: Condition{Genders: []string{"male"}}, AtLeast: 2, AtMost: intPtr(1)}}}, 4, "at_most"},
+		{"no bound", Rules{Team: []TeamRule{{Match: Condition{Genders: []string{"male"}}}}}, 4, "give at_least or at_most"},
+		{"second rule", Rules{Team: []TeamRule{juniorGirls.Team[0], {Match: Condition{Classes: &Range{Min: 8, Max: 6}}, AtLeast: 1}}}, 4, "team rule 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rules.Validate(tt.maxTeam)
+			if tt.errHas == "" {
+				if err != nil {
+					t.Errorf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errHas) {
+				t.Errorf("Validate = %v, want it to mention %q", err, tt.errHas)
+			}
+		})
+	}
+}
+
+func TestCheckMember(t *testing.T) {
+	rules := Rules{Member: Condition{Classes: &Range{Min: 6, Max: 8}, Genders: []string{"female"}}}
+	tests := []struct {
+		name     string
+		member   Member
+		problems int
+	}{
+		{"eligible", Member{Class: 7, Gender: "female"}, 0},
+		{"gender is normalised", Member{Class: 6, Gender: " Female "}, 0},
+		{"class too high", Member{Class: 9, Gender: "female"}, 1},
+		{"missing gender", Member{Class: 8}, 1},
+		{"both wrong", Member{Class: 12, Gender: "male"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.CheckMember(tt.member); len(got) != tt.problems {
+				t.Errorf("CheckMember = %q, want %d problems", got, tt.problems)
+			}
+		})
+	}
+}
+
+func TestCheckTeam(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   Rules
+		members []Member
+		want    []string
+	}{
+		{
+			name:    "eligible",
+			rules:   juniorGirls,
+			members: []Member{{Class: 7, Gender: "female"}, {Class: 11, Gender: "male"}},
+		},
+		{
+			name:    "too small and missing rules",
+			rules:   juniorGirls,
+			members: []Member{{Class: 10, Gender: "male"}},
+			want: []string{
+				"the team needs at least 2 members",
+				"the team must have at least 1 member from classes 6–8",
+				"the team needs a girl",
+			},
+		},
+		{
+			name:    "member problems carry their position",
+			rules:   juniorGirls,
+			members: []Member{{Class: 7, Gender: "female"}, {Class: 5, Gender: "female"}},
+			want:    []string{"participant 2: class 5 is not eligible for this event (open to classes 6–12)"},
+		},
+		{
+			name:    "at most exceeded",
+			rules:   Rules{Team: []TeamRule{{Match: Condition{Classes: &Range{Min: 12, Max: 12}}, AtMost: intPtr(1)}}},
+			members: []Member{{Class: 12}, {Class: 12}, {Class: 11}},
+			want:    []string{"the team must have at most 1 member from class 12"},
+		},
+		{
+			name:    "open rules",
+			rules:   Rules{},
+			members: []Member{{Class: 1}, {Class: 12, Gender: "other"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rules.CheckTeam(tt.members); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckTeam = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequiresGender(t *testing.T) {
+	tests := []struct {
+		rules Rules
+		want  bool
+	}{
+		{Rules{}, false},
+		{ClassRange(6, 8), false},
+		{Rules{Member: Condition{Genders: []string{"female"}}}, true},
+		{juniorGirls, true},
+	}
+	for i, tt := range tests {
+		if got := tt.rules.RequiresGender(); got != tt.want {
+			t.Errorf("case %d: RequiresGender = %v, want %v", i, got, tt.want)
+		}
+	}
+}
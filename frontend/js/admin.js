@@ -4,6 +4,7 @@ class AdminPage {
         this.stats = {};
         this.events = [];
         this.users = [];
+        this.webhooks = [];
     this._tabListenersAttached = false;
     this._modalListenersAttached = false;
     this._createEventBtnAttached = false;
@@ -116,6 +117,13 @@ class AdminPage {
         this._modalListenersAttached = true;
     }
 
+    closeModal() {
+        const modal = document.getElementById('admin-modal');
+        if (modal) {
+            modal.classList.remove('admin-modal--open');
+        }
+    }
+
     switchTab(tabName) {
         this.currentTab = tabName;
         
@@ -147,6 +155,15 @@ class AdminPage {
             case 'registrations':
                 await this.renderRegistrations();
                 break;
+            case 'webhooks':
+                await this.renderWebhooks();
+                break;
+            case 'audit':
+                await this.renderAudit();
+                break;
+            case 'queries':
+                await this.renderQueryReviews();
+                break;
             default:
                 content.innerHTML = '<p>Tab not found</p>';
         }
@@ -160,6 +177,11 @@ class AdminPage {
             'active-events': this.stats.activeEvents || this.stats.active_events || this.stats.totalEvents || this.stats.total_events || 0
         };
 
+        const queries = this.stats.query_reviews || {};
+        statsElements['query-pending'] = queries.pending || 0;
+        statsElements['query-rejected'] = queries.rejected || 0;
+        statsElements['query-flagged'] = queries.flagged || 0;
+
         Object.entries(statsElements).forEach(([id, value]) => {
             const element = document.getElementById(id);
             if (element) {
@@ -206,33 +228,59 @@ class AdminPage {
 
     async renderEvents() {
         try {
-            const response = await ExunServices.events.getAllEvents();
-            this.events = response.data || [];
+            const response = await ExunServices.api.apiRequest('/events?include_archived=1');
+            this.events = (response && response.data) || [];
             
             const content = document.getElementById('admin-content');
             content.innerHTML = `
                 <div class="admin-events">
                     <div class="flex justify-between items-center mb-6">
                         <h3 class="text-xl font-semibold">Event Management</h3>
+                        <div class="admin-actions">
+                            <button class="btn btn--secondary" onclick="adminPage.exportEvents('json')">Export JSON</button>
+                            <button class="btn btn--secondary" onclick="adminPage.exportEvents('csv')">Export CSV</button>
+                            <label class="btn btn--secondary">
+                                Import catalogue
+                                <input type="file" id="event-catalogue-file" accept=".json,.csv" hidden>
+                            </label>
+                            <button class="btn btn--primary" onclick="adminPage.showCreateEventModal()">Add event</button>
+                        </div>
                     </div>
                     <div class="admin-table-container">
                         <table class="admin-table">
                             <thead>
                                 <tr>
+                                    <th>Order</th>
                                     <th>Event Name</th>
+                                    <th>Category</th>
                                     <th>Mode</th>
                                     <th>Participants</th>
                                     <th>Registrations</th>
+                                    <th>Status</th>
+                                    <th>Actions</th>
                                 </tr>
                             </thead>
                             <tbody>
                                 ${this.events.map(event => `
                                     <tr>
+                                        <td>
+                                            <button class="btn btn--secondary" onclick="adminPage.moveEvent('${Utils.escapeHtml(event.id)}', -1)">&uarr;</button>
+                                            <button class="btn btn--secondary" onclick="adminPage.moveEvent('${Utils.escapeHtml(event.id)}', 1)">&darr;</button>
+                                        </td>
                                         <td>${event.name}</td>
+                                        <td>${Utils.escapeHtml(event.category_name || '')}</td>
                                         <td>${Utils.formatEventMode(event.mode)}</td>
                                         <td>${Utils.formatParticipants(event.participants)}</td>
                                         <td>${event.registrations || 0}</td>
-                                        
+                                        <td>${event.archived ? 'Archived' : 'Live'}</td>
+                                        <td>
+                                            <button class="btn btn--secondary" onclick="adminPage.editEvent('${Utils.escapeHtml(event.id)}')">Edit</button>
+                                            <button class="btn btn--secondary" onclick="adminPage.showEligibilityModal('${Utils.escapeHtml(event.id)}')">Eligibility</button>
+                                            <button class="btn btn--secondary" onclick="adminPage.showFormFieldsModal('${Utils.escapeHtml(event.id)}')">Fields</button>
+                                            <button class="btn btn--secondary" onclick="adminPage.exportRegistrations('${Utils.escapeHtml(event.id)}')">CSV</button>
+                                            <button class="btn btn--secondary" onclick="adminPage.archiveEvent('${Utils.escapeHtml(event.id)}', ${!event.archived})">${event.archived ? 'Unarchive' : 'Archive'}</button>
+                                            <button class="btn btn--secondary" onclick="adminPage.showDeleteEventModal('${Utils.escapeHtml(event.id)}')">Delete</button>
+                                        </td>
                                     </tr>
                                 `).join('')}
                             </tbody>
@@ -241,6 +289,7 @@ class AdminPage {
                 </div>
             `;
             
+            document.getElementById('event-catalogue-file').addEventListener('change', (e) => this.previewEventCatalogue(e.target));
             this.setupEventListeners();
         } catch (error) {
             console.error('Failed to load events:', error);
@@ -384,6 +433,7 @@ class AdminPage {
                             <th>Event</th>
                             <th>User</th>
                             <th>Team Name</th>
+                            <th>Captain</th>
                             <th>Members</th>
                             <th>Registration Date</th>
                         </tr>
@@ -394,12 +444,13 @@ class AdminPage {
                             const memberNames = members.length ? members.map(m => (m.name || m.Name || m.Name)).join(', ') : '';
                             const createdRaw = reg.createdAt ? new Date(reg.createdAt) : (reg.createdAt instanceof Date ? reg.createdAt : null);
                             const createdStr = createdRaw ? Utils.formatDate(createdRaw) : (reg.createdAt ? Utils.formatDate(reg.createdAt) : 'N/A');
-                            const team = reg.teamName || reg.TeamName || (members.length ? 'Team' : 'Individual');
+                            const team = reg.teamName || reg.TeamName || (members.length ? `Team ${reg.team || 1}` : 'Individual');
                             return `
                             <tr>
                                 <td>${Utils.escapeHtml(reg.eventName || reg.eventName || '')}</td>
                                 <td>${Utils.escapeHtml(reg.userEmail || reg.userEmail || '')}</td>
                                 <td>${Utils.escapeHtml(team)}</td>
+                                <td>${Utils.escapeHtml(reg.captain || '')}</td>
                                 <td>${members.length || (reg.memberCount || 1)}</td>
                                 <td>${Utils.escapeHtml(createdStr)}</td>
                             </tr>
@@ -413,68 +464,1159 @@ class AdminPage {
         }
     }
 
+    async renderWebhooks() {
+        const content = document.getElementById('admin-content');
+        content.innerHTML = `
+            <div class="admin-webhooks">
+                <div class="flex justify-between items-center mb-6">
+                    <h3 class="text-xl font-semibold">Webhooks</h3>
+                </div>
+                <form class="admin-form" id="create-webhook-form">
+                    <div class="admin-form__row">
+                        <div class="admin-form__group">
+                            <label class="admin-form__label">Endpoint URL</label>
+                            <input type="url" name="url" class="admin-form__input" placeholder="https://example.com/hooks/exun" required>
+                        </div>
+                        <div class="admin-form__group">
+                            <label class="admin-form__label">Description</label>
+                            <input type="text" name="description" class="admin-form__input" placeholder="Certificates team">
+                        </div>
+                    </div>
+                    <div class="admin-form__group" id="webhook-event-types"></div>
+                    <div class="admin-actions">
+                        <button type="submit" class="btn btn--primary">Add webhook</button>
+                    </div>
+                </form>
+                <div id="webhooks-table-container">
+                    <div class="loading-placeholder">Loading webhooks...</div>
+                </div>
+            </div>
+        `;
+
+        const form = document.getElementById('create-webhook-form');
+        form.addEventListener('submit', (e) => this.handleCreateWebhook(e));
+        await this.loadWebhooks();
+    }
+
+    async loadWebhooks() {
+        try {
+            const response = await ExunServices.api.apiRequest('/admin/webhooks');
+            this.webhooks = (response && response.data) || [];
+            this.webhookEventTypes = (response && response.event_types) || [];
+
+            const types = document.getElementById('webhook-event-types');
+            if (types && !types.dataset.rendered) {
+                types.innerHTML = `<label class="admin-form__label">Events</label>` + this.webhookEventTypes.map(t => `
+                    <label style="margin-right:1rem"><input type="checkbox" name="events" value="${Utils.escapeHtml(t)}" checked> ${Utils.escapeHtml(t)}</label>
+                `).join('');
+                types.dataset.rendered = '1';
+            }
+
+            const container = document.getElementById('webhooks-table-container');
+            if (!container) return;
+            if (this.webhooks.length === 0) {
+                container.innerHTML = '<p>No webhooks configured.</p>';
+                return;
+            }
+            container.innerHTML = `
+                <table class="admin-table">
+                    <thead>
+                        <tr>
+                            <th>URL</th>
+                            <th>Events</th>
+                            <th>Status</th>
+                            <th>Actions</th>
+                        </tr>
+                    </thead>
+                    <tbody>
+                        ${this.webhooks.map(hook => `
+                            <tr>
+                                <td>${Utils.escapeHtml(hook.url)}${hook.description ? '<br><small>' + Utils.escapeHtml(hook.description) + '</small>' : ''}</td>
+                                <td>${(hook.events || []).map(e => Utils.escapeHtml(e)).join(', ')}</td>
+                                <td>${hook.active ? 'Active' : 'Disabled'}</td>
+                                <td>
+                                    <button class="btn btn--secondary" onclick="adminPage.testWebhook(${hook.id})">Send test</button>
+                                    <button class="btn btn--secondary" onclick="adminPage.viewWebhookDeliveries(${hook.id})">Deliveries</button>
+                                    <button class="btn btn--secondary" onclick="adminPage.toggleWebhook(${hook.id}, ${!hook.active})">${hook.active ? 'Disable' : 'Enable'}</button>
+                                    <button class="btn btn--secondary" onclick="adminPage.deleteWebhook(${hook.id})">Delete</button>
+                                </td>
+                            </tr>
+                        `).join('')}
+                    </tbody>
+                </table>
+            `;
+        } catch (error) {
+            console.error('Failed to load webhooks:', error);
+            Utils.showToast('Failed to load webhooks', 'error');
+        }
+    }
+
+    async handleCreateWebhook(e) {
+        e.preventDefault();
+        const form = e.target;
+        const events = Array.from(form.querySelectorAll('input[name="events"]:checked')).map(el => el.value);
+        try {
+            const response = await ExunServices.api.apiRequest('/admin/webhooks', {
+                method: 'POST',
+                body: JSON.stringify({
+                    url: form.url.value,
+                    description: form.description.value,
+                    events: events
+                })
+            });
+            const hook = response && response.data;
+            form.reset();
+            this.showWebhookSecret(hook);
+            await this.loadWebhooks();
+        } catch (error) {
+            console.error('Failed to create webhook:', error);
+            Utils.showToast('Failed to create webhook', 'error');
+        }
+    }
+
+    showWebhookSecret(hook) {
+        if (!hook || !hook.secret) return;
+        const modal = document.getElementById('admin-modal');
+        const modalContent = document.getElementById('modal-content');
+        modalContent.innerHTML = `
+            <div class="admin-modal__header">
+                <h3 class="admin-modal__title">Webhook created</h3>
+                <button id="modal-close" class="admin-modal__close">&times;</button>
+            </div>
+            <p>Share this signing secret with the receiving team. Each request carries
+            <code>X-Exun-Timestamp</code> and <code>X-Exun-Signature: sha256=HMAC(secret, timestamp + "." + body)</code>.</p>
+            <pre style="user-select:all">${Utils.escapeHtml(hook.secret)}</pre>
+        `;
+        modal.classList.add('admin-modal--open');
+        document.getElementById('modal-close').addEventListener('click', () => this.closeModal());
+    }
+
+    async testWebhook(id) {
+        try {
+            const response = await ExunServices.api.apiRequest(`/admin/webhooks/${id}/test`, { method: 'POST' });
+            const delivery = (response && response.data) || {};
+            if (response && response.success) {
+                Utils.showToast('Test delivered (HTTP ' + delivery.response_status + ')');
+            } else {
+                Utils.showToast('Test failed: ' + (delivery.error || 'unknown error'), 'error');
+            }
+        } catch (error) {
+            console.error('Webhook test failed:', error);
+            Utils.showToast('Webhook test failed', 'error');
+        }
+    }
+
+    async toggleWebhook(id, active) {
+        try {
+            await ExunServices.api.apiRequest(`/admin/webhooks/${id}`, {
+                method: 'PUT',
+                body: JSON.stringify({ active: active })
+            });
+            await this.loadWebhooks();
+        } catch (error) {
+            console.error('Failed to update webhook:', error);
+            Utils.showToast('Failed to update webhook', 'error');
+        }
+    }
+
+    async deleteWebhook(id) {
+        if (!confirm('Delete this webhook and its delivery log?')) return;
+        try {
+            await ExunServices.api.apiRequest(`/admin/webhooks/${id}`, { method: 'DELETE' });
+            await this.loadWebhooks();
+        } catch (error) {
+            console.error('Failed to delete webhook:', error);
+            Utils.showToast('Failed to delete webhook', 'error');
+        }
+    }
+
+    async viewWebhookDeliveries(id) {
+        try {
+            const response = await ExunServices.api.apiRequest(`/admin/webhooks/${id}/deliveries?limit=50`);
+            const deliveries = (response && response.data) || [];
+            const modal = document.getElementById('admin-modal');
+            const modalContent = document.getElementById('modal-content');
+            modalContent.innerHTML = `
+                <div class="admin-modal__header">
+                    <h3 class="admin-modal__title">Recent deliveries</h3>
+                    <button id="modal-close" class="admin-modal__close">&times;</button>
+                </div>
+                <table class="admin-table">
+                    <thead>
+                        <tr>
+                            <th>Event</th>
+                            <th>Status</th>
+                            <th>Attempts</th>
+                            <th>Response</th>
+                            <th>Created</th>
+                            <th></th>
+                        </tr>
+                    </thead>
+                    <tbody>
+                        ${deliveries.map(d => `
+                            <tr>
+                                <td>${Utils.escapeHtml(d.event_type)}</td>
+                                <td>${Utils.escapeHtml(d.status)}</td>
+                                <td>${d.attempts}</td>
+                                <td>${d.response_status || ''} ${Utils.escapeHtml(d.error || '')}</td>
+                                <td>${Utils.formatDate(new Date(d.created_at))}</td>
+                                <td>${d.status === 'failed' ? `<button class="btn btn--secondary" onclick="adminPage.retryWebhookDelivery(${d.id}, ${id})">Retry</button>` : ''}</td>
+                            </tr>
+                        `).join('')}
+                    </tbody>
+                </table>
+            `;
+            modal.classList.add('admin-modal--open');
+            document.getElementById('modal-close').addEventListener('click', () => this.closeModal());
+        } catch (error) {
+            console.error('Failed to load deliveries:', error);
+            Utils.showToast('Failed to load deliveries', 'error');
+        }
+    }
+
+    async retryWebhookDelivery(deliveryId, webhookId) {
+        try {
+            await ExunServices.api.apiRequest(`/admin/webhooks/deliveries/${deliveryId}/retry`, { method: 'POST' });
+            Utils.showToast('Delivery queued for retry');
+            await this.viewWebhookDeliveries(webhookId);
+        } catch (error) {
+            console.error('Failed to retry delivery:', error);
+            Utils.showToast('Failed to retry delivery', 'error');
+        }
+    }
+
+    async renderAudit() {
+        const content = document.getElementById('admin-content');
+        content.innerHTML = `
+            <div class="admin-audit">
+                <div class="flex justify-between items-center mb-6">
+                    <h3 class="text-xl font-semibold">Audit log</h3>
+                    <div>
+                        <button class="btn btn--secondary" id="audit-verify">Verify chain</button>
+                        <button class="btn btn--secondary" id="audit-export-csv">Export CSV</button>
+                        <button class="btn btn--secondary" id="audit-export-json">Export JSON</button>
+                    </div>
+                </div>
+                <form class="admin-form" id="audit-filter-form">
+                    <div class="admin-form__row">
+                        <div class="admin-form__group">
+                            <label class="admin-form__label">Actor</label>
+                            <input type="text" name="actor" class="admin-form__input" placeholder="admin@example.com">
+                        </div>
+                        <div class="admin-form__group">
+                            <label class="admin-form__label">Action</label>
+                            <input type="text" name="action" class="admin-form__input" placeholder="event.* or user.create">
+                        </div>
+                        <div class="admin-form__group">
+                            <label class="admin-form__label">Entity</label>
+                            <input type="text" name="entity" class="admin-form__input" placeholder="events">
+                        </div>
+                        <div class="admin-form__group">
+                            <label class="admin-form__label">Entity ID</label>
+                            <input type="text" name="entity_id" class="admin-form__input">
+                        </div>
+                    </div>
+                    <div class="admin-form__row">
+                        <div class="admin-form__group">
+                            <label class="admin-form__label">Since</label>
+                            <input type="date" name="since" class="admin-form__input">
+                        </div>
+                        <div class="admin-form__group">
+                            <label class="admin-form__label">Until</label>
+                            <input type="date" name="until" class="admin-form__input">
+                        </div>
+                    </div>
+                    <div class="admin-actions">
+                        <button type="submit" class="btn btn--primary">Filter</button>
+                    </div>
+                </form>
+                <div id="audit-table-container">
+                    <div class="loading-placeholder">Loading audit log...</div>
+                </div>
+            </div>
+        `;
+
+        document.getElementById('audit-filter-form').addEventListener('submit', (e) => {
+            e.preventDefault();
+            this.loadAudit(1);
+        });
+        document.getElementById('audit-verify').addEventListener('click', () => this.verifyAudit());
+        document.getElementById('audit-export-csv').addEventListener('click', () => this.exportAudit('csv'));
+        document.getElementById('audit-export-json').addEventListener('click', () => this.exportAudit('json'));
+        await this.loadAudit(1);
+    }
+
+    auditQuery() {
+        const form = document.getElementById('audit-filter-form');
+        const params = new URLSearchParams();
+        if (!form) return params;
+        ['actor', 'action', 'entity', 'entity_id', 'since', 'until'].forEach(name => {
+            const value = form[name].value.trim();
+            if (value) params.set(name, value);
+        });
+        return params;
+    }
+
+    async loadAudit(page) {
+        const params = this.auditQuery();
+        params.set('page', page);
+        params.set('per_page', 50);
+        try {
+            const response = await ExunServices.api.apiRequest('/admin/audit?' + params.toString());
+            const events = (response && response.data) || [];
+            const total = (response && response.total) || 0;
+            const pages = Math.max(1, Math.ceil(total / 50));
+
+            const container = document.getElementById('audit-table-container');
+            if (!container) return;
+            if (events.length === 0) {
+                container.innerHTML = '<p>No audit events match these filters.</p>';
+                return;
+            }
+            container.innerHTML = `
+                <table class="admin-table">
+                    <thead>
+                        <tr>
+                            <th>Time</th>
+                            <th>Actor</th>
+                            <th>Action</th>
+                            <th>Entity</th>
+                            <th>Changes</th>
+                            <th>IP / Request</th>
+                        </tr>
+                    </thead>
+                    <tbody>
+                        ${events.map(e => `
+                            <tr>
+                                <td>${new Date(e.created_at).toLocaleString()}</td>
+                                <td>${Utils.escapeHtml(e.actor)}</td>
+                                <td>${Utils.escapeHtml(e.action)}</td>
+                                <td>${Utils.escapeHtml(e.entity)}${e.entity_id ? '<br><small>' + Utils.escapeHtml(e.entity_id) + '</small>' : ''}</td>
+                                <td>${e.diff ? '<pre style="max-width:28rem;max-height:10rem;overflow:auto;white-space:pre-wrap">' + Utils.escapeHtml(JSON.stringify(e.diff, null, 2)) + '</pre>' : ''}</td>
+                                <td>${Utils.escapeHtml(e.ip || '')}<br><small>${Utils.escapeHtml(e.request_id || '')}</small></td>
+                            </tr>
+                        `).join('')}
+                    </tbody>
+                </table>
+                <div class="admin-actions">
+                    <button class="btn btn--secondary" ${page <= 1 ? 'disabled' : ''} onclick="adminPage.loadAudit(${page - 1})">Previous</button>
+                    <span>Page ${page} of ${pages} (${total} events)</span>
+                    <button class="btn btn--secondary" ${page >= pages ? 'disabled' : ''} onclick="adminPage.loadAudit(${page + 1})">Next</button>
+                </div>
+            `;
+        } catch (error) {
+            console.error('Failed to load audit log:', error);
+            Utils.showToast('Failed to load audit log', 'error');
+        }
+    }
+
+    exportAudit(format) {
+        const params = this.auditQuery();
+        params.set('format', format);
+        window.location.href = '/api/admin/audit/export?' + params.toString();
+    }
+
+    async verifyAudit() {
+        try {
+            const response = await ExunServices.api.apiRequest('/admin/audit/verify');
+            const result = (response && response.data) || {};
+            if (result.valid) {
+                Utils.showToast(`Audit chain intact (${result.checked} entries)`);
+            } else {
+                Utils.showToast(`Audit chain broken at entry ${result.broken_at}: ${result.reason}`, 'error');
+            }
+        } catch (error) {
+            console.error('Failed to verify audit log:', error);
+            Utils.showToast('Failed to verify audit log', 'error');
+        }
+    }
+
+    async renderQueryReviews() {
+        const content = document.getElementById('admin-content');
+        content.innerHTML = `
+            <div class="admin-queries">
+                <div class="flex justify-between items-center mb-6">
+                    <h3 class="text-xl font-semibold">Query review</h3>
+                    <div>
+                        <button class="btn btn--secondary" id="query-blocked">Blocked clients</button>
+                    </div>
+                </div>
+                <form class="admin-form" id="query-filter-form">
+                    <div class="admin-form__row">
+                        <div class="admin-form__group">
+                            <label class="admin-form__label">Search</label>
+                            <input type="text" name="q" class="admin-form__input" placeholder="Text in the query or answer">
+                        </div>
+                        <div class="admin-form__group">
+                            <label class="admin-form__label">Status</label>
+                            <select name="status" class="admin-form__input">
+                                <option value="">Any</option>
+                                <option value="rejected">Rejected</option>
+                                <option value="ok">Answered</option>
+                                <option value="faq">FAQ answer</option>
+                                <option value="no_context">No context</option>
+                                <option value="llm_error">Model error</option>
+                                <option value="length_or_commas">Too long</option>
+                            </select>
+                        </div>
+                        <div class="admin-form__group">
+                            <label class="admin-form__label">Rule</label>
+                            <input type="text" name="rule" class="admin-form__input" placeholder="jailbreak">
+                        </div>
+                        <div class="admin-form__group">
+                            <label class="admin-form__label">Review</label>
+                            <select name="review" class="admin-form__input">
+                                <option value="pending">Awaiting review</option>
+                                <option value="reviewed">Reviewed</option>
+                                <option value="false_positive">False positives</option>
+                                <option value="dismissed">Dismissed</option>
+                                <option value="promoted">Promoted to FAQ</option>
+                                <option value="blocked">Blocked</option>
+                                <option value="any">Any</option>
+                            </select>
+                        </div>
+                    </div>
+                    <div class="admin-form__row">
+                        <label class="admin-form__label">
+                            <input type="checkbox" name="all" value="1">
+                            Include queries that were not flagged or rejected
+                        </label>
+                    </div>
+                    <div class="admin-actions">
+                        <button type="submit" class="btn btn--primary">Filter</button>
+                    </div>
+                </form>
+                <div id="query-table-container">
+                    <div class="loading-placeholder">Loading queries...</div>
+                </div>
+            </div>
+        `;
+
+        document.getElementById('query-filter-form').addEventListener('submit', (e) => {
+            e.preventDefault();
+            this.loadQueryReviews(1);
+        });
+        document.getElementById('query-blocked').addEventListener('click', () => this.showBlockedClients());
+        await this.loadQueryReviews(1);
+    }
+
+    async loadQueryReviews(page) {
+        const form = document.getElementById('query-filter-form');
+        const params = new URLSearchParams();
+        if (form) {
+            ['q', 'status', 'rule', 'review'].forEach(name => {
+                const value = form[name].value.trim();
+                if (value) params.set(name, value);
+            });
+            if (form.all.checked) params.set('all', '1');
+        }
+        params.set('page', page);
+        params.set('per_page', 50);
+        this.queryReviewPage = page;
+        try {
+            const response = await ExunServices.api.apiRequest('/admin/query-reviews?' + params.toString());
+            const entries = (response && response.data) || [];
+            const total = (response && response.total) || 0;
+            const pages = Math.max(1, Math.ceil(total / 50));
+            this.queryReviews = {};
+            entries.forEach(e => { this.queryReviews[e.id] = e; });
+
+            const container = document.getElementById('query-table-container');
+            if (!container) return;
+            if (entries.length === 0) {
+                container.innerHTML = '<p>No queries match these filters.</p>';
+                return;
+            }
+            container.innerHTML = `
+                <table class="admin-table">
+                    <thead>
+                        <tr>
+                            <th>Time</th>
+                            <th>Query</th>
+                            <th>Answer</th>
+                            <th>Status / rules</th>
+                            <th>Review</th>
+                            <th>Actions</th>
+                        </tr>
+                    </thead>
+                    <tbody>
+                        ${entries.map(e => `
+                            <tr>
+                                <td>${new Date(e.created_at).toLocaleString()}</td>
+                                <td style="max-width:20rem;white-space:pre-wrap">${Utils.escapeHtml(e.query)}</td>
+                                <td style="max-width:24rem;white-space:pre-wrap">${Utils.escapeHtml(e.answer)}</td>
+                                <td>${Utils.escapeHtml(e.status)}${e.rule ? '<br><strong>' + Utils.escapeHtml(e.rule) + '</strong>' + (e.scope ? ' (' + Utils.escapeHtml(e.scope) + ')' : '') : ''}${e.rules.length ? '<br><small>' + Utils.escapeHtml(e.rules.join(', ')) + '</small>' : ''}</td>
+                                <td>${e.review ? Utils.escapeHtml(e.review.state.replace('_', ' ')) + '<br><small>' + Utils.escapeHtml(e.review.reviewed_by) + '</small>' + (e.review.note ? '<br><small>' + Utils.escapeHtml(e.review.note) + '</small>' : '') : 'Pending'}</td>
+                                <td>
+                                    ${e.review ? `<button class="btn btn--secondary" onclick="adminPage.reviewQuery(${e.id}, 'pending')">Reopen</button>` : `
+                                    <button class="btn btn--secondary" onclick="adminPage.reviewQuery(${e.id}, 'false_positive')">False positive</button>
+                                    <button class="btn btn--secondary" onclick="adminPage.reviewQuery(${e.id}, 'dismissed')">Dismiss</button>`}
+                                    <button class="btn btn--secondary" onclick="adminPage.showPromoteQuery(${e.id})">Add to FAQ</button>
+                                    ${e.clients.length ? `<button class="btn btn--secondary" onclick="adminPage.showBlockQuery(${e.id})">Block</button>` : ''}
+                                </td>
+                            </tr>
+                        `).join('')}
+                    </tbody>
+                </table>
+                <div class="admin-actions">
+                    <button class="btn btn--secondary" ${page <= 1 ? 'disabled' : ''} onclick="adminPage.loadQueryReviews(${page - 1})">Previous</button>
+                    <span>Page ${page} of ${pages} (${total} queries)</span>
+                    <button class="btn btn--secondary" ${page >= pages ? 'disabled' : ''} onclick="adminPage.loadQueryReviews(${page + 1})">Next</button>
+                </div>
+            `;
+        } catch (error) {
+            console.error('Failed to load query review queue:', error);
+            Utils.showToast('Failed to load query review queue', 'error');
+        }
+    }
+
+    async queryReviewAction(id, path, body, message) {
+        try {
+            await ExunServices.api.apiRequest('/admin/query-reviews/' + id + path, {
+                method: 'POST',
+                body: JSON.stringify(body)
+            });
+            Utils.showToast(message);
+            this.closeModal();
+            await this.loadQueryReviews(this.queryReviewPage || 1);
+            await this.loadData();
+        } catch (error) {
+            console.error('Query review action failed:', error);
+            Utils.showToast(error.message || 'Action failed', 'error');
+        }
+    }
+
+    reviewQuery(id, state) {
+        const messages = { pending: 'Returned to the queue', false_positive: 'Marked as a false positive', dismissed: 'Dismissed' };
+        this.queryReviewAction(id, '', { state }, messages[state]);
+    }
+
+    showPromoteQuery(id) {
+        const entry = (this.queryReviews || {})[id];
+        if (!entry) return;
+        const modal = document.getElementById('admin-modal');
+        const modalContent = document.getElementById('modal-content');
+        modalContent.innerHTML = `
+            <div class="admin-modal__header">
+                <h3 class="admin-modal__title">Add to FAQ</h3>
+                <button id="modal-close" class="admin-modal__close">&times;</button>
+            </div>
+            <form class="admin-form" id="promote-query-form">
+                <div class="admin-form__group">
+                    <label class="admin-form__label">Question</label>
+                    <input type="text" name="question" class="admin-form__input" required value="${Utils.escapeHtml(entry.query)}">
+                </div>
+                <div class="admin-form__group">
+                    <label class="admin-form__label">Answer</label>
+                    <textarea name="answer" class="admin-form__input" rows="6" required>${Utils.escapeHtml(entry.status === 'ok' || entry.status === 'faq' ? entry.answer : '')}</textarea>
+                </div>
+                <div class="admin-actions">
+                    <button type="button" class="btn btn--secondary" onclick="adminPage.closeModal()">Cancel</button>
+                    <button type="submit" class="btn btn--primary">Add to FAQ</button>
+                </div>
+            </form>
+        `;
+        modal.classList.add('admin-modal--open');
+        document.getElementById('modal-close').addEventListener('click', () => this.closeModal());
+        document.getElementById('promote-query-form').addEventListener('submit', (e) => {
+            e.preventDefault();
+            const form = e.target;
+            this.queryReviewAction(id, '/promote', { question: form.question.value, answer: form.answer.value }, 'Added to the FAQ');
+        });
+    }
+
+    showBlockQuery(id) {
+        const entry = (this.queryReviews || {})[id];
+        if (!entry) return;
+        const hasOther = entry.clients.some(c => !c.startsWith('ip:'));
+        const modal = document.getElementById('admin-modal');
+        const modalContent = document.getElementById('modal-content');
+        modalContent.innerHTML = `
+            <div class="admin-modal__header">
+                <h3 class="admin-modal__title">Block client</h3>
+                <button id="modal-close" class="admin-modal__close">&times;</button>
+            </div>
+            <form class="admin-form" id="block-query-form">
+                <p>Blocked clients can no longer use the query bot.</p>
+                ${entry.clients.map(c => `
+                    <label class="admin-form__label">
+                        <input type="checkbox" name="client" value="${Utils.escapeHtml(c)}" ${!hasOther || !c.startsWith('ip:') ? 'checked' : ''}>
+                        ${Utils.escapeHtml(c)}${c.startsWith('ip:') ? ' <small>(may be shared by a whole school)</small>' : ''}
+                    </label>
+                `).join('')}
+                <div class="admin-form__group">
+                    <label class="admin-form__label">Reason</label>
+                    <input type="text" name="reason" class="admin-form__input">
+                </div>
+                <div class="admin-actions">
+                    <button type="button" class="btn btn--secondary" onclick="adminPage.closeModal()">Cancel</button>
+                    <button type="submit" class="btn btn--primary">Block</button>
+                </div>
+            </form>
+        `;
+        modal.classList.add('admin-modal--open');
+        document.getElementById('modal-close').addEventListener('click', () => this.closeModal());
+        document.getElementById('block-query-form').addEventListener('submit', (e) => {
+            e.preventDefault();
+            const form = e.target;
+            const clients = Array.from(form.querySelectorAll('input[name="client"]:checked')).map(i => i.value);
+            if (clients.length === 0) {
+                Utils.showToast('Choose at least one client to block', 'error');
+                return;
+            }
+            this.queryReviewAction(id, '/block', { clients, reason: form.reason.value }, 'Client blocked');
+        });
+    }
+
+    async showBlockedClients() {
+        try {
+            const response = await ExunServices.api.apiRequest('/admin/query-reviews/blocked');
+            const clients = (response && response.data) || [];
+            const modal = document.getElementById('admin-modal');
+            const modalContent = document.getElementById('modal-content');
+            modalContent.innerHTML = `
+                <div class="admin-modal__header">
+                    <h3 class="admin-modal__title">Blocked clients</h3>
+                    <button id="modal-close" class="admin-modal__close">&times;</button>
+                </div>
+                ${clients.length === 0 ? '<p>No clients are blocked.</p>' : `
+                <table class="admin-table">
+                    <thead>
+                        <tr><th>Client</th><th>Blocked by</th><th>Reason</th><th></th></tr>
+                    </thead>
+                    <tbody>
+                        ${clients.map(c => `
+                            <tr>
+                                <td>${Utils.escapeHtml(c.client)}</td>
+                                <td>${Utils.escapeHtml(c.blocked_by)}<br><small>${new Date(c.blocked_at).toLocaleString()}</small></td>
+                                <td>${Utils.escapeHtml(c.reason || '')}</td>
+                                <td><button class="btn btn--secondary" data-client="${Utils.escapeHtml(c.client)}">Unblock</button></td>
+                            </tr>
+                        `).join('')}
+                    </tbody>
+                </table>`}
+            `;
+            modal.classList.add('admin-modal--open');
+            document.getElementById('modal-close').addEventListener('click', () => this.closeModal());
+        document.getElementById('modal-close').addEventListener('click', () => this.closeModal());
+            modalContent.querySelectorAll('button[data-client]').forEach(btn => {
+                btn.addEventListener('click', () => this.unblockClient(btn.dataset.client));
+            });
+        } catch (error) {
+            console.error('Failed to load blocked clients:', error);
+            Utils.showToast('Failed to load blocked clients', 'error');
+        }
+    }
+
+    async unblockClient(client) {
+        try {
+            await ExunServices.api.apiRequest('/admin/query-reviews/blocked?client=' + encodeURIComponent(client), { method: 'DELETE' });
+            Utils.showToast('Client unblocked');
+            await this.showBlockedClients();
+            await this.loadData();
+        } catch (error) {
+            console.error('Failed to unblock client:', error);
+            Utils.showToast('Failed to unblock client', 'error');
+        }
+    }
+
     showCreateEventModal() {
+        this.showEventModal(null);
+    }
+
+    async editEvent(eventId) {
+        try {
+            const event = await ExunServices.api.apiRequest(`/admin/events/${encodeURIComponent(eventId)}`);
+            this.showEventModal(event);
+        } catch (error) {
+            console.error('Failed to load event:', error);
+            Utils.showToast('Failed to load event', 'error');
+        }
+    }
+
+    async showEventModal(event) {
+        if (!this.eventCategories) {
+            try {
+                const response = await ExunServices.api.apiRequest('/event_categories');
+                this.eventCategories = (response && response.data) || [];
+            } catch (error) {
+                this.eventCategories = [];
+            }
+        }
+        const ev = event || {};
+        const editing = !!event;
+        const range = String(ev.eligibility || '').match(/(\d{1,2}).*?(\d{1,2})/);
+        const descriptions = ev.descriptions || {};
         const modal = document.getElementById('admin-modal');
         const modalContent = document.getElementById('modal-content');
         
         modalContent.innerHTML = `
             <div class="admin-modal__header">
-                <h3 class="admin-modal__title">Create New Event</h3>
+                <h3 class="admin-modal__title">${editing ? 'Edit Event' : 'Create New Event'}</h3>
                 <button id="modal-close" class="admin-modal__close">&times;</button>
             </div>
-            <form class="admin-form" id="create-event-form">
+            <form class="admin-form" id="event-form">
                 <div class="admin-form__row">
                     <div class="admin-form__group">
                         <label class="admin-form__label">Event Name</label>
-                        <input type="text" name="name" class="admin-form__input" required>
+                        <input type="text" name="name" class="admin-form__input" value="${Utils.escapeHtml(ev.name || '')}" required>
+                    </div>
+                    <div class="admin-form__group">
+                        <label class="admin-form__label">Slug</label>
+                        <input type="text" name="slug" class="admin-form__input" value="${Utils.escapeHtml(ev.id || '')}" placeholder="Generated from the name">
+                    </div>
+                </div>
+                <div class="admin-form__row">
+                    <div class="admin-form__group">
+                        <label class="admin-form__label">Category</label>
+                        <select name="category" class="admin-form__select">
+                            <option value="">None</option>
+                            ${this.eventCategories.map(c => `<option value="${Utils.escapeHtml(c.key)}" ${c.key === ev.category ? 'selected' : ''}>${Utils.escapeHtml(c.name)}</option>`).join('')}
+                        </select>
                     </div>
                     <div class="admin-form__group">
                         <label class="admin-form__label">Mode</label>
                         <select name="mode" class="admin-form__select" required>
-                            <option value="">Select mode</option>
-                            <option value="online">Online</option>
-                            <option value="offline">Offline</option>
-                            <option value="hybrid">Hybrid</option>
+                            ${['online', 'offline', 'hybrid'].map(m => `<option value="${m}" ${String(ev.mode || '').toLowerCase() === m ? 'selected' : ''}>${m.charAt(0).toUpperCase() + m.slice(1)}</option>`).join('')}
                         </select>
                     </div>
                 </div>
                 <div class="admin-form__row">
                     <div class="admin-form__group">
                         <label class="admin-form__label">Max Participants per Team</label>
-                        <input type="number" name="participants" class="admin-form__input" min="1" required>
+                        <input type="number" name="participants" class="admin-form__input" min="1" max="8" value="${ev.participants || 1}" required>
+                    </div>
+                    <div class="admin-form__group">
+                        <label class="admin-form__label">Teams per School</label>
+                        <input type="number" name="teamsPerSchool" class="admin-form__input" min="1" max="10" value="${ev.teams_per_school || 1}" required>
                     </div>
                     <div class="admin-form__group">
                         <label class="admin-form__label">Eligibility (Class Range)</label>
                         <div class="flex gap-2">
-                            <input type="number" name="minClass" class="admin-form__input" placeholder="Min" min="6" max="12">
-                            <input type="number" name="maxClass" class="admin-form__input" placeholder="Max" min="6" max="12">
+                            <input type="number" name="minClass" class="admin-form__input" placeholder="Min" min="1" max="12" value="${range ? range[1] : ''}">
+                            <input type="number" name="maxClass" class="admin-form__input" placeholder="Max" min="1" max="12" value="${range ? range[2] : ''}">
                         </div>
+                        <label><input type="checkbox" name="openToAll" ${ev.open_to_all ? 'checked' : ''}> Open to all</label>
+                    </div>
+                </div>
+                <div class="admin-form__row">
+                    <div class="admin-form__group">
+                        <label class="admin-form__label">Points</label>
+                        <input type="number" name="points" class="admin-form__input" min="0" value="${ev.points || 0}">
+                    </div>
+                    <div class="admin-form__group">
+                        <label class="admin-form__label">Dates</label>
+                        <input type="text" name="dates" class="admin-form__input" value="${Utils.escapeHtml(ev.dates || '')}">
+                    </div>
+                </div>
+                <div class="admin-form__row">
+                    <div class="admin-form__group">
+                        <label class="admin-form__label">Image (SVG or PNG)</label>
+                        <input type="hidden" name="image" value="${Utils.escapeHtml(ev.image || '')}">
+                        <input type="file" name="imageFile" accept=".svg,.png,image/svg+xml,image/png" class="admin-form__input">
+                        <small id="event-image-name">${Utils.escapeHtml(ev.image || 'No image')}</small>
+                    </div>
+                    <div class="admin-form__group">
+                        <label><input type="checkbox" name="independentRegistration" ${ev.independent_registration === false ? '' : 'checked'}> Individuals may register</label>
                     </div>
                 </div>
                 <div class="admin-form__group">
-                    <label class="admin-form__label">Description</label>
-                    <textarea name="description" class="admin-form__textarea" required></textarea>
+                    <label class="admin-form__label">Short Description</label>
+                    <textarea name="descriptionShort" class="admin-form__textarea">${Utils.escapeHtml(descriptions.short || '')}</textarea>
+                </div>
+                <div class="admin-form__group">
+                    <label class="admin-form__label">Long Description</label>
+                    <textarea name="descriptionLong" class="admin-form__textarea">${Utils.escapeHtml(descriptions.long || '')}</textarea>
                 </div>
                 <div class="admin-actions">
-                    <button type="submit" class="btn btn--primary">Create Event</button>
+                    <button type="submit" class="btn btn--primary">${editing ? 'Save Event' : 'Create Event'}</button>
                     <button type="button" class="btn btn--secondary" id="cancel-create">Cancel</button>
                 </div>
             </form>
         `;
         
         modal.classList.add('admin-modal--open');
-        this.setupModalEventListeners();
+        document.getElementById('modal-close').addEventListener('click', () => this.closeModal());
         
-        const form = document.getElementById('create-event-form');
-        form.addEventListener('submit', (e) => this.handleCreateEvent(e));
+        const form = document.getElementById('event-form');
+        form.imageFile.addEventListener('change', () => this.uploadEventImage(form));
+        form.addEventListener('submit', (e) => this.handleSaveEvent(e, editing ? ev.id : null));
         
         const cancelBtn = document.getElementById('cancel-create');
         cancelBtn.addEventListener('click', () => this.closeModal());
     }
 
+    async uploadEventImage(form) {
+        const file = form.imageFile.files[0];
+        if (!file) return;
+        const body = new FormData();
+        body.append('image', file);
+        try {
+            const response = await fetch('/api/admin/events/image', { method: 'POST', body: body, credentials: 'include' });
+            if (!response.ok) {
+                throw new Error(await response.text());
+            }
+            const result = await response.json();
+            form.image.value = result.image;
+            document.getElementById('event-image-name').textContent = result.image;
+            Utils.showToast('Image uploaded');
+        } catch (error) {
+            console.error('Failed to upload image:', error);
+            Utils.showToast('Failed to upload image: ' + error.message, 'error');
+        }
+    }
+
+    async handleSaveEvent(e, eventId) {
+        e.preventDefault();
+        const form = e.target;
+        const payload = {
+            name: form.name.value.trim(),
+            category: form.category.value,
+            mode: form.mode.value,
+            participants: parseInt(form.participants.value, 10),
+            teams_per_school: parseInt(form.teamsPerSchool.value, 10) || 1,
+            open_to_all: form.openToAll.checked,
+            independent_registration: form.independentRegistration.checked,
+            points: parseInt(form.points.value, 10) || 0,
+            dates: form.dates.value.trim(),
+            image: form.image.value,
+            description_short: form.descriptionShort.value.trim(),
+            description_long: form.descriptionLong.value.trim()
+        };
+        if (form.slug.value.trim()) payload.slug = form.slug.value.trim();
+        if (form.minClass.value) payload.min_class = parseInt(form.minClass.value, 10);
+        if (form.maxClass.value) payload.max_class = parseInt(form.maxClass.value, 10);
+
+        try {
+            if (eventId) {
+                payload.event_id = eventId;
+                await ExunServices.admin.updateEvent(payload);
+            } else {
+                await ExunServices.api.apiRequest('/admin/events/create', { method: 'POST', body: JSON.stringify(payload) });
+            }
+            this.closeModal();
+            Utils.showToast(eventId ? 'Event saved' : 'Event created');
+            await this.renderEvents();
+        } catch (error) {
+            console.error('Failed to save event:', error);
+            Utils.showToast('Failed to save event: ' + error.message, 'error');
+        }
+    }
+
+    async moveEvent(eventId, delta) {
+        const order = this.events.map(e => e.id);
+        const i = order.indexOf(eventId);
+        const j = i + delta;
+        if (i < 0 || j < 0 || j >= order.length) return;
+        [order[i], order[j]] = [order[j], order[i]];
+        try {
+            await ExunServices.api.apiRequest('/admin/events/order', { method: 'POST', body: JSON.stringify({ order: order }) });
+            await this.renderEvents();
+        } catch (error) {
+            console.error('Failed to reorder events:', error);
+            Utils.showToast('Failed to reorder events', 'error');
+        }
+    }
+
+    async archiveEvent(eventId, archived) {
+        try {
+            await ExunServices.api.apiRequest(`/admin/events/archive/${encodeURIComponent(eventId)}`, {
+                method: 'POST',
+                body: JSON.stringify({ archived: archived })
+            });
+            Utils.showToast(archived ? 'Event archived' : 'Event restored');
+            await this.renderEvents();
+        } catch (error) {
+            console.error('Failed to archive event:', error);
+            Utils.showToast('Failed to archive event', 'error');
+        }
+    }
+
+    async showDeleteEventModal(eventId) {
+        try {
+            const preview = await ExunServices.api.apiRequest(`/admin/events/delete/${encodeURIComponent(eventId)}`);
+            const impact = (preview && preview.data) || {};
+            const event = (preview && preview.event) || {};
+            const modal = document.getElementById('admin-modal');
+            const modalContent = document.getElementById('modal-content');
+            modalContent.innerHTML = `
+                <div class="admin-modal__header">
+                    <h3 class="admin-modal__title">Delete ${Utils.escapeHtml(event.name || eventId)}</h3>
+                    <button id="modal-close" class="admin-modal__close">&times;</button>
+                </div>
+                <p>This permanently removes the event along with
+                <strong>${impact.teams || 0}</strong> team(s) and
+                <strong>${impact.participants || 0}</strong> participant(s).
+                A snapshot is taken first. Consider archiving instead.</p>
+                ${(impact.team_list || []).length ? `
+                    <table class="admin-table">
+                        <thead><tr><th>Account</th><th>Institution</th><th>Participants</th></tr></thead>
+                        <tbody>
+                            ${impact.team_list.map(t => `
+                                <tr>
+                                    <td>${Utils.escapeHtml(t.email)}</td>
+                                    <td>${Utils.escapeHtml(t.institution || '')}</td>
+                                    <td>${t.participants}</td>
+                                </tr>
+                            `).join('')}
+                        </tbody>
+                    </table>
+                ` : ''}
+                <form class="admin-form" id="delete-event-form">
+                    <div class="admin-form__group">
+                        <label class="admin-form__label">Type <code>${Utils.escapeHtml(preview.confirmation)}</code> to confirm</label>
+                        <input type="text" name="confirm" class="admin-form__input" autocomplete="off" required>
+                    </div>
+                    <div class="admin-actions">
+                        <button type="submit" class="btn btn--primary">Delete event</button>
+                    </div>
+                </form>
+            `;
+            modal.classList.add('admin-modal--open');
+            document.getElementById('modal-close').addEventListener('click', () => this.closeModal());
+            document.getElementById('delete-event-form').addEventListener('submit', (e) => this.handleDeleteEvent(e, eventId));
+        } catch (error) {
+            console.error('Failed to load deletion preview:', error);
+            Utils.showToast('Failed to load deletion preview', 'error');
+        }
+    }
+
+    async handleDeleteEvent(e, eventId) {
+        e.preventDefault();
+        try {
+            const response = await ExunServices.api.apiRequest(`/admin/events/delete/${encodeURIComponent(eventId)}`, {
+                method: 'POST',
+                body: JSON.stringify({ confirm: e.target.confirm.value.trim() })
+            });
+            this.closeModal();
+            Utils.showToast('Event deleted (snapshot ' + (response && response.snapshot) + ')');
+            await this.renderEvents();
+        } catch (error) {
+            console.error('Failed to delete event:', error);
+            Utils.showToast('Failed to delete event', 'error');
+        }
+    }
+
+    async showEligibilityModal(eventId) {
+        try {
+            const response = await ExunServices.api.apiRequest(`/admin/events/eligibility/${encodeURIComponent(eventId)}`);
+            const data = (response && response.data) || {};
+            const modal = document.getElementById('admin-modal');
+            const modalContent = document.getElementById('modal-content');
+            modalContent.innerHTML = `
+                <div class="admin-modal__header">
+                    <h3 class="admin-modal__title">Eligibility for ${Utils.escapeHtml(eventId)}</h3>
+                    <button id="modal-close" class="admin-modal__close">&times;</button>
+                </div>
+                <form class="admin-form" id="eligibility-form">
+                    <div class="admin-form__group">
+                        <label class="admin-form__label">Rules</label>
+                        <textarea name="rules" class="admin-form__input" rows="12" spellcheck="false">${Utils.escapeHtml(JSON.stringify(data.rules || {}, null, 2))}</textarea>
+                        <small>member: {classes: {min, max}, genders: [${(data.genders || []).join(', ')}]}; min_team_size;
+                        team: [{match: {...}, at_least, at_most, message}]</small>
+                    </div>
+                    <div class="admin-form__group">
+                        <label class="admin-form__label">Test team (one "class,gender" per line)</label>
+                        <textarea name="sample" class="admin-form__input" rows="4" placeholder="7,female"></textarea>
+                    </div>
+                    <p id="eligibility-description">${Utils.escapeHtml(data.description || '')}</p>
+                    <ul id="eligibility-problems"></ul>
+                    <div class="admin-actions">
+                        <button type="button" id="eligibility-check" class="btn btn--secondary">Check</button>
+                        <button type="submit" class="btn btn--primary">Save rules</button>
+                    </div>
+                </form>
+            `;
+            modal.classList.add('admin-modal--open');
+            document.getElementById('modal-close').addEventListener('click', () => this.closeModal());
+            const form = document.getElementById('eligibility-form');
+            document.getElementById('eligibility-check').addEventListener('click', () => this.saveEligibility(eventId, form, true));
+            form.addEventListener('submit', (e) => {
+                e.preventDefault();
+                this.saveEligibility(eventId, form, false);
+            });
+        } catch (error) {
+            console.error('Failed to load eligibility:', error);
+            Utils.showToast('Failed to load eligibility', 'error');
+        }
+    }
+
+    async saveEligibility(eventId, form, dryRun) {
+        let rules;
+        try {
+            rules = JSON.parse(form.rules.value || '{}');
+        } catch (err) {
+            Utils.showToast('Rules are not valid JSON', 'error');
+            return;
+        }
+        const participants = form.sample.value.split('\n').map(line => line.trim()).filter(Boolean).map(line => {
+            const [cls, gender] = line.split(',').map(v => v.trim());
+            return { class: parseInt(cls, 10) || 0, gender: (gender || '').toLowerCase() };
+        });
+        try {
+            const response = await ExunServices.api.apiRequest(`/admin/events/eligibility/${encodeURIComponent(eventId)}${dryRun ? '?dry_run=1' : ''}`, {
+                method: 'PUT',
+                body: JSON.stringify({ rules, participants })
+            });
+            const data = (response && response.data) || {};
+            document.getElementById('eligibility-description').textContent = data.description || '';
+            document.getElementById('eligibility-problems').innerHTML = (data.problems || []).map(p => `<li>${Utils.escapeHtml(p)}</li>`).join('');
+            if (participants.length && !(data.problems || []).length) {
+                Utils.showToast('Test team is eligible');
+            }
+            if (!dryRun) {
+                Utils.showToast('Eligibility saved');
+                await this.renderEvents();
+            }
+        } catch (error) {
+            console.error('Failed to save eligibility:', error);
+            Utils.showToast('Invalid rules: ' + error.message, 'error');
+        }
+    }
+
+    async showFormFieldsModal(eventId) {
+        try {
+            const response = await ExunServices.api.apiRequest(`/admin/events/fields/${encodeURIComponent(eventId)}`);
+            const modal = document.getElementById('admin-modal');
+            const modalContent = document.getElementById('modal-content');
+            modalContent.innerHTML = `
+                <div class="admin-modal__header">
+                    <h3 class="admin-modal__title">Registration fields for ${Utils.escapeHtml(eventId)}</h3>
+                    <button id="modal-close" class="admin-modal__close">&times;</button>
+                </div>
+                <form class="admin-form" id="form-fields-form">
+                    <div class="admin-form__group">
+                        <label class="admin-form__label">Fields</label>
+                        <textarea name="schema" class="admin-form__input" rows="14" spellcheck="false">${Utils.escapeHtml(JSON.stringify((response && response.data) || {}, null, 2))}</textarea>
+                        <small>team / participant: [{key, label, type: ${((response && response.types) || []).join(' | ')}, required, pattern, options, help}]</small>
+                    </div>
+                    <div class="admin-actions">
+                        <button type="submit" class="btn btn--primary">Save fields</button>
+                    </div>
+                </form>
+            `;
+            modal.classList.add('admin-modal--open');
+            document.getElementById('modal-close').addEventListener('click', () => this.closeModal());
+            const form = document.getElementById('form-fields-form');
+            form.addEventListener('submit', (e) => {
+                e.preventDefault();
+                this.saveFormFields(eventId, form);
+            });
+        } catch (error) {
+            console.error('Failed to load form fields:', error);
+            Utils.showToast('Failed to load form fields', 'error');
+        }
+    }
+
+    async saveFormFields(eventId, form) {
+        let schema;
+        try {
+            schema = JSON.parse(form.schema.value || '{}');
+        } catch (err) {
+            Utils.showToast('Fields are not valid JSON', 'error');
+            return;
+        }
+        try {
+            await ExunServices.api.apiRequest(`/admin/events/fields/${encodeURIComponent(eventId)}`, {
+                method: 'PUT',
+                body: JSON.stringify(schema)
+            });
+            Utils.showToast('Fields saved');
+            this.closeModal();
+        } catch (error) {
+            console.error('Failed to save form fields:', error);
+            Utils.showToast('Invalid fields: ' + error.message, 'error');
+        }
+    }
+
+    exportRegistrations(eventId) {
+        window.location.href = '/api/admin/export?type=registrations&format=csv&event=' + encodeURIComponent(eventId);
+    }
+
+    exportEvents(format) {
+        window.location.href = '/api/admin/events/export?format=' + format;
+    }
 
-    editEvent(eventId) {
-        Utils.showToast('Edit event functionality coming soon!', 'info');
+    async submitEventCatalogue(file, dryRun) {
+        const body = new FormData();
+        body.append('file', file);
+        const response = await fetch('/api/admin/events/import' + (dryRun ? '?dry_run=1' : ''), {
+            method: 'POST', body: body, credentials: 'include'
+        });
+        if (!response.ok && response.status !== 422) {
+            throw new Error(await response.text());
+        }
+        return response.json();
+    }
+
+    async previewEventCatalogue(input) {
+        const file = input.files[0];
+        input.value = '';
+        if (!file) return;
+        try {
+            const report = await this.submitEventCatalogue(file, true);
+            this.showEventCatalogueReport(file, report);
+        } catch (error) {
+            console.error('Failed to check catalogue:', error);
+            Utils.showToast('Failed to read catalogue: ' + error.message, 'error');
+        }
+    }
+
+    showEventCatalogueReport(file, report) {
+        const summary = report.summary || {};
+        const formatValue = (v) => Utils.escapeHtml(typeof v === 'string' ? v : JSON.stringify(v));
+        const modal = document.getElementById('admin-modal');
+        const modalContent = document.getElementById('modal-content');
+        modalContent.innerHTML = `
+            <div class="admin-modal__header">
+                <h3 class="admin-modal__title">Import ${Utils.escapeHtml(file.name)}</h3>
+                <button id="modal-close" class="admin-modal__close">&times;</button>
+            </div>
+            <p>${summary.create || 0} new, ${summary.update || 0} changed, ${summary.unchanged || 0} unchanged,
+            <strong>${summary.invalid || 0} invalid</strong>.
+            ${(report.not_in_catalogue || []).length ? `Not in the file and left as they are: ${report.not_in_catalogue.map(Utils.escapeHtml).join(', ')}.` : ''}</p>
+            <div class="admin-table-container">
+                <table class="admin-table">
+                    <thead><tr><th>Row</th><th>Slug</th><th>Status</th><th>Details</th></tr></thead>
+                    <tbody>
+                        ${(report.rows || []).filter(row => row.status !== 'unchanged').map(row => `
+                            <tr>
+                                <td>${row.row}</td>
+                                <td>${Utils.escapeHtml(row.slug)}</td>
+                                <td>${row.status}</td>
+                                <td>${row.status === 'invalid'
+                                    ? (row.errors || []).map(Utils.escapeHtml).join('<br>')
+                                    : Object.entries(row.changes || {}).map(([field, c]) =>
+                                        `${field}: ${formatValue(c.before)} &rarr; ${formatValue(c.after)}`).join('<br>')}</td>
+                            </tr>
+                        `).join('')}
+                    </tbody>
+                </table>
+            </div>
+            <div class="admin-actions">
+                <button id="apply-catalogue" class="btn btn--primary" ${summary.invalid || !(summary.create || summary.update) ? 'disabled' : ''}>Apply changes</button>
+            </div>
+        `;
+        modal.classList.add('admin-modal--open');
+        document.getElementById('modal-close').addEventListener('click', () => this.closeModal());
+        document.getElementById('apply-catalogue').addEventListener('click', () => this.applyEventCatalogue(file));
+    }
+
+    async applyEventCatalogue(file) {
+        try {
+            const report = await this.submitEventCatalogue(file, false);
+            if (!report.applied) {
+                this.showEventCatalogueReport(file, report);
+                Utils.showToast('Catalogue has invalid rows; nothing was changed', 'error');
+                return;
+            }
+            this.closeModal();
+            Utils.showToast(`Imported events: created=${report.created} updated=${report.updated}`);
+            await this.renderEvents();
+        } catch (error) {
+            console.error('Failed to apply catalogue:', error);
+            Utils.showToast('Failed to apply catalogue: ' + error.message, 'error');
+        }
     }
 
     viewEventRegistrations(eventId) {
@@ -246,6 +246,10 @@ class EventDetailPage {
 
     async openRegistrationModal() {
     const eventId = this.event.id || this.event.ID || this.eventId || this.event.name;
+    let teamFieldValues = {};
+    let team = 1;
+    let teamName = '';
+    let captain = '';
     let capacity = parseInt(this.event.participants || this.event.capacity || 1, 10) || 1;
     let existingMembers = [];
     const self = this;
@@ -263,6 +267,10 @@ class EventDetailPage {
             if (match) {
                 capacity = parseInt(match.capacity || match.Capacity || this.event.participants || 1, 10) || capacity;
                 existingMembers = (match.teamMembers && match.teamMembers.length > 0) ? match.teamMembers : (match.participants && match.participants.length > 0 ? match.participants : []);
+                teamFieldValues = match.team_fields || {};
+                team = parseInt(match.team || 1, 10) || 1;
+                teamName = match.team_name || '';
+                captain = match.captain || '';
             }
         }
     } catch (e) {
@@ -291,25 +299,111 @@ class EventDetailPage {
     const rows = [];
     const initialData = [];
 
+    const formFields = this.event.form_fields || {};
+    const teamSchema = Array.isArray(formFields.team) ? formFields.team : [];
+    const participantSchema = Array.isArray(formFields.participant) ? formFields.participant : [];
+    const fieldInput = (f, value) => {
+        const v = String(value == null ? '' : value).replace(/\"/g,'&quot;');
+        const label = Utils.escapeHtml(f.label + (f.required ? ' *' : ''));
+        const title = f.help ? ` title="${Utils.escapeHtml(f.help)}"` : '';
+        if (f.type === 'enum') {
+            return `<select class="form-input" data-field="${f.key}"${title}><option value="">${label}</option>${(f.options || []).map(o => `<option value="${Utils.escapeHtml(o)}" ${o === value ? 'selected' : ''}>${Utils.escapeHtml(o)}</option>`).join('')}</select>`;
+        }
+        if (f.type === 'file') {
+            return `<label class="form-input" style="display:flex;gap:8px;align-items:center"${title}><span>${label}</span><input type="file" data-upload="${f.key}" /><input type="hidden" data-field="${f.key}" value="${v}" /><small data-file-name>${v ? Utils.escapeHtml(value) : ''}</small></label>`;
+        }
+        const type = f.type === 'number' ? 'number' : (f.type === 'url' ? 'url' : 'text');
+        return `<input class="form-input" type="${type}" data-field="${f.key}" placeholder="${label}" value="${v}"${title} />`;
+    };
+    const wireUploads = (container) => {
+        container.querySelectorAll('input[data-upload]').forEach(input => {
+            input.addEventListener('change', async () => {
+                const file = input.files && input.files[0];
+                if (!file) return;
+                const form = new FormData();
+                form.append('file', file);
+                try {
+                    const resp = await fetch('/api/registration_files', { method: 'POST', body: form, credentials: 'include' });
+                    if (!resp.ok) throw new Error((await resp.text()).trim() || 'Upload failed');
+                    const json = await resp.json();
+                    const name = json && json.data && json.data.file;
+                    const label = input.parentElement;
+                    label.querySelector('input[type="hidden"]').value = name || '';
+                    label.querySelector('[data-file-name]').textContent = name || '';
+                    Utils.showToast('File uploaded', 'success');
+                } catch (err) {
+                    input.value = '';
+                    Utils.showToast((err && err.message) ? err.message : 'Upload failed', 'error');
+                }
+            });
+        });
+    };
+    const readFields = (container) => {
+        const out = {};
+        container.querySelectorAll('[data-field]').forEach(el => {
+            const v = (el.value || '').trim();
+            if (v) out[el.getAttribute('data-field')] = v;
+        });
+        return out;
+    };
+
+    const teamMeta = document.createElement('div');
+    teamMeta.style.display = 'grid';
+    teamMeta.style.gridTemplateColumns = '1fr 1fr';
+    teamMeta.style.gap = '12px';
+    teamMeta.style.marginBottom = '14px';
+    teamMeta.innerHTML = `
+        <input class="form-input" data-team="name" placeholder="Team name" maxlength="60" value="${Utils.escapeHtml(teamName)}" />
+        <select class="form-input" data-team="captain"></select>
+    `;
+    const captainSelect = teamMeta.querySelector('[data-team="captain"]');
+    const refreshCaptains = () => {
+        const current = captainSelect.value || captain;
+        const emails = rows.map(r => (r.querySelector('[data-name="email"]').value || '').trim()).filter(Boolean);
+        captainSelect.innerHTML = `<option value="">Captain</option>` + emails.map(e => `<option value="${Utils.escapeHtml(e)}" ${e.toLowerCase() === current.toLowerCase() ? 'selected' : ''}>${Utils.escapeHtml(e)}</option>`).join('');
+    };
+    captainSelect.addEventListener('focus', refreshCaptains);
+    captainSelect.addEventListener('mousedown', refreshCaptains);
+    editor.appendChild(teamMeta);
+
+    let teamBox = null;
+    if (teamSchema.length > 0) {
+        teamBox = document.createElement('div');
+        teamBox.className = 'inline-team-fields';
+        teamBox.style.display = 'grid';
+        teamBox.style.gridTemplateColumns = 'repeat(auto-fit, minmax(180px, 1fr))';
+        teamBox.style.gap = '12px';
+        teamBox.style.marginBottom = '14px';
+        teamBox.innerHTML = teamSchema.map(f => fieldInput(f, teamFieldValues[f.key])).join('');
+        wireUploads(teamBox);
+        editor.appendChild(teamBox);
+    }
+
+    const askGender = !!this.event.requires_gender;
+    const genderOptions = ['female', 'male', 'other'];
     const closeEditorSafely = () => { try { if (typeof cleanup === 'function') cleanup(); } catch(e) {} };
     const createRow = (p) => {
         const row = document.createElement('div');
         row.className = 'inline-member-row';
         row.style.display = 'grid';
-        row.style.gridTemplateColumns = '1fr 1fr 90px 130px auto';
+        row.style.gridTemplateColumns = askGender ? '1fr 1fr 90px 110px 130px auto' : '1fr 1fr 90px 130px auto';
         row.style.gap = '12px';
         row.style.marginBottom = '10px';
         const nameVal = String(p && (p.name||p.Name) || '');
         const emailVal = String(p && (p.email||p.Email) || '');
         const classVal = String(p && (p.class||p.Class) || '');
         const phoneVal = String(p && (p.phone||p.Phone) || '');
+        const genderVal = String(p && (p.gender||p.Gender) || '');
         row.innerHTML = `
             <input class="form-input" data-name="name" placeholder="Full name" value="${nameVal.replace(/\"/g,'&quot;')}" />
             <input class="form-input" data-name="email" placeholder="Email" value="${emailVal.replace(/\"/g,'&quot;')}" />
             <input class="form-input" data-name="class" placeholder="Class" value="${classVal.replace(/\"/g,'&quot;')}" />
+            ${askGender ? `<select class="form-input" data-name="gender"><option value="">Gender</option>${genderOptions.map(g => `<option value="${g}" ${g === genderVal ? 'selected' : ''}>${g}</option>`).join('')}</select>` : ''}
             <input class="form-input" data-name="phone" placeholder="Phone" value="${phoneVal.replace(/\"/g,'&quot;')}" />
             <button class="btn btn--tertiary btn-inline-clear"><svg xmlns="http://www.w3.org/2000/svg" width="24" height="24" viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round" class="lucide lucide-delete-icon lucide-delete"><path d="M10 5a2 2 0 0 0-1.344.519l-6.328 5.74a1 1 0 0 0 0 1.481l6.328 5.741A2 2 0 0 0 10 19h10a2 2 0 0 0 2-2V7a2 2 0 0 0-2-2z"/><path d="m12 9 6 6"/><path d="m18 9-6 6"/></svg></button>
+            ${participantSchema.length > 0 ? `<div class="inline-member-fields" style="grid-column:1 / -1;display:grid;grid-template-columns:repeat(auto-fit, minmax(160px, 1fr));gap:12px">${participantSchema.map(f => fieldInput(f, ((p && p.fields) || {})[f.key])).join('')}</div>` : ''}
         `;
+        wireUploads(row);
         const clearBtn = row.querySelector('.btn-inline-clear');
             clearBtn.addEventListener('click', async (e) => {
             e.stopPropagation();
@@ -317,7 +411,7 @@ class EventDetailPage {
                     const confirmed = await (window.Utils && window.Utils.showConfirmModal ? window.Utils.showConfirmModal('Delete registration? This will permanently remove your registration for this event. Continue?', 'Delete registration', 'Delete', 'Cancel') : Promise.resolve(confirm('Delete registration? This will permanently remove your registration for this event. Continue?')));
                     if (!confirmed) return;
                     try {
-                        const resp = await fetch('/api/submit_registrations', { method: 'POST', headers: { 'Content-Type': 'application/json' }, credentials: 'include', body: JSON.stringify({ id: eventId, action: 'delete' }) });
+                        const resp = await fetch('/api/submit_registrations', { method: 'POST', headers: { 'Content-Type': 'application/json' }, credentials: 'include', body: JSON.stringify({ id: eventId, team, action: 'delete' }) });
                         let json = null;
                         try { json = await resp.json(); } catch(e) { json = null; }
                         if (resp.ok && (json === true || (json && json.status === 'success'))) {
@@ -366,6 +460,7 @@ class EventDetailPage {
         initialData.push({ name: r.querySelector('[data-name="name"]').value || '', email: r.querySelector('[data-name="email"]').value || '', class: r.querySelector('[data-name="class"]').value || '', phone: r.querySelector('[data-name="phone"]').value || '' });
     }
     editor.__initialData = JSON.stringify(initialData);
+    refreshCaptains();
 
     const addContainer = document.createElement('div');
     addContainer.style.marginTop = '8px';
@@ -423,12 +518,13 @@ class EventDetailPage {
             const email = ((r.querySelector('[data-name="email"]')||{value:''}).value || '').trim();
             const cls = ((r.querySelector('[data-name="class"]')||{value:''}).value || '').trim();
             const phone = ((r.querySelector('[data-name="phone"]')||{value:''}).value || '').trim();
+            const gender = ((r.querySelector('[data-name="gender"]')||{value:''}).value || '').trim();
             if (!name) continue;
-            data.push({ name, email, class: parseInt(cls||0,10) || cls, phone });
+            data.push({ name, email, class: parseInt(cls||0,10) || cls, phone, gender, fields: readFields(r) });
         }
         if (data.length === 0) { Utils.showToast('Please add at least one participant', 'error'); return; }
         try {
-            const resp = await fetch('/api/submit_registrations', { method: 'POST', headers: { 'Content-Type': 'application/json' }, credentials: 'include', body: JSON.stringify({ id: eventId, data }) });
+            const resp = await fetch('/api/submit_registrations', { method: 'POST', headers: { 'Content-Type': 'application/json' }, credentials: 'include', body: JSON.stringify({ id: eventId, team, team_name: teamMeta.querySelector('[data-team="name"]').value.trim(), captain: captainSelect.value, data, fields: teamBox ? readFields(teamBox) : {} }) });
             let json = null;
             try { json = await resp.json(); } catch(e) { json = null; }
             if (resp.ok && (json === true || (json && json.status === 'success'))) {
@@ -63,35 +63,17 @@ class EventsPage {
     }
 
     getEventCategories() {
-        const categories = new Set();
-        
+        const categories = [];
+        const seen = new Set();
+
         this.events.forEach(event => {
-            if (event.category) {
-                categories.add(event.category);
-            }
-            
-            if (event.name.includes('Build:')) {
-                categories.add('build');
-            } else if (event.name.includes('CubXL')) {
-                categories.add('cubing');
-            } else if (event.name.includes('DomainSquare+')) {
-                categories.add('gaming');
-            } else if (event.name.includes('CyberX')) {
-                categories.add('cybersec');
-            } else if (event.name.includes('Roboknights')) {
-                categories.add('robotics');
+            if (event.category && !seen.has(event.category)) {
+                seen.add(event.category);
+                categories.push({ key: event.category, name: event.category_name || event.category });
             }
         });
-        
-        return [
-            { key: 'programming', name: 'Programming' },
-            { key: 'build', name: 'Build Events' },
-            { key: 'gaming', name: 'Gaming' },
-            { key: 'cubing', name: 'Cubing' },
-            { key: 'cybersec', name: 'Cybersecurity' },
-            { key: 'robotics', name: 'Robotics' },
-            { key: 'quiz', name: 'Quiz Events' }
-        ].filter(cat => categories.has(cat.key));
+
+        return categories;
     }
 
     applyFilter(filterKey) {
@@ -100,28 +82,7 @@ class EventsPage {
         if (filterKey === 'all') {
             this.filteredEvents = [...this.events];
         } else {
-            this.filteredEvents = this.events.filter(event => {
-                if (event.category === filterKey) return true;
-                
-                switch (filterKey) {
-                    case 'build':
-                        return event.name.includes('Build:');
-                    case 'cubing':
-                        return event.name.includes('CubXL');
-                    case 'gaming':
-                        return event.name.includes('DomainSquare+') || event.name.includes('Gaming');
-                    case 'cybersec':
-                        return event.name.includes('CyberX');
-                    case 'robotics':
-                        return event.name.includes('Roboknights');
-                    case 'programming':
-                        return ['Competitive Programming', 'Sudocrypt', 'Turing Test'].includes(event.name);
-                    case 'quiz':
-                        return event.name.toLowerCase().includes('quiz') || event.name === 'Crossword';
-                    default:
-                        return false;
-                }
-            });
+            this.filteredEvents = this.events.filter(event => event.category === filterKey);
         }
         
         this.renderEvents();
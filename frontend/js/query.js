@@ -166,6 +166,7 @@ document.addEventListener('DOMContentLoaded', function () {
   if (clearBtn) {
     clearBtn.addEventListener('click', () => {
       if (results) results.innerHTML = '<p class="text-muted">No answers yet. Ask a question to get started.</p>';
+      conversationId = '';
     });
   }
 
@@ -214,7 +215,7 @@ document.addEventListener('DOMContentLoaded', function () {
     results.prepend(container);
   }
 
-  function renderChatMessage(text, from) {
+  function renderChatMessage(text, from, source) {
     const container = document.getElementById('querysContainer') || document.getElementById('messagecontainer');
     if (!container) return;
     const msg = document.createElement('div');
@@ -225,8 +226,18 @@ document.addEventListener('DOMContentLoaded', function () {
     content.className = 'chat-message-content';
     content.textContent = text;
     msg.appendChild(content);
+    if (source && source !== 'policy') {
+      const cite = document.createElement('div');
+      cite.className = 'chat-message-source';
+      cite.style.fontSize = '12px';
+      cite.style.opacity = '0.7';
+      cite.style.marginTop = '4px';
+      cite.textContent = 'Sources: ' + source;
+      msg.appendChild(cite);
+    }
     container.appendChild(msg);
     container.scrollTop = container.scrollHeight;
+    return msg;
   }
 
   function stripMarkdown(md) {
@@ -248,6 +259,28 @@ document.addEventListener('DOMContentLoaded', function () {
     return s;
   }
 
+  // conversationId ties follow-up questions to the earlier ones; the server
+  // keeps the history and hands back the ID with each answer.
+  let conversationId = '';
+
+  function setBotText(msg, text) {
+    const content = msg && msg.querySelector('.chat-message-content');
+    if (!content) return;
+    content.textContent = text;
+    const container = msg.parentNode;
+    if (container) container.scrollTop = container.scrollHeight;
+  }
+
+  function finishBotMessage(msg, data) {
+    if (!msg) return;
+    if (msg.parentNode) msg.parentNode.removeChild(msg);
+    if (data && data.answer) {
+      renderChatMessage(stripMarkdown(data.answer), 'bot', data.source);
+    } else {
+      renderChatMessage('No answer returned.', 'bot');
+    }
+  }
+
   async function sendQuery(q) {
     const chatContainer = document.getElementById('querysContainer') || document.getElementById('messagecontainer');
     if (chatContainer) {
@@ -255,31 +288,56 @@ document.addEventListener('DOMContentLoaded', function () {
       empties.forEach(e => e.style.display = 'none');
     }
     renderChatMessage(q, 'user');
-    renderChatMessage('Thinking...', 'bot');
+    const botMsg = renderChatMessage('Thinking...', 'bot');
     try {
-      const res = await fetch('/api/query', {
+      const res = await fetch('/api/query/stream', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ query: q })
-      }); 
-      const data = await res.json();
+        body: JSON.stringify({ query: q, conversation_id: conversationId })
+      });
+      const type = res.headers.get('Content-Type') || '';
+      if (!type.includes('text/event-stream') || !res.body) {
+        // Rate limits and blocks are answered before streaming starts.
+        finishBotMessage(botMsg, await res.json());
+        return;
+      }
 
-      const container = document.getElementById('querysContainer') || document.getElementById('messagecontainer');
-      if (container) {
-        const nodes = Array.from(container.querySelectorAll('.chat-message'));
-        for (let i = nodes.length - 1; i >= 0; i--) {
-          if (nodes[i].textContent && nodes[i].textContent.includes('Thinking...')) {
-            container.removeChild(nodes[i]);
-            break;
+      const reader = res.body.getReader();
+      const decoder = new TextDecoder();
+      let buffer = '';
+      let shown = '';
+      let done = null;
+      for (;;) {
+        const chunk = await reader.read();
+        if (chunk.done) break;
+        buffer += decoder.decode(chunk.value, { stream: true });
+        let end;
+        while ((end = buffer.indexOf('\n\n')) >= 0) {
+          const raw = buffer.slice(0, end);
+          buffer = buffer.slice(end + 2);
+          let event = 'message';
+          let payload = '';
+          raw.split('\n').forEach(line => {
+            if (line.startsWith('event:')) event = line.slice(6).trim();
+            else if (line.startsWith('data:')) payload += line.slice(5).trim();
+          });
+          if (!payload) continue;
+          const data = JSON.parse(payload);
+          if (event === 'token') {
+            shown += data.text;
+            setBotText(botMsg, shown);
+          } else if (event === 'replace') {
+            shown = data.text;
+            setBotText(botMsg, shown);
+          } else if (event === 'done') {
+            done = data;
           }
         }
       }
-      if (data && data.answer) {
-        renderChatMessage(stripMarkdown(data.answer), 'bot');
-      } else {
-        renderChatMessage('No answer returned.', 'bot');
-      }
+      if (done && done.conversation_id) conversationId = done.conversation_id;
+      finishBotMessage(botMsg, done);
     } catch (err) {
+      if (botMsg && botMsg.parentNode) botMsg.parentNode.removeChild(botMsg);
       renderChatMessage('Error contacting server.', 'bot');
     }
   }
@@ -335,12 +335,32 @@ class SummaryPage {
                         setTimeout(() => { window.location.href = '/complete'; }, 900);
                         return;
                     }
-                    const card = registrationsContainer.querySelector(`.registration-card[data-event-id="${eid}"]`);
-                    const reg = this.registrations.find(r => (r.eventId || r.eventID || r.event_id || r.event || '').toString() === eid.toString());
+                    const team = btn.dataset.team || '1';
+                    const card = registrationsContainer.querySelector(`.registration-card[data-event-id="${eid}"][data-team="${team}"]`);
+                    const reg = this.findRegistration(eid, team);
                     if (card) await this.toggleInlineEditor(card, reg);
                 });
             });
 
+            const addTeamBtns = registrationsContainer.querySelectorAll('.btn-add-team');
+            addTeamBtns.forEach(btn => {
+                btn.addEventListener('click', async (ev) => {
+                    ev.stopPropagation();
+                    const eid = btn.dataset.eventId;
+                    const card = btn.closest('.registration-card');
+                    const base = this.findRegistration(eid, card && card.dataset.team) || {};
+                    const next = {
+                        event_id: eid,
+                        event_name: base.event_name,
+                        capacity: base.capacity,
+                        teams_per_school: base.teams_per_school,
+                        team: parseInt(btn.dataset.nextTeam, 10) || 2,
+                        participants: []
+                    };
+                    if (card) await this.toggleInlineEditor(card, next);
+                });
+            });
+
             const cards = registrationsContainer.querySelectorAll('.registration-card[data-event-id]');
             cards.forEach(card => {
                 card.style.cursor = 'pointer';
@@ -356,13 +376,19 @@ class SummaryPage {
                         return;
                     }
 
-                    const reg = this.registrations.find(r => (r.eventId || r.eventID || r.event_id || r.event || '').toString() === eid.toString());
+                    const reg = this.findRegistration(eid, card.dataset.team);
                     await this.toggleInlineEditor(card, reg);
                 });
             });
         }, 20);
     }
 
+    findRegistration(eventId, team) {
+        return (this.registrations || []).find(r =>
+            (r.eventId || r.eventID || r.event_id || r.event || '').toString() === String(eventId) &&
+            String(r.team || 1) === String(team || 1));
+    }
+
     async toggleInlineEditor(cardEl, registration) {
         const existing = cardEl.querySelector('.inline-registration-editor');
         const checkEditorDirty = (editorEl) => {
@@ -483,11 +509,32 @@ class SummaryPage {
         const capacity = parseInt(registration && (registration.capacity || registration.Capacity) || 1, 10) || 1;
         const members = (registration && (registration.participants || registration.teamMembers)) ? (registration.participants || registration.teamMembers) : [];
 
+        const team = parseInt(registration && registration.team || 1, 10) || 1;
+
         const rows = [];
         const initialData = [];
 
         const closeEditorSafely = () => { try { container.remove(); cardEl.classList.remove('registration-card--open'); } catch (e) {} };
 
+        const teamBox = document.createElement('div');
+        teamBox.style.display = 'grid';
+        teamBox.style.gridTemplateColumns = '1fr 1fr';
+        teamBox.style.gap = '12px';
+        teamBox.style.marginBottom = '12px';
+        teamBox.innerHTML = `
+            <input class="form-input" data-team="name" placeholder="Team name" maxlength="60" value="${escapeHtml(registration && registration.team_name || '')}" />
+            <select class="form-input" data-team="captain"></select>
+        `;
+        const captainSelect = teamBox.querySelector('[data-team="captain"]');
+        const refreshCaptains = () => {
+            const current = captainSelect.value || (registration && registration.captain) || '';
+            const emails = rows.map(r => (r.querySelector('[data-name="email"]').value || '').trim()).filter(Boolean);
+            captainSelect.innerHTML = `<option value="">Captain</option>` + emails.map(e => `<option value="${escapeHtml(e)}" ${e.toLowerCase() === current.toLowerCase() ? 'selected' : ''}>${escapeHtml(e)}</option>`).join('');
+        };
+        captainSelect.addEventListener('focus', refreshCaptains);
+        captainSelect.addEventListener('mousedown', refreshCaptains);
+        container.appendChild(teamBox);
+
         const createRow = (p) => {
             const row = document.createElement('div');
             row.className = 'inline-member-row';
@@ -513,7 +560,7 @@ class SummaryPage {
                     const confirmed = await showDiscardModal('This will delete your registration for this event. Continue?');
                     if (!confirmed) return;
                     try {
-                        const resp = await fetch('/api/submit_registrations', { method: 'POST', headers: { 'Content-Type': 'application/json' }, credentials: 'include', body: JSON.stringify({ id: eventId, action: 'delete' }) });
+                        const resp = await fetch('/api/submit_registrations', { method: 'POST', headers: { 'Content-Type': 'application/json' }, credentials: 'include', body: JSON.stringify({ id: eventId, team, action: 'delete' }) });
                         let json = null;
                         try { json = await resp.json(); } catch(e) { json = null; }
                         if (resp.ok && (json === true || (json && json.status === 'success'))) {
@@ -550,10 +597,12 @@ class SummaryPage {
             const r = createRow(p);
             rows.push(r);
             container.appendChild(r);
+            r.__fields = p.fields || null;
             initialData.push({ name: r.querySelector('[data-name="name"]').value || '', email: r.querySelector('[data-name="email"]').value || '', class: r.querySelector('[data-name="class"]').value || '', phone: r.querySelector('[data-name="phone"]').value || '' });
         }
 
         container.__initialData = JSON.stringify(initialData);
+        refreshCaptains();
 
         const addBtn = document.createElement('button');
         addBtn.className = 'btn btn--tertiary';
@@ -627,10 +676,18 @@ class SummaryPage {
                 const cls = r.querySelector('[data-name="class"]').value.trim();
                 const phone = r.querySelector('[data-name="phone"]').value.trim();
                 if (!name) continue;
-                data.push({ name, email, class: parseInt(cls||0,10) || cls, phone });
+                data.push({ name, email, class: parseInt(cls||0,10) || cls, phone, gender: (members[rows.indexOf(r)] || {}).gender || '', fields: r.__fields || {} });
             }
+            const body = {
+                id: eventId,
+                team,
+                team_name: teamBox.querySelector('[data-team="name"]').value.trim(),
+                captain: captainSelect.value,
+                data,
+                fields: (registration && registration.team_fields) || {}
+            };
             try {
-                const resp = await fetch('/api/submit_registrations', { method: 'POST', headers: { 'Content-Type': 'application/json' }, credentials: 'include', body: JSON.stringify({ id: eventId, data }) });
+                const resp = await fetch('/api/submit_registrations', { method: 'POST', headers: { 'Content-Type': 'application/json' }, credentials: 'include', body: JSON.stringify(body) });
                 let json = null;
                 try { json = await resp.json(); } catch(e) { json = null; }
                 if (resp.ok && (json === true || (json && json.status === 'success'))) {
@@ -687,11 +744,36 @@ class SummaryPage {
         }
 
         const eventId = registration.eventId || registration.eventID || registration.EventID || registration.event_id || registration.event || '';
+        const team = parseInt(registration.team || 1, 10) || 1;
+        const teamsAllowed = parseInt(registration.teams_per_school || 1, 10) || 1;
+        const teamLabel = registration.team_name || (teamsAllowed > 1 && status === 'confirmed' ? `Team ${team}` : '');
+
+        if (registration.team_name) {
+            detailsHtml += `
+                    <div class="registration-detail">
+                        <span class="registration-detail__label">Team:</span>
+                        <span class="registration-detail__value">${escapeHtml(registration.team_name)}</span>
+                    </div>`;
+        }
+        if (registration.captain) {
+            detailsHtml += `
+                    <div class="registration-detail">
+                        <span class="registration-detail__label">Captain:</span>
+                        <span class="registration-detail__value">${escapeHtml(registration.captain)}</span>
+                    </div>`;
+        }
+
+        const eventTeams = (this.registrations || []).filter(r => (r.eventId || r.eventID || r.event_id || r.event || '').toString() === String(eventId));
+        const taken = eventTeams.map(r => parseInt(r.team || 1, 10) || 1);
+        const lastTeam = Math.max(...taken);
+        let nextTeam = 1;
+        while (taken.includes(nextTeam)) nextTeam++;
+        const canAddTeam = status === 'confirmed' && team === lastTeam && eventTeams.length < teamsAllowed;
 
         return `
-            <div class="${wrapperClass}" data-event-id="${escapeHtml(eventId)}">
+            <div class="${wrapperClass}" data-event-id="${escapeHtml(eventId)}" data-team="${team}">
                 <div class="registration-card__header">
-                    <h4 class="registration-card__title">${eventName}</h4>
+                    <h4 class="registration-card__title">${eventName}${teamLabel ? ` — ${escapeHtml(teamLabel)}` : ''}</h4>
                     <div class="registration-card__status registration-card__status--${statusClass}">${status.toString().toUpperCase()}</div>
                 </div>
                 <div class="registration-card__details">
@@ -721,7 +803,8 @@ class SummaryPage {
                 ` : ''}
                 <div class="registration-card__actions" style="margin-top:12px; display:flex; gap:8px; justify-content:flex-end;">
                     <button class="btn btn--secondary btn-view-details" data-event-id="${escapeHtml(eventId)}">View Details</button>
-                    <button class="btn btn--primary btn-register" data-event-id="${escapeHtml(eventId)}">${status === 'confirmed' ? 'Edit Registration' : 'Register'}</button>
+                    ${canAddTeam ? `<button class="btn btn--secondary btn-add-team" data-event-id="${escapeHtml(eventId)}" data-next-team="${nextTeam}">Add team</button>` : ''}
+                    <button class="btn btn--primary btn-register" data-event-id="${escapeHtml(eventId)}" data-team="${team}">${status === 'confirmed' ? 'Edit Registration' : 'Register'}</button>
                 </div>
             </div>
         `;
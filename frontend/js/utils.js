@@ -176,6 +176,7 @@ function formatParticipants(count) {
 
 function formatEligibility(eligibility, openToAll) {
     if (openToAll) return 'Open to All';
+    if (typeof eligibility === 'string' && eligibility) return eligibility;
     if (eligibility && eligibility.length === 2) {
         return `${eligibility[0]}th - ${eligibility[1]}th`;
     }
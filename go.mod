@@ -6,7 +6,10 @@ require github.com/mattn/go-sqlite3 v1.14.30
 
 require google.golang.org/api v0.197.0
 
-require google.golang.org/genai v1.27.0
+require (
+	golang.org/x/oauth2 v0.23.0
+	google.golang.org/genai v1.27.0
+)
 
 require (
 	cloud.google.com/go v0.116.0 // indirect
@@ -31,7 +34,6 @@ require (
 	go.opentelemetry.io/otel/trace v1.29.0 // indirect
 	golang.org/x/crypto v0.27.0 // indirect
 	golang.org/x/net v0.29.0 // indirect
-	golang.org/x/oauth2 v0.23.0 // indirect
 	golang.org/x/sys v0.25.0 // indirect
 	golang.org/x/text v0.18.0 // indirect
 	google.golang.org/appengine v1.6.8 // indirect
@@ -0,0 +1,74 @@
+package guardrails
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// A file that fails to load leaves the previous rules in force.
+type Engine struct {
+	path string
+
+	mu       sync.Mutex
+	rules    *Ruleset
+	modTime  time.Time
+	size     int64
+	loadedAt time.Time
+	lastErr  error
+}
+
+func NewEngine(path string) *Engine {
+	e := &Engine{path: path}
+	e.reload()
+	return e
+}
+
+func (e *Engine) Path() string {
+	return e.path
+}
+
+func (e *Engine) Rules() *Ruleset {
+	e.reload()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.rules
+}
+
+func (e *Engine) Status() (time.Time, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.loadedAt, e.lastErr
+}
+
+func (e *Engine) Evaluate(text, scope string) Result {
+	return e.Rules().Evaluate(text, scope)
+}
+
+func (e *Engine) reload() {
+	info, err := os.Stat(e.path)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		if e.lastErr == nil || e.lastErr.Error() != err.Error() {
+			log.Printf("guardrails: %v", err)
+		}
+		e.lastErr = err
+		return
+	}
+	if !e.modTime.IsZero() && info.ModTime().Equal(e.modTime) && info.Size() == e.size {
+		return
+	}
+	e.modTime, e.size = info.ModTime(), info.Size()
+	rules, err := Load(e.path)
+	if err != nil {
+		log.Printf("guardrails: failed to load %s, keeping previous rules: %v", e.path, err)
+		e.lastErr = err
+		return
+	}
+	e.rules = rules
+	e.loadedAt = time.Now()
+	e.lastErr = nil
+	log.Printf("guardrails: loaded %d rules from %s", len(rules.Rules), e.path)
+}
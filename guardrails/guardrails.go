@@ -0,0 +1,171 @@
+package guardrails
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	ScopeInput  = "input"
+	ScopeOutput = "output"
+	ScopeBoth   = "both"
+
+	ActionReject = "reject"
+	ActionRedact = "redact"
+	ActionFlag   = "flag"
+	ActionAllow  = "allow"
+)
+
+const Redaction = "[BLOCKED]"
+
+// Strike rules count towards the client's cooldown when they reject a query.
+type Rule struct {
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	Pattern     string `json:"pattern"`
+	Scope       string `json:"scope"`
+	Action      string `json:"action"`
+	Message     string `json:"message,omitempty"`
+	Strike      bool   `json:"strike,omitempty"`
+	Disabled    bool   `json:"disabled,omitempty"`
+
+	re *regexp.Regexp
+}
+
+func (r *Rule) appliesTo(scope string) bool {
+	return !r.Disabled && (r.Scope == ScopeBoth || r.Scope == scope)
+}
+
+func (r *Rule) validate() error {
+	if r.ID == "" {
+		return fmt.Errorf("rule id is required")
+	}
+	switch r.Scope {
+	case ScopeInput, ScopeOutput, ScopeBoth:
+	default:
+		return fmt.Errorf("rule %s: scope must be input, output or both", r.ID)
+	}
+	switch r.Action {
+	case ActionReject, ActionRedact, ActionFlag:
+	default:
+		return fmt.Errorf("rule %s: action must be reject, redact or flag", r.ID)
+	}
+	if r.Pattern == "" {
+		return fmt.Errorf("rule %s: pattern is required", r.ID)
+	}
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return fmt.Errorf("rule %s: invalid pattern: %v", r.ID, err)
+	}
+	r.re = re
+	return nil
+}
+
+type Ruleset struct {
+	Rules []Rule `json:"rules"`
+}
+
+func Parse(data []byte) (*Ruleset, error) {
+	var rs Ruleset
+	if err := json.Unmarshal(data, &rs); err != nil {
+		return nil, fmt.Errorf("invalid rule file: %v", err)
+	}
+	seen := map[string]bool{}
+	var problems []string
+	for i := range rs.Rules {
+		r := &rs.Rules[i]
+		r.ID = strings.TrimSpace(r.ID)
+		r.Scope = strings.ToLower(strings.TrimSpace(r.Scope))
+		r.Action = strings.ToLower(strings.TrimSpace(r.Action))
+		if err := r.validate(); err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if seen[r.ID] {
+			problems = append(problems, fmt.Sprintf("duplicate rule id %s", r.ID))
+		}
+		seen[r.ID] = true
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return &rs, nil
+}
+
+func Load(path string) (*Ruleset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+type Match struct {
+	RuleID string `json:"rule_id"`
+	Action string `json:"action"`
+	Text   string `json:"text"`
+}
+
+// Action is the strongest among the matching rules: reject, then redact,
+// then flag, then allow.
+type Result struct {
+	Action  string  `json:"action"`
+	Text    string  `json:"text"`
+	Matches []Match `json:"matches"`
+	Rule    *Rule   `json:"rule,omitempty"`
+}
+
+func (r Result) Rejected() bool {
+	return r.Action == ActionReject
+}
+
+func (r Result) Flagged() bool {
+	for _, m := range r.Matches {
+		if m.Action == ActionFlag {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Result) RuleIDs() []string {
+	ids := make([]string, 0, len(r.Matches))
+	for _, m := range r.Matches {
+		ids = append(ids, m.RuleID)
+	}
+	return ids
+}
+
+var actionRank = map[string]int{ActionAllow: 0, ActionFlag: 1, ActionRedact: 2, ActionReject: 3}
+
+// Redactions are applied in rule order.
+func (rs *Ruleset) Evaluate(text, scope string) Result {
+	res := Result{Action: ActionAllow, Text: text, Matches: []Match{}}
+	if rs == nil {
+		return res
+	}
+	for i := range rs.Rules {
+		r := &rs.Rules[i]
+		if !r.appliesTo(scope) {
+			continue
+		}
+		loc := r.re.FindStringIndex(text)
+		if loc == nil {
+			continue
+		}
+		res.Matches = append(res.Matches, Match{RuleID: r.ID, Action: r.Action, Text: text[loc[0]:loc[1]]})
+		if r.Action == ActionRedact {
+			res.Text = r.re.ReplaceAllString(res.Text, Redaction)
+		}
+		if actionRank[r.Action] > actionRank[res.Action] {
+			res.Action = r.Action
+			if r.Action == ActionReject {
+				res.Rule = r
+			}
+		}
+	}
+	return res
+}
@@ -1,9 +1,9 @@
 package handlers
 
 import (
-	"bytes"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"log"
 	"net/http"
 	"os"
 	"strings"
@@ -11,6 +11,7 @@ import (
 
 	"exunreg25/db"
 	"exunreg25/mail"
+	"exunreg25/webhooks"
 )
 
 type AdminHandler struct {
@@ -24,26 +25,13 @@ type InvitePayload struct {
 	CustomMessage string `json:"custom_message,omitempty"`
 }
 
-type EventUpdateRequest struct {
-	EventID                 string `json:"event_id"`
-	Mode                    string `json:"mode"`
-	Participants            int    `json:"participants"`
-	MinClass                int    `json:"min_class"`
-	MaxClass                int    `json:"max_class"`
-	OpenToAll               bool   `json:"open_to_all"`
-	IndependentRegistration bool   `json:"independent_registration"`
-	Points                  int    `json:"points"`
-	Dates                   string `json:"dates"`
-	DescriptionShort        string `json:"description_short"`
-	DescriptionLong         string `json:"description_long"`
-}
-
 type AdminStats struct {
 	TotalUsers         int                   `json:"total_users"`
 	TotalEvents        int                   `json:"total_events"`
 	TotalRegistrations int                   `json:"total_registrations"`
 	EventStats         map[string]EventStats `json:"event_stats"`
 	UserRegistrations  map[string]UserStats  `json:"user_registrations"`
+	QueryReviews       db.QueryReviewCounts  `json:"query_reviews"`
 }
 
 type EventStats struct {
@@ -121,6 +109,7 @@ func (ah *AdminHandler) GetAdminStats(w http.ResponseWriter, r *http.Request) {
 		"total_registrations": stats.TotalRegistrations,
 		"event_stats":         stats.EventStats,
 		"user_registrations":  stats.UserRegistrations,
+		"query_reviews":       stats.QueryReviews,
 		"data":                stats,
 	}
 
@@ -191,7 +180,7 @@ func GetAdminStatsData() (AdminStats, error) {
 				memberCount := 0
 				if u, ok := usersByID[r.UserID]; ok {
 					if u.Registrations != nil {
-						if parts, exists := u.Registrations[r.EventID]; exists {
+						if parts, exists := u.Registrations[db.TeamKey(r.EventID, r.Team)]; exists {
 							memberCount = len(parts)
 						}
 					}
@@ -213,14 +202,23 @@ func GetAdminStatsData() (AdminStats, error) {
 			TotalParticipants: 0,
 		}
 		if user.Registrations != nil {
-			userStats.TotalEvents = len(user.Registrations)
-			for _, participants := range user.Registrations {
+			events := map[string]bool{}
+			for key, participants := range user.Registrations {
+				eventID, _ := db.SplitTeamKey(key)
+				events[eventID] = true
 				userStats.TotalParticipants += len(participants)
 			}
+			userStats.TotalEvents = len(events)
 		}
 		stats.UserRegistrations[user.Email] = userStats
 	}
 
+	if counts, err := globalDB.QueryReviewCounts(); err != nil {
+		log.Printf("%v", err)
+	} else {
+		stats.QueryReviews = counts
+	}
+
 	return stats, nil
 }
 
@@ -250,10 +248,18 @@ func (ah *AdminHandler) GetEvent(w http.ResponseWriter, r *http.Request) {
 	event := eventData.(*db.Event)
 	response := map[string]interface{}{
 		"id":                       event.ID,
+		"name":                     event.Name,
+		"image":                    event.Image,
+		"category":                 event.Category,
+		"display_order":            event.DisplayOrder,
+		"archived":                 event.ArchivedAt != nil,
 		"mode":                     event.Mode,
 		"participants":             event.Participants,
+		"teams_per_school":         teamsPerSchool(event),
 		"eligibility":              event.Eligibility,
 		"open_to_all":              event.OpenToAll,
+		"eligibility_rules":        eventRules(event),
+		"form_fields":              eventFormFields(event),
 		"independent_registration": event.IndependentRegistration,
 		"points":                   event.Points,
 		"dates":                    event.Dates,
@@ -278,7 +284,7 @@ func (ah *AdminHandler) UpdateEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var req EventUpdateRequest
+	var req EventRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
@@ -291,39 +297,49 @@ func (ah *AdminHandler) UpdateEvent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	existingEvent := existingEventData.(*db.Event)
-	eligibilityStr := fmt.Sprintf("[%d,%d]", req.MinClass, req.MaxClass)
-
-	updatedEvent := db.Event{
-		ID:                      req.EventID,
-		Name:                    existingEvent.Name,
-		Image:                   existingEvent.Image,
-		Mode:                    req.Mode,
-		Participants:            req.Participants,
-		Eligibility:             eligibilityStr,
-		OpenToAll:               req.OpenToAll,
-		IndependentRegistration: req.IndependentRegistration,
-		Points:                  req.Points,
-		Dates:                   req.Dates,
-		DescriptionShort:        req.DescriptionShort,
-		DescriptionLong:         req.DescriptionLong,
-		CreatedAt:               existingEvent.CreatedAt,
-		UpdatedAt:               time.Now(),
-	}
-
-	if err := ah.db.Update("events", req.EventID, updatedEvent); err != nil {
+	updatedEvent := *existingEvent
+	req.apply(&updatedEvent)
+	if req.Slug != nil && strings.TrimSpace(*req.Slug) != "" {
+		updatedEvent.ID = strings.TrimSpace(*req.Slug)
+	}
+	if msg := validateEvent(&updatedEvent); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	renamed := updatedEvent.ID != existingEvent.ID
+	if renamed {
+		if _, err := ah.db.Get("events", updatedEvent.ID); err == nil {
+			http.Error(w, "An event with slug "+updatedEvent.ID+" already exists", http.StatusConflict)
+			return
+		}
+	}
+
+	if err := ah.db.Update("events", req.EventID, &updatedEvent); err != nil {
 		http.Error(w, "Failed to update event", http.StatusInternalServerError)
 		return
 	}
+	if renamed {
+		if err := ah.db.RenameEvent(existingEvent.ID, updatedEvent.ID); err != nil {
+			log.Printf("failed to rename event %s to %s: %v", existingEvent.ID, updatedEvent.ID, err)
+			http.Error(w, "Event updated but renaming the slug failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	updatedEvent.UpdatedAt = time.Now()
+	recordAudit(r, email, "event.update", "events", req.EventID, existingEvent, &updatedEvent)
+	emitWebhook(webhooks.EventEventUpdated, eventWebhookData(&updatedEvent, "updated", email))
 
 	response := map[string]interface{}{
 		"success": true,
 		"message": "Event updated successfully",
+		"data":    &updatedEvent,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// DeleteEvent previews (GET) or performs (POST/DELETE) a cascading delete.
 func (ah *AdminHandler) DeleteEvent(w http.ResponseWriter, r *http.Request) {
 	if !globalAuthHandler.isAuthenticated(r) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -335,26 +351,148 @@ func (ah *AdminHandler) DeleteEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	eventID := strings.TrimPrefix(r.URL.Path, "/api/admin/events/")
+	eventID := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/admin/events/"), "delete/")
 	if eventID == "" {
 		http.Error(w, "Event ID required", http.StatusBadRequest)
 		return
 	}
 
-	if err := ah.db.Delete("events", eventID); err != nil {
+	existingEventData, err := ah.db.Get("events", eventID)
+	if err != nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+	existingEvent := existingEventData.(*db.Event)
+
+	switch r.Method {
+	case http.MethodGet:
+		impact, err := ah.db.EventImpact(eventID)
+		if err != nil {
+			http.Error(w, "Failed to compute deletion impact", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"event":        existingEvent,
+			"data":         impact,
+			"confirmation": eventID,
+		})
+		return
+	case http.MethodPost, http.MethodDelete:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Confirm string `json:"confirm"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Confirm) != eventID {
+		http.Error(w, "Type the event ID to confirm deletion", http.StatusBadRequest)
+		return
+	}
+	if backupManager == nil {
+		http.Error(w, "Backups not configured; refusing to delete without a snapshot", http.StatusServiceUnavailable)
+		return
+	}
+
+	snap, err := backupManager.TakeSnapshot("event-delete:" + eventID)
+	if err != nil {
+		log.Printf("snapshot before deleting event %s failed: %v", eventID, err)
+		http.Error(w, "Failed to take snapshot: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	impact, err := ah.db.DeleteEventCascade(eventID)
+	if err != nil {
+		log.Printf("failed to delete event %s: %v", eventID, err)
 		http.Error(w, "Failed to delete event", http.StatusInternalServerError)
 		return
 	}
+	log.Printf("event %s deleted by %s (%d teams, %d participants, snapshot %s)", eventID, email, impact.Teams, impact.Participants, snap.Name)
+	recordAudit(r, email, "event.delete", "events", eventID, existingEvent, nil)
+	recordAudit(r, email, "event.delete_cascade", "events", eventID, nil, map[string]interface{}{
+		"snapshot":      snap.Name,
+		"teams":         impact.Teams,
+		"participants":  impact.Participants,
+		"registrations": impact.Registrations,
+		"usr_regs":      impact.UsrRegs,
+		"changes":       impact.Changes,
+		"team_list":     impact.TeamList,
+	})
+	emitWebhook(webhooks.EventEventUpdated, eventWebhookData(existingEvent, "deleted", email))
 
 	response := map[string]interface{}{
-		"success": true,
-		"message": "Event deleted successfully",
+		"success":  true,
+		"message":  "Event deleted successfully",
+		"snapshot": snap.Name,
+		"data":     impact,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// ArchiveEvent hides or, with {"archived": false}, restores an event.
+func (ah *AdminHandler) ArchiveEvent(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	eventID := strings.TrimPrefix(r.URL.Path, "/api/admin/events/archive/")
+	if eventID == "" {
+		http.Error(w, "Event ID required", http.StatusBadRequest)
+		return
+	}
+
+	req := struct {
+		Archived *bool `json:"archived"`
+	}{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	archived := req.Archived == nil || *req.Archived
+
+	existingEventData, err := ah.db.Get("events", eventID)
+	if err != nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+	if err := ah.db.SetEventArchived(eventID, archived); err != nil {
+		http.Error(w, "Failed to archive event", http.StatusInternalServerError)
+		return
+	}
+	updatedEventData, err := ah.db.Get("events", eventID)
+	if err != nil {
+		http.Error(w, "Failed to reload event", http.StatusInternalServerError)
+		return
+	}
+	updatedEvent := updatedEventData.(*db.Event)
+
+	auditAction, action := "event.archive", "archived"
+	if !archived {
+		auditAction, action = "event.unarchive", "unarchived"
+	}
+	recordAudit(r, email, auditAction, "events", eventID, existingEventData, updatedEvent)
+	emitWebhook(webhooks.EventEventUpdated, eventWebhookData(updatedEvent, action, email))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": updatedEvent})
+}
+
 func (ah *AdminHandler) GetUserDetails(w http.ResponseWriter, r *http.Request) {
 	if !globalAuthHandler.isAuthenticated(r) {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -414,13 +552,18 @@ func (ah *AdminHandler) SendInvite(w http.ResponseWriter, r *http.Request) {
 		}
 		if err := ah.db.Create("users", &u); err != nil {
 			if existing, err2 := ah.db.Get("users", req.ToEmail); err2 == nil {
-				eu := existing.(db.User)
+				eu := existing.(*db.User)
 				if eu.InstitutionName == "" {
+					before := *eu
 					eu.InstitutionName = req.SchoolName
 					eu.UpdatedAt = time.Now()
-					_ = ah.db.Update("users", req.ToEmail, eu)
+					if err := ah.db.Update("users", req.ToEmail, eu); err == nil {
+						recordAudit(r, email, "user.update", "users", req.ToEmail, &before, eu)
+					}
 				}
 			}
+		} else {
+			recordAudit(r, email, "user.create", "users", req.ToEmail, nil, &u)
 		}
 	}
 
@@ -432,11 +575,20 @@ func (ah *AdminHandler) SendInvite(w http.ResponseWriter, r *http.Request) {
 			CustomMessage: req.CustomMessage,
 		}
 		if err := inviteService.SendInviteEmail(mreq); err != nil {
+			if errors.Is(err, mail.ErrSuppressed) {
+				http.Error(w, "Recipient has unsubscribed from invites", http.StatusConflict)
+				return
+			}
 			http.Error(w, "Failed to send invite", http.StatusInternalServerError)
 			return
 		}
 	}
 
+	recordAudit(r, email, "invite.send", "users", req.ToEmail, nil, map[string]interface{}{
+		"school_name":    req.SchoolName,
+		"principal_name": req.PrincipalName,
+	})
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
 }
@@ -532,10 +684,11 @@ func (ah *AdminHandler) GetEventRegistrations(w http.ResponseWriter, r *http.Req
 		status := reg.Status
 		created := reg.CreatedAt
 
+		teamKey := db.TeamKey(reg.EventID, reg.Team)
 		members := []db.Participant{}
 		memberCount := 0
 		if user.Registrations != nil {
-			if parts, ok := user.Registrations[reg.EventID]; ok {
+			if parts, ok := user.Registrations[teamKey]; ok {
 				members = parts
 				memberCount = len(parts)
 			}
@@ -546,12 +699,17 @@ func (ah *AdminHandler) GetEventRegistrations(w http.ResponseWriter, r *http.Req
 			eventName = ev.Name
 		}
 
+		teamFields, _ := ah.db.TeamFields(reg.UserID, teamKey)
+
 		out = append(out, map[string]interface{}{
 			"eventId":     reg.EventID,
 			"eventName":   eventName,
 			"userEmail":   userEmail,
 			"userName":    userName,
+			"team":        reg.Team,
 			"teamName":    teamName,
+			"captain":     reg.Captain,
+			"teamFields":  teamFields,
 			"members":     members,
 			"memberCount": memberCount,
 			"createdAt":   created,
@@ -592,11 +750,38 @@ func (ah *AdminHandler) ExportData(w http.ResponseWriter, r *http.Request) {
 		data = events
 		filename = "events_export.json"
 	case "registrations":
+		if eventID := r.URL.Query().Get("event"); eventID != "" && r.URL.Query().Get("format") == "csv" {
+			item, err := ah.db.Get("events", eventID)
+			if err != nil {
+				http.Error(w, "Event not found", http.StatusNotFound)
+				return
+			}
+			if err := ah.writeRegistrationsCSV(w, item.(*db.Event)); err != nil {
+				log.Printf("registrations export for %s failed: %v", eventID, err)
+			}
+			return
+		}
 		users, _ := ah.db.GetAll("users")
+		teamFields, _ := ah.db.AllTeamFields()
+		teams := map[int][]*db.Registration{}
+		if regs, err := ah.db.GetAll("registrations"); err == nil {
+			for _, rr := range regs {
+				if reg, ok := rr.(*db.Registration); ok {
+					teams[reg.UserID] = append(teams[reg.UserID], reg)
+				}
+			}
+		}
 		registrations := make(map[string]interface{})
 		for _, userData := range users {
-			user := userData.(db.User)
-			registrations[user.Email] = user.Registrations
+			user, ok := userData.(*db.User)
+			if !ok {
+				continue
+			}
+			registrations[user.Email] = map[string]interface{}{
+				"registrations": user.Registrations,
+				"teams":         teams[user.ID],
+				"team_fields":   teamFields[user.ID],
+			}
 		}
 		data = registrations
 		filename = "registrations_export.json"
@@ -621,174 +806,6 @@ func (ah *AdminHandler) ExportData(w http.ResponseWriter, r *http.Request) {
 var globalAdminHandler *AdminHandler
 var inviteService *mail.InviteEmailService
 
-func (ah *AdminHandler) ImportEvents(w http.ResponseWriter, r *http.Request) {
-	if !globalAuthHandler.isAuthenticated(r) {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-	email := globalAuthHandler.getAuthenticatedUser(r)
-	if !IsAdminEmail(email) {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	b, err := os.ReadFile("frontend/data/events.json")
-	if err != nil {
-		http.Error(w, "Failed to read events.json", http.StatusInternalServerError)
-		return
-	}
-
-	var raw struct {
-		Default struct {
-			OpenToAll    bool   `json:"open_to_all"`
-			Eligibility  []int  `json:"eligibility"`
-			Participants int    `json:"participants"`
-			Mode         string `json:"mode"`
-			Descriptions struct {
-				Long  string `json:"long"`
-				Short string `json:"short"`
-			} `json:"descriptions"`
-			IndependentRegistrations bool   `json:"independent_registrations"`
-			Points                   int    `json:"points"`
-			Dates                    string `json:"dates"`
-		} `json:"default"`
-		Descriptions map[string]struct {
-			Long  string `json:"long"`
-			Short string `json:"short"`
-		} `json:"descriptions"`
-		Participants map[string]int    `json:"participants"`
-		Mode         map[string]string `json:"mode"`
-		Points       map[string]int    `json:"points"`
-		Individual   map[string]bool   `json:"individual"`
-		Eligibility  map[string][]int  `json:"eligibility"`
-		OpenToAll    map[string]bool   `json:"open_to_all"`
-	}
-
-	if err := json.Unmarshal(b, &raw); err != nil {
-		http.Error(w, "Failed to parse events.json", http.StatusInternalServerError)
-		return
-	}
-
-	type evtPair struct{ Name, Image string }
-	ordered := []evtPair{}
-	dec := json.NewDecoder(bytes.NewReader(b))
-	tok, err := dec.Token()
-	if err == nil {
-		for dec.More() {
-			k, err := dec.Token()
-			if err != nil {
-				break
-			}
-			key, ok := k.(string)
-			if !ok {
-				var skip interface{}
-				_ = dec.Decode(&skip)
-				continue
-			}
-			if key == "events" {
-				if _, err := dec.Token(); err != nil {
-					break
-				}
-				for dec.More() {
-					kn, err := dec.Token()
-					if err != nil {
-						break
-					}
-					name, _ := kn.(string)
-					var img string
-					if err := dec.Decode(&img); err != nil {
-						break
-					}
-					ordered = append(ordered, evtPair{Name: name, Image: img})
-				}
-				_, _ = dec.Token()
-			} else {
-				var skip interface{}
-				_ = dec.Decode(&skip)
-			}
-		}
-		_ = tok
-	}
-
-	created := 0
-	updated := 0
-	for _, pair := range ordered {
-		name := pair.Name
-		image := pair.Image
-		slug := slugify(name)
-		participants := raw.Default.Participants
-		if v, ok := raw.Participants[name]; ok {
-			participants = v
-		}
-		mode := raw.Default.Mode
-		if v, ok := raw.Mode[name]; ok {
-			mode = v
-		}
-		points := raw.Default.Points
-		if v, ok := raw.Points[name]; ok {
-			points = v
-		}
-		individual := raw.Default.IndependentRegistrations
-		if v, ok := raw.Individual[name]; ok {
-			individual = v
-		}
-
-		descShort := raw.Default.Descriptions.Short
-		descLong := raw.Default.Descriptions.Long
-		if v, ok := raw.Descriptions[name]; ok {
-			if v.Short != "" {
-				descShort = v.Short
-			}
-			if v.Long != "" {
-				descLong = v.Long
-			}
-		}
-
-		openAll := raw.Default.OpenToAll
-		if v, ok := raw.OpenToAll[name]; ok {
-			openAll = v
-		}
-
-		eligibility := ""
-		if openAll {
-			eligibility = "Open to all"
-		} else if vals, ok := raw.Eligibility[name]; ok && len(vals) >= 2 {
-			eligibility = fmt.Sprintf("Grades %d–%d", vals[0], vals[1])
-		} else if len(raw.Default.Eligibility) >= 2 {
-			eligibility = fmt.Sprintf("Grades %d–%d", raw.Default.Eligibility[0], raw.Default.Eligibility[1])
-		}
-
-		ev := db.Event{
-			ID:                      slug,
-			Name:                    name,
-			Image:                   image,
-			OpenToAll:               openAll,
-			Eligibility:             eligibility,
-			Participants:            participants,
-			Mode:                    mode,
-			IndependentRegistration: individual,
-			Points:                  points,
-			Dates:                   raw.Default.Dates,
-			DescriptionShort:        descShort,
-			DescriptionLong:         descLong,
-			CreatedAt:               time.Now(),
-			UpdatedAt:               time.Now(),
-		}
-
-		if existing, err := ah.db.Get("events", slug); err == nil && existing != nil {
-			_ = ah.db.Update("events", slug, ev)
-			updated++
-		} else {
-			_ = ah.db.Create("events", &ev)
-			created++
-		}
-	}
-
-	resp := map[string]interface{}{"created": created, "updated": updated}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
-}
-
 func SetInviteService(svc *mail.InviteEmailService) {
 	inviteService = svc
 }
@@ -837,6 +854,14 @@ func DeleteEvent(w http.ResponseWriter, r *http.Request) {
 	globalAdminHandler.DeleteEvent(w, r)
 }
 
+func ArchiveEvent(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.ArchiveEvent(w, r)
+}
+
 func GetUserDetails(w http.ResponseWriter, r *http.Request) {
 	if globalAdminHandler == nil {
 		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
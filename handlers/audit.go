@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"exunreg25/db"
+	"exunreg25/middleware"
+)
+
+func recordAudit(r *http.Request, actor, action, entity, entityID string, before, after interface{}) {
+	if globalDB == nil {
+		return
+	}
+	entry := db.AuditEntry{
+		Actor:    actor,
+		Action:   action,
+		Entity:   entity,
+		EntityID: entityID,
+		Before:   before,
+		After:    after,
+	}
+	if r != nil {
+		entry.IP = middleware.ClientIP(r)
+		entry.RequestID = middleware.GetRequestID(r)
+	}
+	if err := globalDB.RecordAudit(entry); err != nil {
+		log.Printf("failed to record audit event %s %s/%s: %v", action, entity, entityID, err)
+	}
+}
+
+func parseAuditTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+func auditFilterFromQuery(r *http.Request) (db.AuditFilter, error) {
+	q := r.URL.Query()
+	f := db.AuditFilter{
+		Actor:    strings.TrimSpace(q.Get("actor")),
+		Action:   strings.TrimSpace(q.Get("action")),
+		Entity:   strings.TrimSpace(q.Get("entity")),
+		EntityID: strings.TrimSpace(q.Get("entity_id")),
+	}
+	var err error
+	if f.Since, err = parseAuditTime(q.Get("since")); err != nil {
+		return f, fmt.Errorf("since must be a date or RFC 3339 time")
+	}
+	if f.Until, err = parseAuditTime(q.Get("until")); err != nil {
+		return f, fmt.Errorf("until must be a date or RFC 3339 time")
+	}
+	return f, nil
+}
+
+func (ah *AdminHandler) AuditEvents(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/audit"), "/")
+
+	switch action {
+	case "":
+		f, err := auditFilterFromQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		if perPage < 1 || perPage > 500 {
+			perPage = 50
+		}
+		f.Limit = perPage
+		f.Offset = (page - 1) * perPage
+		events, total, err := ah.db.ListAuditEvents(f)
+		if err != nil {
+			http.Error(w, "Failed to list audit events", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data":     events,
+			"total":    total,
+			"page":     page,
+			"per_page": perPage,
+		})
+	case "export":
+		f, err := auditFilterFromQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		events, _, err := ah.db.ListAuditEvents(f)
+		if err != nil {
+			http.Error(w, "Failed to export audit events", http.StatusInternalServerError)
+			return
+		}
+		recordAudit(r, email, "audit.export", "audit_events", "", nil, map[string]interface{}{"rows": len(events), "query": r.URL.RawQuery})
+		name := "audit-" + time.Now().Format("20060102-150405")
+		if r.URL.Query().Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.json"`)
+			json.NewEncoder(w).Encode(events)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.csv"`)
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"id", "created_at", "actor", "action", "entity", "entity_id", "diff", "ip", "request_id", "prev_hash", "hash"})
+		for _, e := range events {
+			cw.Write([]string{
+				strconv.FormatInt(e.ID, 10),
+				e.CreatedAt.UTC().Format(time.RFC3339Nano),
+				e.Actor,
+				e.Action,
+				e.Entity,
+				e.EntityID,
+				string(e.Diff),
+				e.IP,
+				e.RequestID,
+				e.PrevHash,
+				e.Hash,
+			})
+		}
+		cw.Flush()
+	case "verify":
+		v, err := ah.db.VerifyAuditChain()
+		if err != nil {
+			http.Error(w, "Failed to verify audit chain: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": v})
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func AuditEvents(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.AuditEvents(w, r)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"exunreg25/db"
+	"exunreg25/middleware"
+)
+
+func TestRecordAuditIP(t *testing.T) {
+	database := useTestDB(t)
+	if err := middleware.SetTrustedProxies([]string{"127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { middleware.SetTrustedProxies(nil) })
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    string
+		want   string
+	}{
+		{"direct", "203.0.113.7:5000", "", "203.0.113.7"},
+		{"forged forwarding header", "203.0.113.7:5000", "198.51.100.1", "203.0.113.7"},
+		{"trusted proxy", "127.0.0.1:5000", "1.1.1.1, 198.51.100.1", "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/admin/events", nil)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			recordAudit(r, "admin@exun.co", "event.update", "event", tt.name, nil, map[string]interface{}{"name": "Quiz"})
+			events, _, err := database.ListAuditEvents(db.AuditFilter{EntityID: tt.name})
+			if err != nil || len(events) != 1 {
+				t.Fatalf("ListAuditEvents = %+v, %v", events, err)
+			}
+			if events[0].IP != tt.want {
+				t.Errorf("audit IP = %q, want %q", events[0].IP, tt.want)
+			}
+		})
+	}
+}
@@ -119,6 +119,7 @@ func (ah *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(response)
 		return
 	}
+	recordAudit(r, email, "user.password_change", "users", email, nil, nil)
 	response := Response{Status: "success", Message: "Password updated"}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -182,6 +183,7 @@ func (ah *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(response)
 		return
 	}
+	recordAudit(r, req.Email, "user.password_reset", "users", req.Email, nil, nil)
 	response := Response{Status: "success", Message: "Password reset successful"}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -2,156 +2,386 @@ package handlers
 
 import (
 	"context"
-	"crypto/sha256"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"os"
-	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"exunreg25/backup"
+
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/drive/v3"
 	"google.golang.org/api/option"
 )
 
-func UploadDBBackupToDrive(_ []byte, dbPath string) (string, error) {
-	ctx := context.Background()
+const driveProvider = "google-drive"
+
+var errDriveNotConnected = errors.New("Google Drive is not connected, an admin must connect it from /admin/drive")
+
+type driveAuthFlow struct {
+	verifier  string
+	admin     string
+	createdAt time.Time
+}
+
+type driveTokenSource struct {
+	mu      sync.Mutex
+	current *oauth2.Token
+}
+
+var (
+	driveFlowsMu     sync.Mutex
+	driveFlows       = make(map[string]driveAuthFlow)
+	driveTokenKey    []byte
+	driveRedirectURL string
+	driveTokens      = &driveTokenSource{}
+)
+
+type DriveStatusData struct {
+	Configured      bool       `json:"configured"`
+	Connected       bool       `json:"connected"`
+	Healthy         bool       `json:"healthy"`
+	HasRefreshToken bool       `json:"has_refresh_token"`
+	ConnectedBy     string     `json:"connected_by,omitempty"`
+	ConnectedAt     *time.Time `json:"connected_at,omitempty"`
+	Expiry          *time.Time `json:"expiry,omitempty"`
+	RefreshedAt     *time.Time `json:"refreshed_at,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+	LastErrorAt     *time.Time `json:"last_error_at,omitempty"`
+	RedirectURL     string     `json:"redirect_url"`
+}
+
+func SetDriveOAuth(baseURL string, tokenKey []byte) {
+	driveRedirectURL = strings.TrimRight(baseURL, "/") + "/oauth2callback"
+	driveTokenKey = tokenKey
+}
 
+func driveOAuthConfig() (*oauth2.Config, error) {
 	clientID := os.Getenv("CLIENT_ID")
 	clientSecret := os.Getenv("CLIENT_SECRET")
 	if clientID == "" || clientSecret == "" {
-		return "", fmt.Errorf("CLIENT_ID and CLIENT_SECRET are required for OAuth2")
+		return nil, fmt.Errorf("CLIENT_ID and CLIENT_SECRET are required for OAuth2")
 	}
-
-	tokenFile := os.Getenv("OAUTH_TOKEN_FILE")
-	if tokenFile == "" {
-		tokenFile = "./drive_token.json"
-	}
-
-	conf := &oauth2.Config{
+	return &oauth2.Config{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
 		Endpoint:     google.Endpoint,
 		Scopes:       []string{drive.DriveFileScope},
-		RedirectURL:  "http://localhost:8080/oauth2callback",
+		RedirectURL:  driveRedirectURL,
+	}, nil
+}
+
+func sealDriveToken(tok *oauth2.Token) (string, error) {
+	b, err := json.Marshal(tok)
+	if err != nil {
+		return "", err
 	}
+	sealed, err := backup.Encrypt(driveTokenKey, b)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
 
-	var token *oauth2.Token
-	if _, err := os.Stat(tokenFile); err == nil {
-		f, err := os.Open(tokenFile)
-		if err == nil {
-			json.NewDecoder(f).Decode(&token)
-			f.Close()
-		}
+func openDriveToken(s string) (*oauth2.Token, error) {
+	sealed, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
 	}
+	b, err := backup.Decrypt(driveTokenKey, sealed)
+	if err != nil {
+		return nil, err
+	}
+	var tok oauth2.Token
+	if err := json.Unmarshal(b, &tok); err != nil {
+		return nil, err
+	}
+	return &tok, nil
+}
 
-	if token == nil {
-		state := "state-token"
-		codeCh := make(chan string)
-		registerOAuthCallback(state, codeCh)
+func (s *driveTokenSource) reset() {
+	s.mu.Lock()
+	s.current = nil
+	s.mu.Unlock()
+}
+
+func (s *driveTokenSource) Token() (*oauth2.Token, error) {
+	return s.token(false)
+}
 
-		authURL := conf.AuthCodeURL(state, oauth2.AccessTypeOffline)
-		fmt.Printf("Visit the URL for the auth dialog: %s\n", authURL)
-		code := <-codeCh
+func (s *driveTokenSource) Refresh() (*oauth2.Token, error) {
+	return s.token(true)
+}
 
-		tok, err := conf.Exchange(ctx, code)
+func (s *driveTokenSource) token(force bool) (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if globalDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if s.current == nil {
+		stored, err := globalDB.GetOAuthToken(driveProvider)
 		if err != nil {
-			return "", err
+			return nil, errDriveNotConnected
 		}
-		token = tok
-		f, err := os.Create(tokenFile)
-		if err == nil {
-			json.NewEncoder(f).Encode(token)
-			f.Close()
+		tok, err := openDriveToken(stored.Token)
+		if err != nil {
+			return nil, fmt.Errorf("stored Drive token could not be decrypted: %v", err)
 		}
+		s.current = tok
+	}
+	if s.current.Valid() && !force {
+		return s.current, nil
 	}
 
-	client := conf.Client(ctx, token)
-	srv, err := drive.NewService(ctx, option.WithHTTPClient(client))
+	expired := *s.current
+	expired.Expiry = time.Now().Add(-time.Minute)
+	conf, err := driveOAuthConfig()
 	if err != nil {
-		return "", fmt.Errorf("failed to create drive service: %v", err)
+		return nil, err
 	}
-
-	inHashF, err := os.Open(dbPath)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	refreshed, err := conf.TokenSource(ctx, &expired).Token()
 	if err != nil {
-		return "", err
+		globalDB.RecordOAuthTokenError(driveProvider, err.Error())
+		return nil, fmt.Errorf("failed to refresh Drive token: %v", err)
 	}
-	defer inHashF.Close()
-	h := sha256.New()
-	if _, err := io.Copy(h, inHashF); err != nil {
-		return "", err
+	sealed, err := sealDriveToken(refreshed)
+	if err != nil {
+		return nil, err
+	}
+	if err := globalDB.UpdateOAuthTokenRefresh(driveProvider, sealed, refreshed.Expiry); err != nil {
+		log.Printf("failed to persist refreshed Drive token: %v", err)
 	}
-	curHash := fmt.Sprintf("%x", h.Sum(nil))
+	s.current = refreshed
+	return refreshed, nil
+}
 
-	hashFile := os.Getenv("BACKUP_HASH_FILE")
-	if hashFile == "" {
-		hashFile = "./last_backup.hash"
+func OAuthDriveTarget(folderID string) (backup.Target, error) {
+	if _, err := driveOAuthConfig(); err != nil {
+		return nil, err
 	}
-	if b, err := os.ReadFile(hashFile); err == nil {
-		if string(b) == curHash {
-			return "", nil
-		}
+	ctx := context.Background()
+	srv, err := drive.NewService(ctx, option.WithHTTPClient(oauth2.NewClient(ctx, driveTokens)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create drive service: %v", err)
 	}
+	return backup.NewDriveTarget("drive-oauth", srv, folderID)
+}
 
-	tmp := filepath.Join(os.TempDir(), filepath.Base(dbPath)+"."+time.Now().Format("20060102-150405")+".bak")
-	in, err := os.Open(dbPath)
+func ImportLegacyDriveToken(path string) error {
+	if globalDB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if _, err := globalDB.GetOAuthToken(driveProvider); err == nil {
+		return nil
+	}
+	f, err := os.Open(path)
 	if err != nil {
-		return "", err
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	var tok oauth2.Token
+	if err := json.NewDecoder(f).Decode(&tok); err != nil {
+		return fmt.Errorf("invalid legacy Drive token file: %v", err)
 	}
-	defer in.Close()
-	out, err := os.Create(tmp)
+	sealed, err := sealDriveToken(&tok)
 	if err != nil {
-		return "", err
+		return err
 	}
-	if _, err := io.Copy(out, in); err != nil {
-		out.Close()
-		return "", err
+	if err := globalDB.SaveOAuthToken(driveProvider, sealed, "legacy token file", tok.Expiry); err != nil {
+		return err
 	}
-	out.Close()
+	driveTokens.reset()
+	log.Printf("imported legacy Drive token from %s; the file can now be deleted", path)
+	return nil
+}
 
-	f, err := os.Open(tmp)
-	if err != nil {
-		return "", err
+func StartDriveTokenRefresher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		stored, err := globalDB.GetOAuthToken(driveProvider)
+		if err != nil {
+			continue
+		}
+		if stored.Expiry != nil && time.Until(*stored.Expiry) > 2*interval {
+			continue
+		}
+		if _, err := driveTokens.Refresh(); err != nil {
+			log.Printf("drive token refresh: %v", err)
+		}
 	}
-	defer f.Close()
+}
 
-	folder := os.Getenv("FOLDER_ID")
-	if folder == "" {
-		return "", fmt.Errorf("FOLDER_ID not set")
+func GetDriveStatusData(check bool) (*DriveStatusData, error) {
+	status := &DriveStatusData{RedirectURL: driveRedirectURL}
+	if _, err := driveOAuthConfig(); err == nil {
+		status.Configured = true
+	}
+	if globalDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if check {
+		if _, err := driveTokens.Refresh(); err != nil && !errors.Is(err, errDriveNotConnected) {
+			log.Printf("drive token check: %v", err)
+		}
 	}
-	file := &drive.File{Name: filepath.Base(tmp), Parents: []string{folder}}
-	uploaded, err := srv.Files.Create(file).Media(f).Do()
+	stored, err := globalDB.GetOAuthToken(driveProvider)
 	if err != nil {
+		return status, nil
+	}
+	status.Connected = true
+	status.ConnectedBy = stored.ConnectedBy
+	status.ConnectedAt = &stored.ConnectedAt
+	status.Expiry = stored.Expiry
+	status.RefreshedAt = stored.RefreshedAt
+	status.LastError = stored.LastError
+	status.LastErrorAt = stored.LastErrorAt
+	if tok, err := openDriveToken(stored.Token); err == nil {
+		status.HasRefreshToken = tok.RefreshToken != ""
+	} else {
+		status.LastError = "stored token could not be decrypted"
+	}
+	status.Healthy = status.Configured && status.HasRefreshToken && status.LastError == ""
+	return status, nil
+}
+
+func randomState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
-	_ = os.WriteFile(hashFile, []byte(curHash), 0644)
-	return uploaded.Id, nil
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
-var (
-	oauthCallbackChans = make(map[string]chan string)
-)
+func (ah *AdminHandler) DriveAuth(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/drive"), "/")
+	switch {
+	case action == "connect" && r.Method == http.MethodGet:
+		conf, err := driveOAuthConfig()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		state, err := randomState()
+		if err != nil {
+			http.Error(w, "Failed to start OAuth flow", http.StatusInternalServerError)
+			return
+		}
+		verifier := oauth2.GenerateVerifier()
+		driveFlowsMu.Lock()
+		for k, f := range driveFlows {
+			if time.Since(f.createdAt) > 10*time.Minute {
+				delete(driveFlows, k)
+			}
+		}
+		driveFlows[state] = driveAuthFlow{verifier: verifier, admin: email, createdAt: time.Now()}
+		driveFlowsMu.Unlock()
+		http.Redirect(w, r, conf.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce, oauth2.S256ChallengeOption(verifier)), http.StatusFound)
+	case action == "status" && (r.Method == http.MethodGet || r.Method == http.MethodPost):
+		status, err := GetDriveStatusData(r.Method == http.MethodPost)
+		if err != nil {
+			http.Error(w, "Failed to read Drive status", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": status})
+	case action == "disconnect" && r.Method == http.MethodPost:
+		if err := globalDB.DeleteOAuthToken(driveProvider); err != nil {
+			http.Error(w, "Failed to disconnect Drive", http.StatusInternalServerError)
+			return
+		}
+		driveTokens.reset()
+		log.Printf("drive disconnected by %s", email)
+		recordAudit(r, email, "drive.disconnect", "oauth_tokens", driveProvider, nil, nil)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
 
-func registerOAuthCallback(state string, ch chan string) {
-	oauthCallbackChans[state] = ch
+func DriveAuth(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.DriveAuth(w, r)
 }
 
 func HandleOAuth2Callback(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
 	state := r.FormValue("state")
-	ch, ok := oauthCallbackChans[state]
-	if !ok {
-		http.Error(w, "no pending oauth request", http.StatusBadRequest)
+	driveFlowsMu.Lock()
+	flow, ok := driveFlows[state]
+	delete(driveFlows, state)
+	driveFlowsMu.Unlock()
+	if !ok || state == "" || flow.admin != email || time.Since(flow.createdAt) > 10*time.Minute {
+		http.Error(w, "Invalid or expired OAuth state, start again from /admin/drive", http.StatusBadRequest)
+		return
+	}
+	if oauthErr := r.FormValue("error"); oauthErr != "" {
+		http.Redirect(w, r, "/admin/drive?error="+url.QueryEscape(oauthErr), http.StatusSeeOther)
+		return
+	}
+
+	conf, err := driveOAuthConfig()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	tok, err := conf.Exchange(ctx, r.FormValue("code"), oauth2.VerifierOption(flow.verifier))
+	if err != nil {
+		log.Printf("drive oauth exchange failed: %v", err)
+		http.Redirect(w, r, "/admin/drive?error="+url.QueryEscape("token exchange failed"), http.StatusSeeOther)
+		return
+	}
+	sealed, err := sealDriveToken(tok)
+	if err != nil {
+		http.Error(w, "Failed to store token", http.StatusInternalServerError)
 		return
 	}
-	if r.FormValue("state") != state {
-		http.Error(w, "state mismatch", http.StatusBadRequest)
+	if err := globalDB.SaveOAuthToken(driveProvider, sealed, email, tok.Expiry); err != nil {
+		http.Error(w, "Failed to store token", http.StatusInternalServerError)
 		return
 	}
-	code := r.FormValue("code")
-	fmt.Fprint(w, "ok")
-	ch <- code
-	delete(oauthCallbackChans, state)
+	driveTokens.reset()
+	log.Printf("drive connected by %s", email)
+	recordAudit(r, email, "drive.connect", "oauth_tokens", driveProvider, nil, map[string]interface{}{"expiry": tok.Expiry})
+	http.Redirect(w, r, "/admin/drive?connected=1", http.StatusSeeOther)
 }
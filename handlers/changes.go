@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var changeFeedToken string
+
+func SetChangeFeedToken(token string) {
+	changeFeedToken = token
+}
+
+func changeFeedAuthorized(r *http.Request) bool {
+	if auth := r.Header.Get("Authorization"); changeFeedToken != "" && strings.HasPrefix(auth, "Bearer ") {
+		token := strings.TrimPrefix(auth, "Bearer ")
+		return subtle.ConstantTimeCompare([]byte(token), []byte(changeFeedToken)) == 1
+	}
+	if globalAuthHandler == nil || !globalAuthHandler.isAuthenticated(r) {
+		return false
+	}
+	return IsAdminEmail(globalAuthHandler.getAuthenticatedUser(r))
+}
+
+func ChangeFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !changeFeedAuthorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if globalDB == nil {
+		http.Error(w, "Database not initialized", http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	since, _ := strconv.ParseInt(q.Get("since"), 10, 64)
+	limit := 100
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil && v > 0 && v <= 1000 {
+		limit = v
+	}
+	var tables []string
+	if v := q.Get("table"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tables = append(tables, t)
+			}
+		}
+	}
+
+	changes, err := globalDB.ListChanges(since, limit+1, tables)
+	if err != nil {
+		http.Error(w, "Failed to read changes", http.StatusInternalServerError)
+		return
+	}
+	hasMore := len(changes) > limit
+	if hasMore {
+		changes = changes[:limit]
+	}
+	if q.Get("include_rows") == "1" || q.Get("include_rows") == "true" {
+		for i := range changes {
+			if changes[i].Op == "delete" {
+				continue
+			}
+			row, err := globalDB.ChangeRow(changes[i].Table, changes[i].PK)
+			if err == nil {
+				changes[i].Row = row
+			}
+		}
+	}
+	next := since
+	if len(changes) > 0 {
+		next = changes[len(changes)-1].ID
+	}
+	_, latest, _ := globalDB.ChangeLogBounds()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":     changes,
+		"next":     next,
+		"has_more": hasMore,
+		"latest":   latest,
+	})
+}
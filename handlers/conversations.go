@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxConversationTurns = 6
+	conversationTTL      = 30 * time.Minute
+	maxConversations     = 5000
+	maxTurnLength        = 1000
+)
+
+type conversationTurn struct {
+	Question string
+	Answer   string
+}
+
+type conversation struct {
+	turns   []conversationTurn
+	updated time.Time
+}
+
+// Conversations are keyed by bot session as well as ID, so one cannot be
+// continued from another browser.
+var conversations = struct {
+	sync.Mutex
+	byKey map[string]*conversation
+}{byKey: map[string]*conversation{}}
+
+func conversationKey(session, id string) string {
+	return session + "|" + id
+}
+
+func newConversationID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func conversationHistory(session, id string) []conversationTurn {
+	if id == "" {
+		return nil
+	}
+	conversations.Lock()
+	defer conversations.Unlock()
+	c, ok := conversations.byKey[conversationKey(session, id)]
+	if !ok || time.Since(c.updated) > conversationTTL {
+		return nil
+	}
+	return append([]conversationTurn(nil), c.turns...)
+}
+
+// When the store is full expired conversations are swept first, then the
+// least recently used one is evicted.
+func addConversationTurn(session, id, question, answer string) {
+	conversations.Lock()
+	defer conversations.Unlock()
+	key := conversationKey(session, id)
+	c, ok := conversations.byKey[key]
+	if !ok || time.Since(c.updated) > conversationTTL {
+		if len(conversations.byKey) >= maxConversations {
+			evictConversations()
+		}
+		c = &conversation{}
+		conversations.byKey[key] = c
+	}
+	c.turns = append(c.turns, conversationTurn{Question: truncateTurn(question), Answer: truncateTurn(answer)})
+	if len(c.turns) > maxConversationTurns {
+		c.turns = c.turns[len(c.turns)-maxConversationTurns:]
+	}
+	c.updated = time.Now()
+}
+
+func evictConversations() {
+	var oldestKey string
+	var oldest time.Time
+	for key, c := range conversations.byKey {
+		if time.Since(c.updated) > conversationTTL {
+			delete(conversations.byKey, key)
+			continue
+		}
+		if oldestKey == "" || c.updated.Before(oldest) {
+			oldestKey, oldest = key, c.updated
+		}
+	}
+	if len(conversations.byKey) >= maxConversations && oldestKey != "" {
+		delete(conversations.byKey, oldestKey)
+	}
+}
+
+func truncateTurn(s string) string {
+	r := []rune(s)
+	if len(r) <= maxTurnLength {
+		return s
+	}
+	return string(r[:maxTurnLength]) + "…"
+}
+
+func conversationContext(turns []conversationTurn) string {
+	if len(turns) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("Conversation so far:\n")
+	for _, t := range turns {
+		fmt.Fprintf(&sb, "User: %s\nAssistant: %s\n", t.Question, t.Answer)
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// A follow-up such as "and for juniors?" says little on its own, so the
+// previous question is searched with it.
+func retrievalQuery(query string, turns []conversationTurn) string {
+	if len(turns) == 0 {
+		return query
+	}
+	return turns[len(turns)-1].Question + " " + query
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidConversationID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{newConversationID(), true},
+		{"0123456789abcdef01234567", true},
+		{"0123456789ABCDEF01234567", false},
+		{"0123456789abcdef0123456", false},
+		{"0123456789abcdef0123456z", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := validConversationID(tt.id); got != tt.want {
+			t.Errorf("validConversationID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestConversationHistory(t *testing.T) {
+	id := newConversationID()
+	for i := 0; i < maxConversationTurns+2; i++ {
+		addConversationTurn("session:a", id, strings.Repeat("q", i+1), "answer")
+	}
+	t.Cleanup(func() {
+		conversations.Lock()
+		delete(conversations.byKey, conversationKey("session:a", id))
+		conversations.Unlock()
+	})
+
+	tests := []struct {
+		name    string
+		session string
+		id      string
+		turns   int
+	}{
+		{"own conversation", "session:a", id, maxConversationTurns},
+		{"another session", "session:b", id, 0},
+		{"unknown id", "session:a", newConversationID(), 0},
+		{"no id", "session:a", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conversationHistory(tt.session, tt.id); len(got) != tt.turns {
+				t.Errorf("history has %d turns, want %d", len(got), tt.turns)
+			}
+		})
+	}
+
+	if turns := conversationHistory("session:a", id); turns[0].Question != "qqq" {
+		t.Errorf("oldest kept turn = %q, want the oldest ones dropped", turns[0].Question)
+	}
+
+	conversations.Lock()
+	conversations.byKey[conversationKey("session:a", id)].updated = time.Now().Add(-conversationTTL - time.Minute)
+	conversations.Unlock()
+	if got := conversationHistory("session:a", id); got != nil {
+		t.Errorf("expired conversation has %d turns", len(got))
+	}
+}
+
+func TestTruncateTurn(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{"short", "hello", 5},
+		{"at limit", strings.Repeat("a", maxTurnLength), maxTurnLength},
+		{"long", strings.Repeat("a", maxTurnLength+10), maxTurnLength + 1},
+		{"multibyte", strings.Repeat("é", maxTurnLength), maxTurnLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len([]rune(truncateTurn(tt.in))); got != tt.want {
+				t.Errorf("truncated to %d runes, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConversationPrompt(t *testing.T) {
+	turns := []conversationTurn{
+		{Question: "when is crossword", Answer: "On Saturday."},
+		{Question: "who can take part", Answer: "Classes 6 to 12."},
+	}
+	tests := []struct {
+		name      string
+		turns     []conversationTurn
+		context   string
+		retrieval string
+	}{
+		{"first question", nil, "", "and juniors?"},
+		{"follow-up", turns,
+			"Conversation so far:\nUser: when is crossword\nAssistant: On Saturday.\nUser: who can take part\nAssistant: Classes 6 to 12.\n\n",
+			"who can take part and juniors?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conversationContext(tt.turns); got != tt.context {
+				t.Errorf("conversationContext = %q, want %q", got, tt.context)
+			}
+			if got := retrievalQuery("and juniors?", tt.turns); got != tt.retrieval {
+				t.Errorf("retrievalQuery = %q, want %q", got, tt.retrieval)
+			}
+		})
+	}
+}
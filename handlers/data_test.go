@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"testing"
+
+	"exunreg25/datasync"
+)
+
+func TestRunSyncSkippedWhilePaused(t *testing.T) {
+	database := useTestDB(t)
+	target, err := datasync.NewCSVTarget(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		paused  bool
+		wantErr bool
+		runs    int
+	}{
+		{"paused", true, true, 0},
+		{"resumed", false, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.paused {
+				database.Pause()
+				defer database.Resume()
+			}
+			_, err := runSync(database, target, "test", false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("runSync = %v, want error %v", err, tt.wantErr)
+			}
+			runs, err := database.ListSyncRuns(10)
+			if err != nil || len(runs) != tt.runs {
+				t.Errorf("%d sync runs recorded, want %d (%v)", len(runs), tt.runs, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestDriveTokenSource(t *testing.T) {
+	database := useTestDB(t)
+	prevKey := driveTokenKey
+	driveTokenKey = bytes.Repeat([]byte{7}, 32)
+	t.Cleanup(func() { driveTokenKey = prevKey })
+
+	src := &driveTokenSource{}
+	if _, err := src.Token(); !errors.Is(err, errDriveNotConnected) {
+		t.Fatalf("Token before connecting = %v", err)
+	}
+
+	tok := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)}
+	sealed, err := sealDriveToken(tok)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains([]byte(sealed), []byte("refresh")) {
+		t.Fatal("sealed token contains the refresh token in clear text")
+	}
+	if err := database.SaveOAuthToken(driveProvider, sealed, "admin@exun.co", tok.Expiry); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := src.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AccessToken != "access" || got.RefreshToken != "refresh" {
+		t.Errorf("token = %+v", got)
+	}
+
+	src.reset()
+	driveTokenKey = bytes.Repeat([]byte{8}, 32)
+	if _, err := src.Token(); err == nil {
+		t.Error("a token sealed with another key was accepted")
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"exunreg25/db"
+	"exunreg25/eligibility"
+	"exunreg25/webhooks"
+)
+
+var legacyClassRange = regexp.MustCompile(`(\d{1,2}).*?(\d{1,2})`)
+
+// Events saved before rules existed are converted from open_to_all and
+// the eligibility string.
+func parseEventRules(ev *db.Event) (eligibility.Rules, error) {
+	var rules eligibility.Rules
+	if strings.TrimSpace(ev.EligibilityRules) != "" {
+		if err := json.Unmarshal([]byte(ev.EligibilityRules), &rules); err != nil {
+			return rules, fmt.Errorf("invalid eligibility rules: %v", err)
+		}
+		return rules, nil
+	}
+	if ev.OpenToAll {
+		return rules, nil
+	}
+	var bounds []int
+	if err := json.Unmarshal([]byte(ev.Eligibility), &bounds); err == nil && len(bounds) == 2 {
+		return eligibility.ClassRange(bounds[0], bounds[1]), nil
+	}
+	if m := legacyClassRange.FindStringSubmatch(ev.Eligibility); len(m) >= 3 {
+		minClass, _ := strconv.Atoi(m[1])
+		maxClass, _ := strconv.Atoi(m[2])
+		return eligibility.ClassRange(minClass, maxClass), nil
+	}
+	return rules, fmt.Errorf("eligibility must give a class range")
+}
+
+func eventRules(ev *db.Event) eligibility.Rules {
+	rules, _ := parseEventRules(ev)
+	return rules
+}
+
+func setEventRules(ev *db.Event, rules eligibility.Rules) {
+	data, _ := json.Marshal(rules)
+	ev.EligibilityRules = string(data)
+	ev.OpenToAll = rules.OpenToAll()
+	ev.Eligibility = rules.Describe()
+}
+
+func withClassRange(rules eligibility.Rules, minClass, maxClass int) eligibility.Rules {
+	rules.Member.Classes = eligibility.ClassRange(minClass, maxClass).Member.Classes
+	return rules
+}
+
+func eligibilityMembers(participants []Participant) []eligibility.Member {
+	members := make([]eligibility.Member, 0, len(participants))
+	for _, p := range participants {
+		members = append(members, eligibility.Member{Class: p.Class, Gender: p.Gender})
+	}
+	return members
+}
+
+func validGender(g string) bool {
+	for _, known := range eligibility.Genders {
+		if g == known {
+			return true
+		}
+	}
+	return false
+}
+
+type EligibilityRequest struct {
+	Rules        eligibility.Rules `json:"rules"`
+	Participants []Participant     `json:"participants"`
+}
+
+func (ah *AdminHandler) EventEligibility(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	eventID := strings.TrimPrefix(r.URL.Path, "/api/admin/events/eligibility/")
+	item, err := ah.db.Get("events", eventID)
+	if err != nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+	ev := item.(*db.Event)
+
+	switch r.Method {
+	case http.MethodGet:
+		rules := eventRules(ev)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"data": map[string]interface{}{
+				"rules":       rules,
+				"description": rules.Describe(),
+				"genders":     eligibility.Genders,
+			},
+		})
+	case http.MethodPut, http.MethodPost:
+		var req EligibilityRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := req.Rules.Validate(ev.Participants); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		problems := []string{}
+		if len(req.Participants) > 0 {
+			problems = append(problems, req.Rules.CheckTeam(eligibilityMembers(req.Participants))...)
+		}
+
+		dryRun := r.URL.Query().Get("dry_run") == "1"
+		if !dryRun {
+			before := *ev
+			setEventRules(ev, req.Rules)
+			if err := ah.db.Update("events", ev.ID, ev); err != nil {
+				http.Error(w, "Failed to update eligibility", http.StatusInternalServerError)
+				return
+			}
+			recordAudit(r, email, "event.eligibility", "events", ev.ID, &before, ev)
+			emitWebhook(webhooks.EventEventUpdated, eventWebhookData(ev, "updated", email))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"saved":   !dryRun,
+			"data": map[string]interface{}{
+				"rules":       req.Rules,
+				"description": req.Rules.Describe(),
+				"problems":    problems,
+			},
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func EventEligibility(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.EventEligibility(w, r)
+}
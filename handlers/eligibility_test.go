@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"testing"
+
+	"exunreg25/db"
+	"exunreg25/eligibility"
+)
+
+func TestParseEventRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		event    db.Event
+		describe string
+		wantErr  bool
+	}{
+		{"stored rules", db.Event{EligibilityRules: `{"member":{"genders":["female"]}}`, Eligibility: "Grades 6–12"}, "Grades 1–12; female participants only", false},
+		{"open to all", db.Event{OpenToAll: true, Eligibility: "Grades 6–12"}, "Open to all", false},
+		{"legacy json", db.Event{Eligibility: "[9,12]"}, "Grades 9–12", false},
+		{"legacy text", db.Event{Eligibility: "Grades 6 to 8"}, "Grades 6–8", false},
+		{"full legacy range", db.Event{Eligibility: "Grades 1–12"}, "Open to all", false},
+		{"no range", db.Event{Eligibility: "everyone"}, "", true},
+		{"bad stored rules", db.Event{EligibilityRules: `{"member":`}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseEventRules(&tt.event)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseEventRules = %+v, want an error", rules)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rules.Describe(); got != tt.describe {
+				t.Errorf("Describe() = %q, want %q", got, tt.describe)
+			}
+		})
+	}
+}
+
+func TestSetEventRules(t *testing.T) {
+	ev := &db.Event{Eligibility: "Grades 6–12"}
+	rules := withClassRange(eventRules(ev), 6, 8)
+	rules.Member.Genders = []string{"female"}
+	setEventRules(ev, rules)
+	if ev.OpenToAll || ev.Eligibility != "Grades 6–8; female participants only" {
+		t.Errorf("event = %+v", ev)
+	}
+	if back := eventRules(ev); back.Describe() != ev.Eligibility {
+		t.Errorf("stored rules describe as %q", back.Describe())
+	}
+
+	setEventRules(ev, eligibility.Rules{})
+	if !ev.OpenToAll || ev.Eligibility != "Open to all" {
+		t.Errorf("open event = %+v", ev)
+	}
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"exunreg25/mail"
+)
+
+type EmailTemplateRequest struct {
+	Body    string `json:"body"`
+	Version int    `json:"version"`
+}
+
+func (ah *AdminHandler) EmailTemplates(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/email-templates"), "/")
+	if path == "" {
+		ah.listEmailTemplates(w, r)
+		return
+	}
+
+	parts := strings.SplitN(path, "/", 2)
+	name := parts[0]
+	if !mail.IsTemplateName(name) {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch action {
+	case "":
+		if r.Method == http.MethodGet {
+			ah.getEmailTemplateVersions(w, name)
+			return
+		}
+		if r.Method == http.MethodPost || r.Method == http.MethodPut {
+			ah.saveEmailTemplate(w, r, name, email)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	case "preview":
+		ah.previewEmailTemplate(w, r, name)
+	case "rollback":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ah.rollbackEmailTemplate(w, r, name)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func (ah *AdminHandler) listEmailTemplates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	out := []map[string]interface{}{}
+	for _, name := range mail.TemplateNames {
+		versions, err := ah.db.ListEmailTemplateVersions(name)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		activeVersion := 0
+		for _, v := range versions {
+			if v.Active {
+				activeVersion = v.Version
+				break
+			}
+		}
+		out = append(out, map[string]interface{}{
+			"name":           name,
+			"active_version": activeVersion,
+			"versions":       len(versions),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": out})
+}
+
+func (ah *AdminHandler) getEmailTemplateVersions(w http.ResponseWriter, name string) {
+	versions, err := ah.db.ListEmailTemplateVersions(name)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"name": name, "data": versions})
+}
+
+func (ah *AdminHandler) saveEmailTemplate(w http.ResponseWriter, r *http.Request, name, author string) {
+	var req EmailTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		http.Error(w, "Template body required", http.StatusBadRequest)
+		return
+	}
+	if err := mail.ValidateTemplate(name, req.Body); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	previous, _ := ah.db.ActiveEmailTemplate(name)
+	tmpl, err := ah.db.CreateEmailTemplateVersion(name, req.Body, author)
+	if err != nil {
+		http.Error(w, "Failed to save template", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, author, "email_template.save", "email_templates", name,
+		map[string]interface{}{"body": previous},
+		map[string]interface{}{"body": req.Body, "version": tmpl.Version})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": tmpl})
+}
+
+func (ah *AdminHandler) previewEmailTemplate(w http.ResponseWriter, r *http.Request, name string) {
+	var req EmailTemplateRequest
+	if r.Method == http.MethodPost && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	body := req.Body
+	if body == "" && req.Version > 0 {
+		tmpl, err := ah.db.GetEmailTemplateVersion(name, req.Version)
+		if err != nil {
+			http.Error(w, "Template version not found", http.StatusNotFound)
+			return
+		}
+		body = tmpl.Body
+	}
+	if body == "" {
+		active, err := ah.db.ActiveEmailTemplate(name)
+		if err != nil {
+			active, err = mail.DefaultTemplate(name)
+			if err != nil {
+				http.Error(w, "Template not available", http.StatusInternalServerError)
+				return
+			}
+		}
+		body = active
+	}
+	html, err := mail.RenderTemplatePreview(name, body)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(html))
+}
+
+func (ah *AdminHandler) rollbackEmailTemplate(w http.ResponseWriter, r *http.Request, name string) {
+	var req EmailTemplateRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	target := req.Version
+	if target == 0 {
+		versions, err := ah.db.ListEmailTemplateVersions(name)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		for i, v := range versions {
+			if v.Active && i+1 < len(versions) {
+				target = versions[i+1].Version
+				break
+			}
+		}
+		if target == 0 {
+			http.Error(w, "No previous version to roll back to", http.StatusConflict)
+			return
+		}
+	}
+	if err := ah.db.ActivateEmailTemplateVersion(name, target); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recordAudit(r, globalAuthHandler.getAuthenticatedUser(r), "email_template.rollback", "email_templates", name, nil, map[string]interface{}{"version": target})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "name": name, "active_version": target})
+}
+
+func EmailTemplates(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.EmailTemplates(w, r)
+}
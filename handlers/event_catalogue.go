@@ -0,0 +1,643 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"exunreg25/db"
+	"exunreg25/eligibility"
+	"exunreg25/regform"
+	"exunreg25/webhooks"
+)
+
+// Missing or zero fields in an EventRecord keep the existing event's values.
+type EventRecord struct {
+	Slug                    string `json:"slug"`
+	Name                    string `json:"name"`
+	Image                   string `json:"image"`
+	Category                string `json:"category"`
+	DisplayOrder            int    `json:"display_order"`
+	Mode                    string `json:"mode"`
+	Participants            int    `json:"participants"`
+	TeamsPerSchool          int    `json:"teams_per_school"`
+	MinClass                int    `json:"min_class"`
+	MaxClass                int    `json:"max_class"`
+	OpenToAll               bool   `json:"open_to_all"`
+	IndependentRegistration bool   `json:"independent_registration"`
+	Points                  int    `json:"points"`
+	Dates                   string `json:"dates"`
+	DescriptionShort        string `json:"description_short"`
+	DescriptionLong         string `json:"description_long"`
+	Archived                *bool  `json:"archived,omitempty"`
+
+	EligibilityRules *eligibility.Rules `json:"eligibility_rules,omitempty"`
+	FormFields       *regform.Schema    `json:"form_fields,omitempty"`
+}
+
+var eventCatalogueColumns = []string{
+	"slug", "name", "image", "category", "display_order", "mode", "participants", "teams_per_school", "min_class", "max_class",
+	"open_to_all", "independent_registration", "points", "dates", "description_short", "description_long", "archived", "eligibility_rules", "form_fields",
+}
+
+type EventImportRow struct {
+	Row     int                               `json:"row"`
+	Slug    string                            `json:"slug"`
+	Status  string                            `json:"status"`
+	Errors  []string                          `json:"errors,omitempty"`
+	Changes map[string]map[string]interface{} `json:"changes,omitempty"`
+}
+
+type EventImportReport struct {
+	Rows           []EventImportRow `json:"rows"`
+	Summary        map[string]int   `json:"summary"`
+	NotInCatalogue []string         `json:"not_in_catalogue"`
+	DryRun         bool             `json:"dry_run"`
+	Applied        bool             `json:"applied"`
+	Created        int              `json:"created"`
+	Updated        int              `json:"updated"`
+}
+
+func eventRecordFrom(ev *db.Event) EventRecord {
+	rules := eventRules(ev)
+	minClass, maxClass := rules.Classes()
+	fields := eventFormFields(ev)
+	archived := ev.ArchivedAt != nil
+	return EventRecord{
+		Slug:                    ev.ID,
+		Name:                    ev.Name,
+		Image:                   ev.Image,
+		Category:                ev.Category,
+		DisplayOrder:            ev.DisplayOrder,
+		Mode:                    ev.Mode,
+		Participants:            ev.Participants,
+		TeamsPerSchool:          teamsPerSchool(ev),
+		MinClass:                minClass,
+		MaxClass:                maxClass,
+		OpenToAll:               ev.OpenToAll,
+		IndependentRegistration: ev.IndependentRegistration,
+		Points:                  ev.Points,
+		Dates:                   ev.Dates,
+		DescriptionShort:        ev.DescriptionShort,
+		DescriptionLong:         ev.DescriptionLong,
+		Archived:                &archived,
+		EligibilityRules:        &rules,
+		FormFields:              &fields,
+	}
+}
+
+func (rec *EventRecord) toEvent(existing *db.Event) db.Event {
+	var ev db.Event
+	if existing != nil {
+		ev = *existing
+	}
+	ev.ID = rec.Slug
+	ev.Name = rec.Name
+	ev.Image = rec.Image
+	ev.Category = rec.Category
+	if rec.DisplayOrder != 0 {
+		ev.DisplayOrder = rec.DisplayOrder
+	}
+	ev.Mode = rec.Mode
+	ev.Participants = rec.Participants
+	if rec.TeamsPerSchool != 0 {
+		ev.TeamsPerSchool = rec.TeamsPerSchool
+	} else if ev.TeamsPerSchool == 0 {
+		ev.TeamsPerSchool = 1
+	}
+	switch {
+	case rec.EligibilityRules != nil:
+		setEventRules(&ev, *rec.EligibilityRules)
+	case rec.OpenToAll:
+		setEventRules(&ev, eligibility.Rules{})
+	default:
+		setEventRules(&ev, withClassRange(eventRules(&ev), rec.MinClass, rec.MaxClass))
+	}
+	ev.IndependentRegistration = rec.IndependentRegistration
+	ev.Points = rec.Points
+	ev.Dates = rec.Dates
+	ev.DescriptionShort = rec.DescriptionShort
+	ev.DescriptionLong = rec.DescriptionLong
+	if rec.FormFields != nil {
+		setEventFormFields(&ev, *rec.FormFields)
+	}
+	if rec.Archived != nil {
+		if !*rec.Archived {
+			ev.ArchivedAt = nil
+		} else if ev.ArchivedAt == nil {
+			now := time.Now()
+			ev.ArchivedAt = &now
+		}
+	}
+	return ev
+}
+
+func eventRecordChanges(before, after EventRecord) map[string]map[string]interface{} {
+	changes := map[string]map[string]interface{}{}
+	bv, av := reflect.ValueOf(before), reflect.ValueOf(after)
+	t := bv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		b, a := bv.Field(i).Interface(), av.Field(i).Interface()
+		if bp, ok := b.(*bool); ok {
+			ap := a.(*bool)
+			b, a = bp != nil && *bp, ap != nil && *ap
+		}
+		if !reflect.DeepEqual(b, a) {
+			changes[name] = map[string]interface{}{"before": b, "after": a}
+		}
+	}
+	return changes
+}
+
+func parseCatalogueBool(v string) (*bool, error) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "":
+		return nil, nil
+	case "true", "1", "yes", "y":
+		b := true
+		return &b, nil
+	case "false", "0", "no", "n":
+		b := false
+		return &b, nil
+	}
+	return nil, fmt.Errorf("%q is not true or false", v)
+}
+
+func parseEventCSV(data []byte) ([]EventRecord, map[int][]string, error) {
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.FieldsPerRecord = -1
+	lines, err := cr.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CSV: %v", err)
+	}
+	if len(lines) == 0 {
+		return nil, nil, fmt.Errorf("CSV has no header row")
+	}
+	index := map[string]int{}
+	for i, h := range lines[0] {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		known := false
+		for _, c := range eventCatalogueColumns {
+			if c == h {
+				known = true
+			}
+		}
+		if !known {
+			return nil, nil, fmt.Errorf("unknown column %q", h)
+		}
+		index[h] = i
+	}
+	if _, ok := index["name"]; !ok {
+		return nil, nil, fmt.Errorf("CSV must have a name column")
+	}
+
+	records := []EventRecord{}
+	rowErrors := map[int][]string{}
+	for n, line := range lines[1:] {
+		raw := func(col string) string {
+			if i, ok := index[col]; ok && i < len(line) {
+				return line[i]
+			}
+			return ""
+		}
+		cell := func(col string) string { return strings.TrimSpace(raw(col)) }
+		num := func(col string) int {
+			v := cell(col)
+			if v == "" {
+				return 0
+			}
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				rowErrors[n] = append(rowErrors[n], fmt.Sprintf("%s: %q is not a number", col, v))
+			}
+			return i
+		}
+		flag := func(col string) *bool {
+			b, err := parseCatalogueBool(cell(col))
+			if err != nil {
+				rowErrors[n] = append(rowErrors[n], col+": "+err.Error())
+			}
+			return b
+		}
+		rec := EventRecord{
+			Slug:             cell("slug"),
+			Name:             cell("name"),
+			Image:            cell("image"),
+			Category:         cell("category"),
+			DisplayOrder:     num("display_order"),
+			Mode:             cell("mode"),
+			Participants:     num("participants"),
+			TeamsPerSchool:   num("teams_per_school"),
+			MinClass:         num("min_class"),
+			MaxClass:         num("max_class"),
+			Points:           num("points"),
+			Dates:            cell("dates"),
+			DescriptionShort: raw("description_short"),
+			DescriptionLong:  raw("description_long"),
+			Archived:         flag("archived"),
+		}
+		if v := cell("eligibility_rules"); v != "" {
+			var rules eligibility.Rules
+			if err := json.Unmarshal([]byte(v), &rules); err != nil {
+				rowErrors[n] = append(rowErrors[n], "eligibility_rules: "+err.Error())
+			} else {
+				rec.EligibilityRules = &rules
+			}
+		}
+		if v := cell("form_fields"); v != "" {
+			var schema regform.Schema
+			if err := json.Unmarshal([]byte(v), &schema); err != nil {
+				rowErrors[n] = append(rowErrors[n], "form_fields: "+err.Error())
+			} else {
+				rec.FormFields = &schema
+			}
+		}
+		if b := flag("open_to_all"); b != nil {
+			rec.OpenToAll = *b
+		}
+		rec.IndependentRegistration = true
+		if b := flag("independent_registration"); b != nil {
+			rec.IndependentRegistration = *b
+		}
+		records = append(records, rec)
+	}
+	return records, rowErrors, nil
+}
+
+// legacyEventCatalogue reads the original events.json layout.
+func legacyEventCatalogue(data []byte) ([]EventRecord, error) {
+	var raw struct {
+		Events  json.RawMessage `json:"events"`
+		Default struct {
+			OpenToAll    bool   `json:"open_to_all"`
+			Eligibility  []int  `json:"eligibility"`
+			Participants int    `json:"participants"`
+			Mode         string `json:"mode"`
+			Descriptions struct {
+				Long  string `json:"long"`
+				Short string `json:"short"`
+			} `json:"descriptions"`
+			IndependentRegistrations bool   `json:"independent_registrations"`
+			Points                   int    `json:"points"`
+			Dates                    string `json:"dates"`
+		} `json:"default"`
+		Descriptions map[string]struct {
+			Long  string `json:"long"`
+			Short string `json:"short"`
+		} `json:"descriptions"`
+		Participants map[string]int    `json:"participants"`
+		Mode         map[string]string `json:"mode"`
+		Points       map[string]int    `json:"points"`
+		Individual   map[string]bool   `json:"individual"`
+		Eligibility  map[string][]int  `json:"eligibility"`
+		OpenToAll    map[string]bool   `json:"open_to_all"`
+		Categories   map[string]string `json:"categories"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	// Read token by token: the key order is the display order.
+	dec := json.NewDecoder(bytes.NewReader(raw.Events))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("events must be an object of name to image")
+	}
+	records := []EventRecord{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		name, _ := tok.(string)
+		var image string
+		if err := dec.Decode(&image); err != nil {
+			return nil, fmt.Errorf("image for %s: %v", name, err)
+		}
+
+		rec := EventRecord{
+			Slug:                    slugify(name),
+			Name:                    name,
+			Image:                   image,
+			Category:                raw.Categories[name],
+			Mode:                    raw.Default.Mode,
+			Participants:            raw.Default.Participants,
+			OpenToAll:               raw.Default.OpenToAll,
+			IndependentRegistration: raw.Default.IndependentRegistrations,
+			Points:                  raw.Default.Points,
+			Dates:                   raw.Default.Dates,
+			DescriptionShort:        raw.Default.Descriptions.Short,
+			DescriptionLong:         raw.Default.Descriptions.Long,
+		}
+		if len(raw.Default.Eligibility) >= 2 {
+			rec.MinClass, rec.MaxClass = raw.Default.Eligibility[0], raw.Default.Eligibility[1]
+		}
+		if v, ok := raw.Participants[name]; ok {
+			rec.Participants = v
+		}
+		if v, ok := raw.Mode[name]; ok {
+			rec.Mode = v
+		}
+		if v, ok := raw.Points[name]; ok {
+			rec.Points = v
+		}
+		if v, ok := raw.Individual[name]; ok {
+			rec.IndependentRegistration = v
+		}
+		if v, ok := raw.OpenToAll[name]; ok {
+			rec.OpenToAll = v
+		}
+		if v, ok := raw.Eligibility[name]; ok && len(v) >= 2 {
+			rec.MinClass, rec.MaxClass = v[0], v[1]
+		}
+		if v, ok := raw.Descriptions[name]; ok {
+			if v.Short != "" {
+				rec.DescriptionShort = v.Short
+			}
+			if v.Long != "" {
+				rec.DescriptionLong = v.Long
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func parseEventJSON(data []byte) ([]EventRecord, error) {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var records []EventRecord
+		err := json.Unmarshal(trimmed, &records)
+		return records, err
+	}
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &probe); err != nil {
+		return nil, err
+	}
+	if _, ok := probe["default"]; ok {
+		return legacyEventCatalogue(trimmed)
+	}
+	var catalogue struct {
+		Events []EventRecord `json:"events"`
+	}
+	if err := json.Unmarshal(trimmed, &catalogue); err != nil {
+		return nil, err
+	}
+	return catalogue.Events, nil
+}
+
+// With no upload, the bundled frontend/data/events.json is imported.
+func readEventCatalogue(r *http.Request) ([]EventRecord, map[int][]string, error) {
+	var data []byte
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, nil, fmt.Errorf("missing file")
+		}
+		defer file.Close()
+		if data, err = io.ReadAll(io.LimitReader(file, 5<<20)); err != nil {
+			return nil, nil, err
+		}
+		if format == "" && strings.HasSuffix(strings.ToLower(header.Filename), ".csv") {
+			format = "csv"
+		}
+	} else {
+		var err error
+		if data, err = io.ReadAll(io.LimitReader(r.Body, 5<<20)); err != nil {
+			return nil, nil, err
+		}
+		if format == "" && strings.Contains(r.Header.Get("Content-Type"), "csv") {
+			format = "csv"
+		}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		b, err := os.ReadFile("frontend/data/events.json")
+		if err != nil {
+			return nil, nil, fmt.Errorf("no catalogue uploaded and events.json is unreadable: %v", err)
+		}
+		data, format = b, "json"
+	}
+	if format == "csv" {
+		return parseEventCSV(data)
+	}
+	records, err := parseEventJSON(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON catalogue: %v", err)
+	}
+	return records, map[int][]string{}, nil
+}
+
+// Nothing is applied unless every row is valid.
+func (ah *AdminHandler) ImportEvents(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	records, rowErrors, err := readEventCatalogue(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	current, err := getAllEventsData(true)
+	if err != nil {
+		http.Error(w, "Failed to load current events", http.StatusInternalServerError)
+		return
+	}
+	existing := map[string]*db.Event{}
+	nextOrder := 1
+	for i := range current {
+		existing[current[i].ID] = &current[i]
+		if current[i].DisplayOrder >= nextOrder {
+			nextOrder = current[i].DisplayOrder + 1
+		}
+	}
+
+	report := EventImportReport{
+		Rows:           []EventImportRow{},
+		Summary:        map[string]int{"create": 0, "update": 0, "unchanged": 0, "invalid": 0},
+		NotInCatalogue: []string{},
+		DryRun:         r.URL.Query().Get("dry_run") == "1" || r.URL.Query().Get("dry_run") == "true",
+	}
+	var toSave []db.Event
+	var before []*db.Event
+	seen := map[string]int{}
+	for i := range records {
+		rec := &records[i]
+		rec.Name = strings.TrimSpace(rec.Name)
+		rec.Slug = strings.TrimSpace(rec.Slug)
+		if rec.Slug == "" {
+			rec.Slug = slugify(rec.Name)
+		}
+		row := EventImportRow{Row: i + 1, Slug: rec.Slug, Errors: rowErrors[i]}
+
+		old := existing[rec.Slug]
+		ev := rec.toEvent(old)
+		if old == nil && ev.DisplayOrder == 0 {
+			ev.DisplayOrder = nextOrder
+			nextOrder++
+		}
+		if msg := validateEvent(&ev); msg != "" {
+			row.Errors = append(row.Errors, msg)
+		}
+		if first, dup := seen[rec.Slug]; dup {
+			row.Errors = append(row.Errors, fmt.Sprintf("duplicate of row %d", first))
+		} else {
+			seen[rec.Slug] = row.Row
+		}
+
+		switch {
+		case len(row.Errors) > 0:
+			row.Status = "invalid"
+		case old == nil:
+			row.Status = "create"
+			row.Changes = eventRecordChanges(EventRecord{}, eventRecordFrom(&ev))
+		default:
+			row.Changes = eventRecordChanges(eventRecordFrom(old), eventRecordFrom(&ev))
+			if len(row.Changes) == 0 {
+				row.Status = "unchanged"
+				row.Changes = nil
+			} else {
+				row.Status = "update"
+			}
+		}
+		report.Summary[row.Status]++
+		report.Rows = append(report.Rows, row)
+		if row.Status == "create" || row.Status == "update" {
+			toSave = append(toSave, ev)
+			before = append(before, old)
+		}
+	}
+	for _, ev := range current {
+		if _, ok := seen[ev.ID]; !ok {
+			report.NotInCatalogue = append(report.NotInCatalogue, ev.ID)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.DryRun {
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+	if report.Summary["invalid"] > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	if err := ah.db.SaveEvents(toSave); err != nil {
+		log.Printf("event import by %s failed: %v", email, err)
+		http.Error(w, "Failed to apply catalogue: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	report.Applied = true
+	report.Created = report.Summary["create"]
+	report.Updated = report.Summary["update"]
+
+	for i := range toSave {
+		action := "created"
+		var prev interface{}
+		if before[i] != nil {
+			action, prev = "updated", before[i]
+		}
+		recordAudit(r, email, "event.import", "events", toSave[i].ID, prev, &toSave[i])
+		emitWebhook(webhooks.EventEventUpdated, eventWebhookData(&toSave[i], action, email))
+	}
+	recordAudit(r, email, "events.import", "events", "", nil, map[string]interface{}{"created": report.Created, "updated": report.Updated, "unchanged": report.Summary["unchanged"]})
+
+	json.NewEncoder(w).Encode(report)
+}
+
+func (ah *AdminHandler) ExportEvents(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	events, err := getAllEventsData(true)
+	if err != nil {
+		http.Error(w, "Failed to load events", http.StatusInternalServerError)
+		return
+	}
+	records := make([]EventRecord, 0, len(events))
+	for i := range events {
+		records = append(records, eventRecordFrom(&events[i]))
+	}
+
+	name := "events-" + time.Now().Format("20060102-150405")
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.csv"`)
+		cw := csv.NewWriter(w)
+		cw.Write(eventCatalogueColumns)
+		for _, rec := range records {
+			rules, _ := json.Marshal(rec.EligibilityRules)
+			fields, _ := json.Marshal(rec.FormFields)
+			cw.Write([]string{
+				rec.Slug,
+				rec.Name,
+				rec.Image,
+				rec.Category,
+				strconv.Itoa(rec.DisplayOrder),
+				rec.Mode,
+				strconv.Itoa(rec.Participants),
+				strconv.Itoa(rec.TeamsPerSchool),
+				strconv.Itoa(rec.MinClass),
+				strconv.Itoa(rec.MaxClass),
+				strconv.FormatBool(rec.OpenToAll),
+				strconv.FormatBool(rec.IndependentRegistration),
+				strconv.Itoa(rec.Points),
+				rec.Dates,
+				rec.DescriptionShort,
+				rec.DescriptionLong,
+				strconv.FormatBool(rec.Archived != nil && *rec.Archived),
+				string(rules),
+				string(fields),
+			})
+		}
+		cw.Flush()
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.json"`)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(map[string]interface{}{"events": records})
+}
+
+func ExportEvents(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.ExportEvents(w, r)
+}
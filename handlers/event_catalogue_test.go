@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"exunreg25/eligibility"
+)
+
+func TestParseCatalogueBool(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    *bool
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"yes", boolPtr(true), false},
+		{" TRUE ", boolPtr(true), false},
+		{"0", boolPtr(false), false},
+		{"n", boolPtr(false), false},
+		{"maybe", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseCatalogueBool(tt.in)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCatalogueBool(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestParseEventCSV(t *testing.T) {
+	tests := []struct {
+		name      string
+		csv       string
+		wantErr   string
+		records   int
+		rowErrors map[int]int
+	}{
+		{
+			name:    "valid",
+			csv:     "\ufeffslug,name,participants,min_class,max_class,archived\nquiz,Quiz,2,6,12,\ncubing,Cubing,1,1,12,yes\n",
+			records: 2,
+		},
+		{
+			name:      "bad cells",
+			csv:       "slug,name,participants,open_to_all\nquiz,Quiz,two,perhaps\ncubing,Cubing,1,no\n",
+			records:   2,
+			rowErrors: map[int]int{0: 2},
+		},
+		{name: "unknown column", csv: "slug,name,colour\n", wantErr: "unknown column"},
+		{name: "no name column", csv: "slug,mode\n", wantErr: "name column"},
+		{name: "empty", csv: "", wantErr: "header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, rowErrors, err := parseEventCSV([]byte(tt.csv))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != tt.records {
+				t.Fatalf("records = %+v", records)
+			}
+			counts := map[int]int{}
+			for row, errs := range rowErrors {
+				counts[row] = len(errs)
+			}
+			if len(counts) == 0 {
+				counts = nil
+			}
+			if !reflect.DeepEqual(counts, tt.rowErrors) {
+				t.Errorf("row errors = %v", rowErrors)
+			}
+		})
+	}
+
+	records, _, _ := parseEventCSV([]byte("slug,name,participants,min_class,max_class,archived\ncubing,Cubing,1,1,12,yes\n"))
+	rec := records[0]
+	if rec.Slug != "cubing" || rec.Participants != 1 || rec.MinClass != 1 || rec.MaxClass != 12 ||
+		!rec.IndependentRegistration || rec.Archived == nil || !*rec.Archived {
+		t.Errorf("record = %+v", rec)
+	}
+}
+
+func TestParseEventJSON(t *testing.T) {
+	bundled, err := os.ReadFile("../frontend/data/events.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		json  string
+		slugs []string
+	}{
+		{"array", `[{"slug":"quiz","name":"Quiz"}]`, []string{"quiz"}},
+		{"wrapped", `{"events":[{"slug":"quiz","name":"Quiz"},{"slug":"cubing","name":"Cubing"}]}`, []string{"quiz", "cubing"}},
+		{"empty", `{"events":[]}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := parseEventJSON([]byte(tt.json))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var slugs []string
+			for _, rec := range records {
+				slugs = append(slugs, rec.Slug)
+			}
+			if !reflect.DeepEqual(slugs, tt.slugs) {
+				t.Errorf("slugs = %v, want %v", slugs, tt.slugs)
+			}
+		})
+	}
+
+	records, err := parseEventJSON(bundled)
+	if err != nil {
+		t.Fatalf("bundled events.json: %v", err)
+	}
+	if len(records) == 0 {
+		t.Fatal("bundled events.json gave no records")
+	}
+	for _, rec := range records {
+		if rec.Name == "" || rec.Slug == "" || rec.Participants < 1 {
+			t.Errorf("bundled record = %+v", rec)
+		}
+	}
+	if _, err := parseEventJSON([]byte(`"quiz"`)); err == nil {
+		t.Error("a JSON string parsed as a catalogue")
+	}
+}
+
+func TestEventRecordRoundTrip(t *testing.T) {
+	ev := validEvent()
+	ev.DisplayOrder = 4
+	rec := eventRecordFrom(ev)
+	if rec.MinClass != 6 || rec.MaxClass != 12 || rec.Archived == nil || *rec.Archived {
+		t.Fatalf("record = %+v", rec)
+	}
+
+	edited := rec
+	edited.Name = "Quiz Night"
+	edited.MaxClass = 10
+	edited.DisplayOrder = 0
+	edited.Archived = boolPtr(true)
+	edited.EligibilityRules = nil
+	back := edited.toEvent(ev)
+	if back.Name != "Quiz Night" || back.Eligibility != "Grades 6–10" || back.DisplayOrder != 4 || back.ArchivedAt == nil {
+		t.Errorf("event = %+v", back)
+	}
+
+	changes := eventRecordChanges(rec, edited)
+	var fields []string
+	for f := range changes {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	if want := []string{"archived", "display_order", "eligibility_rules", "max_class", "name"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("changed fields = %v, want %v", fields, want)
+	}
+
+	withRules := rec
+	withRules.MaxClass = 10
+	withRules.EligibilityRules = &eligibility.Rules{Member: eligibility.Condition{Genders: []string{"female"}}}
+	if back := withRules.toEvent(ev); back.Eligibility != "Grades 1–12; female participants only" {
+		t.Errorf("eligibility_rules did not take precedence: %q", back.Eligibility)
+	}
+}
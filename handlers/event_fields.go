@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"exunreg25/db"
+	"exunreg25/regform"
+	"exunreg25/webhooks"
+)
+
+const (
+	registrationFilesDir = "data/registration_files"
+	maxRegistrationFile  = 5 << 20
+)
+
+var registrationFileTypes = map[string]bool{
+	".pdf": true, ".png": true, ".jpg": true, ".jpeg": true, ".zip": true, ".txt": true, ".md": true,
+}
+
+func eventFormFields(ev *db.Event) regform.Schema {
+	var schema regform.Schema
+	if strings.TrimSpace(ev.FormFields) != "" {
+		if err := json.Unmarshal([]byte(ev.FormFields), &schema); err != nil {
+			log.Printf("event %s has invalid form fields: %v", ev.ID, err)
+		}
+	}
+	return schema
+}
+
+func setEventFormFields(ev *db.Event, schema regform.Schema) {
+	if schema.IsEmpty() {
+		ev.FormFields = ""
+		return
+	}
+	data, _ := json.Marshal(schema)
+	ev.FormFields = string(data)
+}
+
+// A file field must name a file the same account uploaded.
+func registrationFileExists(userID int) func(string) bool {
+	return func(name string) bool {
+		if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+			return false
+		}
+		info, err := os.Stat(filepath.Join(registrationFilesDir, strconv.Itoa(userID), name))
+		return err == nil && !info.IsDir()
+	}
+}
+
+// JSON numbers arrive as float64 and are written without a trailing ".0".
+func fieldValues(v interface{}) map[string]string {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, val := range m {
+		switch x := val.(type) {
+		case nil:
+		case string:
+			out[k] = x
+		case float64:
+			out[k] = strconv.FormatFloat(x, 'f', -1, 64)
+		default:
+			out[k] = fmt.Sprintf("%v", x)
+		}
+	}
+	return out
+}
+
+func checkFormFields(schema regform.Schema, team map[string]string, participants []Participant, fileExists func(string) bool) (map[string]string, []string) {
+	teamValues, problems := regform.Values(schema.Team, team, fileExists)
+	for i := range participants {
+		values, partProblems := regform.Values(schema.Participant, participants[i].Fields, fileExists)
+		participants[i].Fields = values
+		for _, p := range partProblems {
+			problems = append(problems, fmt.Sprintf("participant %d: %s", i+1, p))
+		}
+	}
+	return teamValues, problems
+}
+
+func UploadRegistrationFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	userData, err := globalDB.Get("users", email)
+	if err != nil || userData == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	user := userData.(*db.User)
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRegistrationFile+64<<10)
+	if err := r.ParseMultipartForm(maxRegistrationFile); err != nil {
+		http.Error(w, "File must be at most 5 MB", http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxRegistrationFile+1))
+	if err != nil || len(data) > maxRegistrationFile {
+		http.Error(w, "File must be at most 5 MB", http.StatusBadRequest)
+		return
+	}
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	if !registrationFileTypes[ext] {
+		http.Error(w, "Only PDF, PNG, JPEG, ZIP, TXT and Markdown files are accepted", http.StatusBadRequest)
+		return
+	}
+
+	base := slugify(strings.TrimSuffix(filepath.Base(header.Filename), filepath.Ext(header.Filename)))
+	if base == "" {
+		base = "file"
+	}
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:4]) + "-" + base + ext
+	dir := filepath.Join(registrationFilesDir, strconv.Itoa(user.ID))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		log.Printf("failed to store registration file %s for %s: %v", name, email, err)
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Response{Status: "success", Message: "File uploaded", Data: map[string]interface{}{"file": name}})
+}
+
+func (ah *AdminHandler) RegistrationFile(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/admin/registration_files/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	userID, err := strconv.Atoi(parts[0])
+	if err != nil || !registrationFileExists(userID)(parts[1]) {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="`+parts[1]+`"`)
+	http.ServeFile(w, r, filepath.Join(registrationFilesDir, parts[0], parts[1]))
+}
+
+func RegistrationFile(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.RegistrationFile(w, r)
+}
+
+func (ah *AdminHandler) EventFormFields(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	eventID := strings.TrimPrefix(r.URL.Path, "/api/admin/events/fields/")
+	item, err := ah.db.Get("events", eventID)
+	if err != nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+	ev := item.(*db.Event)
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"data":    eventFormFields(ev),
+			"types":   regform.Types,
+		})
+	case http.MethodPut, http.MethodPost:
+		var schema regform.Schema
+		if err := json.NewDecoder(r.Body).Decode(&schema); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := schema.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		before := *ev
+		setEventFormFields(ev, schema)
+		if err := ah.db.Update("events", ev.ID, ev); err != nil {
+			http.Error(w, "Failed to update form fields", http.StatusInternalServerError)
+			return
+		}
+		recordAudit(r, email, "event.form_fields", "events", ev.ID, &before, ev)
+		emitWebhook(webhooks.EventEventUpdated, eventWebhookData(ev, "updated", email))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": schema})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func EventFormFields(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.EventFormFields(w, r)
+}
+
+func (ah *AdminHandler) writeRegistrationsCSV(w http.ResponseWriter, ev *db.Event) error {
+	usersRaw, err := ah.db.GetAll("users")
+	if err != nil {
+		return err
+	}
+	teamFields, err := ah.db.AllTeamFields()
+	if err != nil {
+		return err
+	}
+	regsRaw, err := ah.db.GetAll("registrations")
+	if err != nil {
+		return err
+	}
+	teamRegs := map[[2]int]*db.Registration{}
+	for _, rr := range regsRaw {
+		if reg, ok := rr.(*db.Registration); ok && reg.EventID == ev.ID {
+			teamRegs[[2]int{reg.UserID, reg.Team}] = reg
+		}
+	}
+	schema := eventFormFields(ev)
+
+	header := []string{"username", "email", "institution", "event_id", "team", "team_name", "captain"}
+	for _, f := range schema.Team {
+		header = append(header, regform.TeamColumn(f.Key))
+	}
+	for n := 1; n <= ev.Participants; n++ {
+		for _, c := range []string{"name", "email", "class", "phone", "gender"} {
+			header = append(header, fmt.Sprintf("p%d_%s", n, c))
+		}
+		for _, f := range schema.Participant {
+			header = append(header, regform.ParticipantColumn(n, f.Key))
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+ev.ID+`-registrations.csv"`)
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	for _, item := range usersRaw {
+		user, ok := item.(*db.User)
+		if !ok {
+			continue
+		}
+		for _, team := range userTeams(user, ev.ID) {
+			key := db.TeamKey(ev.ID, team)
+			parts := user.Registrations[key]
+			if len(parts) == 0 {
+				continue
+			}
+			row := []string{user.Username, user.Email, user.InstitutionName, ev.ID, strconv.Itoa(team), "", ""}
+			if reg := teamRegs[[2]int{user.ID, team}]; reg != nil {
+				row[5], row[6] = reg.TeamName, reg.Captain
+			}
+			for _, f := range schema.Team {
+				row = append(row, teamFields[user.ID][key][f.Key])
+			}
+			for n := 0; n < ev.Participants; n++ {
+				var p db.Participant
+				if n < len(parts) {
+					p = parts[n]
+					row = append(row, p.Name, p.Email, strconv.Itoa(p.Class), p.Phone, p.Gender)
+				} else {
+					row = append(row, "", "", "", "", "")
+				}
+				for _, f := range schema.Participant {
+					row = append(row, p.Fields[f.Key])
+				}
+			}
+			cw.Write(row)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"exunreg25/db"
+	"exunreg25/regform"
+)
+
+func TestFieldValues(t *testing.T) {
+	tests := []struct {
+		name string
+		in   interface{}
+		want map[string]string
+	}{
+		{"not an object", "repo", nil},
+		{"mixed", map[string]interface{}{"repo": "r", "age": float64(14), "score": 2.5, "ok": true, "none": nil},
+			map[string]string{"repo": "r", "age": "14", "score": "2.5", "ok": "true"}},
+	}
+	for _, tt := range tests {
+		if got := fieldValues(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: fieldValues = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckFormFields(t *testing.T) {
+	schema := regform.Schema{
+		Team:        []regform.Field{{Key: "repo", Label: "Repository", Type: regform.TypeURL, Required: true}},
+		Participant: []regform.Field{{Key: "tshirt", Label: "T-shirt", Type: regform.TypeEnum, Options: []string{"S", "M"}, Required: true}},
+	}
+	participants := []Participant{
+		{Name: "A", Fields: map[string]string{"tshirt": "M", "extra": "x"}},
+		{Name: "B", Fields: map[string]string{"tshirt": "XL"}},
+	}
+	team, problems := checkFormFields(schema, map[string]string{"repo": "https://x.org"}, participants, nil)
+	if !reflect.DeepEqual(team, map[string]string{"repo": "https://x.org"}) {
+		t.Errorf("team values = %v", team)
+	}
+	if want := []string{"participant 2: T-shirt must be one of S, M"}; !reflect.DeepEqual(problems, want) {
+		t.Errorf("problems = %q, want %q", problems, want)
+	}
+	if !reflect.DeepEqual(participants[0].Fields, map[string]string{"tshirt": "M"}) || participants[1].Fields != nil {
+		t.Errorf("participant fields = %v, %v", participants[0].Fields, participants[1].Fields)
+	}
+}
+
+func TestRegistrationFileExists(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := filepath.Join(registrationFilesDir, "7")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "slides.pdf"), []byte("%PDF"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	exists := registrationFileExists(7)
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"slides.pdf", true},
+		{"missing.pdf", false},
+		{"sub", false},
+		{"../7/slides.pdf", false},
+		{".hidden", false},
+	}
+	for _, tt := range tests {
+		if got := exists(tt.name); got != tt.want {
+			t.Errorf("exists(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if registrationFileExists(8)("slides.pdf") {
+		t.Error("another account's upload was accepted")
+	}
+}
+
+func TestEventFormFieldsRoundTrip(t *testing.T) {
+	ev := &db.Event{}
+	schema := regform.Schema{Team: []regform.Field{{Key: "repo", Label: "Repository", Type: regform.TypeURL}}}
+	setEventFormFields(ev, schema)
+	if got := eventFormFields(ev); !reflect.DeepEqual(got, schema) {
+		t.Errorf("eventFormFields = %+v", got)
+	}
+	setEventFormFields(ev, regform.Schema{})
+	if ev.FormFields != "" {
+		t.Errorf("empty schema stored as %q", ev.FormFields)
+	}
+}
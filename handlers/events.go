@@ -0,0 +1,426 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"exunreg25/db"
+	"exunreg25/eligibility"
+	"exunreg25/webhooks"
+)
+
+type EventCategory struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+var EventCategories = []EventCategory{
+	{Key: "programming", Name: "Programming"},
+	{Key: "build", Name: "Build Events"},
+	{Key: "gaming", Name: "Gaming"},
+	{Key: "cubing", Name: "Cubing"},
+	{Key: "cybersec", Name: "Cybersecurity"},
+	{Key: "robotics", Name: "Robotics"},
+	{Key: "quiz", Name: "Quiz Events"},
+	{Key: "general", Name: "General"},
+}
+
+func EventCategoryName(key string) string {
+	for _, c := range EventCategories {
+		if c.Key == key {
+			return c.Name
+		}
+	}
+	return ""
+}
+
+const (
+	illustrationsDir = "frontend/illustrations"
+	maxEventImage    = 2 << 20
+	maxParticipants  = 8
+	maxTeams         = 10
+)
+
+var eventSlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Event pages are served at /<slug>, so these would shadow other routes.
+var reservedEventSlugs = map[string]bool{
+	"index": true, "events": true, "brochure": true, "summary": true, "complete": true,
+	"query": true, "login": true, "logout": true, "admin": true, "drive": true,
+	"preferences": true, "unsubscribe": true, "api": true, "assets": true, "css": true,
+	"js": true, "illustrations": true, "data": true, "fonts": true, "components": true,
+	"create": true, "image": true, "order": true, "delete": true, "archive": true,
+	"import": true, "export": true, "eligibility": true, "fields": true,
+}
+
+var eventModes = map[string]bool{"online": true, "offline": true, "hybrid": true}
+
+type EventRequest struct {
+	EventID                 string  `json:"event_id"`
+	Name                    *string `json:"name"`
+	Slug                    *string `json:"slug"`
+	Image                   *string `json:"image"`
+	Category                *string `json:"category"`
+	DisplayOrder            *int    `json:"display_order"`
+	Mode                    *string `json:"mode"`
+	Participants            *int    `json:"participants"`
+	TeamsPerSchool          *int    `json:"teams_per_school"`
+	MinClass                *int    `json:"min_class"`
+	MaxClass                *int    `json:"max_class"`
+	OpenToAll               *bool   `json:"open_to_all"`
+	IndependentRegistration *bool   `json:"independent_registration"`
+	Points                  *int    `json:"points"`
+	Dates                   *string `json:"dates"`
+	DescriptionShort        *string `json:"description_short"`
+	DescriptionLong         *string `json:"description_long"`
+
+	EligibilityRules *eligibility.Rules `json:"eligibility_rules"`
+}
+
+// min_class/max_class adjust the current class range unless
+// eligibility_rules replaces the rules outright.
+func (req *EventRequest) apply(ev *db.Event) {
+	if req.Name != nil {
+		ev.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Image != nil {
+		ev.Image = strings.TrimSpace(*req.Image)
+	}
+	if req.Category != nil {
+		ev.Category = strings.TrimSpace(*req.Category)
+	}
+	if req.DisplayOrder != nil {
+		ev.DisplayOrder = *req.DisplayOrder
+	}
+	if req.Mode != nil {
+		ev.Mode = strings.TrimSpace(*req.Mode)
+	}
+	if req.Participants != nil {
+		ev.Participants = *req.Participants
+	}
+	if req.TeamsPerSchool != nil {
+		ev.TeamsPerSchool = *req.TeamsPerSchool
+	}
+	if req.IndependentRegistration != nil {
+		ev.IndependentRegistration = *req.IndependentRegistration
+	}
+	if req.Points != nil {
+		ev.Points = *req.Points
+	}
+	if req.Dates != nil {
+		ev.Dates = strings.TrimSpace(*req.Dates)
+	}
+	if req.DescriptionShort != nil {
+		ev.DescriptionShort = strings.TrimSpace(*req.DescriptionShort)
+	}
+	if req.DescriptionLong != nil {
+		ev.DescriptionLong = strings.TrimSpace(*req.DescriptionLong)
+	}
+	switch {
+	case req.EligibilityRules != nil:
+		setEventRules(ev, *req.EligibilityRules)
+	case req.OpenToAll != nil && *req.OpenToAll:
+		setEventRules(ev, eligibility.Rules{})
+	case req.MinClass != nil || req.MaxClass != nil || req.OpenToAll != nil:
+		rules := eventRules(ev)
+		minClass, maxClass := rules.Classes()
+		if req.MinClass != nil {
+			minClass = *req.MinClass
+		}
+		if req.MaxClass != nil {
+			maxClass = *req.MaxClass
+		}
+		setEventRules(ev, withClassRange(rules, minClass, maxClass))
+	}
+}
+
+func eventClassRange(ev *db.Event) (int, int, bool) {
+	rules, err := parseEventRules(ev)
+	if err != nil {
+		return 0, 0, false
+	}
+	minClass, maxClass := rules.Classes()
+	return minClass, maxClass, true
+}
+
+func validateEvent(ev *db.Event) string {
+	if ev.Name == "" || len(ev.Name) > 100 {
+		return "Name is required and must be at most 100 characters"
+	}
+	if !eventSlugPattern.MatchString(ev.ID) || len(ev.ID) > 80 {
+		return "Slug must be lowercase letters, digits and single hyphens"
+	}
+	if reservedEventSlugs[ev.ID] {
+		return "Slug " + ev.ID + " is reserved"
+	}
+	if ev.Category != "" && EventCategoryName(ev.Category) == "" {
+		return "Unknown category: " + ev.Category
+	}
+	if !eventModes[strings.ToLower(ev.Mode)] {
+		return "Mode must be online, offline or hybrid"
+	}
+	if ev.Participants < 1 || ev.Participants > maxParticipants {
+		return fmt.Sprintf("Participants must be between 1 and %d", maxParticipants)
+	}
+	if ev.TeamsPerSchool < 0 || ev.TeamsPerSchool > maxTeams {
+		return fmt.Sprintf("Teams per school must be between 1 and %d", maxTeams)
+	}
+	rules, err := parseEventRules(ev)
+	if err != nil {
+		return "Eligibility: " + err.Error()
+	}
+	if err := rules.Validate(ev.Participants); err != nil {
+		return "Eligibility: " + err.Error()
+	}
+	if err := eventFormFields(ev).Validate(); err != nil {
+		return "Form fields: " + err.Error()
+	}
+	if ev.Points < 0 {
+		return "Points cannot be negative"
+	}
+	if ev.Image != "" {
+		if strings.Contains(ev.Image, "/") || strings.Contains(ev.Image, "..") {
+			return "Image must be a file name in the illustrations store"
+		}
+		if _, err := os.Stat(filepath.Join(illustrationsDir, ev.Image)); err != nil {
+			return "Image " + ev.Image + " has not been uploaded"
+		}
+	}
+	return ""
+}
+
+func (ah *AdminHandler) CreateEvent(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req EventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ev := &db.Event{
+		Mode:                    "online",
+		Participants:            1,
+		TeamsPerSchool:          1,
+		IndependentRegistration: true,
+	}
+	setEventRules(ev, eligibility.Rules{})
+	req.apply(ev)
+	ev.ID = slugify(ev.Name)
+	if req.Slug != nil && strings.TrimSpace(*req.Slug) != "" {
+		ev.ID = strings.TrimSpace(*req.Slug)
+	}
+	if req.DisplayOrder == nil {
+		next, err := ah.db.NextEventDisplayOrder()
+		if err != nil {
+			http.Error(w, "Failed to create event", http.StatusInternalServerError)
+			return
+		}
+		ev.DisplayOrder = next
+	}
+	if msg := validateEvent(ev); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if _, err := ah.db.Get("events", ev.ID); err == nil {
+		http.Error(w, "An event with slug "+ev.ID+" already exists", http.StatusConflict)
+		return
+	}
+
+	if err := ah.db.Create("events", ev); err != nil {
+		http.Error(w, "Failed to create event", http.StatusInternalServerError)
+		return
+	}
+	created, err := ah.db.Get("events", ev.ID)
+	if err != nil {
+		http.Error(w, "Failed to reload event", http.StatusInternalServerError)
+		return
+	}
+	ev = created.(*db.Event)
+	log.Printf("event %s created by %s", ev.ID, email)
+	recordAudit(r, email, "event.create", "events", ev.ID, nil, ev)
+	emitWebhook(webhooks.EventEventUpdated, eventWebhookData(ev, "created", email))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": ev})
+}
+
+func (ah *AdminHandler) UploadEventImage(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxEventImage+64<<10)
+	if err := r.ParseMultipartForm(maxEventImage); err != nil {
+		http.Error(w, "Image must be at most 2 MB", http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		http.Error(w, "Missing image file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxEventImage+1))
+	if err != nil || len(data) > maxEventImage {
+		http.Error(w, "Image must be at most 2 MB", http.StatusBadRequest)
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	switch ext {
+	case ".png":
+		if !bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")) {
+			http.Error(w, "File is not a PNG image", http.StatusBadRequest)
+			return
+		}
+	case ".svg":
+		lower := bytes.ToLower(data)
+		if !bytes.Contains(lower, []byte("<svg")) {
+			http.Error(w, "File is not an SVG image", http.StatusBadRequest)
+			return
+		}
+		if bytes.Contains(lower, []byte("<script")) || regexp.MustCompile(`\son[a-z]+\s*=`).Match(lower) || bytes.Contains(lower, []byte("javascript:")) {
+			http.Error(w, "SVG images may not contain scripts", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Only SVG and PNG images are accepted", http.StatusBadRequest)
+		return
+	}
+
+	base := slugify(strings.TrimSuffix(filepath.Base(header.Filename), filepath.Ext(header.Filename)))
+	if base == "" {
+		base = "event"
+	}
+	name := base + ext
+	if existing, err := os.ReadFile(filepath.Join(illustrationsDir, name)); err == nil && !bytes.Equal(existing, data) {
+		sum := sha256.Sum256(data)
+		name = base + "-" + hex.EncodeToString(sum[:4]) + ext
+	}
+	if err := os.WriteFile(filepath.Join(illustrationsDir, name), data, 0644); err != nil {
+		log.Printf("failed to store event image %s: %v", name, err)
+		http.Error(w, "Failed to store image", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, email, "event_image.upload", "illustrations", name, nil, map[string]interface{}{"bytes": len(data)})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"image":   name,
+		"url":     "/illustrations/" + name,
+	})
+}
+
+func (ah *AdminHandler) ReorderEvents(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Order []string `json:"order"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Order) == 0 {
+		http.Error(w, "order must list event IDs", http.StatusBadRequest)
+		return
+	}
+	seen := map[string]bool{}
+	for _, id := range req.Order {
+		if seen[id] {
+			http.Error(w, "Duplicate event "+id+" in order", http.StatusBadRequest)
+			return
+		}
+		seen[id] = true
+	}
+
+	before, _ := GetAllEventsData()
+	if err := ah.db.SetEventOrder(req.Order); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	beforeIDs := make([]string, 0, len(before))
+	for _, ev := range before {
+		beforeIDs = append(beforeIDs, ev.ID)
+	}
+	recordAudit(r, email, "events.reorder", "events", "", map[string]interface{}{"order": beforeIDs}, map[string]interface{}{"order": req.Order})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "order": req.Order})
+}
+
+func EventCategoriesAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Response{Status: "success", Message: "Categories retrieved successfully", Data: EventCategories})
+}
+
+func CreateEvent(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.CreateEvent(w, r)
+}
+
+func UploadEventImage(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.UploadEventImage(w, r)
+}
+
+func ReorderEvents(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.ReorderEvents(w, r)
+}
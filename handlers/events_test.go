@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"exunreg25/db"
+)
+
+func validEvent() *db.Event {
+	return &db.Event{
+		ID:           "quiz-bowl",
+		Name:         "Quiz Bowl",
+		Category:     "quiz",
+		Mode:         "offline",
+		Participants: 2,
+		Eligibility:  "Grades 6–12",
+	}
+}
+
+func TestValidateEvent(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll(illustrationsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(illustrationsDir, "quiz.svg"), []byte("<svg/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		edit   func(ev *db.Event)
+		errHas string
+	}{
+		{"valid", func(ev *db.Event) {}, ""},
+		{"uploaded image", func(ev *db.Event) { ev.Image = "quiz.svg" }, ""},
+		{"json eligibility", func(ev *db.Event) { ev.Eligibility = "[9,12]" }, ""},
+		{"open to all", func(ev *db.Event) { ev.OpenToAll = true; ev.Eligibility = "" }, ""},
+		{"missing name", func(ev *db.Event) { ev.Name = "" }, "Name"},
+		{"uppercase slug", func(ev *db.Event) { ev.ID = "Quiz" }, "Slug"},
+		{"double hyphen", func(ev *db.Event) { ev.ID = "quiz--bowl" }, "Slug"},
+		{"reserved slug", func(ev *db.Event) { ev.ID = "admin" }, "reserved"},
+		{"unknown category", func(ev *db.Event) { ev.Category = "sports" }, "category"},
+		{"bad mode", func(ev *db.Event) { ev.Mode = "remote" }, "Mode"},
+		{"too many participants", func(ev *db.Event) { ev.Participants = maxParticipants + 1 }, "Participants"},
+		{"no participants", func(ev *db.Event) { ev.Participants = 0 }, "Participants"},
+		{"no class range", func(ev *db.Event) { ev.Eligibility = "everyone" }, "class range"},
+		{"inverted class range", func(ev *db.Event) { ev.Eligibility = "Grades 12–6" }, "min <= max"},
+		{"negative points", func(ev *db.Event) { ev.Points = -1 }, "Points"},
+		{"image path", func(ev *db.Event) { ev.Image = "../secret.png" }, "file name"},
+		{"image not uploaded", func(ev *db.Event) { ev.Image = "missing.png" }, "not been uploaded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := validEvent()
+			tt.edit(ev)
+			got := validateEvent(ev)
+			if tt.errHas == "" && got != "" {
+				t.Errorf("validateEvent = %q, want no error", got)
+			}
+			if tt.errHas != "" && !strings.Contains(got, tt.errHas) {
+				t.Errorf("validateEvent = %q, want it to mention %q", got, tt.errHas)
+			}
+		})
+	}
+}
+
+func TestEventRequestApply(t *testing.T) {
+	intp := func(n int) *int { return &n }
+	boolp := func(b bool) *bool { return &b }
+
+	tests := []struct {
+		name        string
+		eligibility string
+		req         EventRequest
+		want        string
+	}{
+		{"untouched", "Grades 6–12", EventRequest{}, "Grades 6–12"},
+		{"min only", "Grades 6–12", EventRequest{MinClass: intp(9)}, "Grades 9–12"},
+		{"max from json", "[6,12]", EventRequest{MaxClass: intp(10)}, "Grades 6–10"},
+		{"open to all", "Grades 6–12", EventRequest{OpenToAll: boolp(true)}, "Open to all"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ev := validEvent()
+			ev.Eligibility = tt.eligibility
+			tt.req.apply(ev)
+			if ev.Eligibility != tt.want {
+				t.Errorf("eligibility = %q, want %q", ev.Eligibility, tt.want)
+			}
+		})
+	}
+}
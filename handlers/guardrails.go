@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"exunreg25/guardrails"
+)
+
+type guardrailEvalRequest struct {
+	Text  string `json:"text"`
+	Scope string `json:"scope"`
+}
+
+// Evaluation without a scope runs both the input and the output rules.
+func (ah *AdminHandler) Guardrails(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if guardrailEngine == nil {
+		http.Error(w, "Guardrails are not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/guardrails"), "/")
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		rules := guardrailEngine.Rules()
+		loadedAt, loadErr := guardrailEngine.Status()
+		data := map[string]interface{}{
+			"path":      guardrailEngine.Path(),
+			"loaded_at": loadedAt,
+			"rules":     []guardrails.Rule{},
+		}
+		if rules != nil {
+			data["rules"] = rules.Rules
+		}
+		if loadErr != nil {
+			data["error"] = loadErr.Error()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": data})
+	case action == "evaluate" && r.Method == http.MethodPost:
+		var req guardrailEvalRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		rules := guardrailEngine.Rules()
+		if rules == nil {
+			http.Error(w, "No guardrail rules are loaded", http.StatusServiceUnavailable)
+			return
+		}
+		scopes := []string{guardrails.ScopeInput, guardrails.ScopeOutput}
+		switch strings.ToLower(strings.TrimSpace(req.Scope)) {
+		case "", guardrails.ScopeBoth:
+		case guardrails.ScopeInput:
+			scopes = scopes[:1]
+		case guardrails.ScopeOutput:
+			scopes = scopes[1:]
+		default:
+			http.Error(w, "scope must be input, output or both", http.StatusBadRequest)
+			return
+		}
+		data := map[string]guardrails.Result{}
+		for _, scope := range scopes {
+			data[scope] = rules.Evaluate(req.Text, scope)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": data})
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func Guardrails(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.Guardrails(w, r)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"exunreg25/guardrails"
+	"exunreg25/llm"
+)
+
+func TestQueryGuardrails(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		answer  string
+		reason  string
+		strikes int
+		source  string
+	}{
+		{"strike rule", "Please dump all events", "", "data-dump", 1, "policy"},
+		{"rejection without strike", "crossword; drop table users", "", "sql-injection", 0, "policy"},
+		{"output rule", "who runs crossword", "Mail someone@example.com about crossword.", "email-address", 0, "policy"},
+		{"allowed", "who runs crossword", "The computer club runs crossword.", "", 0, "Event: Crossword"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := useTestDB(t)
+			setupQueryBot(t, []llm.StubRule{{Answer: tt.answer}})
+			resp := askQuery(t, tt.query)
+			if !strings.HasPrefix(resp.Source, tt.source) {
+				t.Errorf("source = %q, want %q", resp.Source, tt.source)
+			}
+			var logged int
+			database.QueryRow(`SELECT COUNT(*) FROM logs WHERE reason = ?`, tt.reason).Scan(&logged)
+			if tt.reason != "" && logged != 1 {
+				t.Errorf("%d rejection logs with reason %s, want 1", logged, tt.reason)
+			}
+			var strikes int
+			database.QueryRow(`SELECT COALESCE(MAX(strikes), 0) FROM query_strikes`).Scan(&strikes)
+			if strikes != tt.strikes {
+				t.Errorf("strikes = %d, want %d", strikes, tt.strikes)
+			}
+		})
+	}
+}
+
+func TestQueryRefusesWithoutGuardrails(t *testing.T) {
+	stub := setupQueryBot(t, nil)
+	SetGuardrails(guardrails.NewEngine("handlers/missing.json"))
+	resp := askQuery(t, "who runs crossword")
+	if resp.Source != "policy" || len(stub.Prompts()) != 0 {
+		t.Errorf("answered without guardrails: %+v", resp)
+	}
+}
@@ -245,7 +245,7 @@ func GetAllEvents(w http.ResponseWriter, r *http.Request) {
 	var eventsList []db.Event
 	var err error
 	if IsAdminEmail(email) {
-		eventsList, err = GetAllEventsData()
+		eventsList, err = getAllEventsData(r.URL.Query().Get("include_archived") == "1")
 	} else {
 		eventsList, err = GetAllEventsForUser(email)
 	}
@@ -276,6 +276,7 @@ func GetAllEvents(w http.ResponseWriter, r *http.Request) {
 			"description_short": ev.DescriptionShort,
 			"description_long":  ev.DescriptionLong,
 			"participants":      ev.Participants,
+			"teams_per_school":  teamsPerSchool(&ev),
 			"mode":              ev.Mode,
 			"points":            ev.Points,
 			"individual":        ev.IndependentRegistration,
@@ -283,6 +284,13 @@ func GetAllEvents(w http.ResponseWriter, r *http.Request) {
 			"open_to_all":       ev.OpenToAll,
 			"dates":             ev.Dates,
 			"registrations":     regCounts[ev.ID],
+			"category":          ev.Category,
+			"category_name":     EventCategoryName(ev.Category),
+			"eligibility_rules": eventRules(&ev),
+			"requires_gender":   eventRules(&ev).RequiresGender(),
+			"form_fields":       eventFormFields(&ev),
+			"display_order":     ev.DisplayOrder,
+			"archived":          ev.ArchivedAt != nil,
 		}
 		events = append(events, event)
 	}
@@ -311,8 +319,13 @@ func GetEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	showArchived := false
+	if c, err := r.Cookie("email"); err == nil {
+		showArchived = IsAdminEmail(c.Value)
+	}
+
 	if ev, err := globalDB.Get("events", eventID); err == nil && ev != nil {
-		if dbEv, ok := ev.(*db.Event); ok {
+		if dbEv, ok := ev.(*db.Event); ok && (dbEv.ArchivedAt == nil || showArchived) {
 			foundEvent := map[string]interface{}{
 				"id":                dbEv.ID,
 				"name":              dbEv.Name,
@@ -321,12 +334,18 @@ func GetEvent(w http.ResponseWriter, r *http.Request) {
 				"description_short": dbEv.DescriptionShort,
 				"description_long":  dbEv.DescriptionLong,
 				"participants":      dbEv.Participants,
+				"teams_per_school":  teamsPerSchool(dbEv),
 				"mode":              dbEv.Mode,
 				"points":            dbEv.Points,
 				"individual":        dbEv.IndependentRegistration,
 				"eligibility":       dbEv.Eligibility,
 				"open_to_all":       dbEv.OpenToAll,
 				"dates":             dbEv.Dates,
+				"category":          dbEv.Category,
+				"category_name":     EventCategoryName(dbEv.Category),
+				"eligibility_rules": eventRules(dbEv),
+				"requires_gender":   eventRules(dbEv).RequiresGender(),
+				"form_fields":       eventFormFields(dbEv),
 			}
 			response := Response{Status: "success", Message: "Event retrieved successfully", Data: foundEvent}
 			w.Header().Set("Content-Type", "application/json")
@@ -339,7 +358,7 @@ func GetEvent(w http.ResponseWriter, r *http.Request) {
 	all, err := globalDB.GetAll("events")
 	if err == nil {
 		for _, item := range all {
-			if dbEv, ok := item.(*db.Event); ok {
+			if dbEv, ok := item.(*db.Event); ok && (dbEv.ArchivedAt == nil || showArchived) {
 				if dbEv.ID == eventID || slugify(dbEv.Name) == eventID {
 					foundEvent := map[string]interface{}{
 						"id":                dbEv.ID,
@@ -349,12 +368,18 @@ func GetEvent(w http.ResponseWriter, r *http.Request) {
 						"description_short": dbEv.DescriptionShort,
 						"description_long":  dbEv.DescriptionLong,
 						"participants":      dbEv.Participants,
+						"teams_per_school":  teamsPerSchool(dbEv),
 						"mode":              dbEv.Mode,
 						"points":            dbEv.Points,
 						"individual":        dbEv.IndependentRegistration,
 						"eligibility":       dbEv.Eligibility,
 						"open_to_all":       dbEv.OpenToAll,
 						"dates":             dbEv.Dates,
+						"category":          dbEv.Category,
+						"category_name":     EventCategoryName(dbEv.Category),
+						"eligibility_rules": eventRules(dbEv),
+						"requires_gender":   eventRules(dbEv).RequiresGender(),
+						"form_fields":       eventFormFields(dbEv),
 					}
 					response := Response{Status: "success", Message: "Event retrieved successfully", Data: foundEvent}
 					w.Header().Set("Content-Type", "application/json")
@@ -376,10 +401,13 @@ var globalDB *db.Database
 
 func SetGlobalDB(database *db.Database) {
 	globalDB = database
-	go startSheetsSync(database)
 }
 
 func GetAllEventsData() ([]db.Event, error) {
+	return getAllEventsData(false)
+}
+
+func getAllEventsData(includeArchived bool) ([]db.Event, error) {
 	events := []db.Event{}
 	all, err := globalDB.GetAll("events")
 	if err != nil {
@@ -387,6 +415,9 @@ func GetAllEventsData() ([]db.Event, error) {
 	}
 	for _, item := range all {
 		if ev, ok := item.(*db.Event); ok {
+			if ev.ArchivedAt != nil && !includeArchived {
+				continue
+			}
 			events = append(events, *ev)
 		}
 	}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"exunreg25/db"
+	"exunreg25/retrieval"
+)
+
+const (
+	faqPath = "frontend/data/faq.json"
+
+	retrievalTopK = 5
+	// Above this similarity the FAQ answer is returned without asking the model.
+	faqAnswerThreshold = 0.8
+)
+
+// The index is rebuilt when the FAQ or brochure file changes or an event is edited.
+var knowledgeBase struct {
+	sync.Mutex
+	fingerprint string
+	index       *retrieval.Index
+}
+
+func knowledgeIndex() *retrieval.Index {
+	events := knowledgeEvents()
+	fp := knowledgeFingerprint(events)
+
+	knowledgeBase.Lock()
+	defer knowledgeBase.Unlock()
+	if knowledgeBase.index != nil && knowledgeBase.fingerprint == fp {
+		return knowledgeBase.index
+	}
+	chunks := faqChunks()
+	chunks = append(chunks, eventChunks(events)...)
+	chunks = append(chunks, brochureChunks()...)
+	knowledgeBase.index = retrieval.New(chunks)
+	knowledgeBase.fingerprint = fp
+	return knowledgeBase.index
+}
+
+func knowledgeFingerprint(events []db.Event) string {
+	var sb strings.Builder
+	for _, p := range []string{faqPath, "frontend/data/invite.md"} {
+		if info, err := os.Stat(p); err == nil {
+			fmt.Fprintf(&sb, "%s:%d:%d;", p, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	var latest time.Time
+	for _, ev := range events {
+		if ev.UpdatedAt.After(latest) {
+			latest = ev.UpdatedAt
+		}
+	}
+	fmt.Fprintf(&sb, "events:%d:%d", len(events), latest.UnixNano())
+	return sb.String()
+}
+
+func knowledgeEvents() []db.Event {
+	if globalDB != nil {
+		if events, err := getAllEventsData(false); err == nil && len(events) > 0 {
+			return events
+		}
+	}
+	data, _ := loadEventsData()
+	records, err := parseEventJSON([]byte(data))
+	if err != nil {
+		log.Printf("failed to read events for the query bot: %v", err)
+		return nil
+	}
+	events := make([]db.Event, 0, len(records))
+	for i := range records {
+		events = append(events, records[i].toEvent(nil))
+	}
+	return events
+}
+
+func faqChunks() []retrieval.Chunk {
+	b, err := os.ReadFile(faqPath)
+	if err != nil {
+		return nil
+	}
+	var data struct {
+		FAQ []struct {
+			Question string `json:"question"`
+			Answer   string `json:"answer"`
+		} `json:"faq"`
+	}
+	if err := json.Unmarshal(b, &data); err != nil {
+		log.Printf("failed to parse %s: %v", faqPath, err)
+		return nil
+	}
+	chunks := make([]retrieval.Chunk, 0, len(data.FAQ))
+	for i, f := range data.FAQ {
+		chunks = append(chunks, retrieval.Chunk{
+			ID:     "faq:" + strconv.Itoa(i+1),
+			Kind:   retrieval.KindFAQ,
+			Title:  f.Question,
+			Text:   f.Answer,
+			Answer: f.Answer,
+		})
+	}
+	return chunks
+}
+
+var faqWriteMu sync.Mutex
+
+// Other keys in faq.json are kept.
+func appendFAQ(question, answer string) error {
+	faqWriteMu.Lock()
+	defer faqWriteMu.Unlock()
+	doc := map[string]json.RawMessage{}
+	if b, err := os.ReadFile(faqPath); err == nil {
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return fmt.Errorf("failed to parse %s: %v", faqPath, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	type entry struct {
+		Question string `json:"question"`
+		Answer   string `json:"answer"`
+	}
+	var entries []entry
+	if raw, ok := doc["faq"]; ok {
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			return fmt.Errorf("failed to parse %s: %v", faqPath, err)
+		}
+	}
+	for _, e := range entries {
+		if strings.EqualFold(strings.TrimSpace(e.Question), question) {
+			return fmt.Errorf("the FAQ already has the question %q", question)
+		}
+	}
+	entries = append(entries, entry{Question: question, Answer: answer})
+	var raw bytes.Buffer
+	enc := json.NewEncoder(&raw)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(entries); err != nil {
+		return err
+	}
+	doc["faq"] = raw.Bytes()
+	var out bytes.Buffer
+	enc = json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	tmp := faqPath + ".tmp"
+	if err := os.WriteFile(tmp, out.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, faqPath)
+}
+
+func eventChunks(events []db.Event) []retrieval.Chunk {
+	chunks := make([]retrieval.Chunk, 0, len(events))
+	for i := range events {
+		ev := &events[i]
+		var sb strings.Builder
+		if name := EventCategoryName(ev.Category); name != "" {
+			fmt.Fprintf(&sb, "Category: %s. ", name)
+		}
+		fmt.Fprintf(&sb, "Mode: %s. Team size: up to %d. Eligibility: %s. ", ev.Mode, ev.Participants, eventRules(ev).Describe())
+		if ev.Dates != "" {
+			fmt.Fprintf(&sb, "Dates: %s. ", ev.Dates)
+		}
+		if ev.Points > 0 {
+			fmt.Fprintf(&sb, "Overall points: %d. ", ev.Points)
+		}
+		if ev.IndependentRegistration {
+			sb.WriteString("Open to independent participants. ")
+		} else {
+			sb.WriteString("School participation only. ")
+		}
+		sb.WriteString(ev.DescriptionShort)
+		sb.WriteString("\n")
+		sb.WriteString(ev.DescriptionLong)
+		chunks = append(chunks, retrieval.Chunk{
+			ID:    "event:" + ev.ID,
+			Kind:  retrieval.KindEvent,
+			Title: ev.Name,
+			Text:  sb.String(),
+		})
+	}
+	return chunks
+}
+
+func brochureChunks() []retrieval.Chunk {
+	data, _ := loadInviteData()
+	var chunks []retrieval.Chunk
+	seen := map[string]int{}
+	title, body := "Invite", []string{}
+	flush := func() {
+		text := strings.TrimSpace(strings.Join(body, "\n"))
+		if text == "" {
+			return
+		}
+		id := slugify(title)
+		seen[id]++
+		if seen[id] > 1 {
+			id += "-" + strconv.Itoa(seen[id])
+		}
+		chunks = append(chunks, retrieval.Chunk{ID: "brochure:" + id, Kind: retrieval.KindBrochure, Title: title, Text: text})
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(line, "#") {
+			flush()
+			title = strings.Trim(strings.TrimLeft(line, "# "), "*: ")
+			body = body[:0]
+			continue
+		}
+		body = append(body, line)
+	}
+	flush()
+	return chunks
+}
+
+func faqAnswer(idx *retrieval.Index, query string) (retrieval.Chunk, bool) {
+	var best retrieval.Chunk
+	bestScore := 0.0
+	for _, res := range idx.Search(query, 3, retrieval.KindFAQ) {
+		if s := idx.Similarity(query, res.Chunk.Title); s > bestScore {
+			best, bestScore = res.Chunk, s
+		}
+	}
+	return best, bestScore >= faqAnswerThreshold
+}
+
+func citation(c retrieval.Chunk) string {
+	switch c.Kind {
+	case retrieval.KindFAQ:
+		return "FAQ: " + c.Title
+	case retrieval.KindEvent:
+		return "Event: " + c.Title
+	default:
+		return "Brochure: " + c.Title
+	}
+}
+
+func citations(results []retrieval.Result) string {
+	parts := make([]string, 0, len(results))
+	for _, r := range results {
+		parts = append(parts, citation(r.Chunk))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Excerpts are numbered so the model can refer to them.
+func retrievalContext(results []retrieval.Result) string {
+	var sb strings.Builder
+	for i, r := range results {
+		fmt.Fprintf(&sb, "[%d] %s\n%s\n\n", i+1, citation(r.Chunk), strings.TrimSpace(r.Chunk.Text))
+	}
+	return sb.String()
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"exunreg25/db"
+	"exunreg25/retrieval"
+)
+
+func TestEventChunks(t *testing.T) {
+	events := []db.Event{
+		{ID: "crossword", Name: "Crossword", Category: "quiz", Mode: "offline", Participants: 2, Eligibility: "Grades 6–12", DescriptionShort: "Cryptic clues."},
+		{ID: "cubing", Name: "Cubing", Mode: "online", Participants: 1, OpenToAll: true, IndependentRegistration: true, Points: 10},
+	}
+	chunks := eventChunks(events)
+	tests := []struct {
+		id      string
+		has     []string
+		hasNone []string
+	}{
+		{"event:crossword", []string{"Category: Quiz Events.", "Team size: up to 2.", "Eligibility: Grades 6–12.", "School participation only.", "Cryptic clues."}, []string{"Overall points"}},
+		{"event:cubing", []string{"Eligibility: Open to all.", "Overall points: 10.", "Open to independent participants."}, []string{"Category:"}},
+	}
+	for i, tt := range tests {
+		c := chunks[i]
+		if c.ID != tt.id || c.Kind != retrieval.KindEvent {
+			t.Fatalf("chunk %d = %+v", i, c)
+		}
+		for _, s := range tt.has {
+			if !strings.Contains(c.Text, s) {
+				t.Errorf("%s text %q lacks %q", tt.id, c.Text, s)
+			}
+		}
+		for _, s := range tt.hasNone {
+			if strings.Contains(c.Text, s) {
+				t.Errorf("%s text %q has %q", tt.id, c.Text, s)
+			}
+		}
+	}
+}
+
+func TestFAQAnswerAndCitations(t *testing.T) {
+	idx := retrieval.New([]retrieval.Chunk{
+		{ID: "faq:fee", Kind: retrieval.KindFAQ, Title: "Is there a registration fee?", Text: "No, registration is free.", Answer: "No, registration is free."},
+		{ID: "faq:teams", Kind: retrieval.KindFAQ, Title: "How many teams can a school send?", Text: "One team per event."},
+		{ID: "event:cubing", Kind: retrieval.KindEvent, Title: "Cubing", Text: "Speed cubing. Registration fee waived."},
+	})
+	tests := []struct {
+		query string
+		id    string
+		ok    bool
+	}{
+		{"is there any registration fee", "faq:fee", true},
+		{"How many teams may a school send?", "faq:teams", true},
+		{"registration fee for cubing in november", "", false},
+		{"robotics", "", false},
+	}
+	for _, tt := range tests {
+		c, ok := faqAnswer(idx, tt.query)
+		if ok != tt.ok || (ok && c.ID != tt.id) {
+			t.Errorf("faqAnswer(%q) = %s, %v, want %s, %v", tt.query, c.ID, ok, tt.id, tt.ok)
+		}
+	}
+
+	results := idx.Search("registration fee", 2)
+	if got := citations(results); got != "FAQ: Is there a registration fee?; Event: Cubing" {
+		t.Errorf("citations = %q", got)
+	}
+	ctx := retrievalContext(results)
+	if !strings.HasPrefix(ctx, "[1] FAQ: Is there a registration fee?\nNo, registration is free.") || !strings.Contains(ctx, "[2] Event: Cubing") {
+		t.Errorf("retrievalContext = %q", ctx)
+	}
+	if got := citation(retrieval.Chunk{Kind: retrieval.KindBrochure, Title: "Dates"}); got != "Brochure: Dates" {
+		t.Errorf("citation = %q", got)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"exunreg25/db"
+	"exunreg25/mail"
+)
+
+func buildRegistrationChange(user *db.User, event *db.Event, action string, participants []db.Participant) mail.RegistrationChange {
+	schoolName := user.InstitutionName
+	if schoolName == "" {
+		schoolName = user.Fullname
+	}
+	change := mail.RegistrationChange{
+		ToEmail:    user.Email,
+		SchoolName: schoolName,
+		Action:     action,
+		EventID:    event.ID,
+		EventName:  event.Name,
+		Mode:       event.Mode,
+		Dates:      event.Dates,
+		ChangedAt:  time.Now().Format("January 2, 2006 3:04 PM"),
+	}
+	for _, p := range participants {
+		change.Participants = append(change.Participants, mail.RegistrationParticipant{
+			Name:  p.Name,
+			Email: p.Email,
+			Class: p.Class,
+			Phone: p.Phone,
+		})
+	}
+	if emailService != nil {
+		change.SummaryURL = emailService.SummaryURL()
+	}
+	return change
+}
+
+func notifyRegistrationChange(user *db.User, event *db.Event, action string, participants []db.Participant) {
+	if user == nil || event == nil {
+		return
+	}
+	change := buildRegistrationChange(user, event, action, participants)
+	if globalDB != nil {
+		payload, _ := json.Marshal(change)
+		if err := globalDB.RecordRegistrationChange(user.Email, event.ID, action, string(payload)); err != nil {
+			log.Printf("failed to record registration change for %s/%s: %v", user.Email, event.ID, err)
+		}
+	}
+	if emailService == nil {
+		return
+	}
+	go func() {
+		if err := emailService.SendRegistrationConfirmation(change); err != nil {
+			log.Printf("registration confirmation to %s for %s failed: %v", change.ToEmail, change.EventID, err)
+		}
+	}()
+}
+
+// Changes older than digestCarryOver are dropped from digests.
+const digestCarryOver = 7 * 24 * time.Hour
+
+func StartRegistrationDigest(interval time.Duration, minChanges int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if globalDB != nil && globalDB.Paused() {
+			continue
+		}
+		if err := sendRegistrationDigests(time.Now().Add(-digestCarryOver), minChanges); err != nil {
+			log.Printf("registration digest error: %v", err)
+		}
+	}
+}
+
+func sendRegistrationDigests(since time.Time, minChanges int) error {
+	if globalDB == nil || emailService == nil {
+		return nil
+	}
+	pending, err := globalDB.PendingRegistrationChanges(since)
+	if err != nil {
+		return err
+	}
+	for email, changes := range pending {
+		if len(changes) < minChanges {
+			continue
+		}
+		latest := map[string]mail.RegistrationChange{}
+		ids := make([]int, 0, len(changes))
+		for _, c := range changes {
+			ids = append(ids, c.ID)
+			var rc mail.RegistrationChange
+			if err := json.Unmarshal([]byte(c.Payload), &rc); err != nil {
+				continue
+			}
+			latest[c.EventID] = rc
+		}
+		entries := make([]mail.RegistrationChange, 0, len(latest))
+		for _, rc := range latest {
+			entries = append(entries, rc)
+		}
+		sort.Slice(entries, func(a, b int) bool { return entries[a].EventName < entries[b].EventName })
+
+		schoolName := ""
+		if len(entries) > 0 {
+			schoolName = entries[0].SchoolName
+		}
+		err := emailService.SendRegistrationDigest(email, schoolName, changes[0].CreatedAt, len(changes), entries)
+		if errors.Is(err, mail.ErrSuppressed) {
+			log.Printf("registration digest to %s skipped: %v", email, err)
+		} else if err != nil {
+			log.Printf("registration digest to %s failed: %v", email, err)
+			continue
+		}
+		if err := globalDB.MarkRegistrationChangesDigested(ids); err != nil {
+			log.Printf("failed to mark digest changes for %s: %v", email, err)
+		}
+	}
+	return nil
+}
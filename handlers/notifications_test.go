@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"exunreg25/mail"
+)
+
+func TestSendRegistrationDigests(t *testing.T) {
+	t.Chdir("..")
+	database := useTestDB(t)
+	svc := mail.NewEmailService(&mail.EmailConfig{})
+	svc.SetSuppressionChecker(database)
+	prev := emailService
+	emailService = svc
+	t.Cleanup(func() { emailService = prev })
+
+	if err := database.SuppressEmail("quiet@x.org", mail.CategoryDigest, "unsubscribed"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		email    string
+		digested bool
+	}{
+		{"quiet@x.org", true},
+		{"loud@x.org", false},
+	}
+	for _, tt := range tests {
+		for _, ev := range []string{"quiz", "crossword"} {
+			if err := database.RecordRegistrationChange(tt.email, ev, "create", `{"event_name": "`+ev+`"}`); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := sendRegistrationDigests(time.Now().Add(-time.Hour), 1); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := database.PendingRegistrationChanges(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		if digested := len(pending[tt.email]) == 0; digested != tt.digested {
+			t.Errorf("%s: digested = %v, want %v", tt.email, digested, tt.digested)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"exunreg25/db"
+	"exunreg25/webhooks"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -152,6 +153,8 @@ func CompleteSignupAPI(w http.ResponseWriter, r *http.Request) {
 	}
 
 	user := userData.(*db.User)
+	firstCompletion := user.Fullname == ""
+	before := *user
 
 	var req CompleteSignupRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -238,6 +241,10 @@ func CompleteSignupAPI(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(response)
 		return
 	}
+	if firstCompletion {
+		emitWebhook(webhooks.EventUserCreated, userWebhookData(user))
+	}
+	recordAudit(r, email, "user.complete_profile", "users", email, &before, user)
 
 	response := Response{
 		Status:  "success",
@@ -479,9 +486,12 @@ func getUserRegistrations(userID int) (map[string]interface{}, error) {
 			}
 			event := eventData.(*db.Event)
 
-			userRegistrations[reg.EventID] = map[string]interface{}{
+			userRegistrations[db.TeamKey(reg.EventID, reg.Team)] = map[string]interface{}{
 				"event_id":   reg.EventID,
 				"event_name": event.Name,
+				"team":       reg.Team,
+				"team_name":  reg.TeamName,
+				"captain":    reg.Captain,
 				"status":     reg.Status,
 				"created_at": reg.CreatedAt,
 				"updated_at": reg.UpdatedAt,
@@ -3,27 +3,46 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"time"
 
 	"exunreg25/db"
-
-	"google.golang.org/genai"
+	"exunreg25/guardrails"
+	"exunreg25/llm"
 )
 
+var llmProvider llm.Provider
+
+func SetLLMProvider(p llm.Provider) {
+	llmProvider = p
+}
+
+func streamAnswer(ctx context.Context, prompt string, emit func(string) error) (string, error) {
+	if llmProvider == nil {
+		return "", fmt.Errorf("no llm provider configured")
+	}
+	if chain, ok := llmProvider.(*llm.Chain); ok {
+		return chain.StreamFrom(ctx, prompt, emit)
+	}
+	return llmProvider.Name(), llm.Stream(ctx, llmProvider, prompt, emit)
+}
+
 type llmRequest struct {
-	Query string `json:"query"`
+	Query          string `json:"query"`
+	ConversationID string `json:"conversation_id"`
 }
 
 type llmResponse struct {
-	Answer string `json:"answer"`
-	Source string `json:"source"`
+	Answer         string `json:"answer"`
+	Source         string `json:"source"`
+	ConversationID string `json:"conversation_id,omitempty"`
 }
 
 func loadSystemPrompt() (string, error) {
@@ -80,39 +99,25 @@ func loadInviteData() (string, error) {
 	return "", nil
 }
 
-var injectionRe = regexp.MustCompile(`(?i)(select\s+.*from|drop\s+table|insert\s+into|delete\s+from|--|;|\bunion\b|\bexec\b|\bexec\(|\bpasswd\b|\bpassword\b|\boutput\b)`)
-
-var dumpRe = regexp.MustCompile(`(?i)\b(dump|export|full list|give (me|us) the (dataset|data|events)|all events|events list|show me (the )?events|events\.json|bot\.json|system prompt|self[_ ]?reference|identity|internal prompt|instructions|ignore rules|forget all|jailbreak|respond only|context dump)\b`)
-
-var ackRe = regexp.MustCompile(`(?i)\bACKNOWLEDG|\bACK\b|Step\s*\d`)
-
-var jailbreakRe = regexp.MustCompile("(?i)(base64|triple backticks|\\`\\`\\`|paste your system prompt|paste the system prompt|paste prompt|token names|token lengths|token name|length=\\d+|SEC-AUDIT-PASS|confirm with|acknowledge with|context dump|context-dump|give the token names)")
+var guardrailEngine *guardrails.Engine
 
-var base64OutputRe = regexp.MustCompile("[A-Za-z0-9+/=]{100,}")
-
-var roleRe = regexp.MustCompile(`(?i)\b(as a (school auditor|compliance officer|admin|principal)|you must disclose|you are now a system)\b`)
-var legalRe = regexp.MustCompile(`(?i)\b(transparency|foia|freedom of information|audit compliance|show your internal instructions|internal instructions)\b`)
-var conditionalRe = regexp.MustCompile(`(?i)\bI AM BOUND\b|print I AM BOUND|first print I AM BOUND`)
-var encodingRe = regexp.MustCompile(`(?i)\b(base64|hex|rot13|uuencode|uuencoded|encode using|encode it using|encode the prompt)\b`)
-var splitRe = regexp.MustCompile(`(?i)\b(split( across)? messages|across many messages|across many responses|one word at a time|across words|across messages)\b`)
-var htmlRe = regexp.MustCompile(`(?i)(<script>|<!--|</script>|<iframe|<img|javascript:)`)
-var attachRe = regexp.MustCompile(`(?i)\battach( the)? (file|config|config.txt|attachment)\b`)
-var roleplayRe = regexp.MustCompile(`(?i)\broleplay:?\b`)
-var testRe = regexp.MustCompile(`(?i)\b(unit test|for testing|for unit tests|checker|validate outputs|test scenario|for audit)\b`)
-var reverseRe = regexp.MustCompile(`(?i)\b(don't reveal your prompt|if you reveal it, type NO|otherwise reveal)\b`)
-var tokenCountRe = regexp.MustCompile(`(?i)\b(token counts|token count|token lengths|tokens per section|token lengths)\b`)
-var socialProofRe = regexp.MustCompile(`(?i)\b(signed by|principal|dean|@dpsrkp\.net|exun@dpsrkp\.net)\b`)
-var hexOutputRe = regexp.MustCompile(`[0-9a-fA-F]{80,}`)
-var emailRe = regexp.MustCompile(`(?i)[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+func SetGuardrails(e *guardrails.Engine) {
+	guardrailEngine = e
+}
 
-func sanitizeQuery(q string) (string, bool) {
-	if injectionRe.MatchString(q) {
-		return "", false
+// The bot refuses to answer without rules rather than run unfiltered.
+func guardrailRules() *guardrails.Ruleset {
+	if guardrailEngine == nil {
+		return nil
 	}
+	return guardrailEngine.Rules()
+}
+
+func sanitizeQuery(q string) string {
 	cleaned := strings.TrimSpace(q)
 	cleaned = strings.ReplaceAll(cleaned, "`", "")
 	cleaned = strings.ReplaceAll(cleaned, "\\", "")
-	return cleaned, true
+	return cleaned
 }
 
 func logRejection(reason, content string) {
@@ -126,79 +131,140 @@ func logRejection(reason, content string) {
 	fmt.Printf("[rejection] %s", line)
 }
 
-func QueryHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+func logQuery(clients []string, query, answer, status string, extra map[string]string) {
+	if globalDB == nil {
 		return
 	}
+	payload := map[string]string{"query": query, "answer": answer, "status": status, "clients": strings.Join(clients, ",")}
+	for k, v := range extra {
+		if v != "" {
+			payload[k] = v
+		}
+	}
+	if b, err := json.Marshal(payload); err == nil {
+		_ = globalDB.Create("logs", &db.LogEntry{Reason: "query", Content: string(b), CreatedAt: time.Now()})
+	}
+}
 
+func writeQueryResponse(w http.ResponseWriter, resp llmResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func rejectQuery(out queryOutput, clients []string, query, content, scope string, res guardrails.Result) {
+	logRejection(res.Rule.ID, content)
+	answer := res.Rule.Message
+	if answer == "" {
+		answer = loadFallbackMessage()
+	}
+	resp := llmResponse{Answer: answer, Source: "policy"}
+	logQuery(clients, query, resp.Answer, "rejected", map[string]string{"rule": res.Rule.ID, "scope": scope, "rules": strings.Join(res.RuleIDs(), ",")})
+	out.finish(resp)
+}
+
+func logFlags(content, scope string, res guardrails.Result) {
+	for _, m := range res.Matches {
+		if m.Action == guardrails.ActionFlag {
+			logRejection("flag:"+m.RuleID, scope+": "+content)
+		}
+	}
+}
+
+func readQueryRequest(w http.ResponseWriter, r *http.Request) (llmRequest, bool) {
 	var req llmRequest
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return req, false
+	}
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
-		return
+		return req, false
 	}
 	if err := json.Unmarshal(body, &req); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
-		return
+		return req, false
 	}
+	return req, true
+}
 
-	cleaned, ok := sanitizeQuery(req.Query)
-	if !ok || cleaned == "" {
-		logRejection("sanitize_failed", req.Query)
-		resp := llmResponse{Answer: "I may not be able to help with that. Please reach out to exun@dpsrkp.net regarding the query.", Source: "policy"}
-		if globalDB != nil {
-			payload := map[string]string{"query": req.Query, "answer": resp.Answer, "status": "sanitize_failed"}
-			if b, err := json.Marshal(payload); err == nil {
-				_ = globalDB.Create("logs", &db.LogEntry{Reason: "query", Content: string(b), CreatedAt: time.Now()})
-			}
-		}
+func admitQuery(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	clients := queryClients(w, r)
+	if queryBlocked(clients) {
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(resp)
-		return
+		w.WriteHeader(http.StatusForbidden)
+		_ = json.NewEncoder(w).Encode(llmResponse{Answer: "The query assistant is not available to you. Please email exun@dpsrkp.net with your question.", Source: "blocked"})
+		return nil, false
 	}
+	if wait := checkQueryLimits(clients); wait > 0 {
+		writeQueryLimited(w, wait, "You're sending questions too quickly. Please try again in %s.")
+		return nil, false
+	}
+	return clients, true
+}
 
-	if dumpRe.MatchString(cleaned) || ackRe.MatchString(cleaned) {
-		logRejection("dump_or_ack_in_query", cleaned)
-		fallback := loadFallbackMessage()
-		resp := llmResponse{Answer: fallback, Source: "policy"}
-		if globalDB != nil {
-			payload := map[string]string{"query": cleaned, "answer": resp.Answer, "status": "dump_or_ack_in_query"}
-			if b, err := json.Marshal(payload); err == nil {
-				_ = globalDB.Create("logs", &db.LogEntry{Reason: "query", Content: string(b), CreatedAt: time.Now()})
-			}
-		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(resp)
+func QueryHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := readQueryRequest(w, r)
+	if !ok {
 		return
 	}
-	if jailbreakRe.MatchString(cleaned) {
-		logRejection("jailbreak_in_query", cleaned)
-		fallback := loadFallbackMessage()
-		resp := llmResponse{Answer: fallback, Source: "policy"}
-		if globalDB != nil {
-			payload := map[string]string{"query": cleaned, "answer": resp.Answer, "status": "jailbreak_in_query"}
-			if b, err := json.Marshal(payload); err == nil {
-				_ = globalDB.Create("logs", &db.LogEntry{Reason: "query", Content: string(b), CreatedAt: time.Now()})
-			}
-		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(resp)
+	clients, ok := admitQuery(w, r)
+	if !ok {
 		return
 	}
+	answerQuery(w, r, clients, req, &jsonQueryOutput{w: w})
+}
 
-	if roleRe.MatchString(cleaned) || legalRe.MatchString(cleaned) || conditionalRe.MatchString(cleaned) || encodingRe.MatchString(cleaned) || splitRe.MatchString(cleaned) || roleplayRe.MatchString(cleaned) || testRe.MatchString(cleaned) || reverseRe.MatchString(cleaned) || tokenCountRe.MatchString(cleaned) || socialProofRe.MatchString(cleaned) || attachRe.MatchString(cleaned) {
-		logRejection("heuristic_in_query", cleaned)
-		fallback := loadFallbackMessage()
-		resp := llmResponse{Answer: fallback, Source: "policy"}
-		if globalDB != nil {
-			payload := map[string]string{"query": cleaned, "answer": resp.Answer, "status": "heuristic_in_query"}
-			if b, err := json.Marshal(payload); err == nil {
-				_ = globalDB.Create("logs", &db.LogEntry{Reason: "query", Content: string(b), CreatedAt: time.Now()})
-			}
+// A request stopped by the daily model limit gets a 429 before anything is
+// streamed; every other outcome ends with out.finish.
+func answerQuery(w http.ResponseWriter, r *http.Request, clients []string, req llmRequest, out queryOutput) {
+	session := clients[0]
+	convID := strings.TrimSpace(req.ConversationID)
+	if !validConversationID(convID) {
+		convID = newConversationID()
+	}
+	history := conversationHistory(session, convID)
+	out = &conversationOutput{queryOutput: out, id: convID}
+
+	rules := guardrailRules()
+	if rules == nil {
+		resp := llmResponse{Answer: loadFallbackMessage(), Source: "policy"}
+		logQuery(clients, req.Query, resp.Answer, "guardrails_unavailable", nil)
+		out.finish(resp)
+		return
+	}
+
+	input := rules.Evaluate(strings.TrimSpace(req.Query), guardrails.ScopeInput)
+	if input.Rejected() {
+		if input.Rule.Strike {
+			recordQueryStrike(clients, input.Rule.ID)
 		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(resp)
+		rejectQuery(out, clients, req.Query, req.Query, guardrails.ScopeInput, input)
+		return
+	}
+	logFlags(req.Query, guardrails.ScopeInput, input)
+	cleaned := sanitizeQuery(input.Text)
+	if cleaned == "" {
+		resp := llmResponse{Answer: loadFallbackMessage(), Source: "policy"}
+		logQuery(clients, req.Query, resp.Answer, "empty_query", nil)
+		out.finish(resp)
+		return
+	}
+	flags := strings.Join(input.RuleIDs(), ",")
+
+	idx := knowledgeIndex()
+	if faq, ok := faqAnswer(idx, cleaned); ok {
+		resp := llmResponse{Answer: faq.Answer, Source: citation(faq)}
+		logQuery(clients, cleaned, resp.Answer, "faq", map[string]string{"source": resp.Source, "rules": flags})
+		addConversationTurn(session, convID, cleaned, resp.Answer)
+		out.finish(resp)
+		return
+	}
+	results := idx.Search(retrievalQuery(cleaned, history), retrievalTopK)
+	if len(results) == 0 {
+		resp := llmResponse{Answer: loadFallbackMessage(), Source: "policy"}
+		logQuery(clients, cleaned, resp.Answer, "no_context", map[string]string{"rules": flags})
+		out.finish(resp)
 		return
 	}
 
@@ -207,119 +273,69 @@ func QueryHandler(w http.ResponseWriter, r *http.Request) {
 		systemPrompt = "You are Exunb0t, a concise assistant for Exun 2025. Answer only from the provided dataset."
 	}
 
-	eventsData, _ := loadEventsData()
-	inviteData, _ := loadInviteData()
-
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		http.Error(w, "server misconfigured", http.StatusInternalServerError)
-		return
-	}
-
-	ctx := context.Background()
-
 	var sb strings.Builder
 	sb.WriteString(systemPrompt)
-	sb.WriteString("\n\nDataset: ")
-	sb.WriteString(eventsData)
-	if inviteData != "" {
-		sb.WriteString("\n\nInvite: ")
-		sb.WriteString(inviteData)
-	}
-	sb.WriteString("\n\nUser query: ")
+	sb.WriteString("\n\nAnswer only from these excerpts of the official dataset. If they do not answer the question, reply with the fallback message.\n\n")
+	sb.WriteString(retrievalContext(results))
+	sb.WriteString(conversationContext(history))
+	sb.WriteString("User query: ")
 	sb.WriteString(cleaned)
 
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{APIKey: apiKey})
-	if err != nil {
-		http.Error(w, "llm error", http.StatusInternalServerError)
+	if wait := reserveLLMCall(); wait > 0 {
+		writeQueryLimited(w, wait, "The query assistant has reached its limit for today. Please try again in %s or email exun@dpsrkp.net.")
 		return
 	}
-
-	result, err := client.Models.GenerateContent(ctx, "gemini-2.5-flash", genai.Text(sb.String()), nil)
-	if err != nil {
-		http.Error(w, "llm error", http.StatusInternalServerError)
-		return
+	guard := &answerGuard{rules: rules, out: out}
+	provider, err := streamAnswer(r.Context(), sb.String(), guard.add)
+	recordLLMTokens(sb.String(), guard.text())
+	if err == nil && strings.TrimSpace(guard.text()) == "" {
+		err = llm.ErrEmptyAnswer
 	}
-	answer := strings.TrimSpace(result.Text())
-
-	lower := strings.ToLower(answer)
-	if injectionRe.MatchString(answer) || strings.Contains(lower, "secret") || strings.Contains(lower, "api_key") || strings.Contains(lower, "password") || strings.Contains(lower, "private") {
-		logRejection("sensitive_in_answer", answer)
-		fallback := loadFallbackMessage()
-		resp := llmResponse{Answer: fallback, Source: "policy"}
-		if globalDB != nil {
-			payload := map[string]string{"query": cleaned, "answer": resp.Answer, "status": "sensitive_in_answer"}
-			if b, err := json.Marshal(payload); err == nil {
-				_ = globalDB.Create("logs", &db.LogEntry{Reason: "query", Content: string(b), CreatedAt: time.Now()})
-			}
-		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(resp)
+	refused := retractOutput{out}
+	switch {
+	case errors.Is(err, errAnswerRejected):
+		rejectQuery(refused, clients, cleaned, guard.text(), guardrails.ScopeOutput, guard.rejected)
 		return
-	}
-	if dumpRe.MatchString(answer) || ackRe.MatchString(answer) || jailbreakRe.MatchString(answer) || base64OutputRe.MatchString(answer) {
-		logRejection("dump_in_answer", answer)
-		fallback := loadFallbackMessage()
-		resp := llmResponse{Answer: fallback, Source: "policy"}
-		if globalDB != nil {
-			payload := map[string]string{"query": cleaned, "answer": resp.Answer, "status": "dump_in_answer"}
-			if b, err := json.Marshal(payload); err == nil {
-				_ = globalDB.Create("logs", &db.LogEntry{Reason: "query", Content: string(b), CreatedAt: time.Now()})
-			}
+	case errors.Is(err, errAnswerTooLong):
+		resp := llmResponse{Answer: loadFallbackMessage(), Source: "policy"}
+		logQuery(clients, cleaned, resp.Answer, "length_or_commas", nil)
+		refused.finish(resp)
+		return
+	case err != nil:
+		if r.Context().Err() != nil {
+			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(resp)
+		log.Printf("query bot: %v", err)
+		resp := llmResponse{Answer: loadFallbackMessage(), Source: "policy"}
+		logQuery(clients, cleaned, resp.Answer, "llm_error", nil)
+		refused.finish(resp)
 		return
 	}
 
-	if roleRe.MatchString(answer) || legalRe.MatchString(answer) || conditionalRe.MatchString(answer) || encodingRe.MatchString(answer) || splitRe.MatchString(answer) || roleplayRe.MatchString(answer) || testRe.MatchString(answer) || reverseRe.MatchString(answer) || tokenCountRe.MatchString(answer) || socialProofRe.MatchString(answer) || attachRe.MatchString(answer) || htmlRe.MatchString(answer) || hexOutputRe.MatchString(answer) || emailRe.MatchString(answer) {
-		logRejection("heuristic_in_answer", answer)
-		fallback := loadFallbackMessage()
-		resp := llmResponse{Answer: fallback, Source: "policy"}
-		if globalDB != nil {
-			payload := map[string]string{"query": cleaned, "answer": resp.Answer, "status": "heuristic_in_answer"}
-			if b, err := json.Marshal(payload); err == nil {
-				_ = globalDB.Create("logs", &db.LogEntry{Reason: "query", Content: string(b), CreatedAt: time.Now()})
-			}
-		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(resp)
+	answer := strings.TrimSpace(guard.text())
+	output := rules.Evaluate(answer, guardrails.ScopeOutput)
+	if output.Rejected() {
+		rejectQuery(refused, clients, cleaned, answer, guardrails.ScopeOutput, output)
 		return
 	}
-	if strings.Count(answer, ",") > 8 || len(answer) > 2000 {
-		fallback := loadFallbackMessage()
-		resp := llmResponse{Answer: fallback, Source: "policy"}
-		if globalDB != nil {
-			payload := map[string]string{"query": cleaned, "answer": resp.Answer, "status": "length_or_commas"}
-			if b, err := json.Marshal(payload); err == nil {
-				_ = globalDB.Create("logs", &db.LogEntry{Reason: "query", Content: string(b), CreatedAt: time.Now()})
-			}
-		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(resp)
+	logFlags(answer, guardrails.ScopeOutput, output)
+	answer = output.Text
+	if strings.Count(answer, ",") > 8 || len(answer) > maxAnswerLength {
+		resp := llmResponse{Answer: loadFallbackMessage(), Source: "policy"}
+		logQuery(clients, cleaned, resp.Answer, "length_or_commas", nil)
+		refused.finish(resp)
 		return
 	}
-	if strings.Contains(answer, "{\"") || strings.Contains(answer, "\":") {
-		fallback := loadFallbackMessage()
-		resp := llmResponse{Answer: fallback, Source: "policy"}
-		if globalDB != nil {
-			payload := map[string]string{"query": cleaned, "answer": resp.Answer, "status": "looks_like_json"}
-			if b, err := json.Marshal(payload); err == nil {
-				_ = globalDB.Create("logs", &db.LogEntry{Reason: "query", Content: string(b), CreatedAt: time.Now()})
-			}
+	if ids := output.RuleIDs(); len(ids) > 0 {
+		if flags != "" {
+			flags += ","
 		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(resp)
-		return
+		flags += strings.Join(ids, ",")
 	}
 
-	resp := llmResponse{Answer: answer, Source: "llm"}
-	if globalDB != nil {
-		payload := map[string]string{"query": cleaned, "answer": resp.Answer, "status": "ok"}
-		if b, err := json.Marshal(payload); err == nil {
-			_ = globalDB.Create("logs", &db.LogEntry{Reason: "query", Content: string(b), CreatedAt: time.Now()})
-		}
-	}
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(resp)
+	resp := llmResponse{Answer: answer, Source: citations(results)}
+	logQuery(clients, cleaned, resp.Answer, "ok", map[string]string{"source": resp.Source, "provider": provider, "rules": flags})
+	addConversationTurn(session, convID, cleaned, resp.Answer)
+	guard.send(answer)
+	out.finish(resp)
 }
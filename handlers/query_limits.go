@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"exunreg25/middleware"
+)
+
+const (
+	querySessionCookie = "query_session"
+
+	// The cooldown doubles with every strike after strikesBeforeCooldown.
+	strikesBeforeCooldown = 3
+	strikeDecay           = 24 * time.Hour
+	baseQueryCooldown     = time.Minute
+	maxQueryCooldown      = 24 * time.Hour
+)
+
+type QueryLimits struct {
+	PerMinute   int
+	Burst       int
+	IPPerMinute int
+	IPBurst     int
+	DailyCalls  int
+	DailyTokens int
+}
+
+var (
+	queryLimits   QueryLimits
+	clientLimiter *middleware.RateLimiter
+	ipLimiter     *middleware.RateLimiter
+)
+
+// The IP bucket is looser because a whole school may share one address.
+func SetQueryLimits(l QueryLimits) {
+	queryLimits = l
+	if l.PerMinute > 0 {
+		clientLimiter = middleware.NewRateLimiter(l.PerMinute, l.Burst)
+	}
+	if l.IPPerMinute > 0 {
+		ipLimiter = middleware.NewRateLimiter(l.IPPerMinute, l.IPBurst)
+	}
+}
+
+func queryClients(w http.ResponseWriter, r *http.Request) []string {
+	var session string
+	fresh := false
+	if c, err := r.Cookie(querySessionCookie); err == nil && len(c.Value) == 32 {
+		session = c.Value
+	} else {
+		fresh = true
+		b := make([]byte, 16)
+		rand.Read(b)
+		session = hex.EncodeToString(b)
+		secure := globalAuthHandler != nil && globalAuthHandler.config.CookieSecure
+		http.SetCookie(w, &http.Cookie{
+			Name:     querySessionCookie,
+			Value:    session,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   secure,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	ip := middleware.ClientIP(r)
+	clients := []string{"session:" + session}
+	if fresh {
+		// A client that drops the cookie gets a new session every time, so
+		// cookieless queries from one IP share a session-sized bucket.
+		clients = append(clients, "anon:"+ip)
+	}
+	clients = append(clients, "ip:"+ip)
+	if globalAuthHandler != nil && globalAuthHandler.isAuthenticated(r) {
+		clients = append(clients, "email:"+strings.ToLower(globalAuthHandler.getAuthenticatedUser(r)))
+	}
+	return clients
+}
+
+func queryBlocked(clients []string) bool {
+	if globalDB == nil {
+		return false
+	}
+	blocked, err := globalDB.QueryBlocked(clients)
+	if err != nil {
+		log.Printf("%v", err)
+		return false
+	}
+	return blocked != ""
+}
+
+func checkQueryLimits(clients []string) time.Duration {
+	now := time.Now().UTC()
+	if globalDB != nil {
+		until, err := globalDB.QueryCooldown(clients, now)
+		if err != nil {
+			log.Printf("%v", err)
+		} else if !until.IsZero() {
+			return until.Sub(now)
+		}
+	}
+	for _, c := range clients {
+		limiter := clientLimiter
+		if strings.HasPrefix(c, "ip:") {
+			limiter = ipLimiter
+		}
+		if limiter == nil {
+			continue
+		}
+		if ok, d := limiter.Allow(c); !ok {
+			return d
+		}
+	}
+	return 0
+}
+
+// IPs are not struck, since one student could lock out a whole school.
+func recordQueryStrike(clients []string, reason string) {
+	if globalDB == nil {
+		return
+	}
+	now := time.Now().UTC()
+	for _, c := range clients {
+		if ipClient(c) {
+			continue
+		}
+		strikes, err := globalDB.AddQueryStrike(c, now, strikeDecay)
+		if err != nil {
+			log.Printf("%v", err)
+			continue
+		}
+		if strikes < strikesBeforeCooldown {
+			continue
+		}
+		cooldown := time.Duration(float64(baseQueryCooldown) * math.Pow(2, float64(strikes-strikesBeforeCooldown)))
+		if cooldown > maxQueryCooldown || cooldown <= 0 {
+			cooldown = maxQueryCooldown
+		}
+		if err := globalDB.SetQueryCooldown(c, now.Add(cooldown)); err != nil {
+			log.Printf("failed to set query cooldown for %s: %v", c, err)
+			continue
+		}
+		log.Printf("query client %s on cooldown for %s after %d strikes (%s)", c, cooldown, strikes, reason)
+	}
+}
+
+// ipClient reports whether c stands for every client behind an IP.
+func ipClient(c string) bool {
+	return strings.HasPrefix(c, "ip:") || strings.HasPrefix(c, "anon:")
+}
+
+func usageDay(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func reserveLLMCall() time.Duration {
+	if globalDB == nil {
+		return 0
+	}
+	now := time.Now()
+	ok, err := globalDB.ReserveLLMCall(usageDay(now), queryLimits.DailyCalls, queryLimits.DailyTokens)
+	if err != nil {
+		log.Printf("%v", err)
+		return 0
+	}
+	if ok {
+		return 0
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	return midnight.Sub(now)
+}
+
+// Tokens are estimated at four characters each.
+func recordLLMTokens(prompt, answer string) {
+	if globalDB == nil {
+		return
+	}
+	if err := globalDB.AddLLMTokens(usageDay(time.Now()), (len(prompt)+len(answer)+3)/4); err != nil {
+		log.Printf("failed to record llm usage: %v", err)
+	}
+}
+
+func writeQueryLimited(w http.ResponseWriter, wait time.Duration, answer string) {
+	secs := int(math.Ceil(wait.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	w.WriteHeader(http.StatusTooManyRequests)
+	_ = json.NewEncoder(w).Encode(llmResponse{Answer: fmt.Sprintf(answer, retryText(secs)), Source: "rate_limit"})
+}
+
+func retryText(secs int) string {
+	switch {
+	case secs < 120:
+		return fmt.Sprintf("%d seconds", secs)
+	case secs < 2*3600:
+		return fmt.Sprintf("%d minutes", (secs+59)/60)
+	default:
+		return fmt.Sprintf("%d hours", (secs+3599)/3600)
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"exunreg25/llm"
+)
+
+func TestRetryText(t *testing.T) {
+	tests := []struct {
+		secs int
+		want string
+	}{
+		{1, "1 seconds"},
+		{119, "119 seconds"},
+		{120, "2 minutes"},
+		{121, "3 minutes"},
+		{7199, "120 minutes"},
+		{7200, "2 hours"},
+		{7201, "3 hours"},
+	}
+	for _, tt := range tests {
+		if got := retryText(tt.secs); got != tt.want {
+			t.Errorf("retryText(%d) = %q, want %q", tt.secs, got, tt.want)
+		}
+	}
+}
+
+func TestWriteQueryLimited(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeQueryLimited(w, 1500*time.Millisecond, "Try again in %s.")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+		t.Errorf("status %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	var resp llmResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Answer != "Try again in 2 seconds." || resp.Source != "rate_limit" {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestRecordQueryStrikeEscalates(t *testing.T) {
+	useTestDB(t)
+	clients := []string{"session:s", "anon:192.0.2.1", "ip:192.0.2.1"}
+	tests := []struct {
+		strike   int
+		cooldown time.Duration
+	}{
+		{1, 0},
+		{2, 0},
+		{3, baseQueryCooldown},
+		{4, 2 * baseQueryCooldown},
+		{5, 4 * baseQueryCooldown},
+	}
+	for _, tt := range tests {
+		recordQueryStrike(clients, "test")
+		wait := checkQueryLimits(clients)
+		if tt.cooldown == 0 && wait != 0 {
+			t.Errorf("strike %d: wait %s, want none", tt.strike, wait)
+		}
+		if tt.cooldown > 0 && (wait <= tt.cooldown-5*time.Second || wait > tt.cooldown) {
+			t.Errorf("strike %d: wait %s, want about %s", tt.strike, wait, tt.cooldown)
+		}
+	}
+	if wait := checkQueryLimits([]string{"session:other"}); wait != 0 {
+		t.Errorf("an unrelated client waits %s", wait)
+	}
+	if wait := checkQueryLimits([]string{"session:other", "anon:192.0.2.1", "ip:192.0.2.1"}); wait != 0 {
+		t.Errorf("a client sharing the struck session's IP waits %s", wait)
+	}
+}
+
+func TestQueryHandlerRateLimits(t *testing.T) {
+	setupQueryBot(t, []llm.StubRule{{Answer: "Crossword is an online event."}})
+	prev, prevClient, prevIP := queryLimits, clientLimiter, ipLimiter
+	t.Cleanup(func() { queryLimits, clientLimiter, ipLimiter = prev, prevClient, prevIP })
+	SetQueryLimits(QueryLimits{PerMinute: 1, Burst: 2, IPPerMinute: 1, IPBurst: 3})
+
+	ask := func(cookie *http.Cookie, ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/query", strings.NewReader(`{"query":"crossword team size"}`))
+		r.RemoteAddr = ip + ":1234"
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		QueryHandler(w, r)
+		return w
+	}
+
+	first := ask(nil, "192.0.2.1")
+	cookies := first.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != querySessionCookie {
+		t.Fatalf("no session cookie issued: %v", cookies)
+	}
+	session := cookies[0]
+	steps := []struct {
+		name   string
+		cookie *http.Cookie
+		ip     string
+		want   int
+	}{
+		{"second in session burst", session, "192.0.2.1", http.StatusOK},
+		{"session bucket empty", session, "192.0.2.1", http.StatusTooManyRequests},
+		{"refusal spares the IP bucket", nil, "192.0.2.1", http.StatusOK},
+		{"IP bucket empty", nil, "192.0.2.1", http.StatusTooManyRequests},
+		{"new session, new IP", nil, "192.0.2.2", http.StatusOK},
+		{"second cookieless query", nil, "192.0.2.2", http.StatusOK},
+		{"cookieless bucket empty", nil, "192.0.2.2", http.StatusTooManyRequests},
+	}
+	for _, s := range steps {
+		w := ask(s.cookie, s.ip)
+		if w.Code != s.want {
+			t.Errorf("%s: status %d, want %d", s.name, w.Code, s.want)
+		}
+		if s.want == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: no Retry-After", s.name)
+		}
+	}
+}
+
+func TestQueryHandlerDailyCap(t *testing.T) {
+	useTestDB(t)
+	stub := setupQueryBot(t, []llm.StubRule{{Answer: "Crossword is an online event."}})
+	prev := queryLimits
+	t.Cleanup(func() { queryLimits = prev })
+	queryLimits = QueryLimits{DailyCalls: 1}
+
+	codes := []int{}
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		QueryHandler(w, httptest.NewRequest(http.MethodPost, "/api/query", strings.NewReader(`{"query":"crossword team size"}`)))
+		codes = append(codes, w.Code)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("statuses = %v, want 200 then 429", codes)
+	}
+	if n := len(stub.Prompts()); n != 1 {
+		t.Errorf("model called %d times, want 1", n)
+	}
+}
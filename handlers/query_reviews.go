@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"exunreg25/db"
+)
+
+type queryReviewRequest struct {
+	State string `json:"state"`
+	Note  string `json:"note"`
+}
+
+type queryPromoteRequest struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+type queryBlockRequest struct {
+	Clients []string `json:"clients"`
+	Reason  string   `json:"reason"`
+}
+
+func (ah *AdminHandler) QueryReviews(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/query-reviews"), "/"), "/")
+
+	if parts[0] == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		ah.listQueryReviews(w, r)
+		return
+	}
+
+	if parts[0] == "blocked" {
+		switch r.Method {
+		case http.MethodGet:
+			clients, err := ah.db.ListBlockedQueryClients()
+			if err != nil {
+				http.Error(w, "Failed to list blocked clients", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": clients})
+		case http.MethodDelete:
+			client := strings.TrimSpace(r.URL.Query().Get("client"))
+			ok, err := ah.db.UnblockQueryClient(client)
+			if err != nil {
+				http.Error(w, "Failed to unblock client", http.StatusInternalServerError)
+				return
+			}
+			if !ok {
+				http.Error(w, "Client is not blocked", http.StatusNotFound)
+				return
+			}
+			log.Printf("query client %s unblocked by %s", client, email)
+			recordAudit(r, email, "query.unblock", "query_strikes", client, map[string]interface{}{"blocked": true}, map[string]interface{}{"blocked": false})
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) > 2 {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	entry, err := ah.db.GetQueryLog(id)
+	if err != nil {
+		http.Error(w, "Failed to load query", http.StatusInternalServerError)
+		return
+	}
+	if entry == nil {
+		http.Error(w, "Query not found", http.StatusNotFound)
+		return
+	}
+	action := ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch action {
+	case "":
+		var req queryReviewRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.State = strings.TrimSpace(req.State)
+		if req.State == "pending" {
+			err = ah.db.ClearQueryReview(id)
+		} else if req.State == db.ReviewFalsePositive || req.State == db.ReviewDismissed {
+			err = ah.db.SetQueryReview(id, req.State, strings.TrimSpace(req.Note), email)
+		} else {
+			http.Error(w, "state must be false_positive, dismissed or pending", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to save review", http.StatusInternalServerError)
+			return
+		}
+		recordAudit(r, email, "query.review", "logs", strconv.Itoa(id), entry.Review, req)
+
+	case "promote":
+		req := queryPromoteRequest{Question: entry.Query, Answer: entry.Answer}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		req.Question = strings.TrimSpace(req.Question)
+		req.Answer = strings.TrimSpace(req.Answer)
+		if req.Question == "" || req.Answer == "" {
+			http.Error(w, "Question and answer are required", http.StatusBadRequest)
+			return
+		}
+		if err := appendFAQ(req.Question, req.Answer); err != nil {
+			log.Printf("failed to promote query %d to the FAQ: %v", id, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := ah.db.SetQueryReview(id, db.ReviewPromoted, req.Question, email); err != nil {
+			log.Printf("failed to mark query %d promoted: %v", id, err)
+		}
+		log.Printf("query %d promoted to the FAQ by %s", id, email)
+		recordAudit(r, email, "query.promote", "faq", strconv.Itoa(id), nil, req)
+
+	case "block":
+		var req queryBlockRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		clients := req.Clients
+		if len(clients) == 0 {
+			clients = defaultBlockClients(entry.Clients)
+		}
+		if len(clients) == 0 {
+			http.Error(w, "The query has no client to block", http.StatusBadRequest)
+			return
+		}
+		for _, c := range clients {
+			if !slices.Contains(entry.Clients, c) {
+				http.Error(w, "Client "+c+" did not send this query", http.StatusBadRequest)
+				return
+			}
+		}
+		for _, c := range clients {
+			if err := ah.db.BlockQueryClient(c, email, strings.TrimSpace(req.Reason)); err != nil {
+				http.Error(w, "Failed to block client", http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := ah.db.SetQueryReview(id, db.ReviewBlocked, strings.TrimSpace(req.Reason), email); err != nil {
+			log.Printf("failed to mark query %d blocked: %v", id, err)
+		}
+		log.Printf("query clients %s blocked by %s", strings.Join(clients, ", "), email)
+		recordAudit(r, email, "query.block", "query_strikes", strings.Join(clients, ","), nil, map[string]interface{}{"log_id": id, "reason": req.Reason})
+
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	entry, err = ah.db.GetQueryLog(id)
+	if err != nil {
+		http.Error(w, "Failed to load query", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": entry})
+}
+
+func (ah *AdminHandler) listQueryReviews(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := db.QueryLogFilter{
+		Status: strings.TrimSpace(q.Get("status")),
+		Rule:   strings.TrimSpace(q.Get("rule")),
+		Review: strings.TrimSpace(q.Get("review")),
+		Search: strings.TrimSpace(q.Get("q")),
+		All:    q.Get("all") == "1" || q.Get("all") == "true",
+	}
+	if f.Review == "" {
+		f.Review = "pending"
+	} else if f.Review == "any" {
+		f.Review = ""
+	}
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(q.Get("per_page"))
+	if perPage < 1 || perPage > 500 {
+		perPage = 50
+	}
+	f.Limit = perPage
+	f.Offset = (page - 1) * perPage
+	entries, total, err := ah.db.ListQueryLogs(f)
+	if err != nil {
+		log.Printf("%v", err)
+		http.Error(w, "Failed to list queries", http.StatusInternalServerError)
+		return
+	}
+	counts, err := ah.db.QueryReviewCounts()
+	if err != nil {
+		log.Printf("%v", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":     entries,
+		"total":    total,
+		"page":     page,
+		"per_page": perPage,
+		"counts":   counts,
+	})
+}
+
+// An IP is blocked only when there is nothing narrower, since it may be a
+// whole school's.
+func defaultBlockClients(clients []string) []string {
+	var out []string
+	for _, c := range clients {
+		if !ipClient(c) {
+			out = append(out, c)
+		}
+	}
+	if len(out) == 0 {
+		return clients
+	}
+	return out
+}
+
+func QueryReviews(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.QueryReviews(w, r)
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"exunreg25/db"
+)
+
+func TestDefaultBlockClients(t *testing.T) {
+	tests := []struct {
+		name    string
+		clients []string
+		want    []string
+	}{
+		{"session and ip", []string{"session:a", "ip:1.2.3.4"}, []string{"session:a"}},
+		{"signed in", []string{"session:a", "ip:1.2.3.4", "email:x@y.org"}, []string{"session:a", "email:x@y.org"}},
+		{"cookieless", []string{"session:a", "anon:1.2.3.4", "ip:1.2.3.4"}, []string{"session:a"}},
+		{"ip only", []string{"ip:1.2.3.4"}, []string{"ip:1.2.3.4"}},
+		{"none", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := defaultBlockClients(tt.clients); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("defaultBlockClients = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendFAQ(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll(filepath.Dir(faqPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(faqPath, []byte(`{"title": "FAQ", "faq": [{"question": "Is there a fee?", "answer": "No."}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		question string
+		errHas   string
+	}{
+		{"new question", "Can juniors take part in Quiz & Crossword?", ""},
+		{"duplicate", "is there a fee?", "already has"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := appendFAQ(tt.question, "Yes.")
+			if tt.errHas == "" && err != nil || tt.errHas != "" && (err == nil || !strings.Contains(err.Error(), tt.errHas)) {
+				t.Errorf("appendFAQ = %v, want %q", err, tt.errHas)
+			}
+		})
+	}
+
+	b, err := os.ReadFile(faqPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "Quiz & Crossword") {
+		t.Errorf("the FAQ file escapes HTML:\n%s", b)
+	}
+	var doc struct {
+		Title string `json:"title"`
+		FAQ   []struct {
+			Question string `json:"question"`
+		} `json:"faq"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title != "FAQ" || len(doc.FAQ) != 2 || doc.FAQ[1].Question != "Can juniors take part in Quiz & Crossword?" {
+		t.Errorf("faq.json = %+v", doc)
+	}
+}
+
+func TestQueryBlockedClient(t *testing.T) {
+	database := useTestDB(t)
+	stub := setupQueryBot(t, nil)
+	if err := database.BlockQueryClient("ip:192.0.2.1", "admin@x.org", "abuse"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	QueryHandler(w, httptest.NewRequest(http.MethodPost, "/api/query", strings.NewReader(`{"query": "when is crossword"}`)))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", w.Code)
+	}
+	if n := len(stub.Prompts()); n != 0 {
+		t.Errorf("the model was called %d times for a blocked client", n)
+	}
+
+	if _, err := database.UnblockQueryClient("ip:192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	askQuery(t, "When is Exun 2025 being held?")
+	logs, _, err := database.ListQueryLogs(db.QueryLogFilter{All: true})
+	if err != nil || len(logs) != 1 {
+		t.Fatalf("ListQueryLogs = %+v, %v", logs, err)
+	}
+	if c := logs[0].Clients; len(c) != 3 || c[1] != "anon:192.0.2.1" || c[2] != "ip:192.0.2.1" {
+		t.Errorf("logged clients = %v", logs[0].Clients)
+	}
+}
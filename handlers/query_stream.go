@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"exunreg25/guardrails"
+)
+
+const (
+	maxAnswerLength = 2000
+	// Held back so a guardrail pattern completed by later text can still act.
+	streamHoldback = 32
+)
+
+var (
+	errAnswerRejected = errors.New("answer rejected by guardrails")
+	errAnswerTooLong  = errors.New("answer too long")
+)
+
+// Nothing is written before the first call, so a query refused before the
+// model runs can still be answered with a plain status.
+type queryOutput interface {
+	token(text string)
+	replace(text string)
+	finish(resp llmResponse)
+}
+
+type jsonQueryOutput struct {
+	w http.ResponseWriter
+}
+
+func (o *jsonQueryOutput) token(string)   {}
+func (o *jsonQueryOutput) replace(string) {}
+
+func (o *jsonQueryOutput) finish(resp llmResponse) {
+	writeQueryResponse(o.w, resp)
+}
+
+type sseQueryOutput struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	started bool
+}
+
+func newSSEQueryOutput(w http.ResponseWriter) *sseQueryOutput {
+	return &sseQueryOutput{w: w, rc: http.NewResponseController(w)}
+}
+
+func (o *sseQueryOutput) event(name string, v any) {
+	if !o.started {
+		h := o.w.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		h.Set("X-Accel-Buffering", "no")
+		o.w.WriteHeader(http.StatusOK)
+		o.started = true
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(o.w, "event: %s\ndata: %s\n\n", name, b)
+	_ = o.rc.Flush()
+}
+
+func (o *sseQueryOutput) token(text string) {
+	o.event("token", map[string]string{"text": text})
+}
+
+func (o *sseQueryOutput) replace(text string) {
+	o.event("replace", map[string]string{"text": text})
+}
+
+func (o *sseQueryOutput) finish(resp llmResponse) {
+	o.event("done", resp)
+}
+
+type conversationOutput struct {
+	queryOutput
+	id string
+}
+
+func (o *conversationOutput) finish(resp llmResponse) {
+	resp.ConversationID = o.id
+	o.queryOutput.finish(resp)
+}
+
+// retractOutput replaces streamed text with the answer of a late refusal.
+type retractOutput struct {
+	queryOutput
+}
+
+func (o retractOutput) finish(resp llmResponse) {
+	o.replace(resp.Answer)
+	o.queryOutput.finish(resp)
+}
+
+func validConversationID(id string) bool {
+	if len(id) != 24 {
+		return false
+	}
+	for _, c := range id {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+type answerGuard struct {
+	rules    *guardrails.Ruleset
+	out      queryOutput
+	raw      strings.Builder
+	sent     string
+	rejected guardrails.Result
+}
+
+func (g *answerGuard) add(chunk string) error {
+	g.raw.WriteString(chunk)
+	if g.raw.Len() > maxAnswerLength {
+		return errAnswerTooLong
+	}
+	res := g.rules.Evaluate(g.raw.String(), guardrails.ScopeOutput)
+	if res.Rejected() {
+		g.rejected = res
+		return errAnswerRejected
+	}
+	safe := res.Text
+	cut := len(safe) - streamHoldback
+	for cut > 0 && !utf8.RuneStart(safe[cut]) {
+		cut--
+	}
+	if cut <= 0 {
+		return nil
+	}
+	g.send(safe[:cut])
+	return nil
+}
+
+// send replaces the client's text when a redaction changed what it has.
+func (g *answerGuard) send(safe string) {
+	switch {
+	case safe == g.sent:
+	case strings.HasPrefix(safe, g.sent):
+		g.out.token(safe[len(g.sent):])
+	default:
+		g.out.replace(safe)
+	}
+	g.sent = safe
+}
+
+func (g *answerGuard) text() string {
+	return g.raw.String()
+}
+
+func QueryStreamHandler(w http.ResponseWriter, r *http.Request) {
+	req, ok := readQueryRequest(w, r)
+	if !ok {
+		return
+	}
+	clients, ok := admitQuery(w, r)
+	if !ok {
+		return
+	}
+	answerQuery(w, r, clients, req, newSSEQueryOutput(w))
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"exunreg25/guardrails"
+	"exunreg25/llm"
+)
+
+// recordedOutput keeps what answerQuery sent, in order.
+type recordedOutput struct {
+	events []string
+	done   *llmResponse
+}
+
+func (o *recordedOutput) token(text string)   { o.events = append(o.events, "token:"+text) }
+func (o *recordedOutput) replace(text string) { o.events = append(o.events, "replace:"+text) }
+func (o *recordedOutput) finish(resp llmResponse) {
+	o.done = &resp
+}
+
+// shown is the text a client ends up displaying from the streamed events.
+func (o *recordedOutput) shown() string {
+	var s string
+	for _, e := range o.events {
+		kind, text, _ := strings.Cut(e, ":")
+		if kind == "token" {
+			s += text
+		} else {
+			s = text
+		}
+	}
+	return s
+}
+
+func TestAnswerGuard(t *testing.T) {
+	rules, err := guardrails.Parse([]byte(`{"rules": [
+		{"id": "phone", "pattern": "\\b\\d{10}\\b", "scope": "output", "action": "redact"},
+		{"id": "quote", "pattern": "BEGIN.*END", "scope": "output", "action": "redact"},
+		{"id": "secret", "pattern": "(?i)secret", "scope": "output", "action": "reject"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filler := strings.Repeat("x", streamHoldback)
+	tests := []struct {
+		name     string
+		chunks   []string
+		err      error
+		shown    string
+		replaced bool
+	}{
+		{"held back", []string{"Crossword is online."}, nil, "", false},
+		{"forwarded past the holdback", []string{"Crossword is online. ", filler}, nil, "Crossword is online. ", false},
+		{"pattern split across chunks", []string{"Call 98765", "43210 now. ", filler}, nil, "Call [BLOCKED] now. ", false},
+		{"redaction of sent text", []string{"Hi BEGIN " + filler, "END ok " + filler}, nil, "Hi [BLOCKED] ok ", true},
+		{"rejection", []string{"The " + filler, "secret is out"}, errAnswerRejected, "The ", false},
+		{"too long", []string{strings.Repeat("a", maxAnswerLength), "a"}, errAnswerTooLong, strings.Repeat("a", maxAnswerLength-streamHoldback), false},
+		{"multibyte cut", []string{"é" + strings.Repeat("é", streamHoldback/2)}, nil, "é", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &recordedOutput{}
+			g := &answerGuard{rules: rules, out: out}
+			var err error
+			for _, c := range tt.chunks {
+				if err = g.add(c); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("add = %v, want %v", err, tt.err)
+			}
+			if got := out.shown(); got != tt.shown {
+				t.Errorf("shown %q, want %q (events %q)", got, tt.shown, out.events)
+			}
+			if replaced := strings.Contains(strings.Join(out.events, "\n"), "replace:"); replaced != tt.replaced {
+				t.Errorf("replaced = %v, want %v (events %q)", replaced, tt.replaced, out.events)
+			}
+			if strings.Contains(out.shown(), "secret") || strings.Contains(out.shown(), "9876543210") {
+				t.Errorf("blocked text reached the client: %q", out.events)
+			}
+		})
+	}
+}
+
+func streamQuery(t *testing.T, query string) (string, llmResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/query/stream", nil)
+	answerQuery(w, r, []string{"session:test"}, llmRequest{Query: query}, newSSEQueryOutput(w))
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type = %q", ct)
+	}
+	body := w.Body.String()
+	i := strings.LastIndex(body, "event: done\ndata: ")
+	if i < 0 {
+		t.Fatalf("no done event:\n%s", body)
+	}
+	var done llmResponse
+	if err := json.Unmarshal([]byte(strings.TrimSpace(body[i+len("event: done\ndata: "):])), &done); err != nil {
+		t.Fatal(err)
+	}
+	return body, done
+}
+
+// streamedText is the text a client ends up displaying from the token and
+// replace events of an SSE body.
+func streamedText(t *testing.T, body string) string {
+	t.Helper()
+	var s string
+	for _, ev := range strings.Split(body, "\n\n") {
+		name, data, ok := strings.Cut(strings.TrimPrefix(ev, "event: "), "\ndata: ")
+		if !ok || name == "done" {
+			continue
+		}
+		var payload struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal([]byte(data), &payload); err != nil {
+			t.Fatal(err)
+		}
+		if name == "token" {
+			s += payload.Text
+		} else {
+			s = payload.Text
+		}
+	}
+	return s
+}
+
+func TestQueryStream(t *testing.T) {
+	long := "Crossword is an online event for one participant, held on the first day of Exun."
+	tests := []struct {
+		name    string
+		answer  string
+		source  string
+		hidden  string
+		streams bool
+	}{
+		{"answer", long, "Event: Crossword", "", true},
+		{"rejected midway", long + " For anything else email someone@example.com today.", "policy", "example.com", true},
+		{"too many commas", long + " It has rounds, clues, grids, themes, hints, timers, scores, ranks, and prizes.", "policy", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupQueryBot(t, []llm.StubRule{{Match: "crossword", Answer: tt.answer}})
+			body, done := streamQuery(t, "tell me about crossword")
+			if !strings.Contains(done.Source, tt.source) || done.ConversationID == "" {
+				t.Errorf("done = %+v", done)
+			}
+			if tt.source == "policy" && done.Answer != loadFallbackMessage() {
+				t.Errorf("answer = %q, want the fallback", done.Answer)
+			}
+			if tt.hidden != "" && strings.Contains(body, tt.hidden) {
+				t.Errorf("%q reached the client:\n%s", tt.hidden, body)
+			}
+			if strings.Contains(body, "event: token") != tt.streams {
+				t.Errorf("streamed tokens = %v, want %v:\n%s", !tt.streams, tt.streams, body)
+			}
+			if got := streamedText(t, body); got != done.Answer {
+				t.Errorf("client shows %q, want the final answer %q", got, done.Answer)
+			}
+		})
+	}
+}
+
+func TestQueryConversationFollowUp(t *testing.T) {
+	stub := setupQueryBot(t, []llm.StubRule{{Answer: "Crossword is for classes 6 to 12."}})
+	_, first := streamQuery(t, "who can take part in crossword")
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/query/stream", nil)
+	answerQuery(w, r, []string{"session:test"}, llmRequest{Query: "and the team size?", ConversationID: first.ConversationID}, newSSEQueryOutput(w))
+
+	prompts := stub.Prompts()
+	if len(prompts) != 2 {
+		t.Fatalf("model called %d times", len(prompts))
+	}
+	if !strings.Contains(prompts[1], "User: who can take part in crossword\nAssistant: Crossword is for classes 6 to 12.") {
+		t.Errorf("follow-up prompt lacks the earlier turn:\n%s", prompts[1])
+	}
+	if strings.Contains(prompts[0], "Conversation so far") {
+		t.Errorf("first prompt has history:\n%s", prompts[0])
+	}
+}
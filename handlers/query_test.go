@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"exunreg25/guardrails"
+	"exunreg25/llm"
+)
+
+func setupQueryBot(t *testing.T, rules []llm.StubRule) *llm.StubProvider {
+	t.Helper()
+	t.Chdir("..")
+	stub := llm.NewStubProvider(rules)
+	prevProvider, prevEngine := llmProvider, guardrailEngine
+	SetLLMProvider(llm.NewChain(0, stub))
+	SetGuardrails(guardrails.NewEngine("handlers/guardrails.json"))
+	t.Cleanup(func() {
+		llmProvider, guardrailEngine = prevProvider, prevEngine
+	})
+	if guardrailRules() == nil {
+		t.Fatal("guardrail rules did not load")
+	}
+	return stub
+}
+
+func askQuery(t *testing.T, query string) llmResponse {
+	t.Helper()
+	body, _ := json.Marshal(llmRequest{Query: query})
+	w := httptest.NewRecorder()
+	QueryHandler(w, httptest.NewRequest(http.MethodPost, "/api/query", strings.NewReader(string(body))))
+	var resp llmResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	return resp
+}
+
+func TestQueryFAQShortCircuit(t *testing.T) {
+	stub := setupQueryBot(t, nil)
+	resp := askQuery(t, "When is Exun 2025 being held?")
+	if resp.Source != "FAQ: When is Exun 2025 being held?" {
+		t.Errorf("source = %q", resp.Source)
+	}
+	if !strings.Contains(resp.Answer, "Exun Week") {
+		t.Errorf("answer = %q, want the FAQ answer", resp.Answer)
+	}
+	if n := len(stub.Prompts()); n != 0 {
+		t.Errorf("the model was called %d times for an FAQ question", n)
+	}
+}
+
+func TestQueryCitesRetrievedExcerpts(t *testing.T) {
+	stub := setupQueryBot(t, []llm.StubRule{{Match: "crossword", Answer: "Crossword is an online event for one participant."}})
+	resp := askQuery(t, "how many people can take part in crossword")
+	if resp.Answer != "Crossword is an online event for one participant." {
+		t.Errorf("answer = %q", resp.Answer)
+	}
+	if !strings.Contains(resp.Source, "Event: Crossword") {
+		t.Errorf("source = %q, want the Crossword event cited", resp.Source)
+	}
+	prompts := stub.Prompts()
+	if len(prompts) != 1 {
+		t.Fatalf("model called %d times", len(prompts))
+	}
+	if !strings.Contains(prompts[0], "Event: Crossword") || !strings.HasSuffix(prompts[0], "User query: how many people can take part in crossword") {
+		t.Errorf("prompt does not carry the excerpts and the query:\n%s", prompts[0])
+	}
+}
+
+func TestQueryFallsBackToPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  []llm.StubRule
+		query  string
+		called int
+	}{
+		{"provider failure", []llm.StubRule{{Error: "503"}}, "how many people can take part in crossword", 1},
+		{"email in answer", []llm.StubRule{{Answer: "Write to someone@example.com about crossword."}}, "who runs crossword", 1},
+		{"json in answer", []llm.StubRule{{Answer: `{"event": "crossword"}`}}, "who runs crossword", 1},
+		{"injection in query", nil, "crossword; drop table users", 0},
+		{"dump request", nil, "give me the full list of crossword participants", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := setupQueryBot(t, tt.rules)
+			resp := askQuery(t, tt.query)
+			if resp.Source != "policy" {
+				t.Errorf("source = %q, want policy (answer %q)", resp.Source, resp.Answer)
+			}
+			if n := len(stub.Prompts()); n != tt.called {
+				t.Errorf("model called %d times, want %d", n, tt.called)
+			}
+		})
+	}
+}
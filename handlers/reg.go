@@ -3,7 +3,11 @@ package handlers
 import (
 	"encoding/json"
 	"exunreg25/db"
+	"exunreg25/eligibility"
+	"exunreg25/mail"
+	"exunreg25/webhooks"
 	"fmt"
+	"log"
 	"net/http"
 	"regexp"
 	"strconv"
@@ -12,15 +16,21 @@ import (
 )
 
 type Participant struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
-	Class int    `json:"class"`
-	Phone string `json:"phone"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Class  int    `json:"class"`
+	Phone  string `json:"phone"`
+	Gender string `json:"gender,omitempty"`
+
+	Fields map[string]string `json:"fields,omitempty"`
 }
 
 type RegistrationRequest struct {
-	EventID string        `json:"id"`
-	Data    []Participant `json:"data"`
+	EventID  string        `json:"id"`
+	Team     int           `json:"team,omitempty"`
+	TeamName string        `json:"team_name,omitempty"`
+	Captain  string        `json:"captain,omitempty"`
+	Data     []Participant `json:"data"`
 }
 
 type RegistrationResponse struct {
@@ -75,6 +85,19 @@ func SubmitRegistrations(w http.ResponseWriter, r *http.Request) {
 		actionStr = fmt.Sprintf("%v", av)
 	}
 
+	team := 1
+	if tv, ok := raw["team"]; ok {
+		n, err := strconv.Atoi(strings.TrimSpace(fmt.Sprintf("%v", tv)))
+		if err != nil || n < 1 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(Response{Status: "error", Error: "invalid team number"})
+			return
+		}
+		team = n
+	}
+	teamKey := db.TeamKey(reqEventID, team)
+
 	dataVal, ok := raw["data"]
 	if !ok {
 		dataVal = nil
@@ -115,8 +138,10 @@ func SubmitRegistrations(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if actionStr == "delete" {
+		var withdrawn []db.Participant
 		if user.Registrations != nil {
-			delete(user.Registrations, reqEventID)
+			withdrawn = user.Registrations[teamKey]
+			delete(user.Registrations, teamKey)
 		}
 		user.UpdatedAt = time.Now()
 		if err := globalDB.Update("users", email, user); err != nil {
@@ -124,25 +149,39 @@ func SubmitRegistrations(w http.ResponseWriter, r *http.Request) {
 			json.NewEncoder(w).Encode(false)
 			return
 		}
-		if regs, err := globalDB.GetAll("registrations"); err == nil {
-			for _, rr := range regs {
-				if r, ok := rr.(*db.Registration); ok {
-					if r.EventID == reqEventID && r.UserID == user.ID {
-						_ = globalDB.Delete("registrations", strconv.Itoa(r.ID))
-					}
-				}
-			}
+		if err := globalDB.SetTeamFields(user.ID, teamKey, nil); err != nil {
+			log.Printf("failed to clear team fields of %s for %s: %v", email, teamKey, err)
+		}
+		if err := globalDB.DeleteTeamRegistrations(user.ID, reqEventID, team); err != nil {
+			log.Printf("failed to delete registration of %s for %s: %v", email, teamKey, err)
+		}
+		if len(withdrawn) > 0 {
+			notifyRegistrationChange(user, event, mail.RegistrationWithdrawn, withdrawn)
+			emitWebhook(webhooks.EventRegistrationDeleted, registrationWebhookData(user, event, mail.RegistrationWithdrawn, withdrawn))
+			recordAudit(r, email, "registration.withdraw", "registrations", teamKey, map[string]interface{}{"participants": withdrawn}, nil)
 		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(true)
 		return
 	}
 
+	if event.ArchivedAt != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(false)
+		return
+	}
+
 	if len(dataArr) == 0 {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(false)
 		return
 	}
+	if team > teamsPerSchool(event) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{Status: "error", Error: fmt.Sprintf("%s allows at most %d team(s) per school", event.Name, teamsPerSchool(event))})
+		return
+	}
 	if len(dataArr) > event.Participants {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(false)
@@ -183,12 +222,15 @@ func SubmitRegistrations(w http.ResponseWriter, r *http.Request) {
 		}
 
 		phoneVal := fmt.Sprintf("%v", m["phone"])
+		genderVal, _ := m["gender"].(string)
 
 		localParts = append(localParts, Participant{
-			Name:  name,
-			Email: emailVal,
-			Class: classInt,
-			Phone: phoneVal,
+			Name:   name,
+			Email:  emailVal,
+			Class:  classInt,
+			Phone:  phoneVal,
+			Gender: strings.ToLower(strings.TrimSpace(genderVal)),
+			Fields: fieldValues(m["fields"]),
 		})
 	}
 
@@ -198,14 +240,39 @@ func SubmitRegistrations(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(Response{Status: "error", Error: err.Error()})
 		return
 	}
+	teamFields, problems := checkFormFields(eventFormFields(event), fieldValues(raw["fields"]), localParts, registrationFileExists(user.ID))
+	existingReg, err := globalDB.TeamRegistration(user.ID, reqEventID, team)
+	if err != nil {
+		log.Printf("failed to load registration of %s for %s: %v", email, teamKey, err)
+	}
+	teamName, captain := "", ""
+	if existingReg != nil {
+		teamName, captain = existingReg.TeamName, existingReg.Captain
+	}
+	if v, ok := raw["team_name"]; ok && v != nil {
+		teamName = fmt.Sprintf("%v", v)
+	}
+	if v, ok := raw["captain"]; ok && v != nil {
+		captain = fmt.Sprintf("%v", v)
+	}
+	teamName, captain, teamProblems := checkTeam(user, reqEventID, team, teamName, captain, localParts)
+	problems = append(problems, teamProblems...)
+	if len(problems) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Response{Status: "error", Error: strings.Join(problems, "; ")})
+		return
+	}
 
 	participants := make([]db.Participant, 0, len(localParts))
 	for _, p := range localParts {
 		participants = append(participants, db.Participant{
-			Name:  strings.ToUpper(strings.TrimSpace(p.Name)),
-			Email: strings.TrimSpace(p.Email),
-			Class: p.Class,
-			Phone: strings.TrimSpace(p.Phone),
+			Name:   strings.ToUpper(strings.TrimSpace(p.Name)),
+			Email:  strings.TrimSpace(p.Email),
+			Class:  p.Class,
+			Phone:  strings.TrimSpace(p.Phone),
+			Gender: p.Gender,
+			Fields: p.Fields,
 		})
 	}
 
@@ -213,7 +280,13 @@ func SubmitRegistrations(w http.ResponseWriter, r *http.Request) {
 		user.Registrations = make(map[string][]db.Participant)
 	}
 
-	user.Registrations[reqEventID] = participants
+	action := mail.RegistrationCreated
+	var previous []db.Participant
+	if existing, ok := user.Registrations[teamKey]; ok && len(existing) > 0 {
+		action = mail.RegistrationUpdated
+		previous = existing
+	}
+	user.Registrations[teamKey] = participants
 	user.UpdatedAt = time.Now()
 
 	if err := globalDB.Update("users", email, user); err != nil {
@@ -222,19 +295,42 @@ func SubmitRegistrations(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	registration := &db.Registration{
-		EventID:   reqEventID,
-		UserID:    user.ID,
-		TeamName:  "",
-		Status:    "pending",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	if err := globalDB.SetTeamFields(user.ID, teamKey, teamFields); err != nil {
+		log.Printf("failed to store team fields of %s for %s: %v", email, teamKey, err)
 	}
-	if err := globalDB.Create("registrations", registration); err != nil {
+
+	if existingReg != nil {
+		existingReg.TeamName = teamName
+		existingReg.Captain = captain
+		err = globalDB.Update("registrations", strconv.Itoa(existingReg.ID), existingReg)
+	} else {
+		err = globalDB.Create("registrations", &db.Registration{
+			EventID:   reqEventID,
+			UserID:    user.ID,
+			Team:      team,
+			TeamName:  teamName,
+			Captain:   captain,
+			Status:    "pending",
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		})
+	}
+	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(false)
 		return
 	}
+	notifyRegistrationChange(user, event, action, participants)
+	hookData := registrationWebhookData(user, event, action, participants)
+	hookData["team"], hookData["team_name"], hookData["captain"] = team, teamName, captain
+	emitWebhook(webhooks.EventRegistrationSubmitted, hookData)
+	var before interface{}
+	if previous != nil {
+		before = map[string]interface{}{"participants": previous}
+	}
+	recordAudit(r, email, "registration."+action, "registrations", teamKey, before, map[string]interface{}{
+		"participants": participants, "team_fields": teamFields, "team_name": teamName, "captain": captain,
+	})
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(true)
 }
@@ -251,6 +347,13 @@ func validateParticipants(participants []Participant, event *db.Event) error {
 			return fmt.Errorf("participant %d: %v", i+1, err)
 		}
 	}
+	rules, err := parseEventRules(event)
+	if err != nil {
+		return fmt.Errorf("invalid event eligibility format")
+	}
+	if problems := rules.CheckTeam(eligibilityMembers(participants)); len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
 	return nil
 }
 
@@ -265,39 +368,15 @@ func validateParticipant(participant Participant, event *db.Event) error {
 	if participant.Class < 1 || participant.Class > 12 {
 		return fmt.Errorf("class must be between 1 and 12")
 	}
-	var minClass, maxClass int
-	if event.OpenToAll {
-		minClass = 1
-		maxClass = 12
-	} else {
-		var eligibility []int
-		if err := json.Unmarshal([]byte(event.Eligibility), &eligibility); err == nil && len(eligibility) == 2 {
-			minClass = eligibility[0]
-			maxClass = eligibility[1]
-		} else {
-			re := regexp.MustCompile(`(\d{1,2}).*?(\d{1,2})`)
-			m := re.FindStringSubmatch(event.Eligibility)
-			if len(m) >= 3 {
-				minClass, _ = strconv.Atoi(m[1])
-				maxClass, _ = strconv.Atoi(m[2])
-			} else {
-				return fmt.Errorf("invalid event eligibility format")
-			}
-		}
-	}
-
-	if participant.Class < minClass || participant.Class > maxClass {
-		return fmt.Errorf("class %d is not eligible for this event (eligible: %d-%d)", participant.Class, minClass, maxClass)
-	}
-	if participant.Class < minClass || participant.Class > maxClass {
-		return fmt.Errorf("class %d is not eligible for this event (eligible: %d-%d)", participant.Class, minClass, maxClass)
-	}
 	if len(participant.Phone) != 10 {
 		return fmt.Errorf("phone number must be 10 digits")
 	}
 	if _, err := strconv.Atoi(participant.Phone); err != nil {
 		return fmt.Errorf("phone number must contain only digits")
 	}
+	if participant.Gender != "" && !validGender(participant.Gender) {
+		return fmt.Errorf("gender must be one of %s", strings.Join(eligibility.Genders, ", "))
+	}
 
 	return nil
 }
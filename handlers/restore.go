@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"exunreg25/backup"
+	"exunreg25/db"
+	"exunreg25/middleware"
+)
+
+type RestoreRequest struct {
+	Snapshot string   `json:"snapshot"`
+	Source   string   `json:"source"`
+	Confirm  string   `json:"confirm"`
+	Tables   []string `json:"tables"`
+	Table    string   `json:"table"`
+	Column   string   `json:"column"`
+	Value    string   `json:"value"`
+	Keys     []string `json:"keys"`
+	Limit    int      `json:"limit"`
+}
+
+func AvailableSnapshots() ([]backup.Snapshot, map[string]string, error) {
+	if backupManager == nil {
+		return nil, nil, fmt.Errorf("backups not configured")
+	}
+	list, err := backupManager.Available()
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	remote, remoteErrors := backupManager.ListRemote(ctx)
+	list = append(list, remote...)
+	return list, remoteErrors, nil
+}
+
+func resolveSnapshot(source, name string) (string, func(), error) {
+	noop := func() {}
+	if backupManager == nil {
+		return "", noop, fmt.Errorf("backups not configured")
+	}
+	switch source {
+	case "", "local":
+		if _, err := backupManager.Validate(name); err != nil {
+			return "", noop, err
+		}
+		path, err := backupManager.Path(name)
+		return path, noop, err
+	default:
+		if name == "" || name != filepath.Base(name) {
+			return "", noop, fmt.Errorf("invalid snapshot name %q", name)
+		}
+		if err := os.MkdirAll(backupManager.Dir(), 0755); err != nil {
+			return "", noop, err
+		}
+		path := filepath.Join(backupManager.Dir(), source+"-"+name+".staging")
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+		if err := backupManager.Fetch(ctx, source, name, path); err != nil {
+			os.Remove(path)
+			return "", noop, fmt.Errorf("failed to fetch %s from %s: %v", name, source, err)
+		}
+		return path, func() { os.Remove(path) }, nil
+	}
+}
+
+func RestoreSnapshot(source, name string) (*backup.RestoreResult, error) {
+	path, cleanup, err := resolveSnapshot(source, name)
+	defer cleanup()
+	if err != nil {
+		return nil, err
+	}
+
+	middleware.PauseWrites()
+	defer middleware.ResumeWrites()
+	sheetsOpMu.Lock()
+	defer sheetsOpMu.Unlock()
+
+	result, err := backupManager.RestoreFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if source != "" && source != "local" {
+		result.Restored = source + ":" + name
+	}
+	return result, nil
+}
+
+func DiffSnapshot(source, name string, opts db.DiffOptions) ([]db.TableDiff, error) {
+	path, cleanup, err := resolveSnapshot(source, name)
+	defer cleanup()
+	if err != nil {
+		return nil, err
+	}
+	return backupManager.DiffFile(path, opts)
+}
+
+func RestoreSnapshotRows(source, name, table string, keys []string) (map[string]int64, string, error) {
+	path, cleanup, err := resolveSnapshot(source, name)
+	defer cleanup()
+	if err != nil {
+		return nil, "", err
+	}
+	sheetsOpMu.Lock()
+	defer sheetsOpMu.Unlock()
+	return backupManager.RestoreRowsFile(path, table, keys)
+}
+
+func (ah *AdminHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if backupManager == nil {
+		http.Error(w, "Backups not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/restore"), "/")
+
+	if r.Method == http.MethodGet && action == "" {
+		list, remoteErrors, err := AvailableSnapshots()
+		if err != nil {
+			http.Error(w, "Failed to list snapshots", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": list, "remote_errors": remoteErrors})
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Snapshot == "" {
+		http.Error(w, "Snapshot is required", http.StatusBadRequest)
+		return
+	}
+
+	switch action {
+	case "":
+		if req.Confirm != req.Snapshot {
+			http.Error(w, "Type the snapshot name in confirm to restore it", http.StatusBadRequest)
+			return
+		}
+		result, err := RestoreSnapshot(req.Source, req.Snapshot)
+		if err != nil {
+			log.Printf("restore of %s by %s failed: %v", req.Snapshot, email, err)
+			http.Error(w, "Restore failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("database restored from %s by %s (safety snapshot %s)", result.Restored, email, result.SafetySnapshot)
+		recordAudit(r, email, "database.restore", "database", result.Restored, nil, map[string]interface{}{
+			"source":          req.Source,
+			"safety_snapshot": result.SafetySnapshot,
+		})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": result})
+	case "validate":
+		path, cleanup, err := resolveSnapshot(req.Source, req.Snapshot)
+		defer cleanup()
+		if err == nil {
+			_, err = db.VerifySnapshot(path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+	case "diff":
+		diffs, err := DiffSnapshot(req.Source, req.Snapshot, db.DiffOptions{
+			Tables: req.Tables,
+			Column: req.Column,
+			Value:  req.Value,
+			Limit:  req.Limit,
+		})
+		if err != nil {
+			http.Error(w, "Diff failed: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": diffs})
+	case "rows":
+		if req.Table == "" || len(req.Keys) == 0 {
+			http.Error(w, "Table and keys are required", http.StatusBadRequest)
+			return
+		}
+		n, safety, err := RestoreSnapshotRows(req.Source, req.Snapshot, req.Table, req.Keys)
+		if err != nil {
+			http.Error(w, "Row restore failed: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("restored %s rows %v from %s by %s", req.Table, n, req.Snapshot, email)
+		recordAudit(r, email, "rows.restore", req.Table, strings.Join(req.Keys, ","), nil, map[string]interface{}{
+			"snapshot":        req.Snapshot,
+			"source":          req.Source,
+			"restored":        n,
+			"safety_snapshot": safety,
+		})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "restored": n, "safety_snapshot": safety})
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+func Restore(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.Restore(w, r)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"exunreg25/backup"
+)
+
+var backupManager *backup.Manager
+
+func SetBackupManager(m *backup.Manager) {
+	backupManager = m
+}
+
+func (ah *AdminHandler) Snapshots(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if backupManager == nil {
+		http.Error(w, "Backups not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		manifest, err := backupManager.Manifest()
+		if err != nil {
+			http.Error(w, "Failed to read backup manifest", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": manifest})
+	case http.MethodPost:
+		snap, err := backupManager.TakeSnapshot("admin:" + email)
+		if err != nil {
+			log.Printf("manual snapshot by %s failed: %v", email, err)
+			http.Error(w, "Failed to take snapshot: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		recordAudit(r, email, "snapshot.create", "snapshots", snap.Name, nil, nil)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": snap})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func Snapshots(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.Snapshots(w, r)
+}
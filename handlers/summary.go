@@ -19,6 +19,12 @@ type EventSummary struct {
 	TotalCount   int              `json:"total_count"`
 	Status       string           `json:"status"`
 	Capacity     int              `json:"capacity"`
+
+	Team           int               `json:"team"`
+	TeamName       string            `json:"team_name,omitempty"`
+	Captain        string            `json:"captain,omitempty"`
+	TeamsPerSchool int               `json:"teams_per_school"`
+	TeamFields     map[string]string `json:"team_fields,omitempty"`
 }
 
 func GetUserSummary(w http.ResponseWriter, r *http.Request) {
@@ -78,6 +84,15 @@ func GetUserSummary(w http.ResponseWriter, r *http.Request) {
 	pendingCount := 0
 	totalRegistrations := 0
 
+	teamRegs := map[string]*db.Registration{}
+	if regs, err := globalDB.GetAll("registrations"); err == nil {
+		for _, rr := range regs {
+			if reg, ok := rr.(*db.Registration); ok && reg.UserID == user.ID {
+				teamRegs[db.TeamKey(reg.EventID, reg.Team)] = reg
+			}
+		}
+	}
+
 	eventsRaw, err := globalDB.GetAll("events")
 	if err == nil {
 		for _, evd := range eventsRaw {
@@ -86,32 +101,46 @@ func GetUserSummary(w http.ResponseWriter, r *http.Request) {
 					continue
 				}
 				eventID := ev.ID
-				parts := []db.Participant{}
+				teams := []int{}
 				if !user.Individual {
-					if user.Registrations != nil {
-						if p, ok := user.Registrations[eventID]; ok {
-							parts = p
-						}
-					}
+					teams = userTeams(user, eventID)
 				}
-				status := "confirmed"
-				if len(parts) == 0 {
-					status = "pending"
+				if len(teams) == 0 {
 					pendingCount++
-				} else {
-					totalParticipants += len(parts)
-					totalRegistrations++
+					eventSummaries = append(eventSummaries, EventSummary{
+						EventID:        eventID,
+						EventName:      ev.Name,
+						Participants:   []db.Participant{},
+						Status:         "pending",
+						Capacity:       ev.Participants,
+						Team:           1,
+						TeamsPerSchool: teamsPerSchool(ev),
+					})
+					continue
 				}
+				totalRegistrations++
+				for _, team := range teams {
+					key := db.TeamKey(eventID, team)
+					parts := user.Registrations[key]
+					totalParticipants += len(parts)
 
-				eventSummary := EventSummary{
-					EventID:      eventID,
-					EventName:    ev.Name,
-					Participants: parts,
-					TotalCount:   len(parts),
-					Status:       status,
-					Capacity:     ev.Participants,
+					eventSummary := EventSummary{
+						EventID:        eventID,
+						EventName:      ev.Name,
+						Participants:   parts,
+						TotalCount:     len(parts),
+						Status:         "confirmed",
+						Capacity:       ev.Participants,
+						Team:           team,
+						TeamsPerSchool: teamsPerSchool(ev),
+					}
+					if reg := teamRegs[key]; reg != nil {
+						eventSummary.TeamName = reg.TeamName
+						eventSummary.Captain = reg.Captain
+					}
+					eventSummary.TeamFields, _ = globalDB.TeamFields(user.ID, key)
+					eventSummaries = append(eventSummaries, eventSummary)
 				}
-				eventSummaries = append(eventSummaries, eventSummary)
 			}
 		}
 	}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"exunreg25/datasync"
+)
+
+type ResolveConflictRequest struct {
+	Choice string  `json:"choice"`
+	Value  *string `json:"value"`
+}
+
+func (ah *AdminHandler) SyncConflicts(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/sync/conflicts"), "/"), "/")
+
+	if r.Method == http.MethodGet && parts[0] == "" {
+		status := r.URL.Query().Get("status")
+		if status == "" {
+			status = "open"
+		} else if status == "all" {
+			status = ""
+		}
+		conflicts, err := ah.db.ListSyncConflicts(status, r.URL.Query().Get("table"))
+		if err != nil {
+			http.Error(w, "Failed to list conflicts", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": conflicts})
+		return
+	}
+
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if len(parts) == 1 && r.Method == http.MethodGet {
+		c, err := ah.db.GetSyncConflict(id)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Conflict not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to load conflict", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": c})
+		return
+	}
+	if len(parts) != 2 || parts[1] != "resolve" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ResolveConflictRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	sheetsOpMu.Lock()
+	defer sheetsOpMu.Unlock()
+
+	c, err := ah.db.GetSyncConflict(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Conflict not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load conflict", http.StatusInternalServerError)
+		return
+	}
+	if c.Status != "open" {
+		http.Error(w, "Conflict is already resolved", http.StatusConflict)
+		return
+	}
+
+	var value string
+	switch req.Choice {
+	case "sheet":
+		value = c.SheetValue
+	case "db":
+		value = c.DBValue
+	case "manual":
+		if req.Value == nil {
+			http.Error(w, "Value is required for a manual resolution", http.StatusBadRequest)
+			return
+		}
+		value = *req.Value
+	default:
+		http.Error(w, "Choice must be sheet, db or manual", http.StatusBadRequest)
+		return
+	}
+
+	if err := datasync.ResolveConflict(ah.db, c, value); err != nil {
+		log.Printf("failed to resolve sync conflict %d: %v", id, err)
+		http.Error(w, "Failed to resolve conflict: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := ah.db.ResolveSyncConflict(id, req.Choice, &value, email); err != nil {
+		http.Error(w, "Failed to resolve conflict", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("sync conflict %d (%s %s.%s) resolved with %s value by %s", id, c.Table, c.PK, c.Column, req.Choice, email)
+	recordAudit(r, email, "sync_conflict.resolve", c.Table, c.PK,
+		map[string]interface{}{c.Column: c.DBValue},
+		map[string]interface{}{c.Column: value, "conflict_id": id, "choice": req.Choice})
+	TriggerSheetsSync()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
+func SyncConflicts(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.SyncConflicts(w, r)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"exunreg25/datasync"
+)
+
+type SyncRunRequest struct {
+	DryRun bool `json:"dry_run"`
+}
+
+func (ah *AdminHandler) SyncRuns(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	idPart := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/sync/runs"), "/")
+
+	if idPart != "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id, err := strconv.Atoi(idPart)
+		if err != nil {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		run, err := ah.db.GetSyncRun(id)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Sync run not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to load sync run", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": run})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		limit := 50
+		if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 500 {
+			limit = v
+		}
+		runs, err := ah.db.ListSyncRuns(limit)
+		if err != nil {
+			http.Error(w, "Failed to list sync runs", http.StatusInternalServerError)
+			return
+		}
+		for i := range runs {
+			var tables []datasync.TableStats
+			if err := json.Unmarshal(runs[i].Tables, &tables); err != nil {
+				continue
+			}
+			for j := range tables {
+				tables[j].Changes = nil
+			}
+			if b, err := json.Marshal(tables); err == nil {
+				runs[i].Tables = b
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": runs})
+	case http.MethodPost:
+		var req SyncRunRequest
+		if r.Body != nil && r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+		if syncTarget == nil {
+			http.Error(w, "Sync is not configured", http.StatusServiceUnavailable)
+			return
+		}
+		sheetsOpMu.Lock()
+		run, err := runSync(ah.db, syncTarget, "admin:"+email, req.DryRun)
+		sheetsOpMu.Unlock()
+		if run == nil {
+			http.Error(w, "Sync failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err != nil {
+			log.Printf("sync run %d by %s failed: %v", run.ID, email, err)
+		}
+		recordAudit(r, email, "sync.run", "sync_runs", strconv.Itoa(run.ID), nil, map[string]interface{}{"dry_run": req.DryRun, "status": run.Status})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": err == nil, "data": run})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func SyncRuns(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.SyncRuns(w, r)
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"exunreg25/db"
+)
+
+const maxTeamNameLength = 60
+
+func teamsPerSchool(ev *db.Event) int {
+	if ev.TeamsPerSchool < 1 {
+		return 1
+	}
+	return ev.TeamsPerSchool
+}
+
+func userTeams(user *db.User, eventID string) []int {
+	var teams []int
+	for key := range user.Registrations {
+		if id, n := db.SplitTeamKey(key); id == eventID {
+			teams = append(teams, n)
+		}
+	}
+	sort.Ints(teams)
+	return teams
+}
+
+// An empty captain defaults to the first participant.
+func checkTeam(user *db.User, eventID string, team int, name, captain string, participants []Participant) (string, string, []string) {
+	var problems []string
+	name = strings.Join(strings.Fields(name), " ")
+	if len(name) > maxTeamNameLength {
+		problems = append(problems, fmt.Sprintf("team name must be at most %d characters", maxTeamNameLength))
+	} else if name != "" {
+		taken, err := globalDB.TeamNameTaken(eventID, name, user.ID, team)
+		if err != nil {
+			log.Printf("%v", err)
+		} else if taken {
+			problems = append(problems, fmt.Sprintf("team name %q is already taken for this event", name))
+		}
+	}
+
+	captain = strings.TrimSpace(captain)
+	if captain == "" && len(participants) > 0 {
+		captain = strings.TrimSpace(participants[0].Email)
+	}
+	found := false
+	for _, p := range participants {
+		if strings.EqualFold(strings.TrimSpace(p.Email), captain) {
+			captain = strings.TrimSpace(p.Email)
+			found = true
+		}
+	}
+	if !found {
+		problems = append(problems, "captain must be one of the team's participants")
+	}
+
+	for _, other := range userTeams(user, eventID) {
+		if other == team {
+			continue
+		}
+		for _, theirs := range user.Registrations[db.TeamKey(eventID, other)] {
+			for _, p := range participants {
+				if strings.EqualFold(strings.TrimSpace(p.Email), theirs.Email) {
+					problems = append(problems, fmt.Sprintf("%s is already on team %d for this event", theirs.Email, other))
+				}
+			}
+		}
+	}
+	return name, captain, problems
+}
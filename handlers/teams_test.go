@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"exunreg25/db"
+)
+
+func TestUserTeams(t *testing.T) {
+	user := &db.User{Registrations: map[string][]db.Participant{
+		"quiz#3": nil, "quiz": nil, "quiz2": nil, "quiz#2": nil,
+	}}
+	if got := userTeams(user, "quiz"); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("userTeams = %v", got)
+	}
+	if got := teamsPerSchool(&db.Event{}); got != 1 {
+		t.Errorf("teamsPerSchool of an unset event = %d", got)
+	}
+}
+
+func TestCheckTeam(t *testing.T) {
+	database := useTestDB(t)
+	if _, err := database.Exec(`INSERT INTO registrations (event_id, user_id, team, team_name) VALUES ('quiz', 2, 1, 'Bit Flippers')`); err != nil {
+		t.Fatal(err)
+	}
+	user := &db.User{ID: 1, Registrations: map[string][]db.Participant{
+		"quiz": {{Name: "A", Email: "a@example.com"}},
+	}}
+	team := []Participant{{Name: "B", Email: "B@example.com"}, {Name: "C", Email: "c@example.com"}}
+
+	tests := []struct {
+		name        string
+		team        int
+		teamName    string
+		captain     string
+		members     []Participant
+		wantName    string
+		wantCaptain string
+		problems    []string
+	}{
+		{"defaults captain", 2, "  Null   Pointers ", "", team, "Null Pointers", "B@example.com", nil},
+		{"captain matched case-insensitively", 2, "", "b@EXAMPLE.com", team, "", "B@example.com", nil},
+		{"captain not on team", 2, "", "z@example.com", team, "", "z@example.com", []string{"captain must be one of"}},
+		{"name taken", 2, "bit flippers", "", team, "bit flippers", "B@example.com", []string{"already taken"}},
+		{"name too long", 2, strings.Repeat("x", maxTeamNameLength+1), "", team, strings.Repeat("x", maxTeamNameLength+1), "B@example.com", []string{"at most"}},
+		{"participant on another team", 2, "", "", []Participant{{Email: "a@example.com"}}, "", "a@example.com", []string{"already on team 1"}},
+		{"editing the same team", 1, "", "", []Participant{{Email: "a@example.com"}}, "", "a@example.com", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, captain, problems := checkTeam(user, "quiz", tt.team, tt.teamName, tt.captain, tt.members)
+			if name != tt.wantName || captain != tt.wantCaptain {
+				t.Errorf("name, captain = %q, %q, want %q, %q", name, captain, tt.wantName, tt.wantCaptain)
+			}
+			if len(problems) != len(tt.problems) {
+				t.Fatalf("problems = %q, want %d", problems, len(tt.problems))
+			}
+			for i, p := range tt.problems {
+				if !strings.Contains(problems[i], p) {
+					t.Errorf("problem %d = %q, want it to mention %q", i, problems[i], p)
+				}
+			}
+		})
+	}
+}
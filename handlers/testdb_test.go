@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"exunreg25/db"
+)
+
+func useTestDB(t *testing.T) *db.Database {
+	t.Helper()
+	database, err := db.NewConnection(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.InitTables(); err != nil {
+		t.Fatal(err)
+	}
+	prev := globalDB
+	globalDB = database
+	t.Cleanup(func() {
+		globalDB = prev
+		database.Close()
+	})
+	return database
+}
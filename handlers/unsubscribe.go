@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"exunreg25/mail"
+)
+
+type EmailPreferenceCategory struct {
+	Key        string `json:"key"`
+	Name       string `json:"name"`
+	Subscribed bool   `json:"subscribed"`
+}
+
+type EmailPreferencesData struct {
+	Email      string                    `json:"email"`
+	Token      string                    `json:"token"`
+	Categories []EmailPreferenceCategory `json:"categories"`
+}
+
+type EmailPreferencesRequest struct {
+	Token          string          `json:"token"`
+	Subscriptions  map[string]bool `json:"subscriptions"`
+	UnsubscribeAll bool            `json:"unsubscribe_all"`
+}
+
+type SuppressionRequest struct {
+	Email    string `json:"email"`
+	Category string `json:"category"`
+	Reason   string `json:"reason"`
+	Action   string `json:"action"`
+}
+
+var emailService *mail.EmailService
+
+func SetEmailService(svc *mail.EmailService) {
+	emailService = svc
+}
+
+func verifyUnsubscribeToken(token string) (string, error) {
+	if emailService == nil {
+		return "", fmt.Errorf("email service not initialized")
+	}
+	if strings.TrimSpace(token) == "" {
+		return "", fmt.Errorf("token required")
+	}
+	return emailService.VerifyUnsubscribeToken(token)
+}
+
+func GetEmailPreferencesData(token string) (*EmailPreferencesData, error) {
+	email, err := verifyUnsubscribeToken(token)
+	if err != nil {
+		return nil, err
+	}
+	suppressed, err := globalDB.GetSuppressedCategories(email)
+	if err != nil {
+		return nil, err
+	}
+	data := &EmailPreferencesData{Email: email, Token: token}
+	for _, c := range mail.CampaignCategories {
+		data.Categories = append(data.Categories, EmailPreferenceCategory{
+			Key:        c,
+			Name:       mail.CategoryNames[c],
+			Subscribed: !suppressed[c] && !suppressed[mail.CategoryAll],
+		})
+	}
+	return data, nil
+}
+
+func OneClickUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	email, err := verifyUnsubscribeToken(r.URL.Query().Get("token"))
+	if err != nil {
+		http.Error(w, "Invalid unsubscribe link", http.StatusBadRequest)
+		return
+	}
+	if err := globalDB.SuppressEmail(email, mail.CategoryAll, "one-click unsubscribe"); err != nil {
+		http.Error(w, "Failed to unsubscribe", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("You have been unsubscribed."))
+}
+
+func EmailPreferences(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		data, err := GetEmailPreferencesData(r.URL.Query().Get("token"))
+		if err != nil {
+			response := Response{Status: "error", Error: "Invalid or expired preferences link"}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		response := Response{Status: "success", Message: "Email preferences", Data: data}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	case http.MethodPost:
+		var req EmailPreferencesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response := Response{Status: "error", Error: "Invalid request body"}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		email, err := verifyUnsubscribeToken(req.Token)
+		if err != nil {
+			response := Response{Status: "error", Error: "Invalid or expired preferences link"}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		if err := applyEmailPreferences(email, req); err != nil {
+			response := Response{Status: "error", Error: "Failed to update preferences"}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		recordAudit(r, email, "email_preferences.update", "email_suppressions", email, nil, req)
+		data, _ := GetEmailPreferencesData(req.Token)
+		response := Response{Status: "success", Message: "Preferences updated", Data: data}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func applyEmailPreferences(email string, req EmailPreferencesRequest) error {
+	if req.UnsubscribeAll {
+		return globalDB.SuppressEmail(email, mail.CategoryAll, "preferences page")
+	}
+	if err := globalDB.UnsuppressEmail(email, mail.CategoryAll); err != nil {
+		return err
+	}
+	for _, c := range mail.CampaignCategories {
+		subscribed, ok := req.Subscriptions[c]
+		if !ok {
+			continue
+		}
+		var err error
+		if subscribed {
+			err = globalDB.UnsuppressEmail(email, c)
+		} else {
+			err = globalDB.SuppressEmail(email, c, "preferences page")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ah *AdminHandler) ManageSuppressions(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		list, err := ah.db.ListSuppressions(r.URL.Query().Get("email"))
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": list})
+	case http.MethodPost:
+		var req SuppressionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if !validateEmailFormat(strings.TrimSpace(req.Email)) {
+			http.Error(w, "Invalid email", http.StatusBadRequest)
+			return
+		}
+		if req.Category == "" {
+			req.Category = mail.CategoryAll
+		}
+		if req.Category != mail.CategoryAll && !mail.IsCampaignCategory(req.Category) {
+			http.Error(w, "Unknown category", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if req.Action == "remove" {
+			err = ah.db.UnsuppressEmail(req.Email, req.Category)
+		} else {
+			reason := req.Reason
+			if reason == "" {
+				reason = "added by " + email
+			}
+			err = ah.db.SuppressEmail(req.Email, req.Category, reason)
+		}
+		if err != nil {
+			http.Error(w, "Failed to update suppression list", http.StatusInternalServerError)
+			return
+		}
+		action := "suppression.add"
+		if req.Action == "remove" {
+			action = "suppression.remove"
+		}
+		recordAudit(r, email, action, "email_suppressions", req.Email, nil, map[string]interface{}{"category": req.Category, "reason": req.Reason})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func ManageSuppressions(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.ManageSuppressions(w, r)
+}
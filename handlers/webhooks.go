@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"exunreg25/db"
+	"exunreg25/webhooks"
+)
+
+var webhookDispatcher *webhooks.Dispatcher
+
+func SetWebhookDispatcher(d *webhooks.Dispatcher) {
+	webhookDispatcher = d
+}
+
+func emitWebhook(eventType string, data interface{}) {
+	if webhookDispatcher == nil {
+		return
+	}
+	if err := webhookDispatcher.Emit(eventType, data); err != nil {
+		log.Printf("failed to queue %s webhook: %v", eventType, err)
+	}
+}
+
+func registrationWebhookData(user *db.User, event *db.Event, action string, participants []db.Participant) map[string]interface{} {
+	if participants == nil {
+		participants = []db.Participant{}
+	}
+	return map[string]interface{}{
+		"action":           action,
+		"user_id":          user.ID,
+		"user_email":       user.Email,
+		"institution_name": user.InstitutionName,
+		"individual":       user.Individual,
+		"event_id":         event.ID,
+		"event_name":       event.Name,
+		"participants":     participants,
+	}
+}
+
+func userWebhookData(user *db.User) map[string]interface{} {
+	return map[string]interface{}{
+		"id":               user.ID,
+		"email":            user.Email,
+		"fullname":         user.Fullname,
+		"phone_number":     user.PhoneNumber,
+		"individual":       user.Individual,
+		"institution_name": user.InstitutionName,
+		"principals_name":  user.PrincipalsName,
+		"principals_email": user.PrincipalsEmail,
+		"created_at":       user.CreatedAt,
+	}
+}
+
+func eventWebhookData(event *db.Event, action, actor string) map[string]interface{} {
+	return map[string]interface{}{
+		"action": action,
+		"actor":  actor,
+		"event":  event,
+	}
+}
+
+type WebhookRequest struct {
+	URL          *string  `json:"url"`
+	Events       []string `json:"events"`
+	Description  *string  `json:"description"`
+	Active       *bool    `json:"active"`
+	Secret       *string  `json:"secret"`
+	RotateSecret bool     `json:"rotate_secret"`
+}
+
+func (req *WebhookRequest) apply(hook *db.Webhook) string {
+	if req.URL != nil {
+		hook.URL = strings.TrimSpace(*req.URL)
+	}
+	if req.Events != nil {
+		hook.Events = []string{}
+		seen := map[string]bool{}
+		for _, e := range req.Events {
+			e = strings.TrimSpace(e)
+			if e == "" || seen[e] {
+				continue
+			}
+			if !webhooks.ValidEventType(e) {
+				return "Unknown event type: " + e
+			}
+			seen[e] = true
+			hook.Events = append(hook.Events, e)
+		}
+	}
+	if req.Description != nil {
+		hook.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Active != nil {
+		hook.Active = *req.Active
+	}
+	if req.Secret != nil && strings.TrimSpace(*req.Secret) != "" {
+		hook.Secret = strings.TrimSpace(*req.Secret)
+	}
+
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "URL must be an absolute http or https URL"
+	}
+	if len(hook.Events) == 0 {
+		return "At least one event type is required"
+	}
+	return ""
+}
+
+func (ah *AdminHandler) Webhooks(w http.ResponseWriter, r *http.Request) {
+	if !globalAuthHandler.isAuthenticated(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	email := globalAuthHandler.getAuthenticatedUser(r)
+	if !IsAdminEmail(email) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if webhookDispatcher == nil {
+		http.Error(w, "Webhooks are not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/webhooks"), "/"), "/")
+
+	if parts[0] == "" {
+		switch r.Method {
+		case http.MethodGet:
+			hooks, err := ah.db.ListWebhooks()
+			if err != nil {
+				http.Error(w, "Failed to list webhooks", http.StatusInternalServerError)
+				return
+			}
+			for i := range hooks {
+				hooks[i].Secret = ""
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": hooks, "event_types": webhooks.EventTypes})
+		case http.MethodPost:
+			var req WebhookRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			hook := &db.Webhook{Active: true}
+			if msg := req.apply(hook); msg != "" {
+				http.Error(w, msg, http.StatusBadRequest)
+				return
+			}
+			if hook.Secret == "" {
+				secret, err := webhooks.NewSecret()
+				if err != nil {
+					http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+					return
+				}
+				hook.Secret = secret
+			}
+			if err := ah.db.CreateWebhook(hook); err != nil {
+				http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+				return
+			}
+			log.Printf("webhook %d (%s) created by %s", hook.ID, hook.URL, email)
+			recordAudit(r, email, "webhook.create", "webhooks", strconv.Itoa(hook.ID), nil, hook)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": hook})
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if parts[0] == "deliveries" {
+		ah.webhookDeliveries(w, r, 0, parts[1:])
+		return
+	}
+
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	hook, err := ah.db.GetWebhook(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load webhook", http.StatusInternalServerError)
+		return
+	}
+
+	if len(parts) > 1 {
+		switch parts[1] {
+		case "deliveries":
+			ah.webhookDeliveries(w, r, id, parts[2:])
+		case "test":
+			if r.Method != http.MethodPost {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+			defer cancel()
+			delivery, err := webhookDispatcher.SendTest(ctx, hook, email)
+			if err != nil {
+				http.Error(w, "Failed to send test: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": delivery.Status == "delivered", "data": delivery})
+		default:
+			http.Error(w, "Not found", http.StatusNotFound)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": hook})
+	case http.MethodPut, http.MethodPatch:
+		var req WebhookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		before := *hook
+		if msg := req.apply(hook); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		if req.RotateSecret {
+			secret, err := webhooks.NewSecret()
+			if err != nil {
+				http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+				return
+			}
+			hook.Secret = secret
+		}
+		if err := ah.db.UpdateWebhook(hook); err != nil {
+			http.Error(w, "Failed to update webhook", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("webhook %d updated by %s", hook.ID, email)
+		recordAudit(r, email, "webhook.update", "webhooks", strconv.Itoa(hook.ID), &before, hook)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": hook})
+	case http.MethodDelete:
+		if err := ah.db.DeleteWebhook(id); err != nil {
+			http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("webhook %d (%s) deleted by %s", hook.ID, hook.URL, email)
+		recordAudit(r, email, "webhook.delete", "webhooks", strconv.Itoa(hook.ID), hook, nil)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (ah *AdminHandler) webhookDeliveries(w http.ResponseWriter, r *http.Request, webhookID int, parts []string) {
+	if len(parts) == 0 || parts[0] == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		limit := 100
+		if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 1000 {
+			limit = v
+		}
+		deliveries, err := ah.db.ListWebhookDeliveries(webhookID, r.URL.Query().Get("status"), limit)
+		if err != nil {
+			http.Error(w, "Failed to list deliveries", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": deliveries})
+		return
+	}
+
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	delivery, err := ah.db.GetWebhookDelivery(id)
+	if err == sql.ErrNoRows || (err == nil && webhookID > 0 && delivery.WebhookID != webhookID) {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load delivery", http.StatusInternalServerError)
+		return
+	}
+
+	if len(parts) == 1 && r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": delivery})
+		return
+	}
+	if len(parts) != 2 || parts[1] != "retry" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	delivery, err = webhookDispatcher.Retry(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "data": delivery})
+}
+
+func Webhooks(w http.ResponseWriter, r *http.Request) {
+	if globalAdminHandler == nil {
+		http.Error(w, "Admin handler not initialized", http.StatusInternalServerError)
+		return
+	}
+	globalAdminHandler.Webhooks(w, r)
+}
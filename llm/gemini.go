@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/genai"
+)
+
+type GeminiProvider struct {
+	client *genai.Client
+	model  string
+}
+
+func NewGeminiProvider(apiKey, model string) (*GeminiProvider, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("GEMINI_API_KEY is required")
+	}
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{APIKey: apiKey})
+	if err != nil {
+		return nil, err
+	}
+	return &GeminiProvider{client: client, model: model}, nil
+}
+
+func (p *GeminiProvider) Name() string {
+	return "gemini"
+}
+
+func (p *GeminiProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	result, err := p.client.Models.GenerateContent(ctx, p.model, genai.Text(prompt), nil)
+	if err != nil {
+		return "", err
+	}
+	return result.Text(), nil
+}
+
+func (p *GeminiProvider) Stream(ctx context.Context, prompt string, emit func(string) error) error {
+	for result, err := range p.client.Models.GenerateContentStream(ctx, p.model, genai.Text(prompt), nil) {
+		if err != nil {
+			return err
+		}
+		if err := emit(result.Text()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Works with any OpenAI-compatible server, including llama.cpp and Ollama.
+type OpenAIProvider struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+}
+
+func NewOpenAIProvider(baseURL, apiKey, model string) (*OpenAIProvider, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("OPENAI_BASE_URL is required")
+	}
+	if model == "" {
+		return nil, fmt.Errorf("OPENAI_MODEL is required")
+	}
+	return &OpenAIProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		client:  &http.Client{},
+	}, nil
+}
+
+func (p *OpenAIProvider) Name() string {
+	return "openai"
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream,omitempty"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *OpenAIProvider) post(ctx context.Context, prompt string, stream bool) (*http.Response, error) {
+	body, err := json.Marshal(chatRequest{
+		Model:    p.model,
+		Messages: []chatMessage{{Role: "user", Content: prompt}},
+		Stream:   stream,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var out chatResponse
+	if json.Unmarshal(data, &out) == nil && out.Error != nil && out.Error.Message != "" {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, out.Error.Message)
+	}
+	return nil, fmt.Errorf("status %d", resp.StatusCode)
+}
+
+func (p *OpenAIProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	resp, err := p.post(ctx, prompt, false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var out chatResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&out); err != nil {
+		return "", err
+	}
+	if len(out.Choices) == 0 {
+		return "", ErrEmptyAnswer
+	}
+	return out.Choices[0].Message.Content, nil
+}
+
+type chatChunk struct {
+	Choices []struct {
+		Delta chatMessage `json:"delta"`
+	} `json:"choices"`
+}
+
+func (p *OpenAIProvider) Stream(ctx context.Context, prompt string, emit func(string) error) error {
+	resp, err := p.post(ctx, prompt, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return nil
+		}
+		var chunk chatChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("invalid stream chunk: %v", err)
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		if err := emit(chunk.Choices[0].Delta.Content); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAIProvider(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		answer string
+		errHas string
+	}{
+		{"answer", http.StatusOK, `{"choices":[{"message":{"role":"assistant","content":"Hello."}}]}`, "Hello.", ""},
+		{"no choices", http.StatusOK, `{"choices":[]}`, "", "empty answer"},
+		{"api error", http.StatusTooManyRequests, `{"error":{"message":"rate limited"}}`, "", "status 429: rate limited"},
+		{"bare error", http.StatusBadGateway, `{}`, "", "status 502"},
+		{"not json", http.StatusBadGateway, `<html>`, "", "status 502"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got chatRequest
+			var auth string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/chat/completions" {
+					http.NotFound(w, r)
+					return
+				}
+				auth = r.Header.Get("Authorization")
+				json.NewDecoder(r.Body).Decode(&got)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			p, err := NewOpenAIProvider(srv.URL+"/v1/", "key", "local-model")
+			if err != nil {
+				t.Fatal(err)
+			}
+			answer, err := p.Generate(context.Background(), "Hi")
+			if answer != tt.answer {
+				t.Errorf("answer = %q, want %q", answer, tt.answer)
+			}
+			if tt.errHas == "" && err != nil || tt.errHas != "" && (err == nil || !strings.Contains(err.Error(), tt.errHas)) {
+				t.Errorf("err = %v, want %q", err, tt.errHas)
+			}
+			if got.Model != "local-model" || len(got.Messages) != 1 || got.Messages[0].Content != "Hi" || auth != "Bearer key" {
+				t.Errorf("request = %+v, auth %q", got, auth)
+			}
+		})
+	}
+}
+
+func TestNewOpenAIProviderRequiresConfig(t *testing.T) {
+	if _, err := NewOpenAIProvider("", "", "m"); err == nil {
+		t.Error("no base URL accepted")
+	}
+	if _, err := NewOpenAIProvider("http://localhost:8080", "", ""); err == nil {
+		t.Error("no model accepted")
+	}
+}
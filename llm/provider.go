@@ -0,0 +1,142 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+type Provider interface {
+	Name() string
+	Generate(ctx context.Context, prompt string) (string, error)
+}
+
+// An error from emit stops the stream and is returned.
+type Streamer interface {
+	Stream(ctx context.Context, prompt string, emit func(string) error) error
+}
+
+func Stream(ctx context.Context, p Provider, prompt string, emit func(string) error) error {
+	if s, ok := p.(Streamer); ok {
+		return s.Stream(ctx, prompt, emit)
+	}
+	answer, err := p.Generate(ctx, prompt)
+	if err != nil {
+		return err
+	}
+	return emit(answer)
+}
+
+var ErrEmptyAnswer = errors.New("empty answer")
+
+// Each attempt gets its own timeout; a cancelled request stops the chain
+// instead of moving on to the next provider.
+type Chain struct {
+	providers []Provider
+	timeout   time.Duration
+}
+
+func NewChain(timeout time.Duration, providers ...Provider) *Chain {
+	return &Chain{providers: providers, timeout: timeout}
+}
+
+func (c *Chain) Name() string {
+	names := make([]string, 0, len(c.providers))
+	for _, p := range c.providers {
+		names = append(names, p.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+func (c *Chain) Providers() []Provider {
+	return c.providers
+}
+
+func (c *Chain) Generate(ctx context.Context, prompt string) (string, error) {
+	_, answer, err := c.GenerateFrom(ctx, prompt)
+	return answer, err
+}
+
+func (c *Chain) GenerateFrom(ctx context.Context, prompt string) (string, string, error) {
+	if len(c.providers) == 0 {
+		return "", "", errors.New("no llm provider configured")
+	}
+	var errs []string
+	for _, p := range c.providers {
+		answer, err := c.attempt(ctx, p, prompt)
+		if err == nil {
+			return p.Name(), answer, nil
+		}
+		if ctx.Err() != nil {
+			return "", "", ctx.Err()
+		}
+		log.Printf("llm provider %s failed: %v", p.Name(), err)
+		errs = append(errs, fmt.Sprintf("%s: %v", p.Name(), err))
+	}
+	return "", "", fmt.Errorf("all llm providers failed: %s", strings.Join(errs, "; "))
+}
+
+func (c *Chain) attempt(ctx context.Context, p Provider, prompt string) (string, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	answer, err := p.Generate(ctx, prompt)
+	if err != nil {
+		return "", err
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return "", ErrEmptyAnswer
+	}
+	return answer, nil
+}
+
+func (c *Chain) Stream(ctx context.Context, prompt string, emit func(string) error) error {
+	_, err := c.StreamFrom(ctx, prompt, emit)
+	return err
+}
+
+// Once text has been emitted a provider's failure ends the stream instead
+// of falling back.
+func (c *Chain) StreamFrom(ctx context.Context, prompt string, emit func(string) error) (string, error) {
+	if len(c.providers) == 0 {
+		return "", errors.New("no llm provider configured")
+	}
+	var errs []string
+	for _, p := range c.providers {
+		emitted := false
+		err := c.streamAttempt(ctx, p, prompt, func(text string) error {
+			if text == "" {
+				return nil
+			}
+			emitted = true
+			return emit(text)
+		})
+		if err == nil && !emitted {
+			err = ErrEmptyAnswer
+		}
+		if err == nil {
+			return p.Name(), nil
+		}
+		if emitted || ctx.Err() != nil {
+			return p.Name(), err
+		}
+		log.Printf("llm provider %s failed: %v", p.Name(), err)
+		errs = append(errs, fmt.Sprintf("%s: %v", p.Name(), err))
+	}
+	return "", fmt.Errorf("all llm providers failed: %s", strings.Join(errs, "; "))
+}
+
+func (c *Chain) streamAttempt(ctx context.Context, p Provider, prompt string, emit func(string) error) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	return Stream(ctx, p, prompt, emit)
+}
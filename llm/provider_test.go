@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeProvider struct {
+	name   string
+	answer string
+	err    error
+	hang   bool
+	calls  *[]string
+	mu     *sync.Mutex
+	ctxErr error
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	p.mu.Lock()
+	*p.calls = append(*p.calls, p.name)
+	p.mu.Unlock()
+	if p.hang {
+		<-ctx.Done()
+		p.ctxErr = ctx.Err()
+		return "", ctx.Err()
+	}
+	return p.answer, p.err
+}
+
+func newFakes(specs ...fakeProvider) ([]Provider, *[]string) {
+	calls := &[]string{}
+	mu := &sync.Mutex{}
+	providers := make([]Provider, 0, len(specs))
+	for i := range specs {
+		p := specs[i]
+		p.calls, p.mu = calls, mu
+		providers = append(providers, &p)
+	}
+	return providers, calls
+}
+
+func TestChainFallsBackInOrder(t *testing.T) {
+	fakes, calls := newFakes(
+		fakeProvider{name: "down", err: errors.New("503 service unavailable")},
+		fakeProvider{name: "empty", answer: "  "},
+	)
+	stub := NewStubProvider([]StubRule{{Match: "plagiarism", Answer: "Plagiarism leads to disqualification."}})
+	chain := NewChain(time.Second, append(fakes, stub)...)
+
+	name, answer, err := chain.GenerateFrom(context.Background(), "What is the plagiarism policy?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "stub" || answer != "Plagiarism leads to disqualification." {
+		t.Errorf("got %q from %s", answer, name)
+	}
+	if strings.Join(*calls, ",") != "down,empty" {
+		t.Errorf("providers tried in order %v, want down,empty", *calls)
+	}
+	if got := stub.Prompts(); len(got) != 1 || got[0] != "What is the plagiarism policy?" {
+		t.Errorf("stub prompts = %q", got)
+	}
+}
+
+func TestChainTimesOutEachProvider(t *testing.T) {
+	fakes, calls := newFakes(fakeProvider{name: "slow", hang: true})
+	stub := NewStubProvider(nil)
+	chain := NewChain(50*time.Millisecond, append(fakes, stub)...)
+
+	start := time.Now()
+	name, answer, err := chain.GenerateFrom(context.Background(), "anything")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("chain took %s; the slow provider was not cut off", elapsed)
+	}
+	if name != "stub" || answer != StubAnswer {
+		t.Errorf("got %q from %s", answer, name)
+	}
+	if slow := fakes[0].(*fakeProvider); !errors.Is(slow.ctxErr, context.DeadlineExceeded) {
+		t.Errorf("slow provider saw %v, want a deadline", slow.ctxErr)
+	}
+	if len(*calls) != 1 {
+		t.Errorf("calls = %v", *calls)
+	}
+}
+
+func TestChainStopsWhenRequestIsCancelled(t *testing.T) {
+	fakes, _ := newFakes(fakeProvider{name: "slow", hang: true})
+	stub := NewStubProvider(nil)
+	chain := NewChain(time.Minute, append(fakes, stub)...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := chain.GenerateFrom(ctx, "anything"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the request's deadline", err)
+	}
+	if n := len(stub.Prompts()); n != 0 {
+		t.Errorf("stub was called %d times after the request was cancelled", n)
+	}
+}
+
+func TestChainReportsEveryFailure(t *testing.T) {
+	stub := NewStubProvider([]StubRule{{Error: "scripted failure"}})
+	fakes, _ := newFakes(fakeProvider{name: "down", err: errors.New("connection refused")})
+	_, _, err := NewChain(time.Second, append(fakes, stub)...).GenerateFrom(context.Background(), "q")
+	if err == nil || !strings.Contains(err.Error(), "down: connection refused") || !strings.Contains(err.Error(), "stub: scripted failure") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestChainStreamFallsBackOnlyBeforeOutput(t *testing.T) {
+	fakes, _ := newFakes(fakeProvider{name: "down", err: errors.New("503")})
+	stub := NewStubProvider([]StubRule{{Answer: "one two three"}})
+	chain := NewChain(time.Second, append(fakes, stub)...)
+
+	var pieces []string
+	name, err := chain.StreamFrom(context.Background(), "q", func(s string) error {
+		pieces = append(pieces, s)
+		return nil
+	})
+	if err != nil || name != "stub" {
+		t.Fatalf("StreamFrom = %s, %v", name, err)
+	}
+	if strings.Join(pieces, "|") != "one |two |three" {
+		t.Errorf("pieces = %q", pieces)
+	}
+
+	stop := errors.New("stop")
+	second := NewStubProvider(nil)
+	chain = NewChain(time.Second, stub, second)
+	_, err = chain.StreamFrom(context.Background(), "q", func(string) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Errorf("err = %v, want the emit error", err)
+	}
+	if n := len(second.Prompts()); n != 0 {
+		t.Errorf("fell back to the next provider after output was emitted")
+	}
+}
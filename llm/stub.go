@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// The first rule whose Match occurs in the prompt wins; an empty Match
+// matches every prompt.
+type StubRule struct {
+	Match  string `json:"match"`
+	Answer string `json:"answer"`
+	Error  string `json:"error,omitempty"`
+}
+
+const StubAnswer = "This is a stub answer."
+
+type StubProvider struct {
+	rules []StubRule
+
+	mu      sync.Mutex
+	prompts []string
+}
+
+func NewStubProvider(rules []StubRule) *StubProvider {
+	return &StubProvider{rules: rules}
+}
+
+func LoadStubScript(path string) ([]StubRule, error) {
+	if path == "" {
+		return nil, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []StubRule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("invalid stub script %s: %v", path, err)
+	}
+	return rules, nil
+}
+
+func (p *StubProvider) Name() string {
+	return "stub"
+}
+
+func (p *StubProvider) Generate(ctx context.Context, prompt string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	p.mu.Lock()
+	p.prompts = append(p.prompts, prompt)
+	p.mu.Unlock()
+
+	lower := strings.ToLower(prompt)
+	for _, r := range p.rules {
+		if r.Match != "" && !strings.Contains(lower, strings.ToLower(r.Match)) {
+			continue
+		}
+		if r.Error != "" {
+			return "", errors.New(r.Error)
+		}
+		return r.Answer, nil
+	}
+	return StubAnswer, nil
+}
+
+func (p *StubProvider) Prompts() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.prompts...)
+}
+
+func (p *StubProvider) Stream(ctx context.Context, prompt string, emit func(string) error) error {
+	answer, err := p.Generate(ctx, prompt)
+	if err != nil {
+		return err
+	}
+	for _, word := range strings.SplitAfter(answer, " ") {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := emit(word); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStubProviderRules(t *testing.T) {
+	stub := NewStubProvider([]StubRule{
+		{Match: "Fee", Answer: "Registration is free."},
+		{Match: "outage", Error: "scripted outage"},
+		{Answer: "Catch-all."},
+	})
+	tests := []struct {
+		prompt  string
+		answer  string
+		wantErr bool
+	}{
+		{"Is there a registration fee?", "Registration is free.", false},
+		{"simulate an outage", "", true},
+		{"anything else", "Catch-all.", false},
+	}
+	for _, tt := range tests {
+		answer, err := stub.Generate(context.Background(), tt.prompt)
+		if answer != tt.answer || (err != nil) != tt.wantErr {
+			t.Errorf("Generate(%q) = %q, %v", tt.prompt, answer, err)
+		}
+	}
+	if got := stub.Prompts(); len(got) != len(tests) {
+		t.Errorf("prompts = %q", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := stub.Generate(ctx, "fee"); err == nil {
+		t.Error("the stub answered a cancelled request")
+	}
+}
+
+func TestLoadStubScript(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(good, []byte(`[{"match":"fee","answer":"Free."}]`), 0644)
+	os.WriteFile(bad, []byte(`{"match":"fee"}`), 0644)
+
+	tests := []struct {
+		path    string
+		rules   int
+		wantErr bool
+	}{
+		{"", 0, false},
+		{good, 1, false},
+		{bad, 0, true},
+		{filepath.Join(dir, "missing.json"), 0, true},
+	}
+	for _, tt := range tests {
+		rules, err := LoadStubScript(tt.path)
+		if len(rules) != tt.rules || (err != nil) != tt.wantErr {
+			t.Errorf("LoadStubScript(%q) = %v, %v", tt.path, rules, err)
+		}
+	}
+}
@@ -1,14 +1,9 @@
 package mail
 
 import (
-	"bytes"
 	"fmt"
-	"html/template"
 	"log"
 	"net/smtp"
-	"os"
-	"path/filepath"
-	"strings"
 )
 
 type EmailConfig struct {
@@ -18,6 +13,8 @@ type EmailConfig struct {
 	SMTPPassword string
 	FromEmail    string
 	FromName     string
+	BaseURL      string
+	TokenSecret  string
 }
 
 type MailSender interface {
@@ -25,7 +22,9 @@ type MailSender interface {
 }
 
 type EmailService struct {
-	config EmailConfig
+	config      EmailConfig
+	suppression SuppressionChecker
+	templates   TemplateStore
 }
 
 func NewEmailService(config *EmailConfig) *EmailService {
@@ -47,39 +46,50 @@ func (es *EmailService) SendEmail(to, subject, htmlBody string) error {
 	return es.sendEmail(to, subject, htmlBody)
 }
 
-func (es *EmailService) renderOTPTemplate(otp string, schoolCode string) (string, error) {
-	templatePath := filepath.Join("mail", "otp.html")
+func (es *EmailService) SetSuppressionChecker(checker SuppressionChecker) {
+	es.suppression = checker
+}
 
-	templateContent, err := os.ReadFile(templatePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to read template file: %v", err)
+func (es *EmailService) CheckSuppressed(to, category string) error {
+	if category == CategoryTransactional || es.suppression == nil {
+		return nil
 	}
-
-	tmpl, err := template.New("otp").Parse(string(templateContent))
+	suppressed, err := es.suppression.IsSuppressed(to, category)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %v", err)
+		return fmt.Errorf("failed to check suppression list: %v", err)
 	}
-
-	td := `<td align="center" valign="middle" style="width:48px; height:48px; background-color:#2977f5; color:#ffffff; border-radius:12px; font-size:1.5rem; font-weight:700; line-height:48px; text-align:center; vertical-align:middle; padding:0;">%s</td>`
-	var cells strings.Builder
-	for _, d := range strings.Split(otp, "") {
-		cells.WriteString(fmt.Sprintf(td, template.HTMLEscapeString(d)))
+	if suppressed {
+		return ErrSuppressed
 	}
+	return nil
+}
 
-	data := struct {
-		OTP        string
-		OTPCells   template.HTML
-		SchoolCode string
-	}{OTP: otp, OTPCells: template.HTML(cells.String()), SchoolCode: schoolCode}
-
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", fmt.Errorf("failed to execute template: %v", err)
+func (es *EmailService) SendCampaignEmail(to, subject, category, htmlBody string) error {
+	if err := es.CheckSuppressed(to, category); err != nil {
+		return err
 	}
-	return buf.String(), nil
+	headers := map[string]string{}
+	if unsubURL := es.UnsubscribeURL(to); unsubURL != "" {
+		listUnsub := fmt.Sprintf("<%s>", unsubURL)
+		if es.config.FromEmail != "" {
+			listUnsub += fmt.Sprintf(", <mailto:%s?subject=unsubscribe>", es.config.FromEmail)
+		}
+		headers["List-Unsubscribe"] = listUnsub
+		headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+	}
+	return es.sendEmailWithHeaders(to, subject, htmlBody, headers)
+}
+
+func (es *EmailService) renderOTPTemplate(otp string, schoolCode string) (string, error) {
+	data := otpTemplateData{OTP: otp, OTPCells: otpCells(otp), SchoolCode: schoolCode}
+	return es.renderTemplate("otp", data)
 }
 
 func (es *EmailService) sendEmail(to, subject, htmlBody string) error {
+	return es.sendEmailWithHeaders(to, subject, htmlBody, nil)
+}
+
+func (es *EmailService) sendEmailWithHeaders(to, subject, htmlBody string, extra map[string]string) error {
 	if es.config.SMTPUsername == "" || es.config.SMTPPassword == "" {
 		return fmt.Errorf("SMTP credentials not configured")
 	}
@@ -93,6 +103,9 @@ func (es *EmailService) sendEmail(to, subject, htmlBody string) error {
 		"MIME-Version": "1.0",
 		"Content-Type": "text/html; charset=UTF-8",
 	}
+	for k, v := range extra {
+		headers[k] = v
+	}
 
 	message := ""
 	for k, v := range headers {
@@ -105,6 +118,6 @@ func (es *EmailService) sendEmail(to, subject, htmlBody string) error {
 		return fmt.Errorf("failed to send email: %v", err)
 	}
 
-	log.Printf("OTP email sent successfully to %s", to)
+	log.Printf("Email sent successfully to %s", to)
 	return nil
 }
@@ -1,11 +1,9 @@
 package mail
 
 import (
+	"errors"
 	"fmt"
-	"html/template"
-	"os"
-	"path/filepath"
-	"strings"
+	"log"
 	"time"
 )
 
@@ -34,7 +32,7 @@ func (ies *InviteEmailService) SendInviteEmail(req InviteEmailRequest) error {
 		return fmt.Errorf("failed to generate invite email: %v", err)
 	}
 
-	return ies.emailService.SendEmail(req.ToEmail, subject, htmlContent)
+	return ies.emailService.SendCampaignEmail(req.ToEmail, subject, CategoryInvite, htmlContent)
 }
 
 func (ies *InviteEmailService) SendBulkInvites(emails []string, customMessage string) error {
@@ -47,6 +45,10 @@ func (ies *InviteEmailService) SendBulkInvites(emails []string, customMessage st
 		}
 
 		err := ies.SendInviteEmail(req)
+		if errors.Is(err, ErrSuppressed) {
+			log.Printf("skipping invite to %s: unsubscribed", email)
+			continue
+		}
 		if err != nil {
 			return fmt.Errorf("failed to send to %s: %v", email, err)
 		}
@@ -57,76 +59,35 @@ func (ies *InviteEmailService) SendBulkInvites(emails []string, customMessage st
 }
 
 func (ies *InviteEmailService) generateInviteEmail(req InviteEmailRequest) (string, error) {
-	templatePath := filepath.Join("mail", "invite.html")
-
-	templateContent, err := os.ReadFile(templatePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to read template file: %v", err)
-	}
-
-	tmpl, err := template.New("invite").Parse(string(templateContent))
-	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %v", err)
-	}
-
-	data := struct {
-		SchoolName    string
-		PrincipalName string
-		CustomMessage string
-		CurrentYear   int
-		CurrentDate   string
-	}{
-		SchoolName:    req.SchoolName,
-		PrincipalName: req.PrincipalName,
-		CustomMessage: req.CustomMessage,
-		CurrentYear:   time.Now().Year(),
-		CurrentDate:   time.Now().Format("January 2, 2006"),
-	}
-
-	var buf strings.Builder
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", fmt.Errorf("failed to execute template: %v", err)
-	}
-	return buf.String(), nil
+	data := inviteTemplateData{
+		SchoolName:     req.SchoolName,
+		PrincipalName:  req.PrincipalName,
+		CustomMessage:  req.CustomMessage,
+		CurrentYear:    time.Now().Year(),
+		CurrentDate:    time.Now().Format("January 2, 2006"),
+		UnsubscribeURL: ies.emailService.UnsubscribeURL(req.ToEmail),
+	}
+	return ies.emailService.renderTemplate("invite", data)
 }
 
 func (ies *InviteEmailService) SendReminderEmail(email, schoolName string) error {
 	subject := "Reminder: Exun 2025 Registration Deadline Approaching"
 
-	htmlContent, err := ies.generateReminderEmail(schoolName)
+	htmlContent, err := ies.generateReminderEmail(email, schoolName)
 	if err != nil {
 		return fmt.Errorf("failed to generate reminder email: %v", err)
 	}
 
-	return ies.emailService.SendEmail(email, subject, htmlContent)
+	return ies.emailService.SendCampaignEmail(email, subject, CategoryReminder, htmlContent)
 }
 
-func (ies *InviteEmailService) generateReminderEmail(schoolName string) (string, error) {
-	templatePath := filepath.Join("mail", "reminder.html")
-
-	templateContent, err := os.ReadFile(templatePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to read template file: %v", err)
-	}
-
-	tmpl, err := template.New("reminder").Parse(string(templateContent))
-	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %v", err)
-	}
-
-	data := struct {
-		SchoolName  string
-		CurrentYear int
-	}{
-		SchoolName:  schoolName,
-		CurrentYear: time.Now().Year(),
-	}
-
-	var buf strings.Builder
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", fmt.Errorf("failed to execute template: %v", err)
+func (ies *InviteEmailService) generateReminderEmail(email, schoolName string) (string, error) {
+	data := reminderTemplateData{
+		SchoolName:     schoolName,
+		CurrentYear:    time.Now().Year(),
+		UnsubscribeURL: ies.emailService.UnsubscribeURL(email),
 	}
-	return buf.String(), nil
+	return ies.emailService.renderTemplate("reminder", data)
 }
 
 func (ies *InviteEmailService) SendWelcomeEmail(email, schoolName string) error {
@@ -141,29 +102,9 @@ func (ies *InviteEmailService) SendWelcomeEmail(email, schoolName string) error
 }
 
 func (ies *InviteEmailService) generateWelcomeEmail(schoolName string) (string, error) {
-	templatePath := filepath.Join("mail", "welcome.html")
-
-	templateContent, err := os.ReadFile(templatePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to read template file: %v", err)
-	}
-
-	tmpl, err := template.New("welcome").Parse(string(templateContent))
-	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %v", err)
-	}
-
-	data := struct {
-		SchoolName  string
-		CurrentYear int
-	}{
+	data := welcomeTemplateData{
 		SchoolName:  schoolName,
 		CurrentYear: time.Now().Year(),
 	}
-
-	var buf strings.Builder
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", fmt.Errorf("failed to execute template: %v", err)
-	}
-	return buf.String(), nil
+	return ies.emailService.renderTemplate("welcome", data)
 }
@@ -0,0 +1,109 @@
+package mail
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	RegistrationCreated   = "created"
+	RegistrationUpdated   = "updated"
+	RegistrationWithdrawn = "withdrawn"
+)
+
+type RegistrationParticipant struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Class int    `json:"class"`
+	Phone string `json:"phone"`
+}
+
+type RegistrationChange struct {
+	ToEmail      string                    `json:"to_email"`
+	SchoolName   string                    `json:"school_name"`
+	Action       string                    `json:"action"`
+	EventID      string                    `json:"event_id"`
+	EventName    string                    `json:"event_name"`
+	Mode         string                    `json:"mode"`
+	Dates        string                    `json:"dates"`
+	Participants []RegistrationParticipant `json:"participants"`
+	ChangedAt    string                    `json:"changed_at"`
+	SummaryURL   string                    `json:"summary_url"`
+}
+
+type digestTemplateData struct {
+	SchoolName     string
+	Since          string
+	TotalChanges   int
+	Changes        []RegistrationChange
+	SummaryURL     string
+	UnsubscribeURL string
+}
+
+func sampleRegistrationChange(now time.Time) RegistrationChange {
+	return RegistrationChange{
+		ToEmail:    "coordinator@example.com",
+		SchoolName: "Sample Public School",
+		Action:     RegistrationCreated,
+		EventID:    "sample-event",
+		EventName:  "Sample Event",
+		Mode:       "online",
+		Dates:      "November 9-10, 2025",
+		Participants: []RegistrationParticipant{
+			{Name: "ASHA VERMA", Email: "asha@example.com", Class: 11, Phone: "9876543210"},
+			{Name: "ROHAN MEHTA", Email: "rohan@example.com", Class: 12, Phone: "9876501234"},
+		},
+		ChangedAt:  now.Format("January 2, 2006 3:04 PM"),
+		SummaryURL: "https://example.com/summary",
+	}
+}
+
+func (es *EmailService) SummaryURL() string {
+	base := es.config.BaseURL
+	if base == "" {
+		base = "https://reg.exunclan.com"
+	}
+	return strings.TrimRight(base, "/") + "/summary"
+}
+
+func (es *EmailService) SendRegistrationConfirmation(change RegistrationChange) error {
+	if change.SummaryURL == "" {
+		change.SummaryURL = es.SummaryURL()
+	}
+	var subject string
+	switch change.Action {
+	case RegistrationWithdrawn:
+		subject = fmt.Sprintf("Exun 2025: Registration withdrawn for %s", change.EventName)
+	case RegistrationUpdated:
+		subject = fmt.Sprintf("Exun 2025: Registration updated for %s", change.EventName)
+	default:
+		subject = fmt.Sprintf("Exun 2025: Registration received for %s", change.EventName)
+	}
+
+	htmlBody, err := es.renderTemplate("registration", change)
+	if err != nil {
+		return fmt.Errorf("failed to render registration email: %v", err)
+	}
+	return es.sendEmail(change.ToEmail, subject, htmlBody)
+}
+
+func (es *EmailService) SendRegistrationDigest(to, schoolName string, since time.Time, totalChanges int, changes []RegistrationChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	data := digestTemplateData{
+		SchoolName:     schoolName,
+		Since:          since.Format("January 2, 2006 3:04 PM"),
+		TotalChanges:   totalChanges,
+		Changes:        changes,
+		SummaryURL:     es.SummaryURL(),
+		UnsubscribeURL: es.UnsubscribeURL(to),
+	}
+	htmlBody, err := es.renderTemplate("digest", data)
+	if err != nil {
+		return fmt.Errorf("failed to render digest email: %v", err)
+	}
+	subject := fmt.Sprintf("Exun 2025: %d registration changes", totalChanges)
+	return es.SendCampaignEmail(to, subject, CategoryDigest, htmlBody)
+}
@@ -0,0 +1,168 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var TemplateNames = []string{"otp", "invite", "reminder", "welcome", "registration", "digest"}
+
+var templateFuncs = template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}
+
+type TemplateStore interface {
+	ActiveEmailTemplate(name string) (string, error)
+}
+
+type otpTemplateData struct {
+	OTP        string
+	OTPCells   template.HTML
+	SchoolCode string
+}
+
+type inviteTemplateData struct {
+	SchoolName     string
+	PrincipalName  string
+	CustomMessage  string
+	CurrentYear    int
+	CurrentDate    string
+	UnsubscribeURL string
+}
+
+type reminderTemplateData struct {
+	SchoolName     string
+	CurrentYear    int
+	UnsubscribeURL string
+}
+
+type welcomeTemplateData struct {
+	SchoolName  string
+	CurrentYear int
+}
+
+func IsTemplateName(name string) bool {
+	for _, n := range TemplateNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func DefaultTemplate(name string) (string, error) {
+	if !IsTemplateName(name) {
+		return "", fmt.Errorf("unknown template: %s", name)
+	}
+	b, err := os.ReadFile(filepath.Join("mail", name+".html"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read template file: %v", err)
+	}
+	return string(b), nil
+}
+
+func SampleTemplateData(name string) (interface{}, error) {
+	now := time.Now()
+	switch name {
+	case "otp":
+		return otpTemplateData{OTP: "123456", OTPCells: otpCells("123456"), SchoolCode: "123456"}, nil
+	case "invite":
+		return inviteTemplateData{
+			SchoolName:     "Sample Public School",
+			PrincipalName:  "Dr. A. Sharma",
+			CustomMessage:  "We look forward to your participation.",
+			CurrentYear:    now.Year(),
+			CurrentDate:    now.Format("January 2, 2006"),
+			UnsubscribeURL: "https://example.com/unsubscribe?token=sample",
+		}, nil
+	case "reminder":
+		return reminderTemplateData{SchoolName: "Sample Public School", CurrentYear: now.Year(), UnsubscribeURL: "https://example.com/unsubscribe?token=sample"}, nil
+	case "welcome":
+		return welcomeTemplateData{SchoolName: "Sample Public School", CurrentYear: now.Year()}, nil
+	case "registration":
+		return sampleRegistrationChange(now), nil
+	case "digest":
+		change := sampleRegistrationChange(now)
+		return digestTemplateData{
+			SchoolName:     change.SchoolName,
+			Since:          now.Add(-24 * time.Hour).Format("January 2, 2006 3:04 PM"),
+			TotalChanges:   3,
+			Changes:        []RegistrationChange{change, change},
+			SummaryURL:     change.SummaryURL,
+			UnsubscribeURL: "https://example.com/unsubscribe?token=sample",
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown template: %s", name)
+	}
+}
+
+func ValidateTemplate(name, body string) error {
+	sample, err := SampleTemplateData(name)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(body)
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %v", err)
+	}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return fmt.Errorf("failed to execute template against sample data: %v", err)
+	}
+	return nil
+}
+
+func RenderTemplatePreview(name, body string) (string, error) {
+	sample, err := SampleTemplateData(name)
+	if err != nil {
+		return "", err
+	}
+	return executeTemplate(name, body, sample)
+}
+
+func (es *EmailService) SetTemplateStore(store TemplateStore) {
+	es.templates = store
+}
+
+func (es *EmailService) loadTemplate(name string) (string, error) {
+	if es.templates != nil {
+		if body, err := es.templates.ActiveEmailTemplate(name); err == nil && body != "" {
+			return body, nil
+		}
+	}
+	return DefaultTemplate(name)
+}
+
+func (es *EmailService) renderTemplate(name string, data interface{}) (string, error) {
+	body, err := es.loadTemplate(name)
+	if err != nil {
+		return "", err
+	}
+	return executeTemplate(name, body, data)
+}
+
+func executeTemplate(name, body string, data interface{}) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %v", err)
+	}
+	return buf.String(), nil
+}
+
+func otpCells(otp string) template.HTML {
+	td := `<td align="center" valign="middle" style="width:48px; height:48px; background-color:#2977f5; color:#ffffff; border-radius:12px; font-size:1.5rem; font-weight:700; line-height:48px; text-align:center; vertical-align:middle; padding:0;">%s</td>`
+	var cells strings.Builder
+	for _, d := range strings.Split(otp, "") {
+		cells.WriteString(fmt.Sprintf(td, template.HTMLEscapeString(d)))
+	}
+	return template.HTML(cells.String())
+}
@@ -0,0 +1,64 @@
+package mail
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		name, template, body string
+		ok                   bool
+	}{
+		{"known field", "invite", "<p>{{.SchoolName}}</p>", true},
+		{"otp cells", "otp", "{{.OTPCells}} {{.SchoolCode}}", true},
+		{"unknown field", "reminder", "{{.PrincipalName}}", false},
+		{"syntax error", "welcome", "{{if .SchoolName}}", false},
+		{"unknown template", "newsletter", "hello", false},
+	}
+	for _, tt := range tests {
+		err := ValidateTemplate(tt.template, tt.body)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+	}
+}
+
+type fakeTemplates map[string]string
+
+func (f fakeTemplates) ActiveEmailTemplate(name string) (string, error) {
+	return f[name], nil
+}
+
+func TestRenderTemplateUsesStore(t *testing.T) {
+	es := NewEmailService(&EmailConfig{})
+	es.SetTemplateStore(fakeTemplates{"welcome": "Welcome, {{.SchoolName}}!"})
+	got, err := es.renderTemplate("welcome", welcomeTemplateData{SchoolName: "DPS <RK Puram>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Welcome, DPS &lt;RK Puram&gt;!" {
+		t.Errorf("rendered %q", got)
+	}
+
+	preview, err := RenderTemplatePreview("reminder", "{{.SchoolName}} {{.UnsubscribeURL}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(preview, "Sample Public School") {
+		t.Errorf("preview = %q", preview)
+	}
+}
+
+func TestDefaultTemplatesRenderSampleData(t *testing.T) {
+	t.Chdir("..")
+	for _, name := range TemplateNames {
+		body, err := DefaultTemplate(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ValidateTemplate(name, body); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package mail
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	CategoryTransactional = "transactional"
+	CategoryInvite        = "invite"
+	CategoryReminder      = "reminder"
+	CategoryDigest        = "digest"
+	CategoryAll           = "all"
+)
+
+var CampaignCategories = []string{CategoryInvite, CategoryReminder, CategoryDigest}
+
+var CategoryNames = map[string]string{
+	CategoryInvite:   "Symposium invitations",
+	CategoryReminder: "Registration reminders",
+	CategoryDigest:   "Daily registration digest",
+}
+
+var ErrSuppressed = errors.New("recipient has unsubscribed from this category")
+
+type SuppressionChecker interface {
+	IsSuppressed(email, category string) (bool, error)
+}
+
+func IsCampaignCategory(category string) bool {
+	for _, c := range CampaignCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+func GenerateUnsubscribeToken(secret, email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(email))
+	return payload + "." + signUnsubscribePayload(secret, payload)
+}
+
+func VerifyUnsubscribeToken(secret, token string) (string, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("malformed token")
+	}
+	expected := signUnsubscribePayload(secret, parts[0])
+	if !hmac.Equal([]byte(expected), []byte(parts[1])) {
+		return "", fmt.Errorf("invalid token signature")
+	}
+	email, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("malformed token payload")
+	}
+	return string(email), nil
+}
+
+func signUnsubscribePayload(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte("unsubscribe:"+secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (es *EmailService) UnsubscribeToken(email string) string {
+	if es.config.TokenSecret == "" {
+		return ""
+	}
+	return GenerateUnsubscribeToken(es.config.TokenSecret, email)
+}
+
+func (es *EmailService) VerifyUnsubscribeToken(token string) (string, error) {
+	if es.config.TokenSecret == "" {
+		return "", fmt.Errorf("unsubscribe tokens not configured")
+	}
+	return VerifyUnsubscribeToken(es.config.TokenSecret, token)
+}
+
+func (es *EmailService) UnsubscribeURL(email string) string {
+	token := es.UnsubscribeToken(email)
+	if token == "" || es.config.BaseURL == "" {
+		return ""
+	}
+	return strings.TrimRight(es.config.BaseURL, "/") + "/unsubscribe?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,62 @@
+package mail
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUnsubscribeToken(t *testing.T) {
+	token := GenerateUnsubscribeToken("secret", "  Teacher@School.EDU ")
+	email, err := VerifyUnsubscribeToken("secret", token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email != "teacher@school.edu" {
+		t.Errorf("email = %q", email)
+	}
+
+	payload, sig, _ := strings.Cut(token, ".")
+	other := GenerateUnsubscribeToken("secret", "other@school.edu")
+	otherPayload, _, _ := strings.Cut(other, ".")
+	tests := []struct {
+		name, secret, token string
+	}{
+		{"wrong secret", "other", token},
+		{"swapped payload", "secret", otherPayload + "." + sig},
+		{"no signature", "secret", payload},
+		{"empty signature", "secret", payload + "."},
+		{"empty", "secret", ""},
+	}
+	for _, tt := range tests {
+		if _, err := VerifyUnsubscribeToken(tt.secret, tt.token); err == nil {
+			t.Errorf("%s: token was accepted", tt.name)
+		}
+	}
+}
+
+type fakeSuppressions map[string]bool
+
+func (f fakeSuppressions) IsSuppressed(email, category string) (bool, error) {
+	return f[email+"/"+category], nil
+}
+
+func TestCheckSuppressed(t *testing.T) {
+	es := NewEmailService(&EmailConfig{})
+	es.SetSuppressionChecker(fakeSuppressions{"a@x.org/invite": true})
+
+	tests := []struct {
+		to, category string
+		want         error
+	}{
+		{"a@x.org", CategoryInvite, ErrSuppressed},
+		{"a@x.org", CategoryReminder, nil},
+		{"a@x.org", CategoryTransactional, nil},
+		{"b@x.org", CategoryInvite, nil},
+	}
+	for _, tt := range tests {
+		if err := es.CheckSuppressed(tt.to, tt.category); !errors.Is(err, tt.want) {
+			t.Errorf("CheckSuppressed(%q, %q) = %v, want %v", tt.to, tt.category, err, tt.want)
+		}
+	}
+}
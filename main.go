@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -11,13 +13,18 @@ import (
 	"syscall"
 	"time"
 
+	"exunreg25/backup"
 	"exunreg25/config"
+	"exunreg25/datasync"
 	"exunreg25/db"
+	"exunreg25/guardrails"
 	"exunreg25/handlers"
+	"exunreg25/llm"
 	"exunreg25/mail"
 	"exunreg25/middleware"
 	"exunreg25/routes"
 	"exunreg25/templates"
+	"exunreg25/webhooks"
 )
 
 func init() {
@@ -53,6 +60,9 @@ func main() {
 	if cfg.AuthSalt == "" {
 		log.Fatal("AUTH_SALT environment variable is required")
 	}
+	if err := middleware.SetTrustedProxies(strings.Split(cfg.TrustedProxies, ",")); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
 
 	database, err := db.NewConnection(cfg.DBPath)
 	if err != nil {
@@ -64,6 +74,46 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	snapshots := backup.NewManager(database, cfg.DBPath, cfg.BackupDir, backup.Retention{
+		Hourly: cfg.BackupKeepHourly,
+		Daily:  cfg.BackupKeepDaily,
+		Weekly: cfg.BackupKeepWeekly,
+	})
+
+	handlers.SetGlobalDB(database)
+	tokenKey, err := backup.ParseKey(cfg.OAuthTokenKey)
+	if err != nil {
+		log.Fatalf("Invalid OAUTH_TOKEN_KEY: %v", err)
+	}
+	if tokenKey == nil {
+		sum := sha256.Sum256([]byte("oauth-token:" + cfg.AuthSalt))
+		tokenKey = sum[:]
+	}
+	handlers.SetDriveOAuth(cfg.BaseURL, tokenKey)
+	if err := handlers.ImportLegacyDriveToken(cfg.OAuthTokenFile); err != nil {
+		log.Printf("Failed to import legacy Drive token: %v", err)
+	}
+
+	targets, encryptionKey, err := backupTargets(cfg)
+	if err != nil {
+		log.Fatalf("Invalid backup target configuration: %v", err)
+	}
+	snapshots.SetTargets(targets, encryptionKey)
+	handlers.SetBackupManager(snapshots)
+
+	if flag.NArg() > 0 {
+		if err := runCommand(flag.Args(), snapshots, cfg.DBPath); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	lock, err := db.Lock(cfg.DBPath)
+	if err != nil {
+		log.Fatalf("Failed to lock %s: %v", cfg.DBPath, err)
+	}
+	defer lock.Close()
+
 	authConfig := &handlers.AuthConfig{
 		Salt:         cfg.AuthSalt,
 		CookieSecure: cfg.CookieSecure,
@@ -76,25 +126,87 @@ func main() {
 		SMTPPassword: cfg.SMTPPassword,
 		FromEmail:    cfg.FromEmail,
 		FromName:     cfg.FromName,
+		BaseURL:      cfg.BaseURL,
+		TokenSecret:  cfg.TokenSecret,
 	}
 
 	emailService := mail.NewEmailService(emailConfig)
+	emailService.SetSuppressionChecker(database)
+	emailService.SetTemplateStore(database)
+	for _, name := range mail.TemplateNames {
+		body, err := mail.DefaultTemplate(name)
+		if err != nil {
+			log.Printf("email template %s: %v", name, err)
+			continue
+		}
+		if err := database.SeedEmailTemplate(name, body); err != nil {
+			log.Printf("failed to seed email template %s: %v", name, err)
+		}
+	}
 	inviteService := mail.NewInviteEmailService(emailService)
 	authHandler := handlers.NewAuthHandler(database, authConfig, emailService)
 	adminHandler := handlers.NewAdminHandler(database)
 
 	handlers.SetInviteService(inviteService)
+	handlers.SetEmailService(emailService)
 
 	handlers.SetGlobalAuthHandler(authHandler)
 	handlers.SetGlobalAdminHandler(adminHandler)
-	handlers.SetGlobalDB(database)
+	handlers.SetChangeFeedToken(cfg.ChangeFeedToken)
+
+	webhookDispatcher := webhooks.NewDispatcher(database, cfg.WebhookMaxAttempts, time.Duration(cfg.WebhookTimeout)*time.Second)
+	handlers.SetWebhookDispatcher(webhookDispatcher)
+
+	checkCtx, cancelCheck := context.WithTimeout(context.Background(), 30*time.Second)
+	for name, err := range snapshots.CheckTargets(checkCtx) {
+		if err != nil {
+			log.Printf("Backup target %s check failed: %v", name, err)
+		} else {
+			log.Printf("Backup target %s ready", name)
+		}
+	}
+	cancelCheck()
+
+	if cfg.ScheduledSnapshots && cfg.BackupInterval > 0 {
+		go snapshots.Start(time.Duration(cfg.BackupInterval) * time.Minute)
+	}
+
+	go handlers.StartDriveTokenRefresher(30 * time.Minute)
+	go webhookDispatcher.Start(30 * time.Second)
+
+	if target, err := syncTarget(cfg); err != nil {
+		log.Printf("Sync disabled: %v", err)
+	} else if target != nil {
+		handlers.SetSyncTarget(target)
+		go handlers.StartSync(database)
+	}
+
+	handlers.SetGuardrails(guardrails.NewEngine(cfg.GuardrailsPath))
+	handlers.SetQueryLimits(handlers.QueryLimits{
+		PerMinute:   cfg.QueryRatePerMinute,
+		Burst:       cfg.QueryBurst,
+		IPPerMinute: cfg.QueryIPRatePerMinute,
+		IPBurst:     cfg.QueryIPBurst,
+		DailyCalls:  cfg.LLMDailyCallLimit,
+		DailyTokens: cfg.LLMDailyTokenLimit,
+	})
+	if provider := llmProvider(cfg); provider != nil {
+		handlers.SetLLMProvider(provider)
+		log.Printf("Query bot using %s", provider.Name())
+	} else {
+		log.Printf("Query bot disabled: no usable LLM provider in LLM_PROVIDERS=%q", cfg.LLMProviders)
+	}
+
+	if cfg.RegistrationDigest {
+		go handlers.StartRegistrationDigest(24*time.Hour, cfg.DigestMinChanges)
+	}
 
 	if err := templates.InitTemplates(); err != nil {
 		log.Fatal("Failed to initialize templates:", err)
 	}
 
 	handler := routes.SetupRoutes()
-	wrappedHandler := middleware.CORS(middleware.Logger(handler))
+	wrappedHandler := middleware.CORS(middleware.RequestID(middleware.Logger(middleware.WriteGate(handler, "/api/admin/restore"))))
 
 	server := &http.Server{
 		Addr:    ":" + *port,
@@ -115,3 +227,94 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+func backupTargets(cfg *config.Config) ([]backup.Target, []byte, error) {
+	key, err := backup.ParseKey(cfg.BackupEncryptionKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	var targets []backup.Target
+	for _, name := range strings.Split(cfg.BackupTargets, ",") {
+		name = strings.TrimSpace(name)
+		var t backup.Target
+		switch name {
+		case "":
+			continue
+		case "dir":
+			if cfg.BackupMirrorDir == "" {
+				return nil, nil, fmt.Errorf("BACKUP_MIRROR_DIR is required for the dir target")
+			}
+			t = backup.NewLocalTarget(cfg.BackupMirrorDir)
+		case "s3":
+			t, err = backup.NewS3Target(backup.S3Config{
+				Endpoint:  cfg.S3Endpoint,
+				Region:    cfg.S3Region,
+				Bucket:    cfg.S3Bucket,
+				Prefix:    cfg.S3Prefix,
+				AccessKey: cfg.S3AccessKey,
+				SecretKey: cfg.S3SecretKey,
+				PathStyle: cfg.S3PathStyle,
+			})
+		case "drive":
+			t, err = backup.NewServiceAccountDriveTarget(context.Background(), cfg.ServiceAccountJSON, cfg.BackupDriveFolderID)
+		case "drive-oauth":
+			t, err = handlers.OAuthDriveTarget(cfg.BackupDriveFolderID)
+		default:
+			return nil, nil, fmt.Errorf("unknown backup target %q", name)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if key == nil {
+			return nil, nil, fmt.Errorf("BACKUP_ENCRYPTION_KEY is required for the %s target", name)
+		}
+		targets = append(targets, t)
+	}
+	return targets, key, nil
+}
+
+func syncTarget(cfg *config.Config) (datasync.SyncTarget, error) {
+	switch cfg.SyncTarget {
+	case "", "none":
+		return nil, nil
+	case "sheets":
+		return datasync.NewSheetsTarget(context.Background(), cfg.ServiceAccountJSON, cfg.SpreadsheetID)
+	case "csv":
+		return datasync.NewCSVTarget(cfg.SyncCSVDir)
+	default:
+		return nil, fmt.Errorf("unknown sync target %q", cfg.SyncTarget)
+	}
+}
+
+// LLM_PROVIDERS lists the primary first; entries that cannot be set up are skipped.
+func llmProvider(cfg *config.Config) llm.Provider {
+	var providers []llm.Provider
+	for _, name := range strings.Split(cfg.LLMProviders, ",") {
+		name = strings.TrimSpace(name)
+		var p llm.Provider
+		var err error
+		switch name {
+		case "", "none":
+			continue
+		case "gemini":
+			p, err = llm.NewGeminiProvider(cfg.GeminiAPIKey, cfg.GeminiModel)
+		case "openai":
+			p, err = llm.NewOpenAIProvider(cfg.OpenAIBaseURL, cfg.OpenAIAPIKey, cfg.OpenAIModel)
+		case "stub":
+			var rules []llm.StubRule
+			rules, err = llm.LoadStubScript(cfg.LLMStubScript)
+			p = llm.NewStubProvider(rules)
+		default:
+			err = fmt.Errorf("unknown provider")
+		}
+		if err != nil {
+			log.Printf("LLM provider %s unavailable: %v", name, err)
+			continue
+		}
+		providers = append(providers, p)
+	}
+	if len(providers) == 0 {
+		return nil
+	}
+	return llm.NewChain(time.Duration(cfg.LLMTimeout)*time.Second, providers...)
+}
@@ -63,7 +63,7 @@ func Logger(next http.Handler) http.Handler {
 
 		duration := time.Since(start)
 
-		log.Printf("%s %s %d %s", r.Method, r.URL.Path, wrapped.statusCode, duration)
+		log.Printf("%s %s %d %s %s", r.Method, r.URL.Path, wrapped.statusCode, duration, GetRequestID(r))
 	})
 }
 
@@ -77,6 +77,11 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap lets streaming handlers flush through the logging middleware.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func AuthRequired(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		emailCookie, err := r.Cookie("email")
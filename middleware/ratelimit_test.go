@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurst(t *testing.T) {
+	tests := []struct {
+		name      string
+		perMinute int
+		burst     int
+		allowed   int
+	}{
+		{"burst of three", 1, 3, 3},
+		{"burst floor of one", 1, 0, 1},
+		{"no refill", 0, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewRateLimiter(tt.perMinute, tt.burst)
+			for i := 0; i < tt.allowed; i++ {
+				if ok, _ := l.Allow("a"); !ok {
+					t.Fatalf("request %d refused within the burst", i+1)
+				}
+			}
+			ok, wait := l.Allow("a")
+			if ok {
+				t.Fatal("request allowed past the burst")
+			}
+			if tt.perMinute == 0 && wait != time.Hour {
+				t.Errorf("wait = %s with no refill", wait)
+			}
+			if tt.perMinute == 1 && (wait <= 50*time.Second || wait > time.Minute) {
+				t.Errorf("wait = %s, want about a minute", wait)
+			}
+			if ok, _ := l.Allow("b"); !ok {
+				t.Error("another key shared the bucket")
+			}
+		})
+	}
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	l := NewRateLimiter(6000, 1)
+	if ok, _ := l.Allow("a"); !ok {
+		t.Fatal("first request refused")
+	}
+	if ok, _ := l.Allow("a"); ok {
+		t.Fatal("second request allowed immediately")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if ok, wait := l.Allow("a"); !ok {
+		t.Errorf("bucket did not refill; wait %s", wait)
+	}
+}
+
+func TestRateLimiterSweepsFullBuckets(t *testing.T) {
+	l := NewRateLimiter(60, 2)
+	l.Allow("idle")
+	l.buckets["idle"].seen = time.Now().Add(-time.Hour)
+	l.Allow("busy")
+	l.Allow("busy")
+	l.lastSweep = time.Time{}
+	l.Allow("other")
+	if _, ok := l.buckets["idle"]; ok {
+		t.Error("refilled bucket was not swept")
+	}
+	if _, ok := l.buckets["busy"]; !ok {
+		t.Error("an empty bucket was swept")
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetTrustedProxies(t *testing.T) {
+	tests := []struct {
+		entries []string
+		wantErr bool
+	}{
+		{[]string{"127.0.0.1", "::1", " 10.0.0.0/8 ", ""}, false},
+		{nil, false},
+		{[]string{"proxy.local"}, true},
+		{[]string{"10.0.0.0/33"}, true},
+	}
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+	for _, tt := range tests {
+		if err := SetTrustedProxies(tt.entries); (err != nil) != tt.wantErr {
+			t.Errorf("SetTrustedProxies(%q) = %v, want error %v", tt.entries, err, tt.wantErr)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	if err := SetTrustedProxies([]string{"127.0.0.1", "::1", "10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		realIP string
+		want   string
+	}{
+		{"direct", "203.0.113.7:5000", nil, "", "203.0.113.7"},
+		{"untrusted peer forging xff", "203.0.113.7:5000", []string{"198.51.100.1"}, "", "203.0.113.7"},
+		{"untrusted peer forging x-real-ip", "203.0.113.7:5000", nil, "198.51.100.1", "203.0.113.7"},
+		{"trusted proxy", "127.0.0.1:5000", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"client-supplied entry ignored", "127.0.0.1:5000", []string{"1.1.1.1, 198.51.100.1"}, "", "198.51.100.1"},
+		{"chain of trusted proxies", "127.0.0.1:5000", []string{"198.51.100.1, 10.1.2.3", "10.0.0.9"}, "", "198.51.100.1"},
+		{"only proxies", "127.0.0.1:5000", []string{"10.1.2.3"}, "", "10.1.2.3"},
+		{"garbage hop", "127.0.0.1:5000", []string{"198.51.100.1, nonsense"}, "", "127.0.0.1"},
+		{"x-real-ip from proxy", "[::1]:5000", nil, "198.51.100.1", "198.51.100.1"},
+		{"bad x-real-ip", "[::1]:5000", nil, "nonsense", "::1"},
+		{"no port", "203.0.113.7", nil, "", "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	SetTrustedProxies(nil)
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "127.0.0.1:5000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	if got := ClientIP(r); got != "127.0.0.1" {
+		t.Errorf("with no trusted proxies ClientIP = %q", got)
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	writeGate    sync.RWMutex
+	writesPaused atomic.Bool
+)
+
+func PauseWrites() {
+	writesPaused.Store(true)
+	writeGate.Lock()
+}
+
+func ResumeWrites() {
+	writeGate.Unlock()
+	writesPaused.Store(false)
+}
+
+func WritesPaused() bool {
+	return writesPaused.Load()
+}
+
+func WriteGate(next http.Handler, exempt ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, prefix := range exempt {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		if writesPaused.Load() {
+			response := Response{
+				Status: "error",
+				Error:  "Maintenance in progress, please try again shortly",
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		writeGate.RLock()
+		defer writeGate.RUnlock()
+		next.ServeHTTP(w, r)
+	})
+}
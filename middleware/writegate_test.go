@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteGate(t *testing.T) {
+	h := WriteGate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), "/api/admin/restore")
+
+	tests := []struct {
+		method, path string
+		paused       bool
+		want         int
+	}{
+		{http.MethodPost, "/api/register", false, http.StatusNoContent},
+		{http.MethodPost, "/api/register", true, http.StatusServiceUnavailable},
+		{http.MethodGet, "/api/register", true, http.StatusNoContent},
+		{http.MethodDelete, "/api/admin/users", true, http.StatusServiceUnavailable},
+		{http.MethodPost, "/api/admin/restore/apply", true, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		if tt.paused {
+			writesPaused.Store(true)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		writesPaused.Store(false)
+		if w.Code != tt.want {
+			t.Errorf("%s %s (paused %v) = %d, want %d", tt.method, tt.path, tt.paused, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package regform
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	TypeText   = "text"
+	TypeNumber = "number"
+	TypeEnum   = "enum"
+	TypeURL    = "url"
+	TypeFile   = "file"
+)
+
+var Types = []string{TypeText, TypeNumber, TypeEnum, TypeURL, TypeFile}
+
+const maxValueLength = 500
+
+var keyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,29}$`)
+
+// Custom fields with these keys would collide with the built-in columns.
+var reservedKeys = map[string]bool{
+	"name": true, "email": true, "class": true, "phone": true, "gender": true, "fields": true,
+}
+
+type Field struct {
+	Key      string   `json:"key"`
+	Label    string   `json:"label"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required,omitempty"`
+	Pattern  string   `json:"pattern,omitempty"`
+	Options  []string `json:"options,omitempty"`
+	Help     string   `json:"help,omitempty"`
+}
+
+type Schema struct {
+	Team        []Field `json:"team,omitempty"`
+	Participant []Field `json:"participant,omitempty"`
+}
+
+func (s Schema) IsEmpty() bool {
+	return len(s.Team) == 0 && len(s.Participant) == 0
+}
+
+func (s Schema) Validate() error {
+	levels := []struct {
+		name   string
+		fields []Field
+	}{{"team", s.Team}, {"participant", s.Participant}}
+	for _, l := range levels {
+		level, fields := l.name, l.fields
+		seen := map[string]bool{}
+		for i, f := range fields {
+			if err := f.validate(); err != nil {
+				return fmt.Errorf("%s field %d: %v", level, i+1, err)
+			}
+			if seen[f.Key] {
+				return fmt.Errorf("%s field %d: duplicate key %s", level, i+1, f.Key)
+			}
+			seen[f.Key] = true
+		}
+	}
+	return nil
+}
+
+func (f Field) validate() error {
+	if !keyPattern.MatchString(f.Key) {
+		return fmt.Errorf("key must be lowercase letters, digits and underscores, starting with a letter")
+	}
+	if reservedKeys[f.Key] {
+		return fmt.Errorf("key %s is reserved", f.Key)
+	}
+	if strings.TrimSpace(f.Label) == "" {
+		return fmt.Errorf("label is required")
+	}
+	known := false
+	for _, t := range Types {
+		if f.Type == t {
+			known = true
+		}
+	}
+	if !known {
+		return fmt.Errorf("type must be one of %s", strings.Join(Types, ", "))
+	}
+	if f.Type == TypeEnum && len(f.Options) == 0 {
+		return fmt.Errorf("enum fields need options")
+	}
+	if f.Pattern != "" {
+		if _, err := regexp.Compile(f.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %v", err)
+		}
+	}
+	return nil
+}
+
+func (f Field) Check(value string, fileExists func(string) bool) string {
+	if value == "" {
+		if f.Required {
+			return f.Label + " is required"
+		}
+		return ""
+	}
+	if len(value) > maxValueLength {
+		return fmt.Sprintf("%s must be at most %d characters", f.Label, maxValueLength)
+	}
+	switch f.Type {
+	case TypeNumber:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return f.Label + " must be a number"
+		}
+	case TypeEnum:
+		ok := false
+		for _, o := range f.Options {
+			if o == value {
+				ok = true
+			}
+		}
+		if !ok {
+			return fmt.Sprintf("%s must be one of %s", f.Label, strings.Join(f.Options, ", "))
+		}
+	case TypeURL:
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return f.Label + " must be an http or https link"
+		}
+	case TypeFile:
+		if fileExists == nil || !fileExists(value) {
+			return f.Label + " must be an uploaded file"
+		}
+	}
+	if f.Pattern != "" {
+		if re, err := regexp.Compile(f.Pattern); err == nil && !re.MatchString(value) {
+			return f.Label + " is not in the expected format"
+		}
+	}
+	return ""
+}
+
+// Keys the schema does not ask for are dropped.
+func Values(fields []Field, submitted map[string]string, fileExists func(string) bool) (map[string]string, []string) {
+	clean := map[string]string{}
+	var problems []string
+	for _, f := range fields {
+		v := strings.TrimSpace(submitted[f.Key])
+		if msg := f.Check(v, fileExists); msg != "" {
+			problems = append(problems, msg)
+			continue
+		}
+		if v != "" {
+			clean[f.Key] = v
+		}
+	}
+	if len(clean) == 0 {
+		return nil, problems
+	}
+	return clean, problems
+}
+
+func TeamColumn(key string) string {
+	return "team_" + key
+}
+
+func ParticipantColumn(n int, key string) string {
+	return fmt.Sprintf("p%d_%s", n, key)
+}
@@ -0,0 +1,117 @@
+package regform
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	tshirt := Field{Key: "tshirt", Label: "T-shirt size", Type: TypeEnum, Options: []string{"S", "M", "L"}}
+	tests := []struct {
+		name   string
+		schema Schema
+		errHas string
+	}{
+		{"empty", Schema{}, ""},
+		{"valid", Schema{Team: []Field{{Key: "repo", Label: "Repository", Type: TypeURL}}, Participant: []Field{tshirt}}, ""},
+		{"same key on both levels", Schema{Team: []Field{tshirt}, Participant: []Field{tshirt}}, ""},
+		{"bad key", Schema{Team: []Field{{Key: "Repo", Label: "Repository", Type: TypeURL}}}, "team field 1: key"},
+		{"key too long", Schema{Team: []Field{{Key: "a" + strings.Repeat("b", 30), Label: "x", Type: TypeText}}}, "key"},
+		{"reserved key", Schema{Participant: []Field{{Key: "email", Label: "Email", Type: TypeText}}}, "reserved"},
+		{"no label", Schema{Team: []Field{{Key: "repo", Label: " ", Type: TypeURL}}}, "label"},
+		{"unknown type", Schema{Team: []Field{{Key: "repo", Label: "Repository", Type: "link"}}}, "type must be"},
+		{"enum without options", Schema{Team: []Field{{Key: "size", Label: "Size", Type: TypeEnum}}}, "options"},
+		{"bad pattern", Schema{Team: []Field{{Key: "id", Label: "ID", Type: TypeText, Pattern: "("}}}, "pattern"},
+		{"duplicate key", Schema{Participant: []Field{tshirt, tshirt}}, "participant field 2: duplicate key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schema.Validate()
+			if tt.errHas == "" {
+				if err != nil {
+					t.Errorf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errHas) {
+				t.Errorf("Validate = %v, want it to mention %q", err, tt.errHas)
+			}
+		})
+	}
+}
+
+func TestFieldCheck(t *testing.T) {
+	uploaded := func(name string) bool { return name == "slides.pdf" }
+	tests := []struct {
+		name   string
+		field  Field
+		value  string
+		errHas string
+	}{
+		{"optional empty", Field{Label: "Notes", Type: TypeText}, "", ""},
+		{"required empty", Field{Label: "Notes", Type: TypeText, Required: true}, "", "is required"},
+		{"too long", Field{Label: "Notes", Type: TypeText}, strings.Repeat("x", maxValueLength+1), "at most"},
+		{"number", Field{Label: "Age", Type: TypeNumber}, "14.5", ""},
+		{"not a number", Field{Label: "Age", Type: TypeNumber}, "fourteen", "must be a number"},
+		{"enum option", Field{Label: "Size", Type: TypeEnum, Options: []string{"S", "M"}}, "M", ""},
+		{"enum is case sensitive", Field{Label: "Size", Type: TypeEnum, Options: []string{"S", "M"}}, "m", "one of S, M"},
+		{"https url", Field{Label: "Repo", Type: TypeURL}, "https://github.com/exun/repo", ""},
+		{"javascript url", Field{Label: "Repo", Type: TypeURL}, "javascript:alert(1)", "http or https"},
+		{"url without host", Field{Label: "Repo", Type: TypeURL}, "https://", "http or https"},
+		{"uploaded file", Field{Label: "Slides", Type: TypeFile}, "slides.pdf", ""},
+		{"foreign file", Field{Label: "Slides", Type: TypeFile}, "other.pdf", "uploaded file"},
+		{"pattern match", Field{Label: "Roll", Type: TypeText, Pattern: `^\d{4}$`}, "1234", ""},
+		{"pattern mismatch", Field{Label: "Roll", Type: TypeText, Pattern: `^\d{4}$`}, "12a4", "expected format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.field.Check(tt.value, uploaded)
+			if tt.errHas == "" && got != "" {
+				t.Errorf("Check = %q, want no problem", got)
+			}
+			if tt.errHas != "" && !strings.Contains(got, tt.errHas) {
+				t.Errorf("Check = %q, want it to mention %q", got, tt.errHas)
+			}
+		})
+	}
+
+	if got := (Field{Label: "Slides", Type: TypeFile}).Check("slides.pdf", nil); got == "" {
+		t.Error("a file field passed without a file check")
+	}
+}
+
+func TestValues(t *testing.T) {
+	fields := []Field{
+		{Key: "repo", Label: "Repository", Type: TypeURL, Required: true},
+		{Key: "notes", Label: "Notes", Type: TypeText},
+	}
+	tests := []struct {
+		name      string
+		submitted map[string]string
+		want      map[string]string
+		problems  int
+	}{
+		{"trimmed and filtered", map[string]string{"repo": " https://x.org ", "notes": "", "extra": "dropped"}, map[string]string{"repo": "https://x.org"}, 0},
+		{"missing required", map[string]string{"notes": "hi"}, map[string]string{"notes": "hi"}, 1},
+		{"nothing valid", map[string]string{"repo": "ftp://x.org"}, nil, 1},
+		{"nil submission", nil, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, problems := Values(fields, tt.submitted, nil)
+			if !reflect.DeepEqual(got, tt.want) || len(problems) != tt.problems {
+				t.Errorf("Values = %v, %q; want %v with %d problems", got, problems, tt.want, tt.problems)
+			}
+		})
+	}
+}
+
+func TestColumns(t *testing.T) {
+	if got := TeamColumn("repo"); got != "team_repo" {
+		t.Errorf("TeamColumn = %q", got)
+	}
+	if got := ParticipantColumn(3, "tshirt"); got != "p3_tshirt" {
+		t.Errorf("ParticipantColumn = %q", got)
+	}
+}
@@ -0,0 +1,185 @@
+package retrieval
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	KindFAQ      = "faq"
+	KindEvent    = "event"
+	KindBrochure = "brochure"
+)
+
+// BM25 parameters, at their usual defaults.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Answer is set for FAQ entries only.
+type Chunk struct {
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`
+	Title  string `json:"title"`
+	Text   string `json:"text"`
+	Answer string `json:"answer,omitempty"`
+}
+
+type Result struct {
+	Chunk Chunk   `json:"chunk"`
+	Score float64 `json:"score"`
+}
+
+type Index struct {
+	chunks []Chunk
+	terms  []map[string]int
+	lens   []int
+	avgLen float64
+	df     map[string]int
+}
+
+func New(chunks []Chunk) *Index {
+	idx := &Index{chunks: chunks, df: map[string]int{}}
+	total := 0
+	for _, c := range chunks {
+		tf := map[string]int{}
+		tokens := Tokenize(c.Title + " " + c.Text)
+		for _, t := range tokens {
+			tf[t]++
+		}
+		for t := range tf {
+			idx.df[t]++
+		}
+		idx.terms = append(idx.terms, tf)
+		idx.lens = append(idx.lens, len(tokens))
+		total += len(tokens)
+	}
+	if len(chunks) > 0 {
+		idx.avgLen = float64(total) / float64(len(chunks))
+	}
+	return idx
+}
+
+func (idx *Index) Len() int {
+	return len(idx.chunks)
+}
+
+func (idx *Index) idf(term string) float64 {
+	n := float64(len(idx.chunks))
+	df := float64(idx.df[term])
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+// Chunks that share no term with the query are skipped.
+func (idx *Index) Search(query string, k int, kinds ...string) []Result {
+	terms := uniq(Tokenize(query))
+	var results []Result
+	for i, c := range idx.chunks {
+		if len(kinds) > 0 && !contains(kinds, c.Kind) {
+			continue
+		}
+		score := 0.0
+		for _, t := range terms {
+			f := float64(idx.terms[i][t])
+			if f == 0 {
+				continue
+			}
+			norm := 1 - b + b*float64(idx.lens[i])/idx.avgLen
+			score += idx.idf(t) * f * (k1 + 1) / (f + k1*norm)
+		}
+		if score > 0 {
+			results = append(results, Result{Chunk: c, Score: score})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+func (idx *Index) Similarity(a, b string) float64 {
+	va, vb := idx.vector(a), idx.vector(b)
+	dot, na, nb := 0.0, 0.0, 0.0
+	for t, w := range va {
+		dot += w * vb[t]
+		na += w * w
+	}
+	for _, w := range vb {
+		nb += w * w
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+func (idx *Index) vector(s string) map[string]float64 {
+	v := map[string]float64{}
+	for _, t := range Tokenize(s) {
+		v[t] += idx.idf(t)
+	}
+	return v
+}
+
+var stopwords = map[string]bool{
+	"a": true, "an": true, "the": true, "is": true, "are": true, "was": true, "were": true, "be": true,
+	"been": true, "being": true, "am": true, "do": true, "does": true, "did": true, "can": true, "could": true,
+	"will": true, "would": true, "should": true, "shall": true, "may": true, "might": true, "must": true,
+	"i": true, "me": true, "my": true, "we": true, "our": true, "us": true, "you": true, "your": true,
+	"he": true, "she": true, "it": true, "its": true, "they": true, "them": true, "their": true,
+	"this": true, "that": true, "these": true, "those": true, "what": true, "which": true, "who": true,
+	"whom": true, "when": true, "where": true, "why": true, "how": true, "of": true, "in": true, "on": true,
+	"at": true, "to": true, "for": true, "from": true, "by": true, "with": true, "about": true, "as": true,
+	"into": true, "and": true, "or": true, "but": true, "if": true, "so": true, "than": true, "then": true,
+	"there": true, "here": true, "any": true, "some": true, "all": true, "there's": true, "please": true,
+	"tell": true, "know": true, "get": true, "also": true, "just": true, "s": true,
+}
+
+func Tokenize(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if stopwords[f] {
+			continue
+		}
+		tokens = append(tokens, stem(f))
+	}
+	return tokens
+}
+
+func stem(w string) string {
+	switch {
+	case len(w) > 4 && strings.HasSuffix(w, "ies"):
+		return w[:len(w)-3] + "y"
+	case len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && !strings.HasSuffix(w, "us"):
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+func uniq(tokens []string) []string {
+	seen := map[string]bool{}
+	out := tokens[:0:0]
+	for _, t := range tokens {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
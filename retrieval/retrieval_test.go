@@ -0,0 +1,91 @@
+package retrieval
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testChunks = []Chunk{
+	{ID: "faq:fee", Kind: KindFAQ, Title: "Is there a registration fee?", Text: "No, registration is free.", Answer: "No, registration is free."},
+	{ID: "faq:teams", Kind: KindFAQ, Title: "How many teams can a school send?", Text: "Each school may send one team per event."},
+	{ID: "event:crossword", Kind: KindEvent, Title: "Crossword", Text: "Solve cryptic crossword clues. Teams of two from classes 6 to 12."},
+	{ID: "event:cubing", Kind: KindEvent, Title: "Cubing", Text: "Speed cubing for individuals. Bring your own cube."},
+	{ID: "brochure:dates", Kind: KindBrochure, Title: "Dates", Text: "The event days are in November at Delhi Public School."},
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"What is the registration fee?", []string{"registration", "fee"}},
+		{"Categories & classes", []string{"category", "classe"}},
+		{"Cubing, crosswords; QUIZZES!", []string{"cubing", "crossword", "quizze"}},
+		{"bus pass status", []string{"bus", "pass", "status"}},
+		{"Class 6-12", []string{"class", "6", "12"}},
+		{"the of and", []string{}},
+	}
+	for _, tt := range tests {
+		if got := Tokenize(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	idx := New(testChunks)
+	if idx.Len() != len(testChunks) {
+		t.Fatalf("Len = %d", idx.Len())
+	}
+	tests := []struct {
+		name  string
+		query string
+		k     int
+		kinds []string
+		want  []string
+	}{
+		{"best match first", "crossword clues", 5, nil, []string{"event:crossword"}},
+		{"plural matches singular", "how many teams", 1, nil, []string{"faq:teams"}},
+		{"limited to k", "team event school", 2, nil, []string{"faq:teams", "brochure:dates"}},
+		{"kind filter", "event", 5, []string{KindBrochure}, []string{"brochure:dates"}},
+		{"no shared term", "robotics arena", 5, nil, nil},
+		{"stopwords only", "what is the", 5, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range idx.Search(tt.query, tt.k, tt.kinds...) {
+				got = append(got, r.Chunk.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	idx := New(testChunks)
+	tests := []struct {
+		a, b     string
+		min, max float64
+	}{
+		{"Is there a registration fee?", "is there any registration fee", 0.99, 1.01},
+		{"registration fee", "Is there a registration fee?", 0.99, 1.01},
+		{"registration fee for cubing", "Is there a registration fee?", 0.5, 0.95},
+		{"cubing", "registration fee", 0, 0},
+		{"", "registration fee", 0, 0},
+	}
+	for _, tt := range tests {
+		if got := idx.Similarity(tt.a, tt.b); got < tt.min || got > tt.max {
+			t.Errorf("Similarity(%q, %q) = %.3f, want between %.2f and %.2f", tt.a, tt.b, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestEmptyIndex(t *testing.T) {
+	idx := New(nil)
+	if got := idx.Search("anything", 5); got != nil {
+		t.Errorf("Search on an empty index = %v", got)
+	}
+}
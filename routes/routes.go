@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"exunreg25/handlers"
 	"exunreg25/middleware"
@@ -133,6 +134,7 @@ func SetupRoutes() *http.ServeMux {
 							Points:           ev.Points,
 							Individual:       ev.IndependentRegistration,
 							Dates:            ev.Dates,
+							Category:         ev.Category,
 						})
 					}
 					data.Events = tmplEvents
@@ -142,21 +144,7 @@ func SetupRoutes() *http.ServeMux {
 					data.Events = events
 				}
 			}
-			events := data.Events
-			cats := make(map[string]struct{})
-			for _, e := range events {
-				if e.Slug != "" {
-					if strings.Contains(e.Name, "Build") {
-						cats["build"] = struct{}{}
-					}
-					if strings.Contains(e.Name, "CubXL") {
-						cats["cubing"] = struct{}{}
-					}
-				}
-			}
-			for k := range cats {
-				data.Categories = append(data.Categories, templates.Category{Key: k, Name: strings.Title(k)})
-			}
+			data.Categories = templates.CategoriesFor(data.Events)
 			templates.RenderTemplate(w, "index", data)
 			return
 		case "/events":
@@ -186,6 +174,7 @@ func SetupRoutes() *http.ServeMux {
 							Points:           ev.Points,
 							Individual:       ev.IndependentRegistration,
 							Dates:            ev.Dates,
+							Category:         ev.Category,
 						})
 					}
 					data.Events = tmplEvents
@@ -195,20 +184,7 @@ func SetupRoutes() *http.ServeMux {
 					data.Events = events
 				}
 			}
-			cats := make(map[string]struct{})
-			for _, e := range data.Events {
-				if e.Slug != "" {
-					if strings.Contains(e.Name, "Build") {
-						cats["build"] = struct{}{}
-					}
-					if strings.Contains(e.Name, "CubXL") {
-						cats["cubing"] = struct{}{}
-					}
-				}
-			}
-			for k := range cats {
-				data.Categories = append(data.Categories, templates.Category{Key: k, Name: strings.Title(k)})
-			}
+			data.Categories = templates.CategoriesFor(data.Events)
 			templates.RenderTemplate(w, "events", data)
 			return
 		case "/brochure":
@@ -353,6 +329,32 @@ func SetupRoutes() *http.ServeMux {
 
 	mux.HandleFunc("/api/health", handlers.HealthCheck)
 
+	mux.HandleFunc("/unsubscribe", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			handlers.OneClickUnsubscribe(w, r)
+			return
+		}
+		data := getTemplateData(r)
+		data.PageTitle = "Email Preferences | Exun 2025"
+		prefs, err := handlers.GetEmailPreferencesData(r.URL.Query().Get("token"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			templates.RenderTemplate(w, "preferences", data)
+			return
+		}
+		tmplPrefs := &templates.EmailPreferences{Email: prefs.Email, Token: prefs.Token}
+		for _, c := range prefs.Categories {
+			tmplPrefs.Categories = append(tmplPrefs.Categories, templates.EmailPreferenceCategory{
+				Key:        c.Key,
+				Name:       c.Name,
+				Subscribed: c.Subscribed,
+			})
+		}
+		data.EmailPreferences = tmplPrefs
+		templates.RenderTemplate(w, "preferences", data)
+	})
+	mux.HandleFunc("/api/email/preferences", handlers.EmailPreferences)
+
 	mux.HandleFunc("/oauth2callback", handlers.HandleOAuth2Callback)
 
 	mux.HandleFunc("/api/auth/send-otp", handlers.SendOTP)
@@ -373,12 +375,17 @@ func SetupRoutes() *http.ServeMux {
 
 	mux.HandleFunc("/api/events", handlers.GetAllEvents)
 	mux.HandleFunc("/api/events/", handlers.GetEvent)
+	mux.HandleFunc("/api/event_categories", handlers.EventCategoriesAPI)
 
 	mux.HandleFunc("/api/query", handlers.QueryHandler)
+	mux.HandleFunc("/api/query/stream", handlers.QueryStreamHandler)
 
 	submitRegHandler := http.HandlerFunc(handlers.SubmitRegistrations)
 	mux.Handle("/api/submit_registrations", middleware.AuthRequired(submitRegHandler))
 
+	registrationFileHandler := http.HandlerFunc(handlers.UploadRegistrationFile)
+	mux.Handle("/api/registration_files", middleware.AuthRequired(registrationFileHandler))
+
 	completeSignupPageHandler := http.HandlerFunc(handlers.CompleteSignupPage)
 	mux.Handle("/api/complete", middleware.AuthRequired(completeSignupPageHandler))
 
@@ -409,6 +416,33 @@ func SetupRoutes() *http.ServeMux {
 	adminDeleteEventHandler := http.HandlerFunc(handlers.DeleteEvent)
 	mux.Handle("/api/admin/events/delete/", middleware.AuthRequired(adminDeleteEventHandler))
 
+	adminArchiveEventHandler := http.HandlerFunc(handlers.ArchiveEvent)
+	mux.Handle("/api/admin/events/archive/", middleware.AuthRequired(adminArchiveEventHandler))
+
+	adminCreateEventHandler := http.HandlerFunc(handlers.CreateEvent)
+	mux.Handle("/api/admin/events/create", middleware.AuthRequired(adminCreateEventHandler))
+
+	adminEventImageHandler := http.HandlerFunc(handlers.UploadEventImage)
+	mux.Handle("/api/admin/events/image", middleware.AuthRequired(adminEventImageHandler))
+
+	adminReorderEventsHandler := http.HandlerFunc(handlers.ReorderEvents)
+	mux.Handle("/api/admin/events/order", middleware.AuthRequired(adminReorderEventsHandler))
+
+	adminEventsImportHandler := http.HandlerFunc(handlers.ImportEvents)
+	mux.Handle("/api/admin/events/import", middleware.AuthRequired(adminEventsImportHandler))
+
+	adminEventsExportHandler := http.HandlerFunc(handlers.ExportEvents)
+	mux.Handle("/api/admin/events/export", middleware.AuthRequired(adminEventsExportHandler))
+
+	adminEventEligibilityHandler := http.HandlerFunc(handlers.EventEligibility)
+	mux.Handle("/api/admin/events/eligibility/", middleware.AuthRequired(adminEventEligibilityHandler))
+
+	adminEventFieldsHandler := http.HandlerFunc(handlers.EventFormFields)
+	mux.Handle("/api/admin/events/fields/", middleware.AuthRequired(adminEventFieldsHandler))
+
+	adminRegistrationFileHandler := http.HandlerFunc(handlers.RegistrationFile)
+	mux.Handle("/api/admin/registration_files/", middleware.AuthRequired(adminRegistrationFileHandler))
+
 	syncSheetsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		email := middleware.GetEmailFromCookie(r)
 		if !handlers.IsAdminEmail(email) {
@@ -416,12 +450,12 @@ func SetupRoutes() *http.ServeMux {
 			return
 		}
 		if err := handlers.TriggerSheetsSync(); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"status":"ok","message":"sync triggered"}`))
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"status":"ok","message":"sync triggered; see /api/admin/sync/runs for the result"}`))
 	})
 	mux.Handle("/api/admin/sync-sheets", middleware.AuthRequired(syncSheetsHandler))
 
@@ -439,6 +473,87 @@ func SetupRoutes() *http.ServeMux {
 	adminImportEventsHandler := http.HandlerFunc(handlers.ImportEvents)
 	mux.Handle("/api/admin/import_events", middleware.AuthRequired(adminImportEventsHandler))
 
+	adminSuppressionsHandler := http.HandlerFunc(handlers.ManageSuppressions)
+	mux.Handle("/api/admin/suppressions", middleware.AuthRequired(adminSuppressionsHandler))
+
+	adminEmailTemplatesHandler := http.HandlerFunc(handlers.EmailTemplates)
+	mux.Handle("/api/admin/email-templates", middleware.AuthRequired(adminEmailTemplatesHandler))
+	mux.Handle("/api/admin/email-templates/", middleware.AuthRequired(adminEmailTemplatesHandler))
+
+	adminBackupsHandler := http.HandlerFunc(handlers.Snapshots)
+	mux.Handle("/api/admin/backups", middleware.AuthRequired(adminBackupsHandler))
+
+	adminRestoreHandler := http.HandlerFunc(handlers.Restore)
+	mux.Handle("/api/admin/restore", middleware.AuthRequired(adminRestoreHandler))
+	mux.Handle("/api/admin/restore/", middleware.AuthRequired(adminRestoreHandler))
+
+	mux.HandleFunc("/api/changes", handlers.ChangeFeed)
+
+	adminSyncRunsHandler := http.HandlerFunc(handlers.SyncRuns)
+	mux.Handle("/api/admin/sync/runs", middleware.AuthRequired(adminSyncRunsHandler))
+	mux.Handle("/api/admin/sync/runs/", middleware.AuthRequired(adminSyncRunsHandler))
+
+	adminSyncConflictsHandler := http.HandlerFunc(handlers.SyncConflicts)
+	mux.Handle("/api/admin/sync/conflicts", middleware.AuthRequired(adminSyncConflictsHandler))
+	mux.Handle("/api/admin/sync/conflicts/", middleware.AuthRequired(adminSyncConflictsHandler))
+
+	adminAuditHandler := http.HandlerFunc(handlers.AuditEvents)
+	mux.Handle("/api/admin/audit", middleware.AuthRequired(adminAuditHandler))
+	mux.Handle("/api/admin/audit/", middleware.AuthRequired(adminAuditHandler))
+
+	adminWebhooksHandler := http.HandlerFunc(handlers.Webhooks)
+	mux.Handle("/api/admin/webhooks", middleware.AuthRequired(adminWebhooksHandler))
+	mux.Handle("/api/admin/webhooks/", middleware.AuthRequired(adminWebhooksHandler))
+
+	adminGuardrailsHandler := http.HandlerFunc(handlers.Guardrails)
+	mux.Handle("/api/admin/guardrails", middleware.AuthRequired(adminGuardrailsHandler))
+	mux.Handle("/api/admin/guardrails/", middleware.AuthRequired(adminGuardrailsHandler))
+
+	adminQueryReviewsHandler := http.HandlerFunc(handlers.QueryReviews)
+	mux.Handle("/api/admin/query-reviews", middleware.AuthRequired(adminQueryReviewsHandler))
+	mux.Handle("/api/admin/query-reviews/", middleware.AuthRequired(adminQueryReviewsHandler))
+
+	adminDriveHandler := http.HandlerFunc(handlers.DriveAuth)
+	mux.Handle("/api/admin/drive/", middleware.AuthRequired(adminDriveHandler))
+
+	mux.HandleFunc("/admin/drive", func(w http.ResponseWriter, r *http.Request) {
+		data := getTemplateData(r)
+		if !data.IsAuthenticated || !data.IsAdmin {
+			data.PageTitle = "Page not found | Exun 2025"
+			w.WriteHeader(http.StatusNotFound)
+			templates.RenderTemplate(w, "404", data)
+			return
+		}
+		data.PageTitle = "Drive backups | Exun 2025"
+		if status, err := handlers.GetDriveStatusData(false); err == nil {
+			formatTime := func(t *time.Time) string {
+				if t == nil {
+					return ""
+				}
+				return t.Format("January 2, 2006 3:04 PM")
+			}
+			data.DriveStatus = &templates.DriveStatus{
+				Configured:      status.Configured,
+				Connected:       status.Connected,
+				Healthy:         status.Healthy,
+				HasRefreshToken: status.HasRefreshToken,
+				ConnectedBy:     status.ConnectedBy,
+				ConnectedAt:     formatTime(status.ConnectedAt),
+				Expiry:          formatTime(status.Expiry),
+				RefreshedAt:     formatTime(status.RefreshedAt),
+				LastError:       status.LastError,
+				LastErrorAt:     formatTime(status.LastErrorAt),
+				RedirectURL:     status.RedirectURL,
+			}
+		}
+		if msg := r.URL.Query().Get("error"); msg != "" {
+			data.DriveMessage = "Connection failed: " + msg
+		} else if r.URL.Query().Get("connected") != "" {
+			data.DriveMessage = "Google Drive connected"
+		}
+		templates.RenderTemplate(w, "drive", data)
+	})
+
 	mux.HandleFunc("/admin", func(w http.ResponseWriter, r *http.Request) {
 		data := getTemplateData(r)
 		if !data.IsAuthenticated || !data.IsAdmin {
@@ -454,6 +569,9 @@ func SetupRoutes() *http.ServeMux {
 				TotalEvents:        stats.TotalEvents,
 				TotalRegistrations: stats.TotalRegistrations,
 				EventStats:         make(map[string]templates.EventStats),
+				PendingQueries:     stats.QueryReviews.Pending,
+				RejectedQueries:    stats.QueryReviews.Rejected,
+				FlaggedQueries:     stats.QueryReviews.Flagged,
 			}
 			for id, s := range stats.EventStats {
 				tmplStats.EventStats[id] = templates.EventStats{
@@ -28,10 +28,39 @@ type TemplateData struct {
 	Categories       []Category
 	Stats            *AdminStats
 	Summary          *Summary
+	EmailPreferences *EmailPreferences
+	DriveStatus      *DriveStatus
+	DriveMessage     string
 	PageTitle        string
 	CurrentPath      string
 }
 
+type EmailPreferences struct {
+	Email      string
+	Token      string
+	Categories []EmailPreferenceCategory
+}
+
+type DriveStatus struct {
+	Configured      bool
+	Connected       bool
+	Healthy         bool
+	HasRefreshToken bool
+	ConnectedBy     string
+	ConnectedAt     string
+	Expiry          string
+	RefreshedAt     string
+	LastError       string
+	LastErrorAt     string
+	RedirectURL     string
+}
+
+type EmailPreferenceCategory struct {
+	Key        string
+	Name       string
+	Subscribed bool
+}
+
 type Category struct {
 	Key  string `json:"key"`
 	Name string `json:"name"`
@@ -75,6 +104,7 @@ type Event struct {
 	Points           int    `json:"points"`
 	Individual       bool   `json:"individual"`
 	Dates            string `json:"dates"`
+	Category         string `json:"category"`
 }
 
 type AdminStats struct {
@@ -82,6 +112,9 @@ type AdminStats struct {
 	TotalEvents        int                   `json:"total_events"`
 	TotalRegistrations int                   `json:"total_registrations"`
 	EventStats         map[string]EventStats `json:"event_stats"`
+	PendingQueries     int                   `json:"pending_queries"`
+	RejectedQueries    int                   `json:"rejected_queries"`
+	FlaggedQueries     int                   `json:"flagged_queries"`
 }
 
 type EventStats struct {
@@ -158,12 +191,29 @@ func LoadEventsFromJSON() ([]Event, error) {
 			Points:           ev.Points,
 			Individual:       ev.IndependentRegistration,
 			Dates:            ev.Dates,
+			Category:         ev.Category,
 		})
 	}
 
 	return events, nil
 }
 
+func CategoriesFor(events []Event) []Category {
+	used := make(map[string]bool)
+	for _, e := range events {
+		if e.Slug != "" && e.Category != "" {
+			used[e.Category] = true
+		}
+	}
+	var cats []Category
+	for _, c := range handlers.EventCategories {
+		if used[c.Key] {
+			cats = append(cats, Category{Key: c.Key, Name: c.Name})
+		}
+	}
+	return cats
+}
+
 func FindEventBySlug(slug string) (*Event, error) {
 	events, err := LoadEventsFromJSON()
 	if err != nil {
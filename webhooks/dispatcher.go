@@ -0,0 +1,329 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"exunreg25/db"
+)
+
+const (
+	EventUserCreated           = "user.created"
+	EventRegistrationSubmitted = "registration.submitted"
+	EventRegistrationDeleted   = "registration.deleted"
+	EventEventUpdated          = "event.updated"
+	EventTest                  = "webhook.test"
+)
+
+var EventTypes = []string{
+	EventUserCreated,
+	EventRegistrationSubmitted,
+	EventRegistrationDeleted,
+	EventEventUpdated,
+}
+
+const (
+	SignatureHeader = "X-Exun-Signature"
+	TimestampHeader = "X-Exun-Timestamp"
+	EventHeader     = "X-Exun-Event"
+	DeliveryHeader  = "X-Exun-Delivery"
+
+	maxResponseBody   = 1024
+	batchSize         = 50
+	deliveryRetention = 30 * 24 * time.Hour
+)
+
+type Payload struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+type Dispatcher struct {
+	database    *db.Database
+	client      *http.Client
+	maxAttempts int
+	wake        chan struct{}
+}
+
+func NewDispatcher(database *db.Database, maxAttempts int, timeout time.Duration) *Dispatcher {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &Dispatcher{
+		database:    database,
+		client:      &http.Client{Timeout: timeout},
+		maxAttempts: maxAttempts,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+func ValidEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return eventType == "*"
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>".
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func NewSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func newEventID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return "evt_" + hex.EncodeToString(b)
+}
+
+// Backoff doubles from a minute per failed attempt, capped at an hour.
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt > 7 {
+		return time.Hour
+	}
+	d := time.Minute << (attempt - 1)
+	if d > time.Hour {
+		d = time.Hour
+	}
+	return d
+}
+
+func newPayload(eventType string, data interface{}) ([]byte, *Payload, error) {
+	p := &Payload{
+		ID:        newEventID(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	b, err := json.Marshal(p)
+	return b, p, err
+}
+
+// Emit queues a delivery to every active webhook subscribed to the event.
+func (d *Dispatcher) Emit(eventType string, data interface{}) error {
+	hooks, err := d.database.ListWebhooks()
+	if err != nil {
+		return err
+	}
+	var body []byte
+	var payload *Payload
+	queued := 0
+	for i := range hooks {
+		if !hooks[i].Active || !hooks[i].Subscribes(eventType) {
+			continue
+		}
+		if body == nil {
+			if body, payload, err = newPayload(eventType, data); err != nil {
+				return err
+			}
+		}
+		delivery := &db.WebhookDelivery{
+			WebhookID: hooks[i].ID,
+			EventID:   payload.ID,
+			EventType: eventType,
+			Payload:   string(body),
+			Status:    "pending",
+		}
+		if err := d.database.CreateWebhookDelivery(delivery); err != nil {
+			return fmt.Errorf("failed to queue %s for webhook %d: %v", eventType, hooks[i].ID, err)
+		}
+		queued++
+	}
+	if queued > 0 {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// SendTest delivers a webhook.test event once, without retries.
+func (d *Dispatcher) SendTest(ctx context.Context, hook *db.Webhook, sentBy string) (*db.WebhookDelivery, error) {
+	body, payload, err := newPayload(EventTest, map[string]interface{}{
+		"webhook_id": hook.ID,
+		"sent_by":    sentBy,
+		"message":    "This is a test delivery from Exun 2025 registrations.",
+	})
+	if err != nil {
+		return nil, err
+	}
+	delivery := &db.WebhookDelivery{
+		WebhookID: hook.ID,
+		EventID:   payload.ID,
+		EventType: EventTest,
+		Payload:   string(body),
+		Status:    "sending",
+	}
+	if err := d.database.CreateWebhookDelivery(delivery); err != nil {
+		return nil, err
+	}
+	d.attempt(ctx, hook, delivery, 1)
+	return delivery, nil
+}
+
+func (d *Dispatcher) Retry(id int) (*db.WebhookDelivery, error) {
+	delivery, err := d.database.GetWebhookDelivery(id)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Status == "delivered" {
+		return nil, fmt.Errorf("delivery %d was already delivered", id)
+	}
+	delivery.Status = "pending"
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = nil
+	if err := d.database.SaveWebhookAttempt(delivery); err != nil {
+		return nil, err
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+	return delivery, nil
+}
+
+func (d *Dispatcher) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	prune := time.NewTicker(24 * time.Hour)
+	defer prune.Stop()
+	d.deliverDue()
+	for {
+		select {
+		case <-ticker.C:
+		case <-d.wake:
+		case <-prune.C:
+			if n, err := d.database.PruneWebhookDeliveries(time.Now().Add(-deliveryRetention)); err != nil {
+				log.Printf("webhook delivery prune error: %v", err)
+			} else if n > 0 {
+				log.Printf("pruned %d old webhook deliveries", n)
+			}
+			continue
+		}
+		d.deliverDue()
+	}
+}
+
+func (d *Dispatcher) deliverDue() {
+	if d.database.Paused() {
+		return
+	}
+	for {
+		due, err := d.database.DueWebhookDeliveries(time.Now(), batchSize)
+		if err != nil {
+			log.Printf("webhook dispatcher: %v", err)
+			return
+		}
+		for i := range due {
+			delivery := &due[i]
+			hook, err := d.database.GetWebhook(delivery.WebhookID)
+			if err != nil {
+				delivery.Status = "failed"
+				delivery.Error = "webhook no longer exists"
+				delivery.NextAttemptAt = nil
+				_ = d.database.SaveWebhookAttempt(delivery)
+				continue
+			}
+			if !hook.Active {
+				delivery.Status = "failed"
+				delivery.Error = "webhook is disabled"
+				delivery.NextAttemptAt = nil
+				_ = d.database.SaveWebhookAttempt(delivery)
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), d.client.Timeout+5*time.Second)
+			d.attempt(ctx, hook, delivery, d.maxAttempts)
+			cancel()
+		}
+		if len(due) < batchSize {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, hook *db.Webhook, delivery *db.WebhookDelivery, maxAttempts int) {
+	delivery.Attempts++
+	status, body, err := d.post(ctx, hook, delivery)
+	delivery.ResponseStatus = status
+	delivery.ResponseBody = body
+	now := time.Now()
+	if err == nil && status >= 200 && status < 300 {
+		delivery.Status = "delivered"
+		delivery.Error = ""
+		delivery.NextAttemptAt = nil
+		delivery.DeliveredAt = &now
+	} else {
+		if err != nil {
+			delivery.Error = err.Error()
+		} else {
+			delivery.Error = fmt.Sprintf("unexpected status %d", status)
+		}
+		if delivery.Attempts >= maxAttempts {
+			delivery.Status = "failed"
+			delivery.NextAttemptAt = nil
+			log.Printf("webhook %d delivery %d (%s) failed after %d attempts: %s", hook.ID, delivery.ID, delivery.EventType, delivery.Attempts, delivery.Error)
+		} else {
+			next := now.Add(Backoff(delivery.Attempts))
+			delivery.Status = "pending"
+			delivery.NextAttemptAt = &next
+		}
+	}
+	if err := d.database.SaveWebhookAttempt(delivery); err != nil {
+		log.Printf("failed to record webhook delivery %d: %v", delivery.ID, err)
+	}
+}
+
+func (d *Dispatcher) post(ctx context.Context, hook *db.Webhook, delivery *db.WebhookDelivery) (int, string, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "exunreg25-webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.EventID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, "sha256="+Sign(hook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	return resp.StatusCode, string(respBody), nil
+}
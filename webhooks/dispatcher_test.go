@@ -0,0 +1,217 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"exunreg25/db"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{6, 32 * time.Minute},
+		{7, time.Hour},
+		{40, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte(`1760000000.{"id":"evt_1"}`))
+	want := hex.EncodeToString(mac.Sum(nil))
+	if got := Sign("whsec_test", 1760000000, []byte(`{"id":"evt_1"}`)); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+	if Sign("whsec_test", 1760000001, []byte(`{"id":"evt_1"}`)) == want {
+		t.Error("the timestamp is not covered by the signature")
+	}
+}
+
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	status := rc.status
+	rc.mu.Unlock()
+	w.WriteHeader(status)
+}
+
+func newTestDispatcher(t *testing.T, maxAttempts int) (*Dispatcher, *db.Database) {
+	t.Helper()
+	database, err := db.NewConnection(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := database.InitTables(); err != nil {
+		t.Fatal(err)
+	}
+	return NewDispatcher(database, maxAttempts, 5*time.Second), database
+}
+
+func TestEmitDeliversToSubscribers(t *testing.T) {
+	d, database := newTestDispatcher(t, 3)
+	rc := &receiver{status: http.StatusOK}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	hooks := []*db.Webhook{
+		{URL: srv.URL + "/registrations", Secret: "whsec_a", Events: []string{EventRegistrationSubmitted}, Active: true},
+		{URL: srv.URL + "/all", Secret: "whsec_b", Events: []string{"*"}, Active: true},
+		{URL: srv.URL + "/users", Secret: "whsec_c", Events: []string{EventUserCreated}, Active: true},
+		{URL: srv.URL + "/disabled", Secret: "whsec_d", Events: []string{"*"}, Active: false},
+	}
+	for _, h := range hooks {
+		if err := database.CreateWebhook(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Emit(EventRegistrationSubmitted, map[string]string{"event_id": "quiz"}); err != nil {
+		t.Fatal(err)
+	}
+	d.deliverDue()
+
+	secrets := map[string]string{"/registrations": "whsec_a", "/all": "whsec_b"}
+	if len(rc.requests) != len(secrets) {
+		t.Fatalf("receiver got %d requests, want %d", len(rc.requests), len(secrets))
+	}
+	for i, r := range rc.requests {
+		secret, ok := secrets[r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected delivery to %s", r.URL.Path)
+			continue
+		}
+		ts, _ := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+		if got := r.Header.Get(SignatureHeader); got != "sha256="+Sign(secret, ts, rc.bodies[i]) {
+			t.Errorf("%s: bad signature %s", r.URL.Path, got)
+		}
+		var p Payload
+		if err := json.Unmarshal(rc.bodies[i], &p); err != nil || p.Type != EventRegistrationSubmitted || !strings.HasPrefix(p.ID, "evt_") {
+			t.Errorf("%s: payload %s", r.URL.Path, rc.bodies[i])
+		}
+		if r.Header.Get(EventHeader) != EventRegistrationSubmitted || r.Header.Get(DeliveryHeader) != p.ID {
+			t.Errorf("%s: headers %v", r.URL.Path, r.Header)
+		}
+	}
+	if rc.requests[0].Header.Get(DeliveryHeader) != rc.requests[1].Header.Get(DeliveryHeader) {
+		t.Error("one event was delivered under two IDs")
+	}
+
+	deliveries, err := database.ListWebhookDeliveries(0, "delivered", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 2 {
+		t.Errorf("%d deliveries logged as delivered, want 2", len(deliveries))
+	}
+}
+
+func TestDeliveryRetriesThenFails(t *testing.T) {
+	d, database := newTestDispatcher(t, 2)
+	rc := &receiver{status: http.StatusBadGateway}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	hook := &db.Webhook{URL: srv.URL, Secret: "whsec_a", Events: []string{"*"}, Active: true}
+	if err := database.CreateWebhook(hook); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Emit(EventUserCreated, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	d.deliverDue()
+	list, _ := database.ListWebhookDeliveries(hook.ID, "", 10)
+	if len(list) != 1 {
+		t.Fatalf("deliveries = %+v", list)
+	}
+	first := list[0]
+	if first.Status != "pending" || first.Attempts != 1 || first.NextAttemptAt == nil || first.ResponseStatus != http.StatusBadGateway {
+		t.Fatalf("after one failure: %+v", first)
+	}
+	if wait := time.Until(*first.NextAttemptAt); wait < 50*time.Second || wait > Backoff(1) {
+		t.Errorf("next attempt in %s, want about %s", wait, Backoff(1))
+	}
+
+	d.deliverDue()
+	if n := len(rc.requests); n != 1 {
+		t.Fatalf("a delivery was retried before its backoff: %d requests", n)
+	}
+
+	if _, err := database.Exec(`UPDATE webhook_deliveries SET next_attempt_at = ?`, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	d.deliverDue()
+	final, err := database.GetWebhookDelivery(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if final.Status != "failed" || final.Attempts != 2 || final.NextAttemptAt != nil {
+		t.Errorf("after the last attempt: %+v", final)
+	}
+
+	rc.mu.Lock()
+	rc.status = http.StatusNoContent
+	rc.mu.Unlock()
+	test, err := d.SendTest(context.Background(), hook, "admin@exun.co")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if test.Status != "delivered" || test.EventType != EventTest {
+		t.Errorf("test delivery = %+v", test)
+	}
+}
+
+func TestDeliverySkippedWhilePaused(t *testing.T) {
+	d, database := newTestDispatcher(t, 3)
+	rc := &receiver{status: http.StatusOK}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+	if err := database.CreateWebhook(&db.Webhook{URL: srv.URL, Secret: "whsec_a", Events: []string{"*"}, Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Emit(EventUserCreated, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	database.Pause()
+	d.deliverDue()
+	if n := len(rc.requests); n != 0 {
+		t.Fatalf("%d deliveries sent while paused", n)
+	}
+	database.Resume()
+	d.deliverDue()
+	if n := len(rc.requests); n != 1 {
+		t.Errorf("%d deliveries sent after resuming, want 1", n)
+	}
+}